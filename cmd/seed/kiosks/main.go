@@ -26,7 +26,7 @@ func main() {
 	}
 
 	// Run migrations
-	if err := database.AutoMigrate(db); err != nil {
+	if err := database.AutoMigrate(db, []byte(cfg.Audit.ChainKey)); err != nil {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
 
@@ -14,7 +14,10 @@ import (
 	"github.com/attendance-system/internal/config"
 	"github.com/attendance-system/internal/database"
 	"github.com/attendance-system/internal/handlers"
+	"github.com/attendance-system/internal/mailer"
 	"github.com/attendance-system/internal/middleware"
+	"github.com/attendance-system/internal/models"
+	"github.com/attendance-system/internal/oidc"
 	"github.com/attendance-system/internal/repository"
 	"github.com/attendance-system/internal/utils"
 	"github.com/gin-contrib/cors"
@@ -41,7 +44,7 @@ func main() {
 	}
 
 	// Run auto-migrations
-	if err := database.AutoMigrate(db); err != nil {
+	if err := database.AutoMigrate(db, []byte(cfg.Audit.ChainKey)); err != nil {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
 
@@ -67,34 +70,75 @@ func main() {
 		cfg.JWT.RefreshExpiry,
 	)
 
+	// Revoked access tokens are refused before they expire
+	denylist := middleware.NewTokenDenylist(rdb, cfg.JWT.AccessExpiry)
+	loginGuard := middleware.NewLoginGuard(rdb, cfg.Login)
+
 	// Initialize repositories
 	userRepo := repository.NewUserRepository(db)
 	attendanceRepo := repository.NewAttendanceRepository(db)
 	refreshTokenRepo := repository.NewRefreshTokenRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	auditRepo := repository.NewAuditLogRepository(db, []byte(cfg.Audit.ChainKey))
 	officeRepo := repository.NewOfficeRepository(db)
 	employeeRepo := repository.NewEmployeeRepository(db)
+	roleRepo := repository.NewRoleRepository(db)
+	officeScopeRepo := repository.NewOfficeScopeRepository(db)
+	settingsRepo := repository.NewSettingsRepository(db)
+	twoFactorRepo := repository.NewTwoFactorRepository(db)
+	apiKeyRepo := repository.NewAPIKeyRepository(db)
+	kioskRepo := repository.NewKioskRepository(db)
+
+	// Role-based access control
+	authorizer := middleware.NewAuthorizer(roleRepo)
+	can := authorizer.Require
 
 
-	// Initialize WebSocket hub
-	wsHub := handlers.NewWebSocketHub()
+	// Initialize WebSocket hub; clients connect with an access token or a kiosk credential
+	wsAuth := handlers.NewWebSocketAuthenticator(
+		jwtManager,
+		denylist,
+		authorizer,
+		officeScopeRepo,
+		employeeRepo,
+		kioskRepo,
+		settingsRepo,
+		cfg.WS.AllowedOrigins,
+	)
+	wsHub := handlers.NewWebSocketHub(wsAuth, rdb)
 	go wsHub.Run()
 
 	// Initialize handlers
+	twoFactorHandler := handlers.NewTwoFactorHandler(twoFactorRepo, userRepo, settingsRepo, auditRepo, authorizer)
 	authHandler := handlers.NewAuthHandler(
 		userRepo,
 		refreshTokenRepo,
+		sessionRepo,
+		denylist,
+		loginGuard,
+		auditRepo,
+		twoFactorHandler,
 		jwtManager,
 		cfg.Office.DefaultLat,
 		cfg.Office.DefaultLong,
 	)
+	ssoHandler := handlers.NewSSOHandler(oidc.NewClient(cfg.OIDC, rdb), userRepo, authHandler, cfg.OIDC)
 	userHandler := handlers.NewUserHandler(
 		userRepo,
 		employeeRepo,
 		officeRepo,
+		officeScopeRepo,
+		roleRepo,
+		sessionRepo,
+		denylist,
+		loginGuard,
+		auditRepo,
+		authorizer,
 		wsHub,
 		cfg.Office.DefaultLat,
 		cfg.Office.DefaultLong,
 	)
+	sessionHandler := handlers.NewSessionHandler(sessionRepo, denylist)
 	attendanceHandler := handlers.NewAttendanceHandler(attendanceRepo, userRepo, wsHub)
 
 	// Face verification
@@ -102,7 +146,6 @@ func main() {
 	faceVerificationHandler := handlers.NewFaceVerificationHandler(userRepo, facePhotoRepo)
 
 	// Settings and transfer requests
-	settingsRepo := repository.NewSettingsRepository(db)
 	settingsHandler := handlers.NewSettingsHandler(settingsRepo)
 	transferRepo := repository.NewTransferRequestRepository(db)
 	transferHandler := handlers.NewTransferRequestHandler(transferRepo, userRepo, wsHub)
@@ -110,15 +153,73 @@ func main() {
 	// Office Management
 	officeHandler := handlers.NewOfficeHandler(officeRepo)
 	
+	// Outgoing mail
+	mail, err := mailer.New(cfg.Mail)
+	if err != nil {
+		log.Fatalf("Failed to set up mail delivery: %v", err)
+	}
+
+	// Forgot password
+	passwordResetRepo := repository.NewPasswordResetRepository(db)
+	passwordResetHandler := handlers.NewPasswordResetHandler(passwordResetRepo, userRepo, sessionRepo, denylist, loginGuard, mail, cfg.Reset.TTL, cfg.App.PublicURL)
+
+	// Invitations let new employees set their own password
+	invitationRepo := repository.NewInvitationRepository(db)
+	invitationIssuer := handlers.NewInvitationIssuer(invitationRepo, cfg.Invite.TTL, cfg.App.PublicURL)
+	invitationHandler := handlers.NewInvitationHandler(invitationRepo, userRepo, invitationIssuer, authorizer)
+
 	// Employee Management
-	employeeHandler := handlers.NewEmployeeHandler(employeeRepo, userRepo)
+	employeeHandler := handlers.NewEmployeeHandler(employeeRepo, userRepo, invitationIssuer)
 
 	// Kiosk Attendance
-	kioskRepo := repository.NewKioskRepository(db)
-	kioskHandler := handlers.NewKioskHandler(userRepo, attendanceRepo, settingsRepo, kioskRepo, facePhotoRepo, wsHub)
+	badgeRepo := repository.NewBadgeRepository(db)
+	qrSigner := utils.NewQRSigner(cfg.QR.Secret, cfg.QR.RotationPeriod)
+	credentialRepo := repository.NewCredentialRepository(db)
+	ticketSigner := utils.NewTicketSigner(cfg.QR.Secret, 2*time.Minute)
+	// Card and PIN identification is limited to 30 a minute per kiosk and per address
+	identifyLimit := middleware.NewRateLimiter(rdb, "kiosk_identify", 30)
+	kioskHandler := handlers.NewKioskHandler(userRepo, attendanceRepo, settingsRepo, kioskRepo, facePhotoRepo, badgeRepo, credentialRepo, qrSigner, ticketSigner, identifyLimit, wsHub)
+	credentialHandler := handlers.NewCredentialHandler(credentialRepo, userRepo)
+	badgeHandler := handlers.NewBadgeHandler(badgeRepo, userRepo, settingsRepo, qrSigner)
+
+	// Visitors
+	visitorRepo := repository.NewVisitorRepository(db)
+	visitorHandler := handlers.NewVisitorHandler(visitorRepo, userRepo, kioskRepo, settingsRepo, qrSigner, wsHub)
+
+	// Announcements
+	announcementRepo := repository.NewAnnouncementRepository(db)
+	announcementHandler := handlers.NewAnnouncementHandler(announcementRepo, userRepo, employeeRepo, kioskRepo, wsHub)
+
+	// Roles
+	roleHandler := handlers.NewRoleHandler(roleRepo, authorizer)
+	officeScopeHandler := handlers.NewOfficeScopeHandler(officeScopeRepo, userRepo, officeRepo, authorizer)
+
+	// Manager team view
+	managerHandler := handlers.NewManagerHandler(employeeRepo, attendanceRepo, transferRepo)
+
+	// API keys for scripts calling the admin API
+	apiKeys := middleware.NewAPIKeyAuthenticator(apiKeyRepo, rdb)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyRepo, auditRepo, cfg.APIKeys.DefaultRateLimit, cfg.APIKeys.MaxRateLimit)
+
+	// Audit log
+	auditLogHandler := handlers.NewAuditLogHandler(auditRepo)
+
+	// SCIM provisioning
+	scimRepo := repository.NewSCIMRepository(db)
+	scimHandler := handlers.NewSCIMHandler(
+		scimRepo,
+		userRepo,
+		roleRepo,
+		sessionRepo,
+		denylist,
+		wsHub,
+		cfg.Office.DefaultLat,
+		cfg.Office.DefaultLong,
+	)
 
-	// Setup Gin router
-	router := gin.Default()
+	// Setup Gin router; the logger redacts the tokens WebSocket clients send in the query
+	router := gin.New()
+	router.Use(middleware.RequestLogger(), gin.Recovery())
 
 	// CORS configuration
 	router.Use(cors.New(cors.Config{
@@ -162,12 +263,23 @@ func main() {
 			auth.POST("/login", authHandler.Login)
 			auth.POST("/refresh", authHandler.Refresh)
 			auth.POST("/logout", authHandler.Logout)
+			auth.POST("/2fa/verify", authHandler.VerifyTwoFactor)
+			auth.POST("/2fa/setup", authHandler.SetupTwoFactor)
+			auth.POST("/2fa/setup/confirm", authHandler.ConfirmTwoFactorSetup)
+			auth.GET("/sso", ssoHandler.GetSSOStatus)
+			auth.GET("/sso/authorize", ssoHandler.BeginSSO)
+			auth.POST("/sso/callback", ssoHandler.FinishSSO)
+			auth.POST("/password/forgot", passwordResetHandler.ForgotPassword)
+			auth.POST("/password/reset", passwordResetHandler.ResetPassword)
+			auth.GET("/invitations/:token", invitationHandler.GetInvitation)
+			auth.POST("/invitations/accept", invitationHandler.AcceptInvitation)
 		}
 
 		// Kiosk routes (public, no JWT required)
 		kiosk := api.Group("/kiosk")
 		{
 			kiosk.POST("/scan", kioskHandler.ScanQR)
+			kiosk.POST("/identify", kioskHandler.Identify)
 			kiosk.POST("/verify-face", kioskHandler.VerifyFace)
 			kiosk.POST("/verify-face-image", kioskHandler.VerifyFaceImage)
 			kiosk.POST("/check-in", kioskHandler.KioskCheckIn)
@@ -177,26 +289,44 @@ func main() {
 			kiosk.GET("/settings", kioskHandler.GetKioskSettings)
 			kiosk.GET("/available", kioskHandler.GetAvailableKiosks)
 			kiosk.POST("/pair", kioskHandler.PairKiosk)
+			kiosk.POST("/register-key", kioskHandler.RegisterKioskKey)
+			kiosk.POST("/heartbeat", kioskHandler.Heartbeat)
 			kiosk.GET("/employees-for-registration", kioskHandler.GetEmployeesForRegistration)
 			kiosk.POST("/register-face", kioskHandler.RegisterFace)
 			kiosk.GET("/company-settings", kioskHandler.GetCompanySettings)
+			kiosk.GET("/announcements", announcementHandler.GetKioskAnnouncements)
 			// Offline mode support
 			kiosk.GET("/sync-data", kioskHandler.SyncData)
 			kiosk.POST("/offline-sync", kioskHandler.OfflineSync)
+			// Visitor mode
+			kiosk.GET("/visitors/hosts", visitorHandler.GetVisitorHosts)
+			kiosk.POST("/visitors/check-in", visitorHandler.KioskVisitorCheckIn)
+			kiosk.POST("/visitors/check-out", visitorHandler.KioskVisitorCheckOut)
 		}
 
 		// Protected routes
 		protected := api.Group("")
-		protected.Use(middleware.AuthMiddleware(jwtManager))
+		protected.Use(middleware.AuthMiddleware(jwtManager, denylist, apiKeys))
 		{
 			// User routes
 			users := protected.Group("/users")
 			{
 				users.GET("/me", userHandler.GetProfile)
+				users.GET("/me/qr", badgeHandler.GetMyQR)
+				users.GET("/me/permissions", roleHandler.GetMyPermissions)
 				users.PUT("/face-embeddings", userHandler.UpdateFaceEmbeddings)
 				users.GET("/sync-face", userHandler.SyncFaceData)
 				users.PUT("/password", userHandler.ChangePassword)
+				users.GET("/sessions", sessionHandler.GetMySessions)
+				users.DELETE("/sessions", sessionHandler.RevokeOtherSessions)
+				users.DELETE("/sessions/:id", sessionHandler.RevokeMySession)
+				users.GET("/2fa", twoFactorHandler.GetMyTwoFactor)
+				users.POST("/2fa/setup", twoFactorHandler.SetupMyTwoFactor)
+				users.POST("/2fa/confirm", twoFactorHandler.ConfirmMyTwoFactor)
+				users.POST("/2fa/disable", twoFactorHandler.DisableMyTwoFactor)
+				users.POST("/2fa/recovery-codes", twoFactorHandler.RegenerateMyRecoveryCodes)
 				users.POST("/face-photos", faceVerificationHandler.UploadFacePhotos)
+				users.GET("/announcements", announcementHandler.GetMyAnnouncements)
 			}
 
 			// Attendance routes
@@ -213,58 +343,132 @@ func main() {
 			users.POST("/transfer-requests", transferHandler.CreateRequest)
 			users.GET("/transfer-requests", transferHandler.GetMyRequests)
 
-			// Admin/HR routes
+			// Visitor pre-registration (employee as host)
+			users.POST("/visitors", visitorHandler.CreateMyVisitor)
+			users.GET("/visitors", visitorHandler.GetMyVisitors)
+
+			// Manager self-service: the caller's direct and indirect reports
+			manager := protected.Group("/manager")
+			{
+				manager.GET("/team", managerHandler.GetTeam)
+				manager.GET("/team/presence", managerHandler.GetTeamPresence)
+				manager.GET("/team/attendance", managerHandler.GetTeamAttendance)
+				manager.GET("/team/late", managerHandler.GetTeamLate)
+				manager.GET("/team/absent", managerHandler.GetTeamAbsent)
+				manager.GET("/approvals", managerHandler.GetTeamApprovals)
+			}
+
+			// Admin routes, each guarded by the permission it needs and limited to the caller's offices.
+			// Every change made through them is recorded in the audit log.
 			admin := protected.Group("/admin")
-			admin.Use(middleware.HRMiddleware())
+			admin.Use(middleware.OfficeScope(officeScopeRepo), middleware.AuditTrail(auditRepo))
 			{
 				// Dashboard stats
-				admin.GET("/dashboard/stats", attendanceHandler.GetDashboardStats)
-
-				admin.GET("/users", userHandler.GetAllUsers)
-				admin.POST("/users", userHandler.CreateUser)
-				admin.PUT("/users/:id", userHandler.UpdateUser)
-				admin.POST("/users/:id/avatar", userHandler.UploadAvatar)
-				admin.DELETE("/users/:id", userHandler.DeleteUser)
-				admin.GET("/attendance/today", attendanceHandler.GetAllToday)
+				admin.GET("/dashboard/stats", can(models.PermDashboardRead), attendanceHandler.GetDashboardStats)
+
+				admin.GET("/users", can(models.PermUsersRead), userHandler.GetAllUsers)
+				admin.POST("/users", can(models.PermUsersWrite), userHandler.CreateUser)
+				admin.PUT("/users/:id", can(models.PermUsersWrite), userHandler.UpdateUser)
+				admin.POST("/users/:id/avatar", can(models.PermUsersWrite), userHandler.UploadAvatar)
+				admin.DELETE("/users/:id", can(models.PermUsersWrite), userHandler.DeleteUser)
+				admin.POST("/users/:id/logout-everywhere", can(models.PermUsersWrite), userHandler.LogoutEverywhere)
+				admin.POST("/users/:id/unlock", can(models.PermUsersWrite), userHandler.UnlockUser)
+				admin.POST("/users/:id/2fa/reset", can(models.PermUsersWrite), twoFactorHandler.ResetUserTwoFactor)
+				admin.GET("/users/:id/badges", can(models.PermCredentialsManage), badgeHandler.GetUserBadges)
+				admin.POST("/users/:id/badges", can(models.PermCredentialsManage), badgeHandler.IssueBadge)
+				admin.POST("/badges/:serial/revoke", can(models.PermCredentialsManage), badgeHandler.RevokeBadge)
+				admin.GET("/badges/print", can(models.PermCredentialsManage), badgeHandler.PrintBadges)
+				admin.GET("/users/:id/credentials", can(models.PermCredentialsManage), credentialHandler.GetUserCredentials)
+				admin.POST("/users/:id/credentials", can(models.PermCredentialsManage), credentialHandler.CreateCredential)
+				admin.POST("/credentials/:id/revoke", can(models.PermCredentialsManage), credentialHandler.RevokeCredential)
+				admin.GET("/attendance/today", can(models.PermAttendanceRead), attendanceHandler.GetAllToday)
 
 				// Face verification admin routes
-				admin.GET("/face-verifications", faceVerificationHandler.GetPendingVerifications)
-				admin.POST("/face-verifications/:id/approve", faceVerificationHandler.ApproveFaceVerification)
-				admin.POST("/face-verifications/:id/reject", faceVerificationHandler.RejectFaceVerification)
+				admin.GET("/face-verifications", can(models.PermFaceVerify), faceVerificationHandler.GetPendingVerifications)
+				admin.POST("/face-verifications/:id/approve", can(models.PermFaceVerify), faceVerificationHandler.ApproveFaceVerification)
+				admin.POST("/face-verifications/:id/reject", can(models.PermFaceVerify), faceVerificationHandler.RejectFaceVerification)
 
 				// Settings routes
-				admin.GET("/settings", settingsHandler.GetAllSettings)
-				admin.GET("/settings/:key", settingsHandler.GetSetting)
-				admin.PUT("/settings/:key", settingsHandler.UpdateSetting)
-				admin.POST("/settings/logo", settingsHandler.UploadLogo)
+				admin.GET("/settings", can(models.PermSettingsRead), settingsHandler.GetAllSettings)
+				admin.GET("/settings/:key", can(models.PermSettingsRead), settingsHandler.GetSetting)
+				admin.PUT("/settings/:key", can(models.PermSettingsWrite), settingsHandler.UpdateSetting)
+				admin.POST("/settings/logo", can(models.PermSettingsWrite), settingsHandler.UploadLogo)
 
 				// Transfer request admin routes
-				admin.GET("/transfer-requests", transferHandler.GetPendingRequests)
-				admin.POST("/transfer-requests/:id/approve", transferHandler.ApproveRequest)
-				admin.POST("/transfer-requests/:id/reject", transferHandler.RejectRequest)
+				admin.GET("/transfer-requests", can(models.PermTransfersManage), transferHandler.GetPendingRequests)
+				admin.POST("/transfer-requests/:id/approve", can(models.PermTransfersManage), transferHandler.ApproveRequest)
+				admin.POST("/transfer-requests/:id/reject", can(models.PermTransfersManage), transferHandler.RejectRequest)
 
 				// Office management routes
-				admin.GET("/offices", officeHandler.GetAllOffices) // Can be public if needed
-				admin.POST("/offices", officeHandler.CreateOffice)
-				admin.PUT("/offices/:id", officeHandler.UpdateOffice)
-				admin.DELETE("/offices/:id", officeHandler.DeleteOffice)
+				admin.GET("/offices", can(models.PermOfficesRead), officeHandler.GetAllOffices) // Can be public if needed
+				admin.POST("/offices", can(models.PermOfficesWrite), officeHandler.CreateOffice)
+				admin.PUT("/offices/:id", can(models.PermOfficesWrite), officeHandler.UpdateOffice)
+				admin.DELETE("/offices/:id", can(models.PermOfficesWrite), officeHandler.DeleteOffice)
 
 				// Kiosk routes
-				admin.GET("/kiosks", kioskHandler.GetAllKiosks)
-				admin.POST("/kiosks", kioskHandler.CreateKiosk)
-				admin.PUT("/kiosks/:id", kioskHandler.UpdateKiosk)
-				admin.DELETE("/kiosks/:id", kioskHandler.DeleteKiosk)
-				admin.POST("/kiosks/:id/unpair", kioskHandler.UnpairKiosk)
+				admin.GET("/kiosks", can(models.PermKiosksRead), kioskHandler.GetAllKiosks)
+				admin.GET("/kiosks/clock-skew", can(models.PermKiosksRead), kioskHandler.GetKioskClockSkew)
+				admin.POST("/kiosks", can(models.PermKiosksWrite), kioskHandler.CreateKiosk)
+				admin.PUT("/kiosks/:id", can(models.PermKiosksWrite), kioskHandler.UpdateKiosk)
+				admin.DELETE("/kiosks/:id", can(models.PermKiosksWrite), kioskHandler.DeleteKiosk)
+				admin.POST("/kiosks/:id/unpair", can(models.PermKiosksWrite), kioskHandler.UnpairKiosk)
+				admin.POST("/kiosks/:id/pairing-token", can(models.PermKiosksWrite), kioskHandler.IssuePairingToken)
+				admin.GET("/offline-batches", can(models.PermAttendanceRead), kioskHandler.GetOfflineBatches)
+				admin.POST("/offline-batches/:id/approve", can(models.PermAttendanceCorrect), kioskHandler.ApproveOfflineBatch)
+				admin.POST("/offline-batches/:id/reject", can(models.PermAttendanceCorrect), kioskHandler.RejectOfflineBatch)
+
+				// Visitor routes
+				admin.GET("/visitors", can(models.PermVisitorsRead), visitorHandler.GetVisitors)
+				admin.POST("/visitors", can(models.PermVisitorsWrite), visitorHandler.CreateVisitor)
+				admin.GET("/visitors/report", can(models.PermVisitorsRead), visitorHandler.GetVisitorLog)
+				admin.GET("/visitors/:id/pass", can(models.PermVisitorsRead), visitorHandler.PrintVisitorPass)
+				admin.POST("/visitors/:id/cancel", can(models.PermVisitorsWrite), visitorHandler.CancelVisitor)
+				admin.POST("/visitors/:id/check-out", can(models.PermVisitorsWrite), visitorHandler.CheckOutVisitor)
+
+				// Announcement routes
+				admin.GET("/announcements", can(models.PermAnnouncementsManage), announcementHandler.GetAnnouncements)
+				admin.POST("/announcements", can(models.PermAnnouncementsManage), announcementHandler.CreateAnnouncement)
+				admin.PUT("/announcements/:id", can(models.PermAnnouncementsManage), announcementHandler.UpdateAnnouncement)
+				admin.POST("/announcements/:id/publish", can(models.PermAnnouncementsManage), announcementHandler.PublishAnnouncement)
+				admin.POST("/announcements/:id/unpublish", can(models.PermAnnouncementsManage), announcementHandler.UnpublishAnnouncement)
+				admin.DELETE("/announcements/:id", can(models.PermAnnouncementsManage), announcementHandler.DeleteAnnouncement)
+
+				// Role management
+				admin.GET("/permissions", can(models.PermRolesManage), roleHandler.GetPermissions)
+				admin.GET("/roles", can(models.PermRolesManage), roleHandler.GetRoles)
+				admin.POST("/roles", can(models.PermRolesManage), roleHandler.CreateRole)
+				admin.PUT("/roles/:id", can(models.PermRolesManage), roleHandler.UpdateRole)
+				admin.DELETE("/roles/:id", can(models.PermRolesManage), roleHandler.DeleteRole)
+
+				// Invitations
+				admin.GET("/invitations", can(models.PermUsersRead), invitationHandler.GetInvitations)
+				admin.POST("/invitations/resend", can(models.PermUsersWrite), invitationHandler.ResendInvitations)
+				admin.DELETE("/invitations/:id", can(models.PermUsersWrite), invitationHandler.RevokeInvitation)
+				admin.POST("/users/:id/invitation", can(models.PermUsersWrite), invitationHandler.InviteUser)
+
+				// Office scopes for branch admins and HR
+				admin.GET("/users/:id/office-scopes", can(models.PermScopesManage), officeScopeHandler.GetUserOfficeScopes)
+				admin.PUT("/users/:id/office-scopes", can(models.PermScopesManage), officeScopeHandler.UpdateUserOfficeScopes)
+
+				// API keys for integrations
+				admin.GET("/api-keys", can(models.PermAPIKeysManage), apiKeyHandler.GetAPIKeys)
+				admin.POST("/api-keys", can(models.PermAPIKeysManage), apiKeyHandler.IssueAPIKey)
+				admin.DELETE("/api-keys/:id", can(models.PermAPIKeysManage), apiKeyHandler.RevokeAPIKey)
+
+				// Audit log
+				admin.GET("/audit-logs", can(models.PermAuditRead), auditLogHandler.GetAuditLogs)
+				admin.GET("/audit-logs/export", can(models.PermAuditRead), auditLogHandler.ExportAuditLogs)
+				admin.GET("/audit-logs/verify", can(models.PermAuditRead), auditLogHandler.VerifyAuditLog)
 
 				// Employee routes
-				admin.GET("/employees", employeeHandler.GetAllEmployees)
-				admin.POST("/employees", employeeHandler.CreateEmployee)
-				admin.GET("/employees/positions", employeeHandler.GetPositions) // New route
-				admin.GET("/employees/:id", employeeHandler.GetEmployee)
-				admin.PUT("/employees/:id", employeeHandler.UpdateEmployee)
-				admin.DELETE("/employees/:id", employeeHandler.DeleteEmployee)
-				admin.POST("/employees/:id/photo", employeeHandler.UploadPhoto)
-				admin.POST("/employees/import", employeeHandler.ImportEmployees)
+				admin.GET("/employees", can(models.PermEmployeesRead), employeeHandler.GetAllEmployees)
+				admin.POST("/employees", can(models.PermEmployeesWrite), employeeHandler.CreateEmployee)
+				admin.GET("/employees/positions", can(models.PermEmployeesRead), employeeHandler.GetPositions) // New route
+				admin.GET("/employees/:id", can(models.PermEmployeesRead), employeeHandler.GetEmployee)
+				admin.PUT("/employees/:id", can(models.PermEmployeesWrite), employeeHandler.UpdateEmployee)
+				admin.DELETE("/employees/:id", can(models.PermEmployeesWrite), employeeHandler.DeleteEmployee)
+				admin.POST("/employees/:id/photo", can(models.PermEmployeesWrite), employeeHandler.UploadPhoto)
+				admin.POST("/employees/import", can(models.PermEmployeesWrite), employeeHandler.ImportEmployees)
 			}
 
 			// Public/Employee Office routes
@@ -272,6 +476,26 @@ func main() {
 		}
 	}
 
+	// SCIM 2.0 routes for the HR system (own bearer token, no JWT)
+	scimRoutes := router.Group("/scim/v2")
+	scimRoutes.Use(middleware.SCIMAuthMiddleware(cfg.SCIM.Token), middleware.AuditTrail(auditRepo))
+	{
+		scimRoutes.GET("/ServiceProviderConfig", scimHandler.GetServiceProviderConfig)
+		scimRoutes.GET("/ResourceTypes", scimHandler.GetResourceTypes)
+		scimRoutes.GET("/Users", scimHandler.GetUsers)
+		scimRoutes.POST("/Users", scimHandler.CreateUser)
+		scimRoutes.GET("/Users/:id", scimHandler.GetUser)
+		scimRoutes.PUT("/Users/:id", scimHandler.ReplaceUser)
+		scimRoutes.PATCH("/Users/:id", scimHandler.PatchUser)
+		scimRoutes.DELETE("/Users/:id", scimHandler.DeleteUser)
+		scimRoutes.GET("/Groups", scimHandler.GetGroups)
+		scimRoutes.POST("/Groups", scimHandler.CreateGroup)
+		scimRoutes.GET("/Groups/:id", scimHandler.GetGroup)
+		scimRoutes.PUT("/Groups/:id", scimHandler.ReplaceGroup)
+		scimRoutes.PATCH("/Groups/:id", scimHandler.PatchGroup)
+		scimRoutes.DELETE("/Groups/:id", scimHandler.DeleteGroup)
+	}
+
 	// WebSocket route
 	router.GET("/ws/dashboard", wsHub.HandleWebSocket)
 
@@ -1,8 +1,10 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -15,11 +17,22 @@ type Config struct {
 	Redis    RedisConfig
 	JWT      JWTConfig
 	Office   OfficeConfig
+	QR       QRConfig
+	Login    LoginConfig
+	Invite   InviteConfig
+	Reset    ResetConfig
+	Mail     MailConfig
+	OIDC     OIDCConfig
+	SCIM     SCIMConfig
+	APIKeys  APIKeyConfig
+	WS       WebSocketConfig
+	Audit    AuditConfig
 }
 
 type AppConfig struct {
-	Env  string
-	Port string
+	Env       string
+	Port      string
+	PublicURL string // Base URL of the web app, used in links sent to users
 }
 
 type DatabaseConfig struct {
@@ -44,6 +57,65 @@ type JWTConfig struct {
 	RefreshExpiry time.Duration
 }
 
+type QRConfig struct {
+	Secret         string        // HMAC key for rotating QR codes and printed badges
+	RotationPeriod time.Duration // Lifetime of one rotating QR code
+}
+
+type LoginConfig struct {
+	MaxFailures     int           // Failed logins in a row before an account is locked
+	FailureWindow   time.Duration // How long failed logins are remembered
+	LockoutDuration time.Duration // How long a locked account stays locked
+	IPLimit         int           // Login attempts allowed per IP address per minute
+}
+
+type InviteConfig struct {
+	TTL time.Duration // How long an invitation link can be used
+}
+
+type ResetConfig struct {
+	TTL time.Duration // How long a password reset link can be used
+}
+
+type MailConfig struct {
+	Driver   string // "smtp", "file" or "log"
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	Dir      string // Where the file driver writes .eml files
+}
+
+type OIDCConfig struct {
+	IssuerURL       string // Empty disables single sign-on
+	ClientID        string
+	ClientSecret    string
+	RedirectURL     string   // Page of the web app the identity provider sends users back to
+	Scopes          []string // Requested scopes; "openid" is always included
+	EmployeeIDClaim string   // Claim matched against users.employee_id when email does not match
+	TrustEmail      bool     // Match by email even when the provider does not assert email_verified
+	JITProvisioning bool     // Create unknown users on their first SSO login
+	JITRole         string   // Role given to users created on first login
+}
+
+type SCIMConfig struct {
+	Token string // Bearer token of the HR system; empty turns the SCIM API off
+}
+
+type APIKeyConfig struct {
+	DefaultRateLimit int // Requests per minute for keys issued without their own limit
+	MaxRateLimit     int // Highest per-key limit an admin can set
+}
+
+type WebSocketConfig struct {
+	AllowedOrigins []string // Browser origins allowed to open WebSockets besides the API's own host
+}
+
+type AuditConfig struct {
+	ChainKey string // HMAC key of the audit log hash chain, kept out of the database
+}
+
 type OfficeConfig struct {
 	DefaultLat    float64
 	DefaultLong   float64
@@ -58,14 +130,37 @@ func Load() (*Config, error) {
 	accessExpiry, _ := time.ParseDuration(getEnv("JWT_ACCESS_EXPIRY", "15m"))
 	refreshExpiry, _ := time.ParseDuration(getEnv("JWT_REFRESH_EXPIRY", "168h")) // 7 days
 
+	qrRotation, _ := time.ParseDuration(getEnv("QR_ROTATION_PERIOD", "30s"))
+	jwtSecret := getEnv("JWT_SECRET", "default-secret-change-in-production")
+
 	defaultLat, _ := strconv.ParseFloat(getEnv("DEFAULT_OFFICE_LAT", "-6.200000"), 64)
 	defaultLong, _ := strconv.ParseFloat(getEnv("DEFAULT_OFFICE_LONG", "106.816666"), 64)
 	defaultRadius, _ := strconv.Atoi(getEnv("DEFAULT_ALLOWED_RADIUS", "50"))
 
-	return &Config{
+	loginMaxFailures, _ := strconv.Atoi(getEnv("LOGIN_MAX_FAILURES", "5"))
+	loginFailureWindow, _ := time.ParseDuration(getEnv("LOGIN_FAILURE_WINDOW", "15m"))
+	loginLockout, _ := time.ParseDuration(getEnv("LOGIN_LOCKOUT_DURATION", "15m"))
+	loginIPLimit, _ := strconv.Atoi(getEnv("LOGIN_IP_LIMIT", "20"))
+
+	inviteTTL, _ := time.ParseDuration(getEnv("INVITE_TTL", "72h"))
+	resetTTL, _ := time.ParseDuration(getEnv("PASSWORD_RESET_TTL", "1h"))
+
+	publicURL := strings.TrimRight(getEnv("APP_PUBLIC_URL", "http://localhost:3000"), "/")
+	oidcJIT, _ := strconv.ParseBool(getEnv("OIDC_JIT_PROVISIONING", "false"))
+	oidcTrustEmail, _ := strconv.ParseBool(getEnv("OIDC_TRUST_EMAIL", "false"))
+
+	apiKeyRateLimit, _ := strconv.Atoi(getEnv("API_KEY_RATE_LIMIT", "60"))
+	apiKeyMaxRateLimit, _ := strconv.Atoi(getEnv("API_KEY_MAX_RATE_LIMIT", "600"))
+
+	wsOrigins := strings.FieldsFunc(getEnv("WS_ALLOWED_ORIGINS", publicURL), func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+
+	cfg := &Config{
 		App: AppConfig{
-			Env:  getEnv("APP_ENV", "development"),
-			Port: getEnv("PORT", "8080"),
+			Env:       getEnv("APP_ENV", "development"),
+			Port:      getEnv("PORT", "8080"),
+			PublicURL: publicURL,
 		},
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
@@ -82,7 +177,7 @@ func Load() (*Config, error) {
 			DB:       0,
 		},
 		JWT: JWTConfig{
-			Secret:        getEnv("JWT_SECRET", "default-secret-change-in-production"),
+			Secret:        jwtSecret,
 			AccessExpiry:  accessExpiry,
 			RefreshExpiry: refreshExpiry,
 		},
@@ -91,7 +186,63 @@ func Load() (*Config, error) {
 			DefaultLong:   defaultLong,
 			DefaultRadius: defaultRadius,
 		},
-	}, nil
+		QR: QRConfig{
+			Secret:         getEnv("QR_SECRET", jwtSecret),
+			RotationPeriod: qrRotation,
+		},
+		Login: LoginConfig{
+			MaxFailures:     loginMaxFailures,
+			FailureWindow:   loginFailureWindow,
+			LockoutDuration: loginLockout,
+			IPLimit:         loginIPLimit,
+		},
+		Invite: InviteConfig{
+			TTL: inviteTTL,
+		},
+		Reset: ResetConfig{
+			TTL: resetTTL,
+		},
+		Mail: MailConfig{
+			Driver:   getEnv("MAIL_DRIVER", "log"),
+			Host:     getEnv("SMTP_HOST", "localhost"),
+			Port:     getEnv("SMTP_PORT", "1025"),
+			Username: getEnv("SMTP_USERNAME", ""),
+			Password: getEnv("SMTP_PASSWORD", ""),
+			From:     getEnv("MAIL_FROM", "Attendance System <no-reply@localhost>"),
+			Dir:      getEnv("MAIL_DIR", "./mail"),
+		},
+		OIDC: OIDCConfig{
+			IssuerURL:       getEnv("OIDC_ISSUER_URL", ""),
+			ClientID:        getEnv("OIDC_CLIENT_ID", ""),
+			ClientSecret:    getEnv("OIDC_CLIENT_SECRET", ""),
+			RedirectURL:     getEnv("OIDC_REDIRECT_URL", publicURL+"/sso/callback"),
+			Scopes:          strings.Fields(getEnv("OIDC_SCOPES", "openid email profile")),
+			EmployeeIDClaim: getEnv("OIDC_EMPLOYEE_ID_CLAIM", "employee_id"),
+			TrustEmail:      oidcTrustEmail,
+			JITProvisioning: oidcJIT,
+			JITRole:         getEnv("OIDC_JIT_ROLE", "employee"),
+		},
+		SCIM: SCIMConfig{
+			Token: getEnv("SCIM_TOKEN", ""),
+		},
+		APIKeys: APIKeyConfig{
+			DefaultRateLimit: apiKeyRateLimit,
+			MaxRateLimit:     apiKeyMaxRateLimit,
+		},
+		WS: WebSocketConfig{
+			AllowedOrigins: wsOrigins,
+		},
+		Audit: AuditConfig{
+			ChainKey: getEnv("AUDIT_CHAIN_KEY", jwtSecret),
+		},
+	}
+
+	// The log driver writes invitation and reset links to the server log
+	if cfg.App.Env == "production" && (cfg.Mail.Driver == "log" || cfg.Mail.Driver == "") {
+		return nil, fmt.Errorf("MAIL_DRIVER=log is not allowed when APP_ENV=production; use smtp")
+	}
+
+	return cfg, nil
 }
 
 func getEnv(key, defaultValue string) string {
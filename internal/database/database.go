@@ -44,19 +44,34 @@ func Connect(cfg *config.DatabaseConfig) (*gorm.DB, error) {
 	return db, nil
 }
 
-// AutoMigrate runs database migrations
-func AutoMigrate(db *gorm.DB) error {
+// AutoMigrate runs database migrations; auditChainKey keys the audit log hash chain
+func AutoMigrate(db *gorm.DB, auditChainKey []byte) error {
 	log.Println("🔄 Running database migrations...")
 	
 	err := db.AutoMigrate(
 		&models.User{},
+		&models.UserTombstone{},
 		&models.Attendance{},
+		&models.OfflineSyncRecord{},
+		&models.OfflineSyncBatch{},
 		&models.RefreshToken{},
+		&models.Session{},
+		&models.AuditLog{},
+		&models.Invitation{},
+		&models.PasswordReset{},
+		&models.RecoveryCode{},
+		&models.APIKey{},
 		&models.Office{},
 		&models.FacePhoto{},
 		&models.Setting{},
 		&models.OfficeTransferRequest{},
 		&models.Kiosk{},
+		&models.Badge{},
+		&models.Credential{},
+		&models.Visitor{},
+		&models.Announcement{},
+		&models.Role{},
+		&models.OfficeScope{},
 		&models.Employee{},
 		&models.WorkExperience{},
 		&models.EmployeeEvaluation{},
@@ -65,6 +80,221 @@ func AutoMigrate(db *gorm.DB) error {
 		return fmt.Errorf("failed to run migrations: %w", err)
 	}
 
+	if err := seedRoles(db); err != nil {
+		return fmt.Errorf("failed to seed roles: %w", err)
+	}
+
+	if err := scopeSyncRecordIDs(db); err != nil {
+		return fmt.Errorf("failed to migrate offline sync records: %w", err)
+	}
+
+	if err := tombstoneTransfers(db); err != nil {
+		return fmt.Errorf("failed to install user transfer trigger: %w", err)
+	}
+
+	if err := protectAuditLog(db, auditChainKey); err != nil {
+		return fmt.Errorf("failed to protect audit log: %w", err)
+	}
+
 	log.Println("✅ Database migrations completed")
 	return nil
 }
+
+// seedRoles creates the built-in roles that do not exist yet.
+// Existing roles are left alone so permission changes made by admins survive restarts.
+func seedRoles(db *gorm.DB) error {
+	for _, role := range models.DefaultRoles {
+		role := role
+		if err := db.Where("name = ?", role.Name).FirstOrCreate(&role).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scopeSyncRecordIDs makes offline client IDs unique per device instead of globally:
+// it drops the old global unique index and fills in the device of existing records
+func scopeSyncRecordIDs(db *gorm.DB) error {
+	if err := db.Exec("DROP INDEX IF EXISTS idx_offline_sync_records_client_record_id").Error; err != nil {
+		return err
+	}
+	if err := db.Exec("UPDATE offline_sync_records SET device = 'kiosk:' || kiosk_id WHERE device = '' AND source = 'kiosk'").Error; err != nil {
+		return err
+	}
+	return db.Exec("UPDATE offline_sync_records SET device = 'user:' || user_id::text WHERE device = '' AND source = 'mobile' AND user_id IS NOT NULL").Error
+}
+
+// tombstoneTransfers leaves a tombstone for the old office whenever a user's office
+// changes, whichever code path changes it, so kiosk delta syncs can stay office-filtered
+func tombstoneTransfers(db *gorm.DB) error {
+	err := db.Exec(`CREATE OR REPLACE FUNCTION users_tombstone_transfer() RETURNS trigger AS $$
+BEGIN
+	INSERT INTO user_tombstones (user_id, employee_id, office_id, reason, deleted_at)
+	VALUES (OLD.id, OLD.employee_id, OLD.office_id, 'transferred', now());
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql`).Error
+	if err != nil {
+		return err
+	}
+	if err := db.Exec("DROP TRIGGER IF EXISTS users_tombstone_transfer ON users").Error; err != nil {
+		return err
+	}
+	return db.Exec(`CREATE TRIGGER users_tombstone_transfer AFTER UPDATE OF office_id ON users
+FOR EACH ROW WHEN (OLD.office_id IS NOT NULL AND OLD.office_id IS DISTINCT FROM NEW.office_id)
+EXECUTE FUNCTION users_tombstone_transfer()`).Error
+}
+
+// auditRechainBatch is how many audit entries are read at a time while rechaining
+const auditRechainBatch = 1000
+
+// protectAuditLog installs the trigger that refuses every UPDATE and DELETE on the audit
+// log, if it is missing, then rechains the log when it still holds entries from before
+// hash chaining or before the chain was keyed
+func protectAuditLog(db *gorm.DB, key []byte) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("LOCK TABLE audit_logs IN EXCLUSIVE MODE").Error; err != nil {
+			return err
+		}
+
+		err := tx.Exec(`CREATE OR REPLACE FUNCTION audit_logs_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_logs is append-only';
+END;
+$$ LANGUAGE plpgsql`).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Exec(`DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'audit_logs_append_only' AND tgrelid = 'audit_logs'::regclass) THEN
+		CREATE TRIGGER audit_logs_append_only BEFORE UPDATE OR DELETE ON audit_logs
+		FOR EACH ROW EXECUTE FUNCTION audit_logs_append_only();
+	END IF;
+END;
+$$`).Error
+		if err != nil {
+			return err
+		}
+
+		return rechainAuditLog(tx, key)
+	})
+}
+
+// rechainAuditLog chains entries written before hash chaining existed and rehashes an
+// unkeyed chain with key. The chain is checked first and left alone if any entry was
+// tampered with, so rehashing never launders a modified entry. It runs inside
+// protectAuditLog's transaction, which suspends the append-only trigger only while it writes.
+func rechainAuditLog(tx *gorm.DB, key []byte) error {
+	var unchained int64
+	if err := tx.Model(&models.AuditLog{}).Where("seq = 0").Count(&unchained).Error; err != nil {
+		return err
+	}
+	var first models.AuditLog
+	if err := tx.Where("seq = 1").Limit(1).Find(&first).Error; err != nil {
+		return err
+	}
+	rekey := first.Seq == 1 && first.Hash != first.ComputeHash(key)
+	if unchained == 0 && !rekey {
+		return nil
+	}
+
+	if rekey {
+		intact, err := auditChainIntact(tx, key)
+		if err != nil {
+			return err
+		}
+		if !intact {
+			log.Println("⚠️  Audit log chain does not verify with AUDIT_CHAIN_KEY or the unkeyed hash; leaving it as is")
+			rekey = false
+		}
+	}
+	if unchained == 0 && !rekey {
+		return nil
+	}
+
+	if err := tx.Exec("ALTER TABLE audit_logs DISABLE TRIGGER audit_logs_append_only").Error; err != nil {
+		return err
+	}
+
+	var last models.AuditLog
+	if rekey {
+		rehashed := 0
+		for {
+			var entries []models.AuditLog
+			if err := tx.Where("seq > ?", last.Seq).Order("seq ASC").Limit(auditRechainBatch).Find(&entries).Error; err != nil {
+				return err
+			}
+			for i := range entries {
+				if err := chainAuditEntry(tx, &entries[i], &last, key); err != nil {
+					return err
+				}
+				rehashed++
+			}
+			if len(entries) < auditRechainBatch {
+				break
+			}
+		}
+		log.Printf("🔗 Rehashed %d audit log entries with the chain key", rehashed)
+	} else if err := tx.Select("seq", "hash").Order("seq DESC").Limit(1).Find(&last).Error; err != nil {
+		return err
+	}
+
+	if unchained > 0 {
+		var entries []models.AuditLog
+		if err := tx.Where("seq = 0").Order("created_at ASC, id ASC").Find(&entries).Error; err != nil {
+			return err
+		}
+		for i := range entries {
+			last.Seq++
+			entries[i].Seq = last.Seq
+			if err := chainAuditEntry(tx, &entries[i], &last, key); err != nil {
+				return err
+			}
+		}
+		log.Printf("🔗 Chained %d existing audit log entries", len(entries))
+	}
+
+	return tx.Exec("ALTER TABLE audit_logs ENABLE TRIGGER audit_logs_append_only").Error
+}
+
+// chainAuditEntry links entry to last, stores its sequence and hashes, and makes it the new last
+func chainAuditEntry(tx *gorm.DB, entry, last *models.AuditLog, key []byte) error {
+	entry.PrevHash = last.Hash
+	entry.Hash = entry.ComputeHash(key)
+	err := tx.Model(entry).UpdateColumns(map[string]interface{}{
+		"seq":       entry.Seq,
+		"prev_hash": entry.PrevHash,
+		"hash":      entry.Hash,
+	}).Error
+	if err != nil {
+		return err
+	}
+	*last = *entry
+	return nil
+}
+
+// auditChainIntact reports whether every chained entry links to the one before and
+// carries either its keyed or its unkeyed hash
+func auditChainIntact(tx *gorm.DB, key []byte) (bool, error) {
+	var seq int64
+	prevHash := ""
+	for {
+		var entries []models.AuditLog
+		if err := tx.Where("seq > ?", seq).Order("seq ASC").Limit(auditRechainBatch).Find(&entries).Error; err != nil {
+			return false, err
+		}
+		for _, entry := range entries {
+			if entry.Seq != seq+1 || entry.PrevHash != prevHash {
+				return false, nil
+			}
+			if entry.Hash != entry.LegacyHash() && entry.Hash != entry.ComputeHash(key) {
+				return false, nil
+			}
+			seq, prevHash = entry.Seq, entry.Hash
+		}
+		if len(entries) < auditRechainBatch {
+			return true, nil
+		}
+	}
+}
@@ -0,0 +1,459 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/attendance-system/internal/models"
+	"github.com/attendance-system/internal/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// AnnouncementHandler handles HR announcements for kiosks and mobile clients
+type AnnouncementHandler struct {
+	announcementRepo *repository.AnnouncementRepository
+	userRepo         *repository.UserRepository
+	employeeRepo     *repository.EmployeeRepository
+	kioskRepo        *repository.KioskRepository
+	wsHub            *WebSocketHub
+}
+
+// NewAnnouncementHandler creates a new announcement handler
+func NewAnnouncementHandler(
+	announcementRepo *repository.AnnouncementRepository,
+	userRepo *repository.UserRepository,
+	employeeRepo *repository.EmployeeRepository,
+	kioskRepo *repository.KioskRepository,
+	wsHub *WebSocketHub,
+) *AnnouncementHandler {
+	return &AnnouncementHandler{
+		announcementRepo: announcementRepo,
+		userRepo:         userRepo,
+		employeeRepo:     employeeRepo,
+		kioskRepo:        kioskRepo,
+		wsHub:            wsHub,
+	}
+}
+
+// AnnouncementRequest is the body for creating or updating an announcement
+type AnnouncementRequest struct {
+	Title        string     `json:"title" binding:"required"`
+	Body         string     `json:"body"`
+	Priority     string     `json:"priority"`
+	OfficeIDs    []string   `json:"office_ids"`
+	Positions    []string   `json:"positions"`
+	ShowOnKiosk  *bool      `json:"show_on_kiosk"`
+	ShowOnMobile *bool      `json:"show_on_mobile"`
+	StartsAt     *time.Time `json:"starts_at"` // Defaults to now
+	EndsAt       *time.Time `json:"ends_at"`
+	Publish      bool       `json:"publish"` // Publish immediately
+}
+
+// apply validates the request and copies it onto the announcement
+func (req *AnnouncementRequest) apply(announcement *models.Announcement) string {
+	priority := req.Priority
+	if priority == "" {
+		priority = models.AnnouncementInfo
+	}
+	if priority != models.AnnouncementInfo && priority != models.AnnouncementWarning && priority != models.AnnouncementCritical {
+		return "priority must be one of: info, warning, critical"
+	}
+
+	officeIDs := make([]string, 0, len(req.OfficeIDs))
+	for _, id := range req.OfficeIDs {
+		parsed, err := uuid.Parse(id)
+		if err != nil {
+			return "Invalid office ID: " + id
+		}
+		officeIDs = append(officeIDs, parsed.String())
+	}
+
+	positions := make([]string, 0, len(req.Positions))
+	for _, position := range req.Positions {
+		if position = strings.TrimSpace(position); position != "" {
+			positions = append(positions, position)
+		}
+	}
+
+	startsAt := time.Now()
+	if req.StartsAt != nil {
+		startsAt = *req.StartsAt
+	}
+	if req.EndsAt != nil && !req.EndsAt.After(startsAt) {
+		return "ends_at must be after starts_at"
+	}
+
+	announcement.Title = strings.TrimSpace(req.Title)
+	announcement.Body = req.Body
+	announcement.Priority = priority
+	announcement.OfficeIDs = officeIDs
+	announcement.Positions = positions
+	announcement.StartsAt = startsAt
+	announcement.EndsAt = req.EndsAt
+	if req.ShowOnKiosk != nil {
+		announcement.ShowOnKiosk = *req.ShowOnKiosk
+	}
+	if req.ShowOnMobile != nil {
+		announcement.ShowOnMobile = *req.ShowOnMobile
+	}
+	if !announcement.ShowOnKiosk && !announcement.ShowOnMobile {
+		return "Announcement must be shown on kiosks, mobile, or both"
+	}
+	return ""
+}
+
+// GetAnnouncements lists announcements for HR
+// GET /api/admin/announcements
+func (h *AnnouncementHandler) GetAnnouncements(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	filters := repository.AnnouncementFilters{OfficeID: c.Query("office_id")}
+	if published := c.Query("published"); published != "" {
+		value := published == "true"
+		filters.Published = &value
+	}
+	if c.Query("active") == "true" {
+		now := time.Now()
+		filters.ActiveAt = &now
+	}
+
+	announcements, total, err := h.announcementRepo.FindAll(c.Request.Context(), filters, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch announcements"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": announcements, "total": total})
+}
+
+// CreateAnnouncement creates an announcement, optionally publishing it right away
+// POST /api/admin/announcements
+func (h *AnnouncementHandler) CreateAnnouncement(c *gin.Context) {
+	var req AnnouncementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	announcement := &models.Announcement{ShowOnKiosk: true, ShowOnMobile: true}
+	if msg := req.apply(announcement); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if !announcementInScope(c, announcement) {
+		rejectOutOfScope(c)
+		return
+	}
+	if userID, exists := c.Get("user_id"); exists {
+		creator := userID.(uuid.UUID)
+		announcement.CreatedBy = &creator
+	}
+	if req.Publish {
+		now := time.Now()
+		announcement.IsPublished = true
+		announcement.PublishedAt = &now
+	}
+
+	if err := h.announcementRepo.Create(c.Request.Context(), announcement); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create announcement"})
+		return
+	}
+	auditChange(c, "announcement", announcement.ID.String(), nil, announcement)
+
+	if announcement.IsPublished {
+		h.pushAnnouncement(c.Request.Context(), EventAnnouncementPublished, announcement)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":      "Announcement created",
+		"announcement": announcement,
+	})
+}
+
+// UpdateAnnouncement updates an announcement; published changes are pushed to clients again
+// PUT /api/admin/announcements/:id
+func (h *AnnouncementHandler) UpdateAnnouncement(c *gin.Context) {
+	announcement, ok := h.loadAnnouncement(c)
+	if !ok {
+		return
+	}
+
+	var req AnnouncementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	previous := *announcement
+	if req.StartsAt == nil {
+		req.StartsAt = &announcement.StartsAt
+	}
+	if msg := req.apply(announcement); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if !announcementInScope(c, announcement) {
+		rejectOutOfScope(c)
+		return
+	}
+	if req.Publish && !announcement.IsPublished {
+		now := time.Now()
+		announcement.IsPublished = true
+		announcement.PublishedAt = &now
+	}
+
+	if err := h.announcementRepo.Update(c.Request.Context(), announcement); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update announcement"})
+		return
+	}
+	auditChange(c, "announcement", announcement.ID.String(), &previous, announcement)
+
+	// Clients the announcement is no longer targeted at take it down
+	if previous.IsPublished {
+		h.pushAnnouncement(c.Request.Context(), EventAnnouncementWithdrawn, &previous)
+	}
+	if announcement.IsPublished {
+		h.pushAnnouncement(c.Request.Context(), EventAnnouncementPublished, announcement)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Announcement updated",
+		"announcement": announcement,
+	})
+}
+
+// PublishAnnouncement publishes a draft announcement and pushes it to clients
+// POST /api/admin/announcements/:id/publish
+func (h *AnnouncementHandler) PublishAnnouncement(c *gin.Context) {
+	announcement, ok := h.loadAnnouncement(c)
+	if !ok {
+		return
+	}
+
+	if announcement.IsPublished {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Announcement already published"})
+		return
+	}
+
+	before := *announcement
+	now := time.Now()
+	announcement.IsPublished = true
+	announcement.PublishedAt = &now
+	if err := h.announcementRepo.Update(c.Request.Context(), announcement); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to publish announcement"})
+		return
+	}
+	auditChange(c, "announcement", announcement.ID.String(), &before, announcement)
+
+	h.pushAnnouncement(c.Request.Context(), EventAnnouncementPublished, announcement)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Announcement published",
+		"announcement": announcement,
+	})
+}
+
+// UnpublishAnnouncement takes an announcement back to draft and removes it from screens
+// POST /api/admin/announcements/:id/unpublish
+func (h *AnnouncementHandler) UnpublishAnnouncement(c *gin.Context) {
+	announcement, ok := h.loadAnnouncement(c)
+	if !ok {
+		return
+	}
+
+	if !announcement.IsPublished {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Announcement is not published"})
+		return
+	}
+
+	before := *announcement
+	announcement.IsPublished = false
+	if err := h.announcementRepo.Update(c.Request.Context(), announcement); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unpublish announcement"})
+		return
+	}
+	auditChange(c, "announcement", announcement.ID.String(), &before, announcement)
+
+	h.pushAnnouncement(c.Request.Context(), EventAnnouncementWithdrawn, announcement)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Announcement unpublished",
+		"announcement": announcement,
+	})
+}
+
+// DeleteAnnouncement deletes an announcement
+// DELETE /api/admin/announcements/:id
+func (h *AnnouncementHandler) DeleteAnnouncement(c *gin.Context) {
+	announcement, ok := h.loadAnnouncement(c)
+	if !ok {
+		return
+	}
+
+	if err := h.announcementRepo.Delete(c.Request.Context(), announcement.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete announcement"})
+		return
+	}
+	auditChange(c, "announcement", announcement.ID.String(), announcement, nil)
+
+	if announcement.IsPublished {
+		h.pushAnnouncement(c.Request.Context(), EventAnnouncementWithdrawn, announcement)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Announcement deleted"})
+}
+
+// loadAnnouncement finds the announcement named by the :id path parameter
+func (h *AnnouncementHandler) loadAnnouncement(c *gin.Context) (*models.Announcement, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid announcement ID"})
+		return nil, false
+	}
+
+	announcement, err := h.announcementRepo.FindByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Announcement not found"})
+		return nil, false
+	}
+	if !announcementInScope(c, announcement) {
+		rejectOutOfScope(c)
+		return nil, false
+	}
+	return announcement, true
+}
+
+// announcementInScope reports whether the current user may manage an announcement.
+// Scoped users can only manage announcements targeted at their own offices,
+// never company-wide ones.
+func announcementInScope(c *gin.Context, announcement *models.Announcement) bool {
+	if !isOfficeScoped(c) {
+		return true
+	}
+	if len(announcement.OfficeIDs) == 0 {
+		return false
+	}
+	for _, id := range announcement.OfficeIDs {
+		officeID, err := uuid.Parse(id)
+		if err != nil || !officeInScope(c, &officeID) {
+			return false
+		}
+	}
+	return true
+}
+
+// pushAnnouncement sends an announcement event to the clients it is targeted at: kiosks
+// of the targeted offices (position-targeted announcements stay off shared screens, as
+// on GetKioskAnnouncements), mobile users matching the office and position targeting,
+// and dashboards of users managing it. The payload carries the display window so clients
+// can decide when to show it; scheduled announcements are also picked up on the next fetch.
+func (h *AnnouncementHandler) pushAnnouncement(ctx context.Context, event string, announcement *models.Announcement) {
+	if h.wsHub == nil {
+		return
+	}
+
+	audience := AnnouncementAudience{
+		Kiosk: announcement.ShowOnKiosk && len(announcement.Positions) == 0,
+	}
+	for _, id := range announcement.OfficeIDs {
+		if officeID, err := uuid.Parse(id); err == nil {
+			audience.OfficeIDs = append(audience.OfficeIDs, officeID)
+		}
+	}
+
+	if announcement.ShowOnMobile {
+		if len(announcement.OfficeIDs) == 0 && len(announcement.Positions) == 0 {
+			audience.AllUsers = true
+		} else if recipients, err := h.announcementRepo.FindRecipientIDs(ctx, announcement); err != nil {
+			log.Printf("Failed to resolve announcement recipients: %v", err)
+		} else {
+			audience.UserIDs = make([]string, len(recipients))
+			for i, id := range recipients {
+				audience.UserIDs[i] = id.String()
+			}
+		}
+	}
+
+	h.wsHub.BroadcastAnnouncement(audience, event, announcement)
+}
+
+// matchesPosition reports whether an announcement targets the given position
+func matchesPosition(announcement models.Announcement, position string) bool {
+	if len(announcement.Positions) == 0 {
+		return true
+	}
+	for _, target := range announcement.Positions {
+		if strings.EqualFold(target, position) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetMyAnnouncements returns the announcements currently targeted at the logged-in user
+// GET /api/users/announcements
+func (h *AnnouncementHandler) GetMyAnnouncements(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	user, err := h.userRepo.FindByID(c.Request.Context(), userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	position := ""
+	if employee, err := h.employeeRepo.FindByUserID(c.Request.Context(), user.ID); err == nil && employee != nil {
+		position = employee.Position
+	}
+
+	active, err := h.announcementRepo.FindActive(c.Request.Context(), time.Now(), user.OfficeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch announcements"})
+		return
+	}
+
+	announcements := make([]models.Announcement, 0, len(active))
+	for _, announcement := range active {
+		if announcement.ShowOnMobile && matchesPosition(announcement, position) {
+			announcements = append(announcements, announcement)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": announcements})
+}
+
+// GetKioskAnnouncements returns the announcements for a kiosk's idle screen.
+// Kiosks are shared screens, so position-targeted announcements are left out.
+// GET /api/kiosk/announcements?kiosk_id=...
+func (h *AnnouncementHandler) GetKioskAnnouncements(c *gin.Context) {
+	kiosk, err := h.kioskRepo.FindByKioskID(c.Request.Context(), c.Query("kiosk_id"))
+	if err != nil || kiosk == nil || !kiosk.IsActive {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Perangkat Kiosk tidak terdaftar atau tidak aktif"})
+		return
+	}
+
+	active, err := h.announcementRepo.FindActive(c.Request.Context(), time.Now(), &kiosk.OfficeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memuat pengumuman"})
+		return
+	}
+
+	announcements := make([]models.Announcement, 0, len(active))
+	for _, announcement := range active {
+		if announcement.ShowOnKiosk && len(announcement.Positions) == 0 {
+			announcements = append(announcements, announcement)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": announcements})
+}
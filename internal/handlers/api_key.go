@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/attendance-system/internal/models"
+	"github.com/attendance-system/internal/repository"
+	"github.com/attendance-system/internal/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// apiKeyDisplayLength is how much of a key is kept to recognise it in listings
+const apiKeyDisplayLength = 12
+
+// APIKeyHandler handles issuing, listing and revoking API keys
+type APIKeyHandler struct {
+	apiKeyRepo       *repository.APIKeyRepository
+	auditRepo        *repository.AuditLogRepository
+	defaultRateLimit int
+	maxRateLimit     int
+}
+
+// NewAPIKeyHandler creates a new API key handler
+func NewAPIKeyHandler(
+	apiKeyRepo *repository.APIKeyRepository,
+	auditRepo *repository.AuditLogRepository,
+	defaultRateLimit, maxRateLimit int,
+) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyRepo:       apiKeyRepo,
+		auditRepo:        auditRepo,
+		defaultRateLimit: defaultRateLimit,
+		maxRateLimit:     maxRateLimit,
+	}
+}
+
+// APIKeyResponse is an API key with whether it still works
+type APIKeyResponse struct {
+	models.APIKey
+	Active bool `json:"active"`
+}
+
+// IssueAPIKeyRequest is the body for issuing an API key. Scopes are permission names,
+// e.g. ["attendance.read", "employees.read"] for a read-only reporting script.
+type IssueAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1"`
+	ExpiresAt *time.Time `json:"expires_at"` // Empty means the key does not expire
+	RateLimit int        `json:"rate_limit"` // Requests per minute; 0 uses the default
+}
+
+// GetAPIKeys lists API keys, newest first
+// GET /api/admin/api-keys?include_inactive=true
+func (h *APIKeyHandler) GetAPIKeys(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	includeInactive := c.Query("include_inactive") == "true"
+
+	keys, total, err := h.apiKeyRepo.FindAll(c.Request.Context(), includeInactive, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch API keys"})
+		return
+	}
+
+	result := make([]APIKeyResponse, len(keys))
+	for i := range keys {
+		result[i] = APIKeyResponse{APIKey: keys[i], Active: keys[i].Active()}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  result,
+		"total": total,
+	})
+}
+
+// IssueAPIKey creates a key acting for the caller, limited to the given scopes.
+// The key is only shown in this response.
+// POST /api/admin/api-keys
+func (h *APIKeyHandler) IssueAPIKey(c *gin.Context) {
+	// A key issuing keys could outlive its own revocation through them
+	if _, viaKey := c.Get("api_key_id"); viaKey {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API keys cannot issue API keys", "code": "PERMISSION_DENIED"})
+		return
+	}
+
+	var req IssueAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	scopes, problem := validatePermissions(req.Scopes)
+	if problem != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": problem})
+		return
+	}
+	for _, scope := range scopes {
+		if scope == models.PermAll {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "API keys must list their permissions; * is not allowed"})
+			return
+		}
+	}
+	if !canGrantAll(c, scopes) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot grant permissions you do not have", "code": "PERMISSION_DENIED"})
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
+
+	rateLimit := req.RateLimit
+	if rateLimit == 0 {
+		rateLimit = h.defaultRateLimit
+	}
+	if rateLimit < 1 || rateLimit > h.maxRateLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "rate_limit must be between 1 and " + strconv.Itoa(h.maxRateLimit)})
+		return
+	}
+
+	secret, err := utils.NewSecureToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate API key"})
+		return
+	}
+	token := models.APIKeyPrefix + secret
+
+	key := &models.APIKey{
+		Name:      req.Name,
+		Prefix:    token[:apiKeyDisplayLength],
+		KeyHash:   utils.HashSecureToken(token),
+		Scopes:    scopes,
+		RateLimit: rateLimit,
+		ExpiresAt: req.ExpiresAt,
+		CreatedBy: *callerID(c),
+	}
+	if err := h.apiKeyRepo.Create(c.Request.Context(), key); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+	auditChange(c, "api_key", key.ID.String(), nil, key)
+	recordAudit(c, h.auditRepo, models.AuditAPIKeyIssued, "api_key", key.ID.String(), gin.H{
+		"name":   key.Name,
+		"scopes": key.Scopes,
+	})
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Store this key now, it cannot be shown again",
+		"key":     token,
+		"api_key": APIKeyResponse{APIKey: *key, Active: true},
+	})
+}
+
+// RevokeAPIKey stops a key from working
+// DELETE /api/admin/api-keys/:id
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	key, err := h.apiKeyRepo.FindByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+
+	before := *key
+	if err := h.apiKeyRepo.Revoke(c.Request.Context(), key.ID); err != nil {
+		if errors.Is(err, repository.ErrAPIKeyRevoked) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "API key was already revoked"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+		return
+	}
+	revokedAt := time.Now()
+	key.RevokedAt = &revokedAt
+	auditChange(c, "api_key", key.ID.String(), &before, key)
+	recordAudit(c, h.auditRepo, models.AuditAPIKeyRevoked, "api_key", key.ID.String(), gin.H{"name": key.Name})
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
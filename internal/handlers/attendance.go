@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	"context"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/attendance-system/internal/models"
@@ -143,6 +145,7 @@ func (h *AttendanceHandler) CheckIn(c *gin.Context) {
 			EmployeeID: user.EmployeeID,
 			Time:       now,
 			IsLate:     isLate,
+			OfficeID:   user.OfficeID,
 		})
 	}
 
@@ -228,6 +231,7 @@ func (h *AttendanceHandler) CheckOut(c *gin.Context) {
 			UserName:   user.Name,
 			EmployeeID: user.EmployeeID,
 			Time:       now,
+			OfficeID:   user.OfficeID,
 		})
 	}
 
@@ -310,6 +314,9 @@ func (h *AttendanceHandler) GetAllToday(c *gin.Context) {
 		Status:    c.Query("status"),
 		SortBy:    c.Query("sort_by"),
 		SortOrder: c.Query("sort_order"),
+
+		WorkedOfficeID: c.Query("worked_office_id"),
+		Visit:          c.Query("visit"),
 	}
 
 	attendances, total, err := h.attendanceRepo.FindAll(c.Request.Context(), filters, limit, offset)
@@ -333,6 +340,8 @@ func (h *AttendanceHandler) GetAllToday(c *gin.Context) {
 			"total_on_time":     stats.TotalOnTime,
 			"total_late":        stats.TotalLate,
 			"total_early_leave": stats.TotalEarlyLeave,
+			"total_visits":      stats.TotalVisits,
+			"total_flagged":     stats.TotalFlagged,
 		},
 	})
 }
@@ -474,6 +483,7 @@ type OfflineSyncRequest struct {
 
 // OfflineAttendanceRecord represents a single offline attendance entry
 type OfflineAttendanceRecord struct {
+	ClientID       string  `json:"client_id"` // Client-generated UUID, reused on retry; derived for older clients
 	Type           string  `json:"type" binding:"required,oneof=check-in check-out"`
 	Latitude       float64 `json:"latitude" binding:"required"`
 	Longitude      float64 `json:"longitude" binding:"required"`
@@ -482,7 +492,111 @@ type OfflineAttendanceRecord struct {
 	IsMockLocation bool    `json:"is_mock_location"`
 }
 
-// OfflineSync handles batch synchronization of offline attendance records
+// Offline sync record statuses
+const (
+	SyncStatusCreated   = "created"
+	SyncStatusDuplicate = "duplicate"
+	SyncStatusRejected  = "rejected"
+	SyncStatusHeld      = "held" // Stored server-side but waiting for HR review
+)
+
+// OfflineSyncResult reports the outcome of a single offline record.
+// Clients can drop their local copy once the status is created, duplicate or held.
+type OfflineSyncResult struct {
+	ClientID     string     `json:"client_id"`
+	Status       string     `json:"status"`         // created, duplicate, rejected, held
+	Code         string     `json:"code,omitempty"` // Reason code when rejected or held
+	Message      string     `json:"message,omitempty"`
+	AttendanceID *uuid.UUID `json:"attendance_id,omitempty"`
+}
+
+// legacyRecordNamespace derives client IDs for records queued by clients that predate client_id
+var legacyRecordNamespace = uuid.MustParse("6f1c2a52-7d0e-4f53-9a8e-3b8c1d54e2a7")
+
+// legacyClientID derives a stable client ID from the fields that identify a legacy record,
+// so retrying the same queue still finds the records it already synced
+func legacyClientID(fields ...string) string {
+	return uuid.NewSHA1(legacyRecordNamespace, []byte(strings.Join(fields, "\n"))).String()
+}
+
+// mobileSyncDevice and kioskSyncDevice name the device client IDs are unique on
+func mobileSyncDevice(userID uuid.UUID) string { return "user:" + userID.String() }
+func kioskSyncDevice(kioskID string) string    { return "kiosk:" + kioskID }
+
+func rejectOfflineRecord(clientID, code, message string) OfflineSyncResult {
+	return OfflineSyncResult{
+		ClientID: clientID,
+		Status:   SyncStatusRejected,
+		Code:     code,
+		Message:  message,
+	}
+}
+
+// checkOfflineDuplicate parses a record's client ID and reports whether the device already
+// had it handled, either earlier in the same batch or by a previous upload.
+// A non-nil result means the record must not be applied again.
+func checkOfflineDuplicate(
+	ctx context.Context,
+	txRepo *repository.AttendanceRepository,
+	device string,
+	clientID string,
+	seen map[uuid.UUID]OfflineSyncResult,
+) (uuid.UUID, *OfflineSyncResult, error) {
+	id, err := uuid.Parse(clientID)
+	if err != nil {
+		result := rejectOfflineRecord(clientID, "INVALID_CLIENT_ID", "client_id must be a UUID")
+		return uuid.Nil, &result, nil
+	}
+
+	if previous, ok := seen[id]; ok {
+		if previous.Status == SyncStatusCreated {
+			previous.Status = SyncStatusDuplicate
+		}
+		return id, &previous, nil
+	}
+
+	applied, err := txRepo.FindSyncRecord(ctx, device, id)
+	if err != nil {
+		return id, nil, err
+	}
+	if applied != nil {
+		switch applied.Status {
+		case models.SyncRecordHeld:
+			result := OfflineSyncResult{ClientID: clientID, Status: SyncStatusHeld, Code: "HELD_FOR_REVIEW", Message: "Record is waiting for HR review"}
+			return id, &result, nil
+		case models.SyncRecordDiscarded:
+			result := rejectOfflineRecord(clientID, "REJECTED_BY_REVIEW", "Record was not applied after HR review")
+			return id, &result, nil
+		}
+		return id, &OfflineSyncResult{
+			ClientID:     clientID,
+			Status:       SyncStatusDuplicate,
+			AttendanceID: applied.AttendanceID,
+		}, nil
+	}
+
+	return id, nil, nil
+}
+
+// summarizeOfflineSync counts results per status
+func summarizeOfflineSync(results []OfflineSyncResult) (created, duplicates, rejected, held int) {
+	for _, result := range results {
+		switch result.Status {
+		case SyncStatusCreated:
+			created++
+		case SyncStatusDuplicate:
+			duplicates++
+		case SyncStatusRejected:
+			rejected++
+		case SyncStatusHeld:
+			held++
+		}
+	}
+	return created, duplicates, rejected, held
+}
+
+// OfflineSync handles batch synchronization of offline attendance records.
+// The whole batch is applied in one transaction and every record gets its own result.
 // POST /api/attendance/offline-sync
 func (h *AttendanceHandler) OfflineSync(c *gin.Context) {
 	userID, exists := c.Get("user_id")
@@ -498,7 +612,7 @@ func (h *AttendanceHandler) OfflineSync(c *gin.Context) {
 	}
 
 	if len(req.Records) == 0 {
-		c.JSON(http.StatusOK, gin.H{"message": "No records to sync", "synced": 0})
+		c.JSON(http.StatusOK, gin.H{"message": "No records to sync", "synced": 0, "results": []OfflineSyncResult{}})
 		return
 	}
 
@@ -508,103 +622,175 @@ func (h *AttendanceHandler) OfflineSync(c *gin.Context) {
 		return
 	}
 
-	synced := 0
-	errors := []string{}
-
-	for _, record := range req.Records {
-		// Parse timestamp from offline record
-		recordTime, err := time.Parse(time.RFC3339, record.Timestamp)
-		if err != nil {
-			errors = append(errors, "Invalid timestamp: "+record.Timestamp)
-			continue
+	for i := range req.Records {
+		if record := &req.Records[i]; record.ClientID == "" {
+			record.ClientID = legacyClientID(record.Type, record.Timestamp)
 		}
+	}
+
+	var results []OfflineSyncResult
+	err = h.attendanceRepo.Transaction(c.Request.Context(), func(txRepo *repository.AttendanceRepository) error {
+		results = make([]OfflineSyncResult, 0, len(req.Records))
+		seen := make(map[uuid.UUID]OfflineSyncResult)
 
-		// Basic validation: record shouldn't be in the future
-		if recordTime.After(time.Now().Add(5 * time.Minute)) {
-			errors = append(errors, "Future timestamp rejected: "+record.Timestamp)
-			continue
+		for _, record := range req.Records {
+			result, err := h.applyOfflineRecord(c.Request.Context(), txRepo, user, record, seen)
+			if err != nil {
+				return err
+			}
+			results = append(results, result)
 		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to sync offline records, nothing was applied",
+			"code":  "SYNC_FAILED",
+		})
+		return
+	}
 
-		// Get date for the record
-		recordDate := recordTime.Format("2006-01-02")
+	synced, duplicates, rejected, _ := summarizeOfflineSync(results)
 
-		if record.Type == "check-in" {
-			// Check if already checked in on that date
-			existing, _ := h.attendanceRepo.FindByUserAndDate(c.Request.Context(), userID.(uuid.UUID), recordDate)
-			if existing != nil && existing.CheckInTime != nil {
-				errors = append(errors, "Already checked in on "+recordDate)
-				continue
-			}
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Sync completed",
+		"synced":     synced,
+		"duplicates": duplicates,
+		"rejected":   rejected,
+		"results":    results,
+	})
+}
 
-			// Determine check-in status
-			checkInSchedule := "08:00"
-			checkInTolerance := 30
-			if user.Office != nil {
-				if user.Office.CheckInTime != "" {
-					checkInSchedule = user.Office.CheckInTime
-				}
-				checkInTolerance = user.Office.CheckInTolerance
-			}
-			checkInStatus := utils.DetermineCheckInStatus(recordTime, checkInSchedule, checkInTolerance)
-
-			attendance := &models.Attendance{
-				UserID:         userID.(uuid.UUID),
-				CheckInTime:    &recordTime,
-				CheckInLat:     &record.Latitude,
-				CheckInLong:    &record.Longitude,
-				DeviceInfo:     record.DeviceInfo + " (offline)",
-				CheckInStatus:  checkInStatus,
-				IsMockLocation: record.IsMockLocation,
-				Notes:          "Synced from offline mode",
-			}
+// applyOfflineRecord applies one offline record for the authenticated user inside the sync transaction.
+// Business rule violations come back as rejected results; only database failures return an error.
+func (h *AttendanceHandler) applyOfflineRecord(
+	ctx context.Context,
+	txRepo *repository.AttendanceRepository,
+	user *models.User,
+	record OfflineAttendanceRecord,
+	seen map[uuid.UUID]OfflineSyncResult,
+) (OfflineSyncResult, error) {
+	device := mobileSyncDevice(user.ID)
+	clientID, handled, err := checkOfflineDuplicate(ctx, txRepo, device, record.ClientID, seen)
+	if err != nil {
+		return OfflineSyncResult{}, err
+	}
+	if handled != nil {
+		return *handled, nil
+	}
 
-			if err := h.attendanceRepo.Create(c.Request.Context(), attendance); err != nil {
-				errors = append(errors, "Failed to create check-in: "+err.Error())
-				continue
-			}
-			synced++
-
-		} else if record.Type == "check-out" {
-			// Find today's check-in record
-			existing, err := h.attendanceRepo.FindByUserAndDate(c.Request.Context(), userID.(uuid.UUID), recordDate)
-			if err != nil || existing == nil {
-				errors = append(errors, "No check-in found for "+recordDate)
-				continue
-			}
-			if existing.CheckOutTime != nil {
-				errors = append(errors, "Already checked out on "+recordDate)
-				continue
-			}
+	result, err := h.applyOfflineAttendance(ctx, txRepo, user, record)
+	if err != nil {
+		return OfflineSyncResult{}, err
+	}
+	seen[clientID] = result
+	if result.Status != SyncStatusCreated {
+		return result, nil
+	}
+
+	syncRecord := &models.OfflineSyncRecord{
+		Device:         device,
+		ClientRecordID: clientID,
+		Source:         "mobile",
+		UserID:         &user.ID,
+		AttendanceID:   result.AttendanceID,
+		Type:           record.Type,
+	}
+	if err := txRepo.CreateSyncRecord(ctx, syncRecord); err != nil {
+		return OfflineSyncResult{}, err
+	}
+
+	return result, nil
+}
+
+// applyOfflineAttendance writes the check-in or check-out described by an offline record
+func (h *AttendanceHandler) applyOfflineAttendance(
+	ctx context.Context,
+	txRepo *repository.AttendanceRepository,
+	user *models.User,
+	record OfflineAttendanceRecord,
+) (OfflineSyncResult, error) {
+	// Parse timestamp from offline record
+	recordTime, err := time.Parse(time.RFC3339, record.Timestamp)
+	if err != nil {
+		return rejectOfflineRecord(record.ClientID, "INVALID_TIMESTAMP", "Invalid timestamp: "+record.Timestamp), nil
+	}
+
+	// Basic validation: record shouldn't be in the future
+	if recordTime.After(time.Now().Add(5 * time.Minute)) {
+		return rejectOfflineRecord(record.ClientID, "FUTURE_TIMESTAMP", "Future timestamp rejected: "+record.Timestamp), nil
+	}
+
+	// Get date for the record
+	recordDate := recordTime.Format("2006-01-02")
+
+	switch record.Type {
+	case "check-in":
+		// Check if already checked in on that date
+		existing, _ := txRepo.FindByUserAndDate(ctx, user.ID, recordDate)
+		if existing != nil && existing.CheckInTime != nil {
+			return rejectOfflineRecord(record.ClientID, "ALREADY_CHECKED_IN", "Already checked in on "+recordDate), nil
+		}
 
-			// Determine check-out status
-			checkOutSchedule := "17:00"
-			checkOutTolerance := 15
-			if user.Office != nil {
-				if user.Office.CheckOutTime != "" {
-					checkOutSchedule = user.Office.CheckOutTime
-				}
-				checkOutTolerance = user.Office.CheckOutTolerance
+		// Determine check-in status
+		checkInSchedule := "08:00"
+		checkInTolerance := 30
+		if user.Office != nil {
+			if user.Office.CheckInTime != "" {
+				checkInSchedule = user.Office.CheckInTime
 			}
-			checkOutStatus := utils.DetermineCheckOutStatus(recordTime, checkOutSchedule, checkOutTolerance)
+			checkInTolerance = user.Office.CheckInTolerance
+		}
+		checkInStatus := utils.DetermineCheckInStatus(recordTime, checkInSchedule, checkInTolerance)
+
+		attendance := &models.Attendance{
+			UserID:         user.ID,
+			CheckInTime:    &recordTime,
+			CheckInLat:     &record.Latitude,
+			CheckInLong:    &record.Longitude,
+			DeviceInfo:     record.DeviceInfo + " (offline)",
+			CheckInStatus:  checkInStatus,
+			IsMockLocation: record.IsMockLocation,
+			Notes:          "Synced from offline mode",
+		}
+
+		if err := txRepo.Create(ctx, attendance); err != nil {
+			return OfflineSyncResult{}, err
+		}
+		return OfflineSyncResult{ClientID: record.ClientID, Status: SyncStatusCreated, AttendanceID: &attendance.ID}, nil
 
-			existing.CheckOutTime = &recordTime
-			existing.CheckOutLat = &record.Latitude
-			existing.CheckOutLong = &record.Longitude
-			existing.CheckOutStatus = checkOutStatus
-			existing.Notes = existing.Notes + " | Check-out synced from offline"
+	case "check-out":
+		// Find the check-in record of that date
+		existing, err := txRepo.FindByUserAndDate(ctx, user.ID, recordDate)
+		if err != nil || existing == nil {
+			return rejectOfflineRecord(record.ClientID, "NO_CHECK_IN", "No check-in found for "+recordDate), nil
+		}
+		if existing.CheckOutTime != nil {
+			return rejectOfflineRecord(record.ClientID, "ALREADY_CHECKED_OUT", "Already checked out on "+recordDate), nil
+		}
 
-			if err := h.attendanceRepo.Update(c.Request.Context(), existing); err != nil {
-				errors = append(errors, "Failed to update check-out: "+err.Error())
-				continue
+		// Determine check-out status
+		checkOutSchedule := "17:00"
+		checkOutTolerance := 15
+		if user.Office != nil {
+			if user.Office.CheckOutTime != "" {
+				checkOutSchedule = user.Office.CheckOutTime
 			}
-			synced++
+			checkOutTolerance = user.Office.CheckOutTolerance
+		}
+		checkOutStatus := utils.DetermineCheckOutStatus(recordTime, checkOutSchedule, checkOutTolerance)
+
+		existing.CheckOutTime = &recordTime
+		existing.CheckOutLat = &record.Latitude
+		existing.CheckOutLong = &record.Longitude
+		existing.CheckOutStatus = checkOutStatus
+		existing.Notes = existing.Notes + " | Check-out synced from offline"
+
+		if err := txRepo.Update(ctx, existing); err != nil {
+			return OfflineSyncResult{}, err
 		}
+		return OfflineSyncResult{ClientID: record.ClientID, Status: SyncStatusCreated, AttendanceID: &existing.ID}, nil
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Sync completed",
-		"synced":  synced,
-		"errors":  errors,
-	})
+	return rejectOfflineRecord(record.ClientID, "INVALID_TYPE", "Unknown record type: "+record.Type), nil
 }
-
@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/attendance-system/internal/middleware"
+	"github.com/attendance-system/internal/models"
+	"github.com/attendance-system/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// auditExportLimit caps the entries in one CSV export
+const auditExportLimit = 50000
+
+// auditIgnoredFields are left out of diffs: timestamps that change on every save, and
+// biometric data that does not belong in a log
+var auditIgnoredFields = map[string]bool{"updated_at": true, "face_embeddings": true, "pass_code": true}
+
+// auditMaskedFields are fields, per target type, whose changes are logged without their
+// values: the audit log is readable with audit.read, which does not grant employees.read_sensitive.
+// A user's loaded employee record carries the same columns.
+var auditMaskedFields = map[string]map[string]bool{
+	"employee": sensitiveEmployeeColumns,
+	"user":     {"employee": true},
+}
+
+// auditMaskedChange stands in for the from and to of a masked field
+var auditMaskedChange = gin.H{"changed": true}
+
+// recordAudit appends an event to the audit log, attributed to the logged-in user if any.
+// A failure is logged rather than failing the request that caused the event.
+func recordAudit(c *gin.Context, auditRepo *repository.AuditLogRepository, action, targetType, targetID string, details gin.H) {
+	entry := middleware.NewAuditEntry(c, action, targetType, targetID)
+	if details != nil {
+		raw, err := json.Marshal(details)
+		if err != nil {
+			log.Printf("[Audit] Failed to encode details of %s: %v", action, err)
+		}
+		entry.Details = raw
+	}
+
+	if err := auditRepo.Create(c.Request.Context(), entry); err != nil {
+		log.Printf("[Audit] Failed to record %s on %s %s: %v", action, targetType, targetID, err)
+	}
+}
+
+// auditChange notes what a request changed for its audit entry. before is nil for
+// something created and after is nil for something deleted; only fields that differ
+// in their JSON form are kept. Fields missing from after, such as relations that were
+// not loaded, count as unchanged.
+func auditChange(c *gin.Context, targetType, targetID string, before, after interface{}) {
+	c.Set("audit_target_type", targetType)
+	c.Set("audit_target_id", targetID)
+
+	changes := auditDiff(before, after)
+	if len(changes) == 0 {
+		return
+	}
+	for field := range changes {
+		if auditMaskedFields[targetType][field] {
+			changes[field] = auditMaskedChange
+		}
+	}
+	raw, err := json.Marshal(changes)
+	if err != nil {
+		log.Printf("[Audit] Failed to encode changes of %s %s: %v", targetType, targetID, err)
+		return
+	}
+	c.Set("audit_changes", json.RawMessage(raw))
+}
+
+// auditDiff compares two values by their JSON fields
+func auditDiff(before, after interface{}) map[string]gin.H {
+	from, to := auditFields(before), auditFields(after)
+	changes := make(map[string]gin.H)
+	for field, value := range to {
+		if !auditIgnoredFields[field] && !reflect.DeepEqual(from[field], value) {
+			changes[field] = gin.H{"from": from[field], "to": value}
+		}
+	}
+	if len(to) == 0 {
+		for field, value := range from {
+			if !auditIgnoredFields[field] {
+				changes[field] = gin.H{"from": value, "to": nil}
+			}
+		}
+	}
+	return changes
+}
+
+// auditFields returns the JSON object a value encodes to; nil gives no fields
+func auditFields(v interface{}) map[string]interface{} {
+	fields := make(map[string]interface{})
+	if v == nil {
+		return fields
+	}
+	if rv := reflect.ValueOf(v); (rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Map) && rv.IsNil() {
+		return fields
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fields
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&fields); err != nil {
+		return map[string]interface{}{}
+	}
+	return fields
+}
+
+// AuditLogHandler serves the audit log to auditors
+type AuditLogHandler struct {
+	auditRepo *repository.AuditLogRepository
+}
+
+// NewAuditLogHandler creates a new audit log handler
+func NewAuditLogHandler(auditRepo *repository.AuditLogRepository) *AuditLogHandler {
+	return &AuditLogHandler{auditRepo: auditRepo}
+}
+
+// auditFilters reads the filters shared by listing and export
+func auditFilters(c *gin.Context) (repository.AuditLogFilters, bool) {
+	filters := repository.AuditLogFilters{
+		ActorID:    c.Query("actor_id"),
+		Action:     c.Query("action"),
+		TargetType: c.Query("target_type"),
+		TargetID:   c.Query("target_id"),
+	}
+	if c.Query("start_date") != "" || c.Query("end_date") != "" {
+		start, end, err := parseVisitorRange(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
+			return filters, false
+		}
+		filters.From, filters.To = &start, &end
+	}
+	return filters, true
+}
+
+// GetAuditLogs lists audit entries, newest first. action takes an exact action or a
+// prefix ending in *, e.g. action=PUT /api/admin/* for every admin update.
+// GET /api/admin/audit-logs?actor_id=&action=&target_type=&target_id=&start_date=&end_date=
+func (h *AuditLogHandler) GetAuditLogs(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	filters, ok := auditFilters(c)
+	if !ok {
+		return
+	}
+
+	entries, total, err := h.auditRepo.FindAll(c.Request.Context(), filters, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit log"})
+		return
+	}
+	maskAuditChanges(c, entries)
+
+	c.JSON(http.StatusOK, gin.H{"data": entries, "total": total})
+}
+
+// ExportAuditLogs downloads the matching entries as CSV, oldest first
+// GET /api/admin/audit-logs/export
+func (h *AuditLogHandler) ExportAuditLogs(c *gin.Context) {
+	filters, ok := auditFilters(c)
+	if !ok {
+		return
+	}
+
+	entries, err := h.auditRepo.FindForExport(c.Request.Context(), filters, auditExportLimit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit log"})
+		return
+	}
+	maskAuditChanges(c, entries)
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"Seq", "Time", "Actor ID", "Actor Email", "API Key ID", "Action", "Target Type", "Target ID",
+		"Status", "IP Address", "User Agent", "Changes", "Details", "Previous Hash", "Hash"})
+
+	for _, entry := range entries {
+		actorID, apiKeyID, status := "", "", ""
+		if entry.ActorID != nil {
+			actorID = entry.ActorID.String()
+		}
+		if entry.APIKeyID != nil {
+			apiKeyID = entry.APIKeyID.String()
+		}
+		if entry.StatusCode != 0 {
+			status = strconv.Itoa(entry.StatusCode)
+		}
+		w.Write([]string{strconv.FormatInt(entry.Seq, 10), entry.CreatedAt.UTC().Format(time.RFC3339), actorID,
+			entry.ActorEmail, apiKeyID, entry.Action, entry.TargetType, entry.TargetID, status, entry.IPAddress,
+			entry.UserAgent, csvJSON(entry.Changes), csvJSON(entry.Details), entry.PrevHash, entry.Hash})
+	}
+	w.Flush()
+
+	filename := fmt.Sprintf("audit-log_%s.csv", time.Now().Format("20060102-150405"))
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
+
+// maskAuditChanges hides the values of masked fields in entries recorded before they were
+// masked at write time, unless the caller may read sensitive employee data. Stored entries
+// stay as they are, so the hash chain still verifies.
+func maskAuditChanges(c *gin.Context, entries []models.AuditLog) {
+	if hasPermission(c, models.PermEmployeesReadSensitive) {
+		return
+	}
+	for i := range entries {
+		masked := auditMaskedFields[entries[i].TargetType]
+		if masked == nil || len(entries[i].Changes) == 0 {
+			continue
+		}
+		var changes map[string]json.RawMessage
+		if err := json.Unmarshal(entries[i].Changes, &changes); err != nil {
+			continue
+		}
+		redacted := false
+		for field := range changes {
+			if masked[field] {
+				changes[field], _ = json.Marshal(auditMaskedChange)
+				redacted = true
+			}
+		}
+		if redacted {
+			raw, _ := json.Marshal(changes)
+			entries[i].Changes = models.JSONRaw(raw)
+		}
+	}
+}
+
+// csvJSON renders a JSON column for CSV, leaving null empty
+func csvJSON(raw models.JSONRaw) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	return string(raw)
+}
+
+// VerifyAuditLog checks the hash chain from the first entry to the last
+// GET /api/admin/audit-logs/verify
+func (h *AuditLogHandler) VerifyAuditLog(c *gin.Context) {
+	status, err := h.auditRepo.Verify(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify audit log"})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
@@ -1,8 +1,15 @@
 package handlers
 
 import (
+	"context"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/attendance-system/internal/middleware"
 	"github.com/attendance-system/internal/models"
 	"github.com/attendance-system/internal/repository"
 	"github.com/attendance-system/internal/utils"
@@ -15,6 +22,11 @@ import (
 type AuthHandler struct {
 	userRepo         *repository.UserRepository
 	refreshTokenRepo *repository.RefreshTokenRepository
+	sessionRepo      *repository.SessionRepository
+	denylist         *middleware.TokenDenylist
+	loginGuard       *middleware.LoginGuard
+	auditRepo        *repository.AuditLogRepository
+	twoFactor        *TwoFactorHandler
 	jwtManager       *utils.JWTManager
 	defaultOfficeLat  float64
 	defaultOfficeLong float64
@@ -24,12 +36,22 @@ type AuthHandler struct {
 func NewAuthHandler(
 	userRepo *repository.UserRepository,
 	refreshTokenRepo *repository.RefreshTokenRepository,
+	sessionRepo *repository.SessionRepository,
+	denylist *middleware.TokenDenylist,
+	loginGuard *middleware.LoginGuard,
+	auditRepo *repository.AuditLogRepository,
+	twoFactor *TwoFactorHandler,
 	jwtManager *utils.JWTManager,
 	defaultOfficeLat, defaultOfficeLong float64,
 ) *AuthHandler {
 	return &AuthHandler{
 		userRepo:          userRepo,
 		refreshTokenRepo:  refreshTokenRepo,
+		sessionRepo:       sessionRepo,
+		denylist:          denylist,
+		loginGuard:        loginGuard,
+		auditRepo:         auditRepo,
+		twoFactor:         twoFactor,
 		jwtManager:        jwtManager,
 		defaultOfficeLat:  defaultOfficeLat,
 		defaultOfficeLong: defaultOfficeLong,
@@ -42,17 +64,20 @@ type RegisterRequest struct {
 	Name       string `json:"name" binding:"required"`
 	Email      string `json:"email" binding:"required,email"`
 	Password   string `json:"password" binding:"required,min=6"`
+	DeviceInfo string `json:"device_info"`
 }
 
 // LoginRequest represents login payload
 type LoginRequest struct {
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required"`
+	Email      string `json:"email" binding:"required,email"`
+	Password   string `json:"password" binding:"required"`
+	DeviceInfo string `json:"device_info"` // e.g. "Pixel 7 / Android 14"; falls back to the X-Device-Info header
 }
 
 // RefreshRequest represents refresh token payload
 type RefreshRequest struct {
 	RefreshToken string `json:"refresh_token" binding:"required"`
+	DeviceInfo   string `json:"device_info"`
 }
 
 // AuthResponse represents authentication response
@@ -109,27 +134,9 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	// Generate tokens
-	accessToken, err := h.jwtManager.GenerateAccessToken(user.ID, user.Email, user.Role, user.EmployeeID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate access token"})
-		return
-	}
-
-	refreshToken, expiresAt, err := h.jwtManager.GenerateRefreshToken(user.ID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate refresh token"})
-		return
-	}
-
-	// Store refresh token
-	refreshTokenRecord := &models.RefreshToken{
-		UserID:    user.ID,
-		Token:     refreshToken,
-		ExpiresAt: expiresAt,
-	}
-	if err := h.refreshTokenRepo.Create(c.Request.Context(), refreshTokenRecord); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store refresh token"})
+	// Open a session for this device and generate its tokens
+	accessToken, refreshToken, ok := h.startSession(c, user, req.DeviceInfo)
+	if !ok {
 		return
 	}
 
@@ -149,10 +156,20 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	account := middleware.LoginAccount(req.Email)
+	block, err := h.loginGuard.Check(c.Request.Context(), c.ClientIP(), account)
+	if err != nil {
+		// Throttling needs Redis; an outage should not stop everyone from logging in
+		log.Printf("[Login] Login guard unavailable: %v", err)
+	} else if block != nil {
+		rejectBlockedLogin(c, block)
+		return
+	}
+
 	// Find user by email
 	user, err := h.userRepo.FindByEmail(c.Request.Context(), req.Email)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+		h.loginFailed(c, account, nil, "Invalid email or password")
 		return
 	}
 
@@ -164,31 +181,186 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 	// Verify password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+		h.loginFailed(c, account, user, "Invalid email or password")
 		return
 	}
 
-	// Generate tokens
-	accessToken, err := h.jwtManager.GenerateAccessToken(user.ID, user.Email, user.Role, user.EmployeeID)
+	h.completeLogin(c, user, req.DeviceInfo)
+}
+
+// completeLogin finishes a login whose first factor checked out, by password or SSO.
+// With 2FA it only earns a challenge; failed logins are cleared once the second step
+// succeeds, so guessing codes counts towards the lockout as well.
+func (h *AuthHandler) completeLogin(c *gin.Context, user *models.User, deviceInfo string) {
+	if user.TwoFactorEnabled {
+		h.issueChallenge(c, user, utils.ChallengeTwoFactor)
+		return
+	}
+	if h.twoFactor.required(c.Request.Context(), user.Role) {
+		h.issueChallenge(c, user, utils.ChallengeTwoFactorSetup)
+		return
+	}
+
+	account := middleware.LoginAccount(user.Email)
+	if err := h.loginGuard.RecordSuccess(c.Request.Context(), account); err != nil {
+		log.Printf("[Login] Failed to clear failed logins of %s: %v", account, err)
+	}
+
+	// Open a session for this device and generate its tokens
+	accessToken, refreshToken, ok := h.startSession(c, user, deviceInfo)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, AuthResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		User:         user,
+	})
+}
+
+// rejectBlockedLogin responds to a login attempt refused by the login guard
+func rejectBlockedLogin(c *gin.Context, block *middleware.LoginBlock) {
+	retryAfter := int(math.Ceil(block.RetryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+
+	switch block.Reason {
+	case middleware.LoginBlockedAccount:
+		c.JSON(http.StatusLocked, gin.H{
+			"error":       "Account is temporarily locked after too many failed logins",
+			"code":        "ACCOUNT_LOCKED",
+			"retry_after": retryAfter,
+		})
+	case middleware.LoginBlockedIP:
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":       "Too many login attempts, please try again later",
+			"code":        "TOO_MANY_ATTEMPTS",
+			"retry_after": retryAfter,
+		})
+	default:
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":       "Please wait before trying again",
+			"code":        "LOGIN_DELAYED",
+			"retry_after": retryAfter,
+		})
+	}
+}
+
+// loginFailed counts a failed login and responds to it with message. The failure that locks
+// the account is written to the audit log; user is nil when no account has that email.
+func (h *AuthHandler) loginFailed(c *gin.Context, account string, user *models.User, message string) {
+	failures, locked, err := h.loginGuard.RecordFailure(c.Request.Context(), account)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate access token"})
+		log.Printf("[Login] Failed to record failed login of %s: %v", account, err)
+	}
+
+	if locked {
+		log.Printf("[Login] Locked %s after %d failed logins, last from %s", account, failures, c.ClientIP())
+		targetID := ""
+		if user != nil {
+			targetID = user.ID.String()
+		}
+		recordAudit(c, h.auditRepo, models.AuditAccountLocked, "user", targetID, gin.H{
+			"email":    account,
+			"failures": failures,
+		})
+		rejectBlockedLogin(c, &middleware.LoginBlock{
+			Reason:     middleware.LoginBlockedAccount,
+			RetryAfter: h.loginGuard.LockoutDuration(),
+		})
 		return
 	}
 
-	refreshToken, expiresAt, err := h.jwtManager.GenerateRefreshToken(user.ID)
+	c.JSON(http.StatusUnauthorized, gin.H{"error": message})
+}
+
+// issueChallenge answers a correct password with a challenge token instead of a session:
+// "2fa" asks for a code, "2fa_setup" asks the user to enroll first
+func (h *AuthHandler) issueChallenge(c *gin.Context, user *models.User, purpose string) {
+	token, expiresAt, err := h.jwtManager.GenerateChallengeToken(user.ID, purpose)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate refresh token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	// Store refresh token
-	refreshTokenRecord := &models.RefreshToken{
-		UserID:    user.ID,
-		Token:     refreshToken,
-		ExpiresAt: expiresAt,
+	flag := "two_factor_required"
+	if purpose == utils.ChallengeTwoFactorSetup {
+		flag = "two_factor_setup_required"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		flag:              true,
+		"challenge_token": token,
+		"expires_at":      expiresAt,
+	})
+}
+
+// challengeUser resolves a challenge token to its active user, responding if that fails
+func (h *AuthHandler) challengeUser(c *gin.Context, token, purpose string) (*models.User, bool) {
+	userID, err := h.jwtManager.ValidateChallengeToken(token, purpose)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login has expired, please log in again", "code": "CHALLENGE_INVALID"})
+		return nil, false
+	}
+	user, err := h.userRepo.FindByID(c.Request.Context(), userID)
+	if err != nil || !user.IsActive {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account is deactivated"})
+		return nil, false
+	}
+	return user, true
+}
+
+// VerifyTwoFactorRequest finishes a login with a TOTP code or a recovery code
+type VerifyTwoFactorRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recovery_code"`
+	DeviceInfo     string `json:"device_info"`
+}
+
+// VerifyTwoFactor is the second login step for users with 2FA enabled. Wrong codes count
+// as failed logins, and each code works only once.
+// POST /api/auth/2fa/verify
+func (h *AuthHandler) VerifyTwoFactor(c *gin.Context) {
+	var req VerifyTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Code == "" && req.RecoveryCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code or recovery_code is required"})
+		return
+	}
+
+	user, ok := h.challengeUser(c, req.ChallengeToken, utils.ChallengeTwoFactor)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	account := middleware.LoginAccount(user.Email)
+	block, err := h.loginGuard.Check(ctx, c.ClientIP(), account)
+	if err != nil {
+		log.Printf("[Login] Login guard unavailable: %v", err)
+	} else if block != nil {
+		rejectBlockedLogin(c, block)
+		return
+	}
+
+	verified, err := h.twoFactor.verify(ctx, user, req.Code, req.RecoveryCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	}
+	if !verified {
+		h.loginFailed(c, account, user, "Invalid verification code")
+		return
 	}
-	if err := h.refreshTokenRepo.Create(c.Request.Context(), refreshTokenRecord); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store refresh token"})
+	if err := h.loginGuard.RecordSuccess(ctx, account); err != nil {
+		log.Printf("[Login] Failed to clear failed logins of %s: %v", account, err)
+	}
+
+	accessToken, refreshToken, ok := h.startSession(c, user, req.DeviceInfo)
+	if !ok {
 		return
 	}
 
@@ -199,7 +371,103 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	})
 }
 
-// Refresh handles token refresh
+// TwoFactorChallengeRequest carries the challenge token of a login that must enroll in 2FA
+type TwoFactorChallengeRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+}
+
+// SetupTwoFactor gives a user whose role requires 2FA a secret to enroll during login
+// POST /api/auth/2fa/setup
+func (h *AuthHandler) SetupTwoFactor(c *gin.Context) {
+	var req TwoFactorChallengeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := h.challengeUser(c, req.ChallengeToken, utils.ChallengeTwoFactorSetup)
+	if !ok {
+		return
+	}
+	if user.TwoFactorEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled, please log in again"})
+		return
+	}
+
+	setup, err := h.twoFactor.beginSetup(c.Request.Context(), user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start two-factor setup"})
+		return
+	}
+
+	c.JSON(http.StatusOK, setup)
+}
+
+// ConfirmTwoFactorSetupRequest finishes enrollment during login
+type ConfirmTwoFactorSetupRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+	DeviceInfo     string `json:"device_info"`
+}
+
+// TwoFactorEnrolledResponse logs the user in and hands out their recovery codes, shown this once only
+type TwoFactorEnrolledResponse struct {
+	AuthResponse
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// ConfirmTwoFactorSetup enables 2FA with the first code from the app and completes the login
+// POST /api/auth/2fa/setup/confirm
+func (h *AuthHandler) ConfirmTwoFactorSetup(c *gin.Context) {
+	var req ConfirmTwoFactorSetupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := h.challengeUser(c, req.ChallengeToken, utils.ChallengeTwoFactorSetup)
+	if !ok {
+		return
+	}
+	if user.TwoFactorEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled, please log in again"})
+		return
+	}
+
+	codes, err := h.twoFactor.confirmSetup(c.Request.Context(), user, req.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+	if codes == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid verification code", "code": "INVALID_2FA_CODE"})
+		return
+	}
+	user.TwoFactorEnabled = true
+
+	account := middleware.LoginAccount(user.Email)
+	if err := h.loginGuard.RecordSuccess(c.Request.Context(), account); err != nil {
+		log.Printf("[Login] Failed to clear failed logins of %s: %v", account, err)
+	}
+
+	accessToken, refreshToken, ok := h.startSession(c, user, req.DeviceInfo)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, TwoFactorEnrolledResponse{
+		AuthResponse: AuthResponse{
+			AccessToken:  accessToken,
+			RefreshToken: refreshToken,
+			User:         user,
+		},
+		RecoveryCodes: codes,
+	})
+}
+
+// Refresh rotates a refresh token: the presented token is marked used and a new one is
+// issued in the same session. Presenting a used token again revokes the whole session,
+// since either the client or an attacker holds a stolen copy.
 // POST /api/auth/refresh
 func (h *AuthHandler) Refresh(c *gin.Context) {
 	var req RefreshRequest
@@ -207,49 +475,84 @@ func (h *AuthHandler) Refresh(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	ctx := c.Request.Context()
 
 	// Validate refresh token
-	tokenRecord, err := h.refreshTokenRepo.FindByToken(c.Request.Context(), req.RefreshToken)
+	tokenRecord, err := h.refreshTokenRepo.FindByToken(ctx, req.RefreshToken)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
 		return
 	}
 
+	var session *models.Session
+	if tokenRecord.SessionID != nil {
+		session, err = h.sessionRepo.FindByID(ctx, *tokenRecord.SessionID)
+		if err != nil || session.RevokedAt != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked", "code": "SESSION_REVOKED"})
+			return
+		}
+	}
+
+	rotated, err := h.refreshTokenRepo.MarkUsed(ctx, tokenRecord.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate refresh token"})
+		return
+	}
+	if !rotated {
+		if session != nil {
+			if err := h.sessionRepo.Revoke(ctx, session.ID, models.SessionTokenReuse); err != nil {
+				log.Printf("[Refresh] Failed to revoke session %s after token reuse: %v", session.ID, err)
+			}
+			if err := h.denylist.RevokeSessions(ctx, session.ID); err != nil {
+				log.Printf("[Refresh] Failed to revoke access tokens of session %s: %v", session.ID, err)
+			}
+		}
+		log.Printf("[Refresh] Reuse of rotated refresh token for user %s from %s", tokenRecord.UserID, c.ClientIP())
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token was already used; please log in again", "code": "TOKEN_REUSED"})
+		return
+	}
+
 	user := tokenRecord.User
 	if user == nil {
-		user, err = h.userRepo.FindByID(c.Request.Context(), tokenRecord.UserID)
+		user, err = h.userRepo.FindByID(ctx, tokenRecord.UserID)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
 			return
 		}
 	}
-
-	// Delete old refresh token
-	_ = h.refreshTokenRepo.DeleteByToken(c.Request.Context(), req.RefreshToken)
-
-	// Generate new tokens
-	accessToken, err := h.jwtManager.GenerateAccessToken(user.ID, user.Email, user.Role, user.EmployeeID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate access token"})
+	if !user.IsActive {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account is deactivated"})
 		return
 	}
-
-	refreshToken, expiresAt, err := h.jwtManager.GenerateRefreshToken(user.ID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate refresh token"})
+	// Sessions that predate the 2FA policy end here, so the user logs in again and enrolls
+	if !user.TwoFactorEnabled && h.twoFactor.required(ctx, user.Role) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Two-factor authentication must be set up, please log in again", "code": "TWO_FACTOR_SETUP_REQUIRED"})
 		return
 	}
 
-	// Store new refresh token
-	newTokenRecord := &models.RefreshToken{
-		UserID:    user.ID,
-		Token:     refreshToken,
-		ExpiresAt: expiresAt,
+	// Tokens issued before sessions existed move into a new session on first use
+	if session == nil {
+		_ = h.refreshTokenRepo.DeleteByToken(ctx, req.RefreshToken)
+		accessToken, refreshToken, ok := h.startSession(c, user, req.DeviceInfo)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, AuthResponse{
+			AccessToken:  accessToken,
+			RefreshToken: refreshToken,
+			User:         user,
+		})
+		return
 	}
-	if err := h.refreshTokenRepo.Create(c.Request.Context(), newTokenRecord); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store refresh token"})
+
+	accessToken, refreshToken, expiresAt, err := h.issueTokens(ctx, user, session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
 		return
 	}
+	if err := h.sessionRepo.Touch(ctx, session.ID, c.ClientIP(), c.Request.UserAgent(), expiresAt); err != nil {
+		log.Printf("[Refresh] Failed to update session %s: %v", session.ID, err)
+	}
 
 	c.JSON(http.StatusOK, AuthResponse{
 		AccessToken:  accessToken,
@@ -258,9 +561,18 @@ func (h *AuthHandler) Refresh(c *gin.Context) {
 	})
 }
 
-// Logout handles user logout
+// Logout ends the session of the given refresh token. The access token sent in the
+// Authorization header, if any, stops working at once.
 // POST /api/auth/logout
 func (h *AuthHandler) Logout(c *gin.Context) {
+	if parts := strings.Fields(c.GetHeader("Authorization")); len(parts) == 2 && strings.EqualFold(parts[0], "bearer") {
+		if claims, err := h.jwtManager.ValidateAccessToken(parts[1]); err == nil {
+			if err := h.denylist.RevokeToken(c.Request.Context(), claims.ID, claims.ExpiresAt.Time); err != nil {
+				log.Printf("[Logout] Failed to revoke access token: %v", err)
+			}
+		}
+	}
+
 	var req RefreshRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		// Even if no refresh token provided, return success
@@ -268,12 +580,74 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 		return
 	}
 
-	// Delete refresh token
-	_ = h.refreshTokenRepo.DeleteByToken(c.Request.Context(), req.RefreshToken)
+	tokenRecord, err := h.refreshTokenRepo.FindByToken(c.Request.Context(), req.RefreshToken)
+	if err == nil && tokenRecord.SessionID != nil {
+		_ = h.sessionRepo.Revoke(c.Request.Context(), *tokenRecord.SessionID, models.SessionLoggedOut)
+		if err := h.denylist.RevokeSessions(c.Request.Context(), *tokenRecord.SessionID); err != nil {
+			log.Printf("[Logout] Failed to revoke access tokens of session %s: %v", *tokenRecord.SessionID, err)
+		}
+	} else {
+		_ = h.refreshTokenRepo.DeleteByToken(c.Request.Context(), req.RefreshToken)
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
 }
 
+// startSession opens a login session for the requesting device and issues its first tokens
+func (h *AuthHandler) startSession(c *gin.Context, user *models.User, deviceInfo string) (string, string, bool) {
+	if deviceInfo == "" {
+		deviceInfo = c.GetHeader("X-Device-Info")
+	}
+
+	now := time.Now()
+	session := &models.Session{
+		ID:         uuid.New(),
+		UserID:     user.ID,
+		DeviceInfo: deviceInfo,
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+		ExpiresAt:  now,
+		LastUsedAt: now,
+	}
+	if err := h.sessionRepo.Create(c.Request.Context(), session); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		return "", "", false
+	}
+
+	accessToken, refreshToken, expiresAt, err := h.issueTokens(c.Request.Context(), user, session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
+		return "", "", false
+	}
+	_ = h.sessionRepo.Touch(c.Request.Context(), session.ID, session.IPAddress, session.UserAgent, expiresAt)
+
+	return accessToken, refreshToken, true
+}
+
+// issueTokens generates an access token and stores the next refresh token of a session
+func (h *AuthHandler) issueTokens(ctx context.Context, user *models.User, sessionID uuid.UUID) (string, string, time.Time, error) {
+	accessToken, err := h.jwtManager.GenerateAccessToken(user.ID, user.Email, user.Role, user.EmployeeID, sessionID, user.MustChangePassword)
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+
+	refreshToken, expiresAt, err := h.jwtManager.GenerateRefreshToken(user.ID)
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+
+	record := &models.RefreshToken{
+		UserID:    user.ID,
+		SessionID: &sessionID,
+		Token:     refreshToken,
+		ExpiresAt: expiresAt,
+	}
+	if err := h.refreshTokenRepo.Create(ctx, record); err != nil {
+		return "", "", time.Time{}, err
+	}
+	return accessToken, refreshToken, expiresAt, nil
+}
+
 // GetCurrentUser returns current user info
 // GET /api/auth/me
 func (h *AuthHandler) GetCurrentUser(c *gin.Context) {
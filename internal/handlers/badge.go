@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/attendance-system/internal/models"
+	"github.com/attendance-system/internal/repository"
+	"github.com/attendance-system/internal/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// BadgeHandler handles rotating QR codes and printed QR badges
+type BadgeHandler struct {
+	badgeRepo    *repository.BadgeRepository
+	userRepo     *repository.UserRepository
+	settingsRepo *repository.SettingsRepository
+	qrSigner     *utils.QRSigner
+}
+
+// NewBadgeHandler creates a new badge handler
+func NewBadgeHandler(
+	badgeRepo *repository.BadgeRepository,
+	userRepo *repository.UserRepository,
+	settingsRepo *repository.SettingsRepository,
+	qrSigner *utils.QRSigner,
+) *BadgeHandler {
+	return &BadgeHandler{
+		badgeRepo:    badgeRepo,
+		userRepo:     userRepo,
+		settingsRepo: settingsRepo,
+		qrSigner:     qrSigner,
+	}
+}
+
+// GetMyQR returns the current rotating QR payload for the logged-in user.
+// The app should refresh it at expires_at.
+// GET /api/users/me/qr
+func (h *BadgeHandler) GetMyQR(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	user, err := h.userRepo.FindByID(c.Request.Context(), userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.EmployeeID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User has no employee ID"})
+		return
+	}
+
+	payload, expiresAt := h.qrSigner.RotatingPayload(user.EmployeeID, time.Now())
+	c.JSON(http.StatusOK, gin.H{
+		"payload":        payload,
+		"expires_at":     expiresAt,
+		"period_seconds": int(h.qrSigner.Period().Seconds()),
+	})
+}
+
+// IssueBadge issues a new printed badge for a user, revoking the previous one
+// POST /api/admin/users/:id/badges
+func (h *BadgeHandler) IssueBadge(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	user, err := h.userRepo.FindByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !officeInScope(c, user.OfficeID) {
+		rejectOutOfScope(c)
+		return
+	}
+
+	if user.EmployeeID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User has no employee ID"})
+		return
+	}
+
+	serial, err := utils.NewBadgeSerial()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate badge serial"})
+		return
+	}
+
+	badge := &models.Badge{
+		UserID: user.ID,
+		Serial: serial,
+	}
+	if issuerID, exists := c.Get("user_id"); exists {
+		issuer := issuerID.(uuid.UUID)
+		badge.IssuedBy = &issuer
+	}
+
+	if err := h.badgeRepo.Issue(c.Request.Context(), badge); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue badge"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Badge issued",
+		"badge":   badge,
+		"payload": h.qrSigner.BadgePayload(user.EmployeeID, badge.Serial),
+	})
+}
+
+// GetUserBadges lists the badges issued to a user
+// GET /api/admin/users/:id/badges
+func (h *BadgeHandler) GetUserBadges(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	user, err := h.userRepo.FindByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !officeInScope(c, user.OfficeID) {
+		rejectOutOfScope(c)
+		return
+	}
+
+	badges, err := h.badgeRepo.FindByUserID(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch badges"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": badges})
+}
+
+// RevokeBadge revokes a printed badge so it can no longer be scanned
+// POST /api/admin/badges/:serial/revoke
+func (h *BadgeHandler) RevokeBadge(c *gin.Context) {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	c.ShouldBindJSON(&req)
+
+	badge, err := h.badgeRepo.FindBySerial(c.Request.Context(), c.Param("serial"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Badge not found"})
+		return
+	}
+
+	if badge.RevokedAt != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Badge already revoked"})
+		return
+	}
+	if badge.User == nil || !officeInScope(c, badge.User.OfficeID) {
+		rejectOutOfScope(c)
+		return
+	}
+
+	now := time.Now()
+	badge.RevokedAt = &now
+	badge.RevokedReason = req.Reason
+	if err := h.badgeRepo.Update(c.Request.Context(), badge); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke badge"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Badge revoked",
+		"badge":   badge,
+	})
+}
+
+// badgeCard is one badge on the printable page
+type badgeCard struct {
+	Name       string
+	EmployeeID string
+	Serial     string
+	QRDataURI  template.URL
+}
+
+var badgePrintTemplate = template.Must(template.New("badges").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Badges</title>
+<style>
+	body { font-family: sans-serif; margin: 0; padding: 10mm; }
+	.grid { display: flex; flex-wrap: wrap; gap: 6mm; }
+	.badge { width: 54mm; height: 86mm; border: 1px solid #999; border-radius: 3mm; padding: 4mm; box-sizing: border-box; text-align: center; page-break-inside: avoid; }
+	.company { font-size: 9pt; font-weight: bold; text-transform: uppercase; }
+	.qr { width: 40mm; height: 40mm; margin: 4mm auto; }
+	.name { font-size: 11pt; font-weight: bold; }
+	.id { font-family: monospace; font-size: 10pt; }
+	.serial { font-family: monospace; font-size: 7pt; color: #666; margin-top: 3mm; }
+	@media print { body { padding: 0; } }
+</style>
+</head>
+<body>
+<div class="grid">
+{{range .Cards}}
+	<div class="badge">
+		<div class="company">{{$.Company}}</div>
+		<img class="qr" src="{{.QRDataURI}}" alt="QR">
+		<div class="name">{{.Name}}</div>
+		<div class="id">{{.EmployeeID}}</div>
+		<div class="serial">S/N {{.Serial}}</div>
+	</div>
+{{end}}
+</div>
+</body>
+</html>`))
+
+// PrintBadges renders active badges as a printable HTML page
+// GET /api/admin/badges/print?serials=SERIAL1,SERIAL2
+func (h *BadgeHandler) PrintBadges(c *gin.Context) {
+	var serials []string
+	for _, serial := range strings.Split(c.Query("serials"), ",") {
+		if serial = strings.TrimSpace(serial); serial != "" {
+			serials = append(serials, serial)
+		}
+	}
+	if len(serials) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "serials is required"})
+		return
+	}
+
+	badges, err := h.badgeRepo.FindBySerials(c.Request.Context(), serials)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch badges"})
+		return
+	}
+
+	cards := make([]badgeCard, 0, len(badges))
+	for _, badge := range badges {
+		if badge.RevokedAt != nil || badge.User == nil || !officeInScope(c, badge.User.OfficeID) {
+			continue
+		}
+
+		png, err := qrcode.Encode(h.qrSigner.BadgePayload(badge.User.EmployeeID, badge.Serial), qrcode.Medium, 256)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render QR code"})
+			return
+		}
+
+		cards = append(cards, badgeCard{
+			Name:       badge.User.Name,
+			EmployeeID: badge.User.EmployeeID,
+			Serial:     badge.Serial,
+			QRDataURI:  template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(png)),
+		})
+	}
+
+	if len(cards) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No active badges found"})
+		return
+	}
+
+	company := ""
+	if setting, err := h.settingsRepo.GetByKey(c.Request.Context(), "company_name"); err == nil && setting != nil {
+		company = setting.Value
+	}
+
+	var page bytes.Buffer
+	if err := badgePrintTemplate.Execute(&page, gin.H{"Company": company, "Cards": cards}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render badges"})
+		return
+	}
+
+	c.Data(http.StatusOK, "text/html; charset=utf-8", page.Bytes())
+}
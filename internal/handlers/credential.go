@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/attendance-system/internal/models"
+	"github.com/attendance-system/internal/repository"
+	"github.com/attendance-system/internal/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// PIN lockout after repeated failures at a kiosk
+const (
+	maxPINAttempts  = 5
+	pinLockDuration = 15 * time.Minute
+)
+
+var (
+	cardUIDPattern = regexp.MustCompile(`^[0-9A-Z]{4,64}$`)
+	pinPattern     = regexp.MustCompile(`^[0-9]{4,8}$`)
+)
+
+// normalizeCardUID strips reader formatting ("04:a2:...", "04-A2 ...") from a card UID
+func normalizeCardUID(uid string) string {
+	uid = strings.ToUpper(uid)
+	return strings.NewReplacer(":", "", "-", "", " ", "").Replace(uid)
+}
+
+// CredentialHandler handles enrollment of kiosk cards and PINs
+type CredentialHandler struct {
+	credentialRepo *repository.CredentialRepository
+	userRepo       *repository.UserRepository
+}
+
+// NewCredentialHandler creates a new credential handler
+func NewCredentialHandler(credentialRepo *repository.CredentialRepository, userRepo *repository.UserRepository) *CredentialHandler {
+	return &CredentialHandler{
+		credentialRepo: credentialRepo,
+		userRepo:       userRepo,
+	}
+}
+
+// CreateCredentialRequest enrolls a card or PIN
+type CreateCredentialRequest struct {
+	Type    string `json:"type" binding:"required,oneof=card pin"`
+	CardUID string `json:"card_uid"`
+	PIN     string `json:"pin"`
+	Label   string `json:"label"`
+}
+
+// CreateCredential enrolls a card UID or sets a PIN for a user.
+// A new PIN replaces the previous one; a card UID can only be active for one user.
+// POST /api/admin/users/:id/credentials
+func (h *CredentialHandler) CreateCredential(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req CreateCredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.userRepo.FindByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !officeInScope(c, user.OfficeID) {
+		rejectOutOfScope(c)
+		return
+	}
+
+	credential := &models.Credential{
+		UserID: user.ID,
+		Type:   req.Type,
+		Label:  req.Label,
+	}
+	if creatorID, exists := c.Get("user_id"); exists {
+		creator := creatorID.(uuid.UUID)
+		credential.CreatedBy = &creator
+	}
+
+	switch req.Type {
+	case models.CredentialCard:
+		uid := normalizeCardUID(req.CardUID)
+		if !cardUIDPattern.MatchString(uid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "card_uid must be 4-64 hex or digit characters"})
+			return
+		}
+		if existing, err := h.credentialRepo.FindActiveCard(c.Request.Context(), uid); err == nil && existing != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Card is already enrolled", "code": "CARD_IN_USE"})
+			return
+		}
+		credential.CardUID = uid
+		err = h.credentialRepo.Create(c.Request.Context(), credential)
+
+	case models.CredentialPIN:
+		if !pinPattern.MatchString(req.PIN) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "pin must be 4-8 digits"})
+			return
+		}
+		hash, hashErr := utils.HashPassword(req.PIN)
+		if hashErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash PIN"})
+			return
+		}
+		credential.PINHash = hash
+		err = h.credentialRepo.ReplacePIN(c.Request.Context(), credential)
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save credential"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":    "Credential enrolled",
+		"credential": credential,
+	})
+}
+
+// GetUserCredentials lists a user's cards and PINs (PIN hashes are never returned)
+// GET /api/admin/users/:id/credentials
+func (h *CredentialHandler) GetUserCredentials(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	user, err := h.userRepo.FindByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !officeInScope(c, user.OfficeID) {
+		rejectOutOfScope(c)
+		return
+	}
+
+	credentials, err := h.credentialRepo.FindByUserID(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch credentials"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": credentials})
+}
+
+// RevokeCredential revokes a card or PIN
+// POST /api/admin/credentials/:id/revoke
+func (h *CredentialHandler) RevokeCredential(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid credential ID"})
+		return
+	}
+
+	credential, err := h.credentialRepo.FindByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Credential not found"})
+		return
+	}
+
+	if credential.RevokedAt != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Credential already revoked"})
+		return
+	}
+	if isOfficeScoped(c) {
+		owner, err := h.userRepo.FindByID(c.Request.Context(), credential.UserID)
+		if err != nil || !officeInScope(c, owner.OfficeID) {
+			rejectOutOfScope(c)
+			return
+		}
+	}
+
+	now := time.Now()
+	credential.RevokedAt = &now
+	if err := h.credentialRepo.Update(c.Request.Context(), credential); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke credential"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Credential revoked",
+		"credential": credential,
+	})
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -20,18 +21,54 @@ import (
 type EmployeeHandler struct {
 	employeeRepo *repository.EmployeeRepository
 	userRepo     *repository.UserRepository
+	invitations  *InvitationIssuer
 }
 
-func NewEmployeeHandler(employeeRepo *repository.EmployeeRepository, userRepo *repository.UserRepository) *EmployeeHandler {
+func NewEmployeeHandler(employeeRepo *repository.EmployeeRepository, userRepo *repository.UserRepository, invitations *InvitationIssuer) *EmployeeHandler {
 	return &EmployeeHandler{
 		employeeRepo: employeeRepo,
 		userRepo:     userRepo,
+		invitations:  invitations,
 	}
 }
 
+// sensitiveEmployeeColumns are the payroll and identity columns guarded by employees.read_sensitive
+var sensitiveEmployeeColumns = map[string]bool{
+	"ktp_number":        true,
+	"bank_account":      true,
+	"bank_name":         true,
+	"bpjs_kesehatan":    true,
+	"bpjs_tenaga_kerja": true,
+	"npwp":              true,
+	"basic_salary":      true,
+}
+
+// redactSensitive clears payroll and identity numbers for callers without employees.read_sensitive
+func redactSensitive(e *models.Employee) {
+	e.KTPNumber = ""
+	e.BankAccount = ""
+	e.BankName = ""
+	e.BPJSKesehatan = ""
+	e.BPJSTenagaKerja = ""
+	e.NPWP = ""
+	e.BasicSalary = 0
+}
+
+// keepSensitive copies the stored payroll and identity numbers onto an update
+func keepSensitive(dst, stored *models.Employee) {
+	dst.KTPNumber = stored.KTPNumber
+	dst.BankAccount = stored.BankAccount
+	dst.BankName = stored.BankName
+	dst.BPJSKesehatan = stored.BPJSKesehatan
+	dst.BPJSTenagaKerja = stored.BPJSTenagaKerja
+	dst.NPWP = stored.NPWP
+	dst.BasicSalary = stored.BasicSalary
+}
+
 // CreateEmployeeRequest represents the payload for creating a new employee
 type CreateEmployeeRequest struct {
-	// If creating a new user account
+	// If creating a new user account. Without a password the employee gets an
+	// invitation link to set their own; with one they must change it on first login.
 	CreateUser bool   `json:"create_user"`
 	Name       string `json:"name"`
 	Email      string `json:"email"`
@@ -52,15 +89,27 @@ func (h *EmployeeHandler) CreateEmployee(c *gin.Context) {
 		return
 	}
 
+	if !officeInScope(c, &req.OfficeID) {
+		rejectOutOfScope(c)
+		return
+	}
+
 	// 1. Handle User Account Creation or Linking
 	var userID *uuid.UUID
+	var newUser *models.User
 	if req.CreateUser {
-		if req.Email == "" || req.Password == "" || req.Name == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Email, Password, and Name are required for new user"})
+		if req.Email == "" || req.Name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Email and Name are required for new user"})
 			return
 		}
 
-		hashedPassword, err := utils.HashPassword(req.Password)
+		var hashedPassword string
+		var err error
+		if req.Password != "" {
+			hashedPassword, err = utils.HashPassword(req.Password)
+		} else {
+			hashedPassword, err = unusablePasswordHash()
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
 			return
@@ -76,6 +125,8 @@ func (h *EmployeeHandler) CreateEmployee(c *gin.Context) {
 			OfficeID:     &req.OfficeID,
 			OfficeLat:    -6.2088, // Default fallback, should be set from Office
 			OfficeLong:   106.8456,
+
+			MustChangePassword: req.Password != "",
 		}
 
 		if err := h.userRepo.Create(c.Request.Context(), user); err != nil {
@@ -83,6 +134,7 @@ func (h *EmployeeHandler) CreateEmployee(c *gin.Context) {
 			return
 		}
 		userID = &user.ID
+		newUser = user
 	} else if req.UserID != nil && *req.UserID != "" {
 		uid, err := uuid.Parse(*req.UserID)
 		if err != nil {
@@ -94,6 +146,9 @@ func (h *EmployeeHandler) CreateEmployee(c *gin.Context) {
 
 	// 2. Prepare Employee Data
 	employee := req.Employee
+	if !hasPermission(c, models.PermEmployeesReadSensitive) {
+		redactSensitive(&employee)
+	}
 	employee.Name = req.Name // Map top-level Name to Employee struct
 	employee.ID = uuid.New()
 	employee.UserID = userID
@@ -117,8 +172,26 @@ func (h *EmployeeHandler) CreateEmployee(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create employee: " + err.Error()})
 		return
 	}
+	auditChange(c, "employee", employee.ID.String(), nil, &employee)
 
-	c.JSON(http.StatusCreated, employee)
+	response := CreateEmployeeResponse{Employee: employee}
+	if newUser != nil && req.Password == "" {
+		link, err := h.invitations.Issue(c.Request.Context(), newUser, callerID(c))
+		if err != nil {
+			// The account exists; the invitation can be resent from the invitations list
+			log.Printf("[CreateEmployee] Failed to invite user %s: %v", newUser.ID, err)
+		}
+		response.Invitation = link
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// CreateEmployeeResponse is the created employee plus, for new accounts without a
+// password, the invitation link to pass on to the employee
+type CreateEmployeeResponse struct {
+	models.Employee
+	Invitation *InvitationLink `json:"invitation,omitempty"`
 }
 
 // UpdateEmployee updates an existing employee
@@ -137,6 +210,21 @@ func (h *EmployeeHandler) UpdateEmployee(c *gin.Context) {
 	}
 
 	req.ID = id
+	stored, err := h.employeeRepo.FindByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Employee not found"})
+		return
+	}
+	if !officeInScope(c, &stored.OfficeID) || !officeInScope(c, &req.OfficeID) {
+		rejectOutOfScope(c)
+		return
+	}
+
+	canSeeSensitive := hasPermission(c, models.PermEmployeesReadSensitive)
+	if !canSeeSensitive {
+		keepSensitive(&req, stored)
+	}
+
 	// Ensure relationship FKs stay correct
 	for i := range req.WorkExperiences {
 		req.WorkExperiences[i].EmployeeID = id
@@ -149,7 +237,11 @@ func (h *EmployeeHandler) UpdateEmployee(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update employee: " + err.Error()})
 		return
 	}
+	auditChange(c, "employee", id.String(), stored, &req)
 
+	if !canSeeSensitive {
+		redactSensitive(&req)
+	}
 	c.JSON(http.StatusOK, req)
 }
 
@@ -167,7 +259,14 @@ func (h *EmployeeHandler) GetEmployee(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Employee not found"})
 		return
 	}
+	if !officeInScope(c, &employee.OfficeID) {
+		rejectOutOfScope(c)
+		return
+	}
 
+	if !hasPermission(c, models.PermEmployeesReadSensitive) {
+		redactSensitive(employee)
+	}
 	c.JSON(http.StatusOK, employee)
 }
 
@@ -196,12 +295,30 @@ func (h *EmployeeHandler) GetAllEmployees(c *gin.Context) {
 		}
 	}
 
+	// Filtering on hidden columns would let the values be guessed
+	canSeeSensitive := hasPermission(c, models.PermEmployeesReadSensitive)
+	if !canSeeSensitive {
+		allowed := filters.DynamicFilters[:0]
+		for _, f := range filters.DynamicFilters {
+			if !sensitiveEmployeeColumns[f.Field] {
+				allowed = append(allowed, f)
+			}
+		}
+		filters.DynamicFilters = allowed
+	}
+
 	employees, total, err := h.employeeRepo.FindAll(c.Request.Context(), filters, limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch employees"})
 		return
 	}
 
+	if !canSeeSensitive {
+		for i := range employees {
+			redactSensitive(&employees[i])
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"data":  employees,
 		"total": total,
@@ -217,10 +334,21 @@ func (h *EmployeeHandler) DeleteEmployee(c *gin.Context) {
 		return
 	}
 
+	employee, err := h.employeeRepo.FindByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Employee not found"})
+		return
+	}
+	if !officeInScope(c, &employee.OfficeID) {
+		rejectOutOfScope(c)
+		return
+	}
+
 	if err := h.employeeRepo.Delete(c.Request.Context(), id); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete employee"})
 		return
 	}
+	auditChange(c, "employee", id.String(), employee, nil)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Employee deleted successfully"})
 }
@@ -235,6 +363,16 @@ func (h *EmployeeHandler) UploadPhoto(c *gin.Context) {
 		return
 	}
 
+	employee, err := h.employeeRepo.FindByID(c.Request.Context(), employeeID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Employee not found"})
+		return
+	}
+	if !officeInScope(c, &employee.OfficeID) {
+		rejectOutOfScope(c)
+		return
+	}
+
 	// Get file from request
 	file, err := c.FormFile("photo")
 	if err != nil {
@@ -262,11 +400,6 @@ func (h *EmployeeHandler) UploadPhoto(c *gin.Context) {
 
 	// Update employee record
 	photoURL := "/uploads/employees/" + filename
-	employee, err := h.employeeRepo.FindByID(c.Request.Context(), employeeID)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Employee not found"})
-		return
-	}
 
 	employee.PhotoURL = photoURL
 	if err := h.employeeRepo.Update(c.Request.Context(), employee); err != nil {
@@ -333,6 +466,7 @@ func (h *EmployeeHandler) ImportEmployees(c *gin.Context) {
 	var importedCount int
 	var skippedCount int
 	var errors []string
+	invitations := []*InvitationLink{}
 
 	for i := 1; i < len(records); i++ {
 		record := records[i]
@@ -377,6 +511,11 @@ func (h *EmployeeHandler) ImportEmployees(c *gin.Context) {
 			skippedCount++
 			continue
 		}
+		if !officeInScope(c, &officeID) {
+			errors = append(errors, fmt.Sprintf("Row %d: Office is outside your administrative scope", i+1))
+			skippedCount++
+			continue
+		}
 
 		// 2. Parse Date
 		joinDate, err := time.Parse("2006-01-02", joinDateStr)
@@ -452,7 +591,13 @@ func (h *EmployeeHandler) ImportEmployees(c *gin.Context) {
 			email = fmt.Sprintf("%s@placeholder.com", nik)
 		}
 
-		hashedPwd, _ := utils.HashPassword("123456") // Default Password
+		// No shared default password: the employee sets their own through an invitation
+		hashedPwd, err := unusablePasswordHash()
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("Row %d: Failed to prepare user account (%v)", i+1, err))
+			skippedCount++
+			continue
+		}
 
 		user := models.User{
 			Name:          name,
@@ -475,6 +620,9 @@ func (h *EmployeeHandler) ImportEmployees(c *gin.Context) {
 		userID := user.ID
 		emp.UserID = &userID
 
+		if !hasPermission(c, models.PermEmployeesReadSensitive) {
+			redactSensitive(&emp)
+		}
 		if err := h.employeeRepo.Create(c.Request.Context(), &emp); err != nil {
 			h.userRepo.Delete(c.Request.Context(), userID) // Rollback User
 			errors = append(errors, fmt.Sprintf("Row %d: Failed to create employee (%v)", i+1, err))
@@ -483,12 +631,20 @@ func (h *EmployeeHandler) ImportEmployees(c *gin.Context) {
 		}
 
 		importedCount++
+
+		link, err := h.invitations.Issue(c.Request.Context(), &user, callerID(c))
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("Row %d: Imported, but the invitation failed (%v); resend it from the invitations list", i+1, err))
+			continue
+		}
+		invitations = append(invitations, link)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"imported": importedCount,
-		"skipped":  skippedCount,
-		"errors":   errors,
+		"imported":    importedCount,
+		"skipped":     skippedCount,
+		"errors":      errors,
+		"invitations": invitations,
 	})
 }
 
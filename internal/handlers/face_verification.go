@@ -169,6 +169,10 @@ func (h *FaceVerificationHandler) ApproveFaceVerification(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
+	if !officeInScope(c, user.OfficeID) {
+		rejectOutOfScope(c)
+		return
+	}
 
 	if user.FaceVerificationStatus != "pending" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "User is not pending verification"})
@@ -198,6 +202,9 @@ func (h *FaceVerificationHandler) ApproveFaceVerification(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
 		return
 	}
+	auditChange(c, "user", user.ID.String(),
+		gin.H{"face_verification_status": "pending"},
+		gin.H{"face_verification_status": "verified", "face_photos": len(photos)})
 
 	// Delete photo files
 	for _, photo := range photos {
@@ -238,6 +245,10 @@ func (h *FaceVerificationHandler) RejectFaceVerification(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
+	if !officeInScope(c, user.OfficeID) {
+		rejectOutOfScope(c)
+		return
+	}
 
 	// Get photos to delete files
 	photos, _ := h.facePhotoRepo.FindByUserID(c.Request.Context(), userID)
@@ -253,8 +264,12 @@ func (h *FaceVerificationHandler) RejectFaceVerification(c *gin.Context) {
 	h.facePhotoRepo.DeleteByUserID(c.Request.Context(), userID)
 
 	// Update user status
+	previousStatus := user.FaceVerificationStatus
 	user.FaceVerificationStatus = "rejected"
 	h.userRepo.Update(c.Request.Context(), user)
+	auditChange(c, "user", user.ID.String(),
+		gin.H{"face_verification_status": previousStatus},
+		gin.H{"face_verification_status": "rejected", "reason": req.Reason})
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Verifikasi ditolak.",
@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/attendance-system/internal/middleware"
+	"github.com/attendance-system/internal/models"
+	"github.com/attendance-system/internal/repository"
+	"github.com/attendance-system/internal/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// InvitationIssuer creates invitation links for accounts whose owners set their own password
+type InvitationIssuer struct {
+	invitationRepo *repository.InvitationRepository
+	ttl            time.Duration
+	baseURL        string
+}
+
+// NewInvitationIssuer creates an issuer whose links point at baseURL and last for ttl
+func NewInvitationIssuer(invitationRepo *repository.InvitationRepository, ttl time.Duration, baseURL string) *InvitationIssuer {
+	return &InvitationIssuer{
+		invitationRepo: invitationRepo,
+		ttl:            ttl,
+		baseURL:        baseURL,
+	}
+}
+
+// InvitationLink is a freshly issued invitation. The URL holds the only copy of the token,
+// so it is shown once and cannot be looked up again; resend to get a new one.
+type InvitationLink struct {
+	InvitationID uuid.UUID `json:"invitation_id"`
+	UserID       uuid.UUID `json:"user_id"`
+	Email        string    `json:"email"`
+	URL          string    `json:"url"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// Issue creates a new invitation for user, replacing any pending one
+func (i *InvitationIssuer) Issue(ctx context.Context, user *models.User, createdBy *uuid.UUID) (*InvitationLink, error) {
+	token, err := utils.NewSecureToken()
+	if err != nil {
+		return nil, err
+	}
+
+	invitation := &models.Invitation{
+		UserID:    user.ID,
+		TokenHash: utils.HashSecureToken(token),
+		ExpiresAt: time.Now().Add(i.ttl),
+		CreatedBy: createdBy,
+	}
+	if err := i.invitationRepo.Issue(ctx, invitation); err != nil {
+		return nil, err
+	}
+
+	return &InvitationLink{
+		InvitationID: invitation.ID,
+		UserID:       user.ID,
+		Email:        user.Email,
+		URL:          i.baseURL + "/invite/" + token,
+		ExpiresAt:    invitation.ExpiresAt,
+	}, nil
+}
+
+// unusablePasswordHash returns a hash no one knows the password for, for accounts
+// that wait for their owner to accept an invitation
+func unusablePasswordHash() (string, error) {
+	secret, err := utils.NewSecureToken()
+	if err != nil {
+		return "", err
+	}
+	return utils.HashPassword(secret)
+}
+
+// callerID returns the logged-in user's ID, or nil outside authenticated routes
+func callerID(c *gin.Context) *uuid.UUID {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		return nil
+	}
+	id := userID.(uuid.UUID)
+	return &id
+}
+
+// InvitationHandler handles issuing, listing, revoking and accepting invitations
+type InvitationHandler struct {
+	invitationRepo *repository.InvitationRepository
+	userRepo       *repository.UserRepository
+	issuer         *InvitationIssuer
+	authorizer     *middleware.Authorizer
+}
+
+// NewInvitationHandler creates a new invitation handler
+func NewInvitationHandler(
+	invitationRepo *repository.InvitationRepository,
+	userRepo *repository.UserRepository,
+	issuer *InvitationIssuer,
+	authorizer *middleware.Authorizer,
+) *InvitationHandler {
+	return &InvitationHandler{
+		invitationRepo: invitationRepo,
+		userRepo:       userRepo,
+		issuer:         issuer,
+		authorizer:     authorizer,
+	}
+}
+
+// InvitationResponse is an invitation as listed to admins
+type InvitationResponse struct {
+	models.Invitation
+	Status string `json:"status"`
+}
+
+// canInvite reports whether the caller may hand out a link that sets user's password.
+// Like editing the user, it needs the user's office in scope and at least the user's access.
+func (h *InvitationHandler) canInvite(c *gin.Context, user *models.User) bool {
+	return officeInScope(c, user.OfficeID) &&
+		canGrantAll(c, h.authorizer.Permissions(c.Request.Context(), user.Role))
+}
+
+// GetInvitations lists invitations, newest first
+// GET /api/admin/invitations?status=pending
+func (h *InvitationHandler) GetInvitations(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	pendingOnly := c.Query("status") == models.InvitationPending
+
+	invitations, total, err := h.invitationRepo.FindAll(c.Request.Context(), pendingOnly, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invitations"})
+		return
+	}
+
+	result := make([]InvitationResponse, len(invitations))
+	for i := range invitations {
+		result[i] = InvitationResponse{
+			Invitation: invitations[i],
+			Status:     invitations[i].Status(),
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  result,
+		"total": total,
+	})
+}
+
+// InviteUser issues a new invitation link for a user, replacing any pending one
+// POST /api/admin/users/:id/invitation
+func (h *InvitationHandler) InviteUser(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	user, err := h.userRepo.FindByID(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !user.IsActive {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot invite a deactivated user"})
+		return
+	}
+	if !h.canInvite(c, user) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot invite this user", "code": "PERMISSION_DENIED"})
+		return
+	}
+
+	link, err := h.issuer.Issue(c.Request.Context(), user, callerID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitation"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, link)
+}
+
+// ResendInvitationsRequest picks the users to resend to; empty means everyone who
+// was invited and has not accepted yet
+type ResendInvitationsRequest struct {
+	UserIDs []uuid.UUID `json:"user_ids"`
+}
+
+// ResendInvitations issues fresh links in bulk. Earlier links of the same users stop working.
+// POST /api/admin/invitations/resend
+func (h *InvitationHandler) ResendInvitations(c *gin.Context) {
+	var req ResendInvitationsRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var users []models.User
+	if len(req.UserIDs) == 0 {
+		awaiting, err := h.invitationRepo.FindUsersAwaitingInvitation(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invited users"})
+			return
+		}
+		users = awaiting
+	} else {
+		for _, id := range req.UserIDs {
+			user, err := h.userRepo.FindByID(c.Request.Context(), id)
+			if err != nil {
+				continue
+			}
+			users = append(users, *user)
+		}
+	}
+
+	links := make([]*InvitationLink, 0, len(users))
+	skipped := make([]gin.H, 0)
+	for i := range users {
+		user := &users[i]
+		if !user.IsActive || !h.canInvite(c, user) {
+			skipped = append(skipped, gin.H{"user_id": user.ID, "reason": "not allowed"})
+			continue
+		}
+		link, err := h.issuer.Issue(c.Request.Context(), user, callerID(c))
+		if err != nil {
+			skipped = append(skipped, gin.H{"user_id": user.ID, "reason": "failed to create invitation"})
+			continue
+		}
+		links = append(links, link)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sent":        len(links),
+		"invitations": links,
+		"skipped":     skipped,
+	})
+}
+
+// RevokeInvitation invalidates a pending invitation
+// DELETE /api/admin/invitations/:id
+func (h *InvitationHandler) RevokeInvitation(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID"})
+		return
+	}
+
+	invitation, err := h.invitationRepo.FindByID(c.Request.Context(), id)
+	if err != nil || invitation.User == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return
+	}
+	if !officeInScope(c, invitation.User.OfficeID) {
+		rejectOutOfScope(c)
+		return
+	}
+
+	if err := h.invitationRepo.Revoke(c.Request.Context(), invitation.ID); err != nil {
+		if errors.Is(err, repository.ErrInvitationUnavailable) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invitation was already used or revoked"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invitation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation revoked"})
+}
+
+// GetInvitation shows who an invitation link is for, so the sign-up page can greet them
+// GET /api/auth/invitations/:token
+func (h *InvitationHandler) GetInvitation(c *gin.Context) {
+	invitation, err := h.invitationRepo.FindPendingByTokenHash(c.Request.Context(), utils.HashSecureToken(c.Param("token")))
+	if err != nil || invitation.User == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation is invalid or has expired", "code": "INVITATION_INVALID"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"name":       invitation.User.Name,
+		"email":      invitation.User.Email,
+		"expires_at": invitation.ExpiresAt,
+	})
+}
+
+// AcceptInvitationRequest sets the password of an invited account
+type AcceptInvitationRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+// AcceptInvitation sets the invited user's password; they log in normally afterwards
+// POST /api/auth/invitations/accept
+func (h *InvitationHandler) AcceptInvitation(c *gin.Context) {
+	var req AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	invitation, err := h.invitationRepo.FindPendingByTokenHash(c.Request.Context(), utils.HashSecureToken(req.Token))
+	if err != nil || invitation.User == nil || !invitation.User.IsActive {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation is invalid or has expired", "code": "INVITATION_INVALID"})
+		return
+	}
+
+	hashedPassword, err := utils.HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	if err := h.invitationRepo.Accept(c.Request.Context(), invitation, hashedPassword); err != nil {
+		if errors.Is(err, repository.ErrInvitationUnavailable) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invitation is invalid or has expired", "code": "INVITATION_INVALID"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept invitation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Password set, you can now log in",
+		"email":   invitation.User.Email,
+	})
+}
@@ -2,10 +2,16 @@ package handlers
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"math"
 	"net/http"
 	"os"
@@ -14,8 +20,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/attendance-system/internal/middleware"
 	"github.com/attendance-system/internal/models"
 	"github.com/attendance-system/internal/repository"
+	"github.com/attendance-system/internal/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
@@ -27,6 +35,11 @@ type KioskHandler struct {
 	settingsRepo   *repository.SettingsRepository
 	kioskRepo      *repository.KioskRepository
 	facePhotoRepo  *repository.FacePhotoRepository
+	badgeRepo      *repository.BadgeRepository
+	credentialRepo *repository.CredentialRepository
+	qrSigner       *utils.QRSigner
+	ticketSigner   *utils.TicketSigner
+	identifyLimit  *middleware.RateLimiter // Identify calls per kiosk and per address
 	wsHub          *WebSocketHub
 }
 
@@ -37,6 +50,11 @@ func NewKioskHandler(
 	settingsRepo *repository.SettingsRepository,
 	kioskRepo *repository.KioskRepository,
 	facePhotoRepo *repository.FacePhotoRepository,
+	badgeRepo *repository.BadgeRepository,
+	credentialRepo *repository.CredentialRepository,
+	qrSigner *utils.QRSigner,
+	ticketSigner *utils.TicketSigner,
+	identifyLimit *middleware.RateLimiter,
 	wsHub *WebSocketHub,
 ) *KioskHandler {
 	return &KioskHandler{
@@ -45,13 +63,53 @@ func NewKioskHandler(
 		settingsRepo:   settingsRepo,
 		kioskRepo:      kioskRepo,
 		facePhotoRepo:  facePhotoRepo,
+		badgeRepo:      badgeRepo,
+		credentialRepo: credentialRepo,
+		qrSigner:       qrSigner,
+		ticketSigner:   ticketSigner,
+		identifyLimit:  identifyLimit,
 		wsHub:          wsHub,
 	}
 }
 
-// ScanQRRequest represents QR code scan payload
+// ScanQRRequest represents QR code scan payload.
+// EmployeeID carries the scanned content: a signed rotating code or badge payload,
+// or a raw employee ID when the kiosk_allow_plain_qr setting is "true".
 type ScanQRRequest struct {
 	EmployeeID string `json:"employee_id" binding:"required"`
+	KioskID    string `json:"kiosk_id"` // Picks the office factor policy; the global one applies without it
+}
+
+// resolveScannedQR verifies a scanned QR value and returns the employee ID it identifies.
+// On failure it returns an error code and a user-facing message.
+func (h *KioskHandler) resolveScannedQR(ctx context.Context, scanned string) (employeeID, code, message string) {
+	if !utils.IsSignedQRPayload(scanned) {
+		setting, _ := h.settingsRepo.GetByKey(ctx, "kiosk_allow_plain_qr")
+		if setting != nil && setting.Value == "true" {
+			return scanned, "", ""
+		}
+		return "", "QR_NOT_SIGNED", "QR code tidak valid. Gunakan QR dari aplikasi atau badge resmi"
+	}
+
+	claims, err := h.qrSigner.Verify(scanned, time.Now())
+	if errors.Is(err, utils.ErrQRExpired) {
+		return "", "QR_EXPIRED", "QR code sudah kedaluwarsa, silakan muat ulang di aplikasi"
+	}
+	if err != nil {
+		return "", "QR_INVALID", "QR code tidak valid"
+	}
+
+	if claims.Kind == utils.QRKindBadge {
+		badge, err := h.badgeRepo.FindBySerial(ctx, claims.Serial)
+		if err != nil || badge.User == nil || badge.User.EmployeeID != claims.EmployeeID {
+			return "", "QR_INVALID", "QR code tidak valid"
+		}
+		if badge.RevokedAt != nil {
+			return "", "BADGE_REVOKED", "Badge sudah dicabut, silakan hubungi Admin"
+		}
+	}
+
+	return claims.EmployeeID, "", ""
 }
 
 // ScanQRResponse returns employee info after QR scan
@@ -62,7 +120,9 @@ type ScanQRResponse struct {
 	HasFaceData    bool     `json:"has_face_data"`
 	TodayStatus    string   `json:"today_status"` // not_checked_in, checked_in, checked_out
 	CheckInTime    *string  `json:"check_in_time,omitempty"`
-	FaceEmbeddings [][]float64 `json:"face_embeddings,omitempty"`
+	FaceEmbeddings [][]float64 `json:"face_embeddings,omitempty"` // Only when the factor policy does not use the face
+	Ticket         string   `json:"ticket,omitempty"`        // Proof of the factors passed so far, sent on to verify-face and check-in
+	RequiresFace   bool     `json:"requires_face,omitempty"` // Face verification is still needed by the office factor policy
 }
 
 // ScanQR verifies QR code and returns employee info
@@ -74,8 +134,18 @@ func (h *KioskHandler) ScanQR(c *gin.Context) {
 		return
 	}
 
+	employeeID, code, message := h.resolveScannedQR(c.Request.Context(), req.EmployeeID)
+	if code != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   message,
+			"code":    code,
+		})
+		return
+	}
+
 	// Find user by employee ID
-	user, err := h.userRepo.FindByEmployeeID(c.Request.Context(), req.EmployeeID)
+	user, err := h.userRepo.FindByEmployeeID(c.Request.Context(), employeeID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"success": false,
@@ -92,13 +162,20 @@ func (h *KioskHandler) ScanQR(c *gin.Context) {
 		return
 	}
 
+	response := h.buildScanResponse(c.Request.Context(), user, h.kioskFactorPolicy(c.Request.Context(), req.KioskID))
+	response.Ticket = h.ticketSigner.Issue(user.EmployeeID, []string{utils.FactorQR}, time.Now())
+	c.JSON(http.StatusOK, response)
+}
+
+// buildScanResponse returns employee info and today's status after identification
+func (h *KioskHandler) buildScanResponse(ctx context.Context, user *models.User, policy [][]string) ScanQRResponse {
 	// Check face verification status
 	hasFaceData := user.FaceVerificationStatus == "verified" && len(user.FaceEmbeddings) > 0
 
 	// Get today's attendance status
 	todayStatus := "not_checked_in"
 	var checkInTime *string
-	attendance, _ := h.attendanceRepo.FindTodayByUserID(c.Request.Context(), user.ID)
+	attendance, _ := h.attendanceRepo.FindTodayByUserID(ctx, user.ID)
 	if attendance != nil {
 		if attendance.CheckOutTime != nil {
 			todayStatus = "checked_out"
@@ -118,21 +195,288 @@ func (h *KioskHandler) ScanQR(c *gin.Context) {
 		CheckInTime: checkInTime,
 	}
 
-	// Include face embeddings for client-side matching
-	if hasFaceData {
+	// Include face embeddings for client-side matching, unless the face is a factor: then
+	// they would let any caller present the employee's face without having it
+	if hasFaceData && !policyUsesFace(policy) {
 		response.FaceEmbeddings = user.FaceEmbeddings
 	}
 
+	return response
+}
+
+// defaultKioskFactors is what identification assumes when no policy is configured:
+// every identifier must be followed by face verification
+var defaultKioskFactors = []string{"qr+face", "card+face", "pin+face"}
+
+// configuredFactorPolicy returns the factor combos accepted at the kiosk's office:
+// the office's kiosk_factors, else the comma-separated kiosk_factor_policy setting.
+// Nil means no policy is configured and check-ins are not enforced.
+func (h *KioskHandler) configuredFactorPolicy(ctx context.Context, kiosk *models.Kiosk) [][]string {
+	var combos []string
+	if kiosk != nil && kiosk.Office != nil && len(kiosk.Office.KioskFactors) > 0 {
+		combos = kiosk.Office.KioskFactors
+	} else if setting, _ := h.settingsRepo.GetByKey(ctx, "kiosk_factor_policy"); setting != nil && setting.Value != "" {
+		combos = strings.Split(setting.Value, ",")
+	}
+
+	var policy [][]string
+	for _, combo := range combos {
+		if utils.IsValidFactorCombo(combo) {
+			policy = append(policy, utils.ParseFactorCombo(combo))
+		}
+	}
+	return policy
+}
+
+// kioskFactorPolicy returns the combos accepted at a kiosk, or defaultKioskFactors when
+// no policy is configured. An unknown kiosk gets the policy of no office.
+func (h *KioskHandler) kioskFactorPolicy(ctx context.Context, kioskID string) [][]string {
+	var kiosk *models.Kiosk
+	if kioskID != "" {
+		kiosk, _ = h.kioskRepo.FindByKioskID(ctx, kioskID)
+	}
+	return h.effectiveFactorPolicy(ctx, kiosk)
+}
+
+// effectiveFactorPolicy is configuredFactorPolicy falling back to defaultKioskFactors
+func (h *KioskHandler) effectiveFactorPolicy(ctx context.Context, kiosk *models.Kiosk) [][]string {
+	policy := h.configuredFactorPolicy(ctx, kiosk)
+	if len(policy) == 0 {
+		for _, combo := range defaultKioskFactors {
+			policy = append(policy, utils.ParseFactorCombo(combo))
+		}
+	}
+	return policy
+}
+
+// policyUsesFace reports whether any accepted combo includes the face factor
+func policyUsesFace(policy [][]string) bool {
+	for _, combo := range policy {
+		for _, factor := range combo {
+			if factor == utils.FactorFace {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// factorPolicySatisfied reports whether the ticket covers at least one accepted combo
+func factorPolicySatisfied(policy [][]string, ticket *utils.KioskTicket) bool {
+	for _, combo := range policy {
+		if ticket.HasFactors(combo) {
+			return true
+		}
+	}
+	return false
+}
+
+// factorPolicyAllows reports whether the presented factors are part of at least one accepted combo
+func factorPolicyAllows(policy [][]string, factors []string) bool {
+	for _, combo := range policy {
+		ticket := utils.KioskTicket{Factors: combo}
+		if ticket.HasFactors(factors) {
+			return true
+		}
+	}
+	return false
+}
+
+// identificationFactors are the factors that identify an employee at a kiosk. Every ticket
+// a punch uses carries at least one, so it was issued by ScanQR or Identify.
+var identificationFactors = []string{utils.FactorQR, utils.FactorCard, utils.FactorPIN}
+
+// hasIdentificationFactor reports whether a ticket came from identifying the employee,
+// not only from a face match
+func hasIdentificationFactor(ticket *utils.KioskTicket) bool {
+	for _, factor := range identificationFactors {
+		if ticket.HasFactors([]string{factor}) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkKioskFactors checks the ticket of a kiosk check-in or check-out: it must be valid,
+// issued for the employee by ScanQR or Identify, and meet the office factor policy when
+// one is configured. Punches by bare employee ID are refused.
+func (h *KioskHandler) checkKioskFactors(ctx context.Context, kiosk *models.Kiosk, employeeID, token string) (code, message string) {
+	ticket, err := h.ticketSigner.Verify(token, time.Now())
+	if err != nil || ticket.EmployeeID != employeeID || !hasIdentificationFactor(ticket) {
+		return "TICKET_REQUIRED", "Sesi identifikasi tidak valid, silakan ulangi"
+	}
+
+	policy := h.configuredFactorPolicy(ctx, kiosk)
+	if len(policy) > 0 && !factorPolicySatisfied(policy, ticket) {
+		return "FACTORS_NOT_SATISFIED", "Metode identifikasi belum memenuhi kebijakan kantor"
+	}
+	return "", ""
+}
+
+// upgradeTicket adds the face factor to a ticket after the server matched a face it
+// extracted itself. A missing or foreign ticket gets nothing: a face match alone cannot punch.
+func (h *KioskHandler) upgradeTicket(token, employeeID string) string {
+	ticket, err := h.ticketSigner.Verify(token, time.Now())
+	if err != nil || ticket.EmployeeID != employeeID || !hasIdentificationFactor(ticket) {
+		return ""
+	}
+	return h.ticketSigner.Issue(employeeID, append(ticket.Factors, utils.FactorFace), time.Now())
+}
+
+// KioskIdentifyRequest identifies an employee by card tap and/or PIN.
+// A PIN without a card needs the employee ID to know whose PIN to check.
+type KioskIdentifyRequest struct {
+	KioskID    string `json:"kiosk_id" binding:"required"`
+	CardUID    string `json:"card_uid"`
+	EmployeeID string `json:"employee_id"`
+	PIN        string `json:"pin"`
+}
+
+// Identify identifies an employee by RFID/NFC card and/or PIN, as an alternative to QR.
+// The returned ticket feeds verify-face and check-in like the one from ScanQR.
+// POST /api/kiosk/identify
+func (h *KioskHandler) Identify(c *gin.Context) {
+	var req KioskIdentifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if !h.allowIdentify(c, req.KioskID) {
+		return
+	}
+	kiosk, err := h.kioskRepo.FindByKioskID(ctx, req.KioskID)
+	if err != nil || kiosk == nil || !kiosk.IsActive {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Perangkat Kiosk tidak terdaftar atau tidak aktif"})
+		return
+	}
+	_ = h.kioskRepo.UpdateLastSeen(ctx, kiosk.ID)
+
+	var factors []string
+	if req.CardUID != "" {
+		factors = append(factors, utils.FactorCard)
+	}
+	if req.PIN != "" {
+		factors = append(factors, utils.FactorPIN)
+	}
+	if len(factors) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "card_uid or pin is required"})
+		return
+	}
+
+	policy := h.effectiveFactorPolicy(ctx, kiosk)
+	if !factorPolicyAllows(policy, factors) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Metode identifikasi ini tidak diizinkan di kantor ini", "code": "FACTOR_NOT_ALLOWED"})
+		return
+	}
+
+	// Resolve the user from the card, or from the employee ID for PIN-only entry
+	var user *models.User
+	if req.CardUID != "" {
+		card, err := h.credentialRepo.FindActiveCard(ctx, normalizeCardUID(req.CardUID))
+		if err != nil || card.User == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Kartu tidak terdaftar", "code": "CARD_NOT_FOUND"})
+			return
+		}
+		now := time.Now()
+		card.LastUsedAt = &now
+		_ = h.credentialRepo.Update(ctx, card)
+		user = card.User
+	} else {
+		user, err = h.userRepo.FindByEmployeeID(ctx, req.EmployeeID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Karyawan tidak ditemukan"})
+			return
+		}
+	}
+
+	if req.PIN != "" {
+		if code, message, status := h.verifyKioskPIN(ctx, user.ID, req.PIN); code != "" {
+			c.JSON(status, gin.H{"error": message, "code": code})
+			return
+		}
+	}
+
+	if !user.IsActive {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Akun tidak aktif"})
+		return
+	}
+
+	ticket := h.ticketSigner.Issue(user.EmployeeID, factors, time.Now())
+	parsed, _ := h.ticketSigner.Verify(ticket, time.Now())
+
+	response := h.buildScanResponse(ctx, user, policy)
+	response.Ticket = ticket
+	response.RequiresFace = !factorPolicySatisfied(policy, parsed)
 	c.JSON(http.StatusOK, response)
 }
 
+// allowIdentify counts an identification against the limits for the kiosk and the
+// caller's address. Kiosk IDs are not secret and card UIDs can be guessed, so without
+// them anyone could enumerate cards. Like the API key limit, it is best effort while
+// Redis is down.
+func (h *KioskHandler) allowIdentify(c *gin.Context, kioskID string) bool {
+	if h.identifyLimit == nil {
+		return true
+	}
+	for _, key := range []string{"kiosk:" + kioskID, "ip:" + c.ClientIP()} {
+		retryAfter, err := h.identifyLimit.Allow(c.Request.Context(), key)
+		if err != nil {
+			log.Printf("[Kiosk] Identify rate limit unavailable: %v", err)
+			return true
+		}
+		if retryAfter > 0 {
+			seconds := int(math.Ceil(retryAfter.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Terlalu banyak percobaan, coba lagi nanti", "code": "RATE_LIMITED", "retry_after": seconds})
+			return false
+		}
+	}
+	return true
+}
+
+// verifyKioskPIN checks a PIN and applies the lockout after repeated failures
+func (h *KioskHandler) verifyKioskPIN(ctx context.Context, userID uuid.UUID, pin string) (code, message string, status int) {
+	credential, err := h.credentialRepo.FindActivePIN(ctx, userID)
+	if err != nil {
+		return "PIN_NOT_SET", "PIN belum terdaftar", http.StatusNotFound
+	}
+
+	now := time.Now()
+	if credential.LockedUntil != nil && now.Before(*credential.LockedUntil) {
+		return "PIN_LOCKED", "PIN terkunci sementara, coba lagi nanti", http.StatusLocked
+	}
+
+	if !utils.CheckPasswordHash(pin, credential.PINHash) {
+		credential.FailedAttempts++
+		if credential.FailedAttempts >= maxPINAttempts {
+			lockedUntil := now.Add(pinLockDuration)
+			credential.LockedUntil = &lockedUntil
+			credential.FailedAttempts = 0
+		}
+		_ = h.credentialRepo.Update(ctx, credential)
+		return "PIN_INVALID", "PIN salah", http.StatusUnauthorized
+	}
+
+	credential.FailedAttempts = 0
+	credential.LockedUntil = nil
+	credential.LastUsedAt = &now
+	_ = h.credentialRepo.Update(ctx, credential)
+	return "", "", 0
+}
+
 // VerifyFaceRequest represents face verification payload
 type VerifyFaceRequest struct {
 	EmployeeID     string    `json:"employee_id" binding:"required"`
 	FaceEmbedding  []float64 `json:"face_embedding" binding:"required"`
+	KioskID        string    `json:"kiosk_id"` // Picks the office factor policy; the global one applies without it
 }
 
-// VerifyFace compares face embedding with stored data
+// VerifyFace compares a client-computed face embedding with stored data. The caller
+// controls the embedding, so a match here never counts as the face factor; kiosks
+// enforcing it use VerifyFaceImage. The distance is withheld when the policy uses the
+// face, so callers cannot probe how close a guess is.
 // POST /api/kiosk/verify-face
 func (h *KioskHandler) VerifyFace(c *gin.Context) {
 	var req VerifyFaceRequest
@@ -168,17 +512,22 @@ func (h *KioskHandler) VerifyFace(c *gin.Context) {
 
 	matched := minDistance < threshold
 
-	c.JSON(http.StatusOK, gin.H{
-		"success":  matched,
-		"distance": minDistance,
-		"message":  ternary(matched, "Wajah terverifikasi", "Wajah tidak cocok"),
-	})
+	response := gin.H{
+		"success": matched,
+		"message": ternary(matched, "Wajah terverifikasi", "Wajah tidak cocok"),
+	}
+	if !policyUsesFace(h.kioskFactorPolicy(c.Request.Context(), req.KioskID)) {
+		response["distance"] = minDistance
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 // VerifyFaceImageRequest represents face verification with base64 image
 type VerifyFaceImageRequest struct {
 	EmployeeID  string `json:"employee_id" binding:"required"`
 	ImageBase64 string `json:"image_base64" binding:"required"`
+	Ticket      string `json:"ticket"`   // Ticket from scan/identify, upgraded with the face factor on match
+	KioskID     string `json:"kiosk_id"` // Picks the office factor policy; the global one applies without it
 }
 
 // VerifyFaceImage verifies face from base64 webcam capture
@@ -299,20 +648,109 @@ func (h *KioskHandler) VerifyFaceImage(c *gin.Context) {
 		similarity = 0
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"success":    matched,
-		"match":      matched,
-		"distance":   minDistance,
-		"similarity": similarity,
-		"threshold":  threshold,
-		"message":    ternary(matched, "Wajah terverifikasi", "Wajah tidak cocok"),
-	})
+	response := gin.H{
+		"success": matched,
+		"match":   matched,
+		"message": ternary(matched, "Wajah terverifikasi", "Wajah tidak cocok"),
+	}
+	// How close a miss came would help tune a spoof, so it is only shown when the face is not a factor
+	if !policyUsesFace(h.kioskFactorPolicy(c.Request.Context(), req.KioskID)) {
+		response["distance"] = minDistance
+		response["similarity"] = similarity
+		response["threshold"] = threshold
+	}
+	if matched {
+		if ticket := h.upgradeTicket(req.Ticket, user.EmployeeID); ticket != "" {
+			response["ticket"] = ticket
+		}
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 // KioskCheckInRequest represents kiosk check-in payload
 type KioskCheckInRequest struct {
 	EmployeeID string `json:"employee_id" binding:"required"`
 	KioskID    string `json:"kiosk_id"`
+	Ticket     string `json:"ticket"` // From scan/identify (and verify-face), bound to EmployeeID; required
+}
+
+// kioskPunch describes where a kiosk punch happened relative to the employee's home office
+type kioskPunch struct {
+	OfficeID *uuid.UUID
+	Lat      float64
+	Long     float64
+	IsVisit  bool
+	Flagged  bool
+	Rejected bool
+	Reason   string
+}
+
+// resolveKioskPunch applies the cross-office policy of the kiosk's office to a punch by user.
+// The office's own policy wins over the kiosk_cross_office_policy setting (default visit).
+// Without a known kiosk office the punch falls back to the user's office location.
+// A user without a home office is foreign to every office.
+func (h *KioskHandler) resolveKioskPunch(ctx context.Context, kiosk *models.Kiosk, user *models.User) kioskPunch {
+	punch := kioskPunch{Lat: user.OfficeLat, Long: user.OfficeLong}
+	if kiosk == nil || kiosk.Office == nil {
+		return punch
+	}
+
+	office := kiosk.Office
+	punch.OfficeID = &office.ID
+	punch.Lat, punch.Long = office.Latitude, office.Longitude
+	if user.OfficeID != nil && *user.OfficeID == office.ID {
+		return punch
+	}
+
+	policy := office.CrossOfficePolicy
+	if policy == "" {
+		policy = CrossOfficeVisit
+		setting, _ := h.settingsRepo.GetByKey(ctx, "kiosk_cross_office_policy")
+		if setting != nil && setting.Value != "" && isValidCrossOfficePolicy(setting.Value) {
+			policy = setting.Value
+		}
+	}
+
+	punch.IsVisit = true
+	punch.Reason = "Absen di kiosk " + office.Name + ", di luar kantor asal karyawan"
+	if user.OfficeID == nil {
+		punch.Reason = "Absen di kiosk " + office.Name + ", karyawan belum memiliki kantor asal"
+	}
+	switch policy {
+	case CrossOfficeReject:
+		punch.Rejected = true
+	case CrossOfficeFlag:
+		punch.Flagged = true
+	}
+	return punch
+}
+
+// applyCheckIn records the punch office and visit state on a new check-in
+func (p kioskPunch) applyCheckIn(attendance *models.Attendance) {
+	lat, long := p.Lat, p.Long
+	attendance.CheckInOfficeID = p.OfficeID
+	attendance.CheckInLat = &lat
+	attendance.CheckInLong = &long
+	p.applyVisit(attendance)
+}
+
+// applyCheckOut records the punch office and visit state on a check-out
+func (p kioskPunch) applyCheckOut(attendance *models.Attendance) {
+	lat, long := p.Lat, p.Long
+	attendance.CheckOutOfficeID = p.OfficeID
+	attendance.CheckOutLat = &lat
+	attendance.CheckOutLong = &long
+	p.applyVisit(attendance)
+}
+
+func (p kioskPunch) applyVisit(attendance *models.Attendance) {
+	if p.IsVisit {
+		attendance.IsVisit = true
+	}
+	if p.Flagged {
+		attendance.IsFlagged = true
+		attendance.FlagReason = p.Reason
+	}
 }
 
 // KioskCheckIn records attendance via kiosk
@@ -348,19 +786,29 @@ func (h *KioskHandler) KioskCheckIn(c *gin.Context) {
 		return
 	}
 
+	if code, message := h.checkKioskFactors(c.Request.Context(), kiosk, user.EmployeeID, req.Ticket); code != "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": message, "code": code})
+		return
+	}
+
+	punch := h.resolveKioskPunch(c.Request.Context(), kiosk, user)
+	if punch.Rejected {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Karyawan tidak terdaftar di kantor ini", "code": "CROSS_OFFICE_NOT_ALLOWED"})
+		return
+	}
+
 	// Determine if late
 	now := time.Now()
 	isLate := now.Hour() >= 9 && now.Minute() > 0
 
-	// Use user's office location for kiosk check-in
+	// Record the kiosk's office location for kiosk check-in
 	attendance := &models.Attendance{
 		UserID:      user.ID,
 		CheckInTime: &now,
-		CheckInLat:  &user.OfficeLat,
-		CheckInLong: &user.OfficeLong,
 		DeviceInfo:  "Kiosk: " + req.KioskID,
 		IsLate:      isLate,
 	}
+	punch.applyCheckIn(attendance)
 
 	if err := h.attendanceRepo.Create(c.Request.Context(), attendance); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record check-in"})
@@ -370,12 +818,14 @@ func (h *KioskHandler) KioskCheckIn(c *gin.Context) {
 	// Broadcast to WebSocket
 	if h.wsHub != nil {
 		h.wsHub.BroadcastAttendanceUpdate(AttendanceEvent{
-			Type:       "check_in",
-			UserID:     user.ID,
-			UserName:   user.Name,
-			EmployeeID: user.EmployeeID,
-			Time:       now,
-			IsLate:     isLate,
+			Type:          "check_in",
+			UserID:        user.ID,
+			UserName:      user.Name,
+			EmployeeID:    user.EmployeeID,
+			Time:          now,
+			IsLate:        isLate,
+			OfficeID:      user.OfficeID,
+			PunchOfficeID: attendance.CheckInOfficeID,
 		})
 	}
 
@@ -385,6 +835,7 @@ func (h *KioskHandler) KioskCheckIn(c *gin.Context) {
 		"name":       user.Name,
 		"time":       now.Format("15:04:05"),
 		"is_late":    isLate,
+		"is_visit":   punch.IsVisit,
 		"attendance": attendance,
 	})
 }
@@ -424,11 +875,21 @@ func (h *KioskHandler) KioskCheckOut(c *gin.Context) {
 		return
 	}
 
+	if code, message := h.checkKioskFactors(c.Request.Context(), kiosk, user.EmployeeID, req.Ticket); code != "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": message, "code": code})
+		return
+	}
+
+	punch := h.resolveKioskPunch(c.Request.Context(), kiosk, user)
+	if punch.Rejected {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Karyawan tidak terdaftar di kantor ini", "code": "CROSS_OFFICE_NOT_ALLOWED"})
+		return
+	}
+
 	// Update checkout
 	now := time.Now()
 	attendance.CheckOutTime = &now
-	attendance.CheckOutLat = &user.OfficeLat
-	attendance.CheckOutLong = &user.OfficeLong
+	punch.applyCheckOut(attendance)
 
 	if err := h.attendanceRepo.Update(c.Request.Context(), attendance); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record check-out"})
@@ -438,11 +899,13 @@ func (h *KioskHandler) KioskCheckOut(c *gin.Context) {
 	// Broadcast
 	if h.wsHub != nil {
 		h.wsHub.BroadcastAttendanceUpdate(AttendanceEvent{
-			Type:       "check_out",
-			UserID:     user.ID,
-			UserName:   user.Name,
-			EmployeeID: user.EmployeeID,
-			Time:       now,
+			Type:          "check_out",
+			UserID:        user.ID,
+			UserName:      user.Name,
+			EmployeeID:    user.EmployeeID,
+			Time:          now,
+			OfficeID:      user.OfficeID,
+			PunchOfficeID: attendance.CheckOutOfficeID,
 		})
 	}
 
@@ -451,6 +914,7 @@ func (h *KioskHandler) KioskCheckOut(c *gin.Context) {
 		"message":    "Check-out berhasil!",
 		"name":       user.Name,
 		"time":       now.Format("15:04:05"),
+		"is_visit":   punch.IsVisit,
 		"attendance": attendance,
 	})
 }
@@ -558,10 +1022,31 @@ func (h *KioskHandler) GetAvailableKiosks(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-// PairKioskRequest represents payload for pairing
+// PairKioskRequest represents payload for pairing.
+// PublicKey is the device's base64 SPKI signing key used for offline records; sending one
+// requires a PairingToken issued by an admin for the kiosk.
 type PairKioskRequest struct {
-	KioskID   string `json:"kiosk_id" binding:"required"`
-	AdminCode string `json:"admin_code" binding:"required"`
+	KioskID      string `json:"kiosk_id" binding:"required"`
+	AdminCode    string `json:"admin_code" binding:"required"`
+	PublicKey    string `json:"public_key"`
+	PairingToken string `json:"pairing_token"`
+}
+
+// kioskPairingTokenTTL is how long an admin-issued pairing token stays usable
+const kioskPairingTokenTTL = 15 * time.Minute
+
+// consumePairingToken checks a pairing token against the kiosk and uses it up, so each
+// token registers one device key. The shared kiosk admin code is not enough for that.
+func (h *KioskHandler) consumePairingToken(ctx context.Context, kiosk *models.Kiosk, token string) (bool, error) {
+	if token == "" {
+		return false, nil
+	}
+	ok, err := h.kioskRepo.ConsumePairingToken(ctx, kiosk.ID, utils.HashSecureToken(token))
+	if ok {
+		kiosk.PairingTokenHash = ""
+		kiosk.PairingTokenExpiresAt = nil
+	}
+	return ok, err
 }
 
 // PairKiosk pairs a device with a kiosk ID
@@ -597,8 +1082,27 @@ func (h *KioskHandler) PairKiosk(c *gin.Context) {
 		return
 	}
 
-	// Mark as paired
+	if req.PublicKey != "" {
+		if _, err := utils.ParseDevicePublicKey(req.PublicKey); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid device public key: " + err.Error()})
+			return
+		}
+		ok, err := h.consumePairingToken(c.Request.Context(), kiosk, req.PairingToken)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memeriksa token pairing"})
+			return
+		}
+		if !ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Token pairing tidak valid atau kedaluwarsa", "code": "PAIRING_TOKEN_INVALID"})
+			return
+		}
+	}
+
+	// Mark as paired; a new device starts a new signed record chain
 	kiosk.IsPaired = true
+	kiosk.PublicKey = req.PublicKey
+	kiosk.SyncSeq = 0
+	kiosk.SyncHash = ""
 	if err := h.kioskRepo.Update(c.Request.Context(), kiosk); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan status pairing"})
 		return
@@ -611,6 +1115,65 @@ func (h *KioskHandler) PairKiosk(c *gin.Context) {
 	})
 }
 
+// RegisterKioskKeyRequest represents payload for registering a device signing key
+type RegisterKioskKeyRequest struct {
+	KioskID      string `json:"kiosk_id" binding:"required"`
+	PairingToken string `json:"pairing_token" binding:"required"`
+	PublicKey    string `json:"public_key" binding:"required"`
+}
+
+// RegisterKioskKey registers or replaces the signing key of a paired kiosk. It needs a
+// pairing token issued by an admin for the kiosk; a new key starts a new record chain.
+// POST /api/kiosk/register-key
+func (h *KioskHandler) RegisterKioskKey(c *gin.Context) {
+	var req RegisterKioskKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	kiosk, err := h.kioskRepo.FindByKioskID(c.Request.Context(), req.KioskID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Kiosk not found"})
+		return
+	}
+
+	if !kiosk.IsPaired {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kiosk is not paired"})
+		return
+	}
+
+	if _, err := utils.ParseDevicePublicKey(req.PublicKey); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid device public key: " + err.Error()})
+		return
+	}
+
+	ok, err := h.consumePairingToken(c.Request.Context(), kiosk, req.PairingToken)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check pairing token"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid or expired pairing token", "code": "PAIRING_TOKEN_INVALID"})
+		return
+	}
+
+	kiosk.PublicKey = req.PublicKey
+	kiosk.SyncSeq = 0
+	kiosk.SyncHash = ""
+	if err := h.kioskRepo.Update(c.Request.Context(), kiosk); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register device key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"message":   "Device key registered",
+		"sync_seq":  kiosk.SyncSeq,
+		"sync_hash": kiosk.SyncHash,
+	})
+}
+
 // Admin Kiosk Management Handlers
 
 // GetAllKiosks returns all registered kiosks with pagination
@@ -651,6 +1214,10 @@ func (h *KioskHandler) CreateKiosk(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Office ID"})
 		return
 	}
+	if !officeInScope(c, &officeID) {
+		rejectOutOfScope(c)
+		return
+	}
 
 	kiosk := &models.Kiosk{
 		Name:     req.Name,
@@ -664,6 +1231,7 @@ func (h *KioskHandler) CreateKiosk(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, "kiosk", kiosk.ID.String(), nil, kiosk)
 	c.JSON(http.StatusCreated, kiosk)
 }
 
@@ -700,7 +1268,12 @@ func (h *KioskHandler) UpdateKiosk(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Office ID"})
 		return
 	}
+	if !officeInScope(c, &kiosk.OfficeID) || !officeInScope(c, &officeID) {
+		rejectOutOfScope(c)
+		return
+	}
 
+	before := *kiosk
 	kiosk.Name = req.Name
 	kiosk.OfficeID = officeID
 	if req.IsActive != nil {
@@ -712,6 +1285,7 @@ func (h *KioskHandler) UpdateKiosk(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, "kiosk", kiosk.ID.String(), &before, kiosk)
 	c.JSON(http.StatusOK, kiosk)
 }
 
@@ -724,11 +1298,22 @@ func (h *KioskHandler) DeleteKiosk(c *gin.Context) {
 		return
 	}
 
+	kiosk, err := h.kioskRepo.FindByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Kiosk not found"})
+		return
+	}
+	if !officeInScope(c, &kiosk.OfficeID) {
+		rejectOutOfScope(c)
+		return
+	}
+
 	if err := h.kioskRepo.Delete(c.Request.Context(), id); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete kiosk"})
 		return
 	}
 
+	auditChange(c, "kiosk", kiosk.ID.String(), kiosk, nil)
 	c.JSON(http.StatusOK, gin.H{"message": "Kiosk deleted successfully"})
 }
 
@@ -746,16 +1331,65 @@ func (h *KioskHandler) UnpairKiosk(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Kiosk not found"})
 		return
 	}
+	if !officeInScope(c, &kiosk.OfficeID) {
+		rejectOutOfScope(c)
+		return
+	}
 
+	before := *kiosk
 	kiosk.IsPaired = false
+	kiosk.PublicKey = ""
+	kiosk.SyncSeq = 0
+	kiosk.SyncHash = ""
 	if err := h.kioskRepo.Update(c.Request.Context(), kiosk); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unpair kiosk"})
 		return
 	}
 
+	auditChange(c, "kiosk", kiosk.ID.String(), &before, kiosk)
 	c.JSON(http.StatusOK, gin.H{"message": "Kiosk unpaired successfully"})
 }
 
+// IssuePairingToken issues a one-time token that lets a device register or replace the
+// kiosk's signing key. The token is only shown in this response.
+// POST /api/admin/kiosks/:id/pairing-token
+func (h *KioskHandler) IssuePairingToken(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	kiosk, err := h.kioskRepo.FindByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Kiosk not found"})
+		return
+	}
+	if !officeInScope(c, &kiosk.OfficeID) {
+		rejectOutOfScope(c)
+		return
+	}
+
+	token, err := utils.NewSecureToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue pairing token"})
+		return
+	}
+	expiresAt := time.Now().Add(kioskPairingTokenTTL)
+	kiosk.PairingTokenHash = utils.HashSecureToken(token)
+	kiosk.PairingTokenExpiresAt = &expiresAt
+	if err := h.kioskRepo.Update(c.Request.Context(), kiosk); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue pairing token"})
+		return
+	}
+
+	auditChange(c, "kiosk", kiosk.ID.String(), nil, gin.H{"pairing_token_expires_at": expiresAt})
+	c.JSON(http.StatusOK, gin.H{
+		"pairing_token": token,
+		"expires_at":    expiresAt,
+	})
+}
+
 // GetKioskSettings returns public settings for kiosk
 // GET /api/kiosk/settings
 func (h *KioskHandler) GetKioskSettings(c *gin.Context) {
@@ -997,11 +1631,23 @@ func ternary(cond bool, a, b string) string {
 
 // ========== OFFLINE MODE SUPPORT ==========
 
-// SyncDataResponse contains all data needed for offline kiosk operation
+// Kiosk sync page sizes
+const (
+	defaultSyncPageSize = 500
+	maxSyncPageSize     = 2000
+)
+
+// SyncDataResponse contains the data needed for offline kiosk operation.
+// With since empty it is a full snapshot (FullSync) and the kiosk replaces its cache;
+// otherwise it carries only upserts and tombstones since that point.
 type SyncDataResponse struct {
-	Employees    []EmployeeSyncData `json:"employees"`
-	LastSyncTime string             `json:"last_sync_time"`
-	OfficeInfo   OfficeSyncData     `json:"office_info"`
+	Employees    []EmployeeSyncData  `json:"employees"` // Upserts
+	Tombstones   []EmployeeTombstone `json:"tombstones"`
+	FullSync     bool                `json:"full_sync"`
+	LastSyncTime string              `json:"last_sync_time"` // Pass as since on the next sync
+	NextCursor   string              `json:"next_cursor,omitempty"`
+	HasMore      bool                `json:"has_more"`
+	OfficeInfo   OfficeSyncData      `json:"office_info"`
 }
 
 type EmployeeSyncData struct {
@@ -1012,6 +1658,13 @@ type EmployeeSyncData struct {
 	IsActive       bool        `json:"is_active"`
 }
 
+// EmployeeTombstone tells a kiosk to drop a cached employee
+type EmployeeTombstone struct {
+	ID         string `json:"id"`
+	EmployeeID string `json:"employee_id"`
+	Reason     string `json:"reason"` // deactivated, face_revoked, transferred, deleted
+}
+
 type OfficeSyncData struct {
 	ID        string  `json:"id"`
 	Name      string  `json:"name"`
@@ -1020,9 +1673,34 @@ type OfficeSyncData struct {
 	Longitude float64 `json:"longitude"`
 }
 
-// SyncData returns all employee data for offline kiosk operation
-// GET /api/kiosk/sync-data
-func (h *KioskHandler) SyncData(c *gin.Context) {
+// syncCursor is the opaque page position handed to kiosks as next_cursor
+type syncCursor struct {
+	Since     time.Time `json:"s"`
+	Until     time.Time `json:"u"`
+	UpdatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"i"`
+}
+
+func encodeSyncCursor(cur syncCursor) string {
+	data, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeSyncCursor(s string) (syncCursor, error) {
+	var cur syncCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cur, err
+	}
+	err = json.Unmarshal(data, &cur)
+	return cur, err
+}
+
+// SyncData returns employee data for offline kiosk operation.
+// Query: kiosk_id, code, since (last_sync_time of the previous sync), cursor (next_cursor of the previous page), limit.
+// Responses carry an ETag and are gzip-compressed when the kiosk accepts it.
+// GET /api/kiosk/sync-data
+func (h *KioskHandler) SyncData(c *gin.Context) {
 	kioskID := c.Query("kiosk_id")
 	adminCode := c.Query("code")
 
@@ -1045,23 +1723,65 @@ func (h *KioskHandler) SyncData(c *gin.Context) {
 		return
 	}
 
-	// Get all active employees with face data for this office
-	filters := repository.UserFilters{
-		OfficeID: kiosk.OfficeID.String(),
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultSyncPageSize)))
+	if limit <= 0 || limit > maxSyncPageSize {
+		limit = defaultSyncPageSize
+	}
+
+	// Resolve the sync window: a cursor continues a paged sync, otherwise start a new one
+	var cur syncCursor
+	firstPage := true
+	if cursorStr := c.Query("cursor"); cursorStr != "" {
+		cur, err = decodeSyncCursor(cursorStr)
+		if err != nil || cur.Until.IsZero() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		firstPage = false
+	} else {
+		if sinceStr := c.Query("since"); sinceStr != "" {
+			cur.Since, err = time.Parse(time.RFC3339Nano, sinceStr)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid since, expected RFC3339 time"})
+				return
+			}
+		}
+		cur.Until, err = h.userRepo.SyncHighWaterMark(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch employees"})
+			return
+		}
+		if cur.Until.Before(cur.Since) {
+			cur.Until = cur.Since
+		}
 	}
-	active := true
-	filters.IsActive = &active
 
-	users, _, err := h.userRepo.FindAll(c.Request.Context(), filters, 1000, 0)
+	users, err := h.userRepo.FindForKioskSync(c.Request.Context(), repository.KioskSyncQuery{
+		OfficeID:       kiosk.OfficeID,
+		Since:          cur.Since,
+		Until:          cur.Until,
+		AfterUpdatedAt: cur.UpdatedAt,
+		AfterID:        cur.ID,
+		Limit:          limit,
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch employees"})
 		return
 	}
 
-	// Transform to sync format
-	var employees []EmployeeSyncData
+	// Split into upserts and tombstones
+	employees := []EmployeeSyncData{}
+	tombstones := []EmployeeTombstone{}
 	for _, user := range users {
-		if user.FaceVerificationStatus == "verified" && len(user.FaceEmbeddings) > 0 {
+		reason := ""
+		switch {
+		case !user.IsActive:
+			reason = "deactivated"
+		case user.FaceVerificationStatus != "verified" || len(user.FaceEmbeddings) == 0:
+			reason = "face_revoked"
+		}
+
+		if reason == "" {
 			employees = append(employees, EmployeeSyncData{
 				ID:             user.ID.String(),
 				EmployeeID:     user.EmployeeID,
@@ -1069,22 +1789,92 @@ func (h *KioskHandler) SyncData(c *gin.Context) {
 				FaceEmbeddings: user.FaceEmbeddings,
 				IsActive:       user.IsActive,
 			})
+		} else if !cur.Since.IsZero() {
+			tombstones = append(tombstones, EmployeeTombstone{
+				ID:         user.ID.String(),
+				EmployeeID: user.EmployeeID,
+				Reason:     reason,
+			})
+		}
+	}
+
+	// Users who left the office only exist as tombstone rows; send them once, with the first page
+	if firstPage && !cur.Since.IsZero() {
+		deleted, err := h.userRepo.FindTombstones(c.Request.Context(), kiosk.OfficeID, cur.Since, cur.Until)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch employees"})
+			return
+		}
+		for _, t := range deleted {
+			tombstones = append(tombstones, EmployeeTombstone{
+				ID:         t.UserID.String(),
+				EmployeeID: t.EmployeeID,
+				Reason:     t.Reason,
+			})
 		}
 	}
 
 	response := SyncDataResponse{
 		Employees:    employees,
-		LastSyncTime: time.Now().UTC().Format(time.RFC3339),
-		OfficeInfo: OfficeSyncData{
+		Tombstones:   tombstones,
+		FullSync:     cur.Since.IsZero(),
+		LastSyncTime: cur.Until.UTC().Format(time.RFC3339Nano),
+		HasMore:      len(users) == limit,
+	}
+	if kiosk.Office != nil {
+		response.OfficeInfo = OfficeSyncData{
 			ID:        kiosk.Office.ID.String(),
 			Name:      kiosk.Office.Name,
 			Address:   kiosk.Office.Address,
 			Latitude:  kiosk.Office.Latitude,
 			Longitude: kiosk.Office.Longitude,
-		},
+		}
+	}
+	if response.HasMore {
+		last := users[len(users)-1]
+		response.NextCursor = encodeSyncCursor(syncCursor{
+			Since:     cur.Since,
+			Until:     cur.Until,
+			UpdatedAt: last.UpdatedAt,
+			ID:        last.ID,
+		})
 	}
 
-	c.JSON(http.StatusOK, response)
+	writeCachedJSON(c, http.StatusOK, response)
+}
+
+// writeCachedJSON writes a JSON body with a content-hash ETag, answers 304 when the
+// client already has it and gzip-compresses the body when the client accepts it
+func writeCachedJSON(c *gin.Context, status int, body interface{}) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode response"})
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Header("ETag", etag)
+	c.Header("Vary", "Accept-Encoding")
+
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	if !strings.Contains(c.GetHeader("Accept-Encoding"), "gzip") {
+		c.Data(status, "application/json; charset=utf-8", data)
+		return
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(data); err != nil || gz.Close() != nil {
+		c.Data(status, "application/json; charset=utf-8", data)
+		return
+	}
+	c.Header("Content-Encoding", "gzip")
+	c.Data(status, "application/json; charset=utf-8", buf.Bytes())
 }
 
 // KioskOfflineSyncRequest represents batch of offline attendance records
@@ -1092,18 +1882,109 @@ type KioskOfflineSyncRequest struct {
 	KioskID   string                      `json:"kiosk_id" binding:"required"`
 	AdminCode string                      `json:"admin_code" binding:"required"`
 	Records   []KioskOfflineAttendanceRecord `json:"records" binding:"required"`
+
+	// Kiosk clock reading taken just before sending, used to measure clock skew
+	ClientTime string `json:"client_time"`
+	RTTMs      int64  `json:"rtt_ms"`
 }
 
+// KioskOfflineAttendanceRecord is one offline punch. Seq, PrevHash and Signature form the
+// kiosk's tamper-evident chain: Seq increases by one per record, PrevHash is the chain hash
+// of the previous record and Signature is the device signature over canonicalOfflineRecord.
 type KioskOfflineAttendanceRecord struct {
+	ClientID   string  `json:"client_id"` // Client-generated UUID, reused on retry; derived for older clients
 	EmployeeID string  `json:"employee_id" binding:"required"`
 	Type       string  `json:"type" binding:"required,oneof=check-in check-out"`
 	Timestamp  string  `json:"timestamp" binding:"required"`
 	Confidence float64 `json:"confidence"`
+	Seq        int64   `json:"seq"`
+	PrevHash   string  `json:"prev_hash"`
+	Signature  string  `json:"signature"` // base64
+}
+
+// canonicalOfflineRecord is the exact byte string a kiosk signs for one offline record:
+// client_id, kiosk_id, employee_id, type, timestamp, confidence (4 decimals), seq and
+// prev_hash joined by newlines. Its SHA-256 (hex) is the chain hash of the record.
+func canonicalOfflineRecord(kioskID string, record KioskOfflineAttendanceRecord) []byte {
+	return []byte(strings.Join([]string{
+		record.ClientID,
+		kioskID,
+		record.EmployeeID,
+		record.Type,
+		record.Timestamp,
+		strconv.FormatFloat(record.Confidence, 'f', 4, 64),
+		strconv.FormatInt(record.Seq, 10),
+		record.PrevHash,
+	}, "\n"))
+}
+
+// offlineChainCheck is the outcome of verifying a batch against a kiosk's signed chain
+type offlineChainCheck struct {
+	Reasons         []string
+	SignaturesValid bool
+	FirstSeq        int64
+	LastSeq         int64
+	LastHash        string
+}
+
+// verifyOfflineChain checks signatures, sequence continuity and hash links of new records.
+// Any reason returned means the batch must be held for review instead of applied.
+func verifyOfflineChain(kiosk *models.Kiosk, records []KioskOfflineAttendanceRecord) offlineChainCheck {
+	check := offlineChainCheck{}
+	if kiosk.PublicKey == "" {
+		check.Reasons = append(check.Reasons, "Kiosk has no registered device key, records are unsigned")
+		return check
+	}
+
+	key, err := utils.ParseDevicePublicKey(kiosk.PublicKey)
+	if err != nil {
+		check.Reasons = append(check.Reasons, "Registered device key is invalid: "+err.Error())
+		return check
+	}
+
+	check.SignaturesValid = true
+	expectedSeq := kiosk.SyncSeq + 1
+	prevHash := kiosk.SyncHash
+
+	for i, record := range records {
+		message := canonicalOfflineRecord(kiosk.KioskID, record)
+		if record.Signature == "" || !utils.VerifyDeviceSignature(key, message, record.Signature) {
+			check.SignaturesValid = false
+			check.Reasons = append(check.Reasons, "Invalid or missing signature on record "+record.ClientID)
+		}
+
+		switch {
+		case record.Seq > expectedSeq:
+			check.Reasons = append(check.Reasons, fmt.Sprintf("Sequence gap: expected %d, got %d (record %s)", expectedSeq, record.Seq, record.ClientID))
+		case record.Seq < expectedSeq:
+			check.Reasons = append(check.Reasons, fmt.Sprintf("Sequence %d replayed or out of order, expected %d (record %s)", record.Seq, expectedSeq, record.ClientID))
+		}
+		if record.PrevHash != prevHash {
+			check.Reasons = append(check.Reasons, fmt.Sprintf("Hash chain broken at sequence %d (record %s)", record.Seq, record.ClientID))
+		}
+
+		if i == 0 {
+			check.FirstSeq = record.Seq
+		}
+		check.LastSeq = record.Seq
+		check.LastHash = utils.ChainHash(message)
+		expectedSeq = record.Seq + 1
+		prevHash = check.LastHash
+	}
+
+	return check
 }
 
-// OfflineSync handles batch synchronization of offline attendance from kiosk
+// OfflineSync handles batch synchronization of offline attendance from kiosk.
+// The whole batch is applied in one transaction and every record gets its own result.
+// Records that were not seen before must continue the kiosk's signed chain; otherwise
+// they are held for HR review (status held) instead of being applied.
+// Timestamps are shifted by the kiosk's clock offset when it exceeds the tolerance,
+// and the batch is held when the offset is too large to correct.
 // POST /api/kiosk/offline-sync
 func (h *KioskHandler) OfflineSync(c *gin.Context) {
+	receivedAt := time.Now()
+
 	var req KioskOfflineSyncRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -1128,97 +2009,738 @@ func (h *KioskHandler) OfflineSync(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or inactive kiosk"})
 		return
 	}
-	_ = h.kioskRepo.UpdateLastSeen(c.Request.Context(), kiosk.ID)
+	ctx := c.Request.Context()
 
-	synced := 0
-	errors := []string{}
-
-	for _, record := range req.Records {
-		// Parse timestamp
-		recordTime, err := time.Parse(time.RFC3339, record.Timestamp)
-		if err != nil {
-			errors = append(errors, "Invalid timestamp: "+record.Timestamp)
-			continue
-		}
-
-		// Reject future timestamps
-		if recordTime.After(time.Now().Add(5 * time.Minute)) {
-			errors = append(errors, "Future timestamp rejected: "+record.Timestamp)
-			continue
+	// Prefer the offset measured on this upload, fall back to a recent heartbeat
+	var offset time.Duration
+	if measured, ok := measureClockOffset(req.ClientTime, req.RTTMs, receivedAt); ok {
+		offset = measured
+		_ = h.kioskRepo.UpdateClockOffset(ctx, kiosk.ID, offset.Milliseconds(), req.RTTMs)
+	} else {
+		if kiosk.ClockCheckedAt != nil && time.Since(*kiosk.ClockCheckedAt) <= clockOffsetMaxAge {
+			offset = time.Duration(kiosk.ClockOffsetMs) * time.Millisecond
 		}
+		_ = h.kioskRepo.UpdateLastSeen(ctx, kiosk.ID)
+	}
+	correction := h.resolveClockCorrection(ctx, offset)
 
-		// Find user
-		user, err := h.userRepo.FindByEmployeeID(c.Request.Context(), record.EmployeeID)
-		if err != nil {
-			errors = append(errors, "Employee not found: "+record.EmployeeID)
-			continue
+	for i := range req.Records {
+		if record := &req.Records[i]; record.ClientID == "" {
+			record.ClientID = legacyClientID(record.EmployeeID, record.Type, record.Timestamp)
 		}
+	}
 
-		recordDate := recordTime.Format("2006-01-02")
+	var results []OfflineSyncResult
+	var heldBatch *models.OfflineSyncBatch
+	err = h.attendanceRepo.Transaction(ctx, func(txRepo *repository.AttendanceRepository) error {
+		results = make([]OfflineSyncResult, len(req.Records))
+		heldBatch = nil
 
-		if record.Type == "check-in" {
-			// Check existing
-			existing, _ := h.attendanceRepo.FindByUserAndDate(c.Request.Context(), user.ID, recordDate)
-			if existing != nil && existing.CheckInTime != nil {
-				errors = append(errors, "Already checked in: "+record.EmployeeID+" on "+recordDate)
+		// Records handled by an earlier upload are answered from the sync log;
+		// only the remaining ones take part in the chain check
+		var fresh []int
+		for i, record := range req.Records {
+			_, handled, err := checkOfflineDuplicate(ctx, txRepo, kioskSyncDevice(kiosk.KioskID), record.ClientID, nil)
+			if err != nil {
+				return err
+			}
+			if handled != nil {
+				results[i] = *handled
 				continue
 			}
+			fresh = append(fresh, i)
+		}
+		if len(fresh) == 0 {
+			return nil
+		}
 
-			isLate := recordTime.Hour() >= 9 && recordTime.Minute() > 0
+		records := make([]KioskOfflineAttendanceRecord, len(fresh))
+		for j, i := range fresh {
+			records[j] = req.Records[i]
+		}
 
-			attendance := &models.Attendance{
-				UserID:      user.ID,
-				CheckInTime: &recordTime,
-				CheckInLat:  &user.OfficeLat,
-				CheckInLong: &user.OfficeLong,
-				DeviceInfo:  "Kiosk (offline): " + req.KioskID,
-				IsLate:      isLate,
-				Notes:       fmt.Sprintf("Offline sync | Confidence: %.2f%%", record.Confidence*100),
+		check := verifyOfflineChain(kiosk, records)
+		if correction.Exceeds {
+			check.Reasons = append(check.Reasons, fmt.Sprintf("Kiosk clock is off by %s, beyond the correctable limit", correction.Offset.Round(time.Second)))
+		}
+		if len(check.Reasons) > 0 {
+			batch, err := holdOfflineBatch(ctx, txRepo, req.KioskID, records, check, correction.Offset)
+			if err != nil {
+				return err
 			}
-
-			if err := h.attendanceRepo.Create(c.Request.Context(), attendance); err != nil {
-				errors = append(errors, "Failed to create check-in: "+err.Error())
-				continue
+			heldBatch = batch
+			for _, i := range fresh {
+				results[i] = OfflineSyncResult{
+					ClientID: req.Records[i].ClientID,
+					Status:   SyncStatusHeld,
+					Code:     "HELD_FOR_REVIEW",
+					Message:  "Batch failed integrity checks and is waiting for HR review",
+				}
 			}
-			synced++
+			return nil
+		}
 
-		} else if record.Type == "check-out" {
-			existing, err := h.attendanceRepo.FindByUserAndDate(c.Request.Context(), user.ID, recordDate)
-			if err != nil || existing == nil {
-				errors = append(errors, "No check-in found: "+record.EmployeeID+" on "+recordDate)
-				continue
+		seen := make(map[uuid.UUID]OfflineSyncResult)
+		for _, i := range fresh {
+			result, err := h.applyOfflineRecord(ctx, txRepo, kiosk, req.Records[i], correction.Shift, seen)
+			if err != nil {
+				return err
 			}
-			if existing.CheckOutTime != nil {
-				errors = append(errors, "Already checked out: "+record.EmployeeID+" on "+recordDate)
+			results[i] = result
+		}
+		return txRepo.AdvanceKioskSyncChain(ctx, kiosk.ID, kiosk.SyncSeq, check.LastSeq, check.LastHash)
+	})
+	if errors.Is(err, repository.ErrSyncChainConflict) {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"error":   "Another upload from this kiosk was applied concurrently, retry the sync",
+			"code":    "SYNC_CONFLICT",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to sync offline records, nothing was applied",
+			"code":    "SYNC_FAILED",
+		})
+		return
+	}
+
+	synced, duplicates, rejected, held := summarizeOfflineSync(results)
+
+	// Broadcast updates if any synced
+	if synced > 0 && h.wsHub != nil {
+		h.wsHub.Broadcast("kiosk:sync", gin.H{
+			"kiosk_id": req.KioskID,
+			"synced":   synced,
+		})
+	}
+	if heldBatch != nil && h.wsHub != nil {
+		h.wsHub.BroadcastToOffice(kiosk.OfficeID, models.PermAttendanceRead, "kiosk:sync_held", gin.H{
+			"kiosk_id": req.KioskID,
+			"batch_id": heldBatch.ID,
+			"held":     held,
+			"reasons":  heldBatch.Reasons,
+		})
+	}
+
+	response := gin.H{
+		"success":         true,
+		"message":         "Sync completed",
+		"synced":          synced,
+		"duplicates":      duplicates,
+		"rejected":        rejected,
+		"held":            held,
+		"results":         results,
+		"server_time":     receivedAt.Format(time.RFC3339Nano),
+		"clock_offset_ms": correction.Offset.Milliseconds(),
+	}
+	if heldBatch != nil {
+		response["message"] = "Sync completed, some records are held for review"
+		response["batch_id"] = heldBatch.ID
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// holdOfflineBatch stores records that failed the chain check for HR review.
+// Each record is logged as held so a retried upload does not create a second batch.
+func holdOfflineBatch(
+	ctx context.Context,
+	txRepo *repository.AttendanceRepository,
+	kioskID string,
+	records []KioskOfflineAttendanceRecord,
+	check offlineChainCheck,
+	clockOffset time.Duration,
+) (*models.OfflineSyncBatch, error) {
+	payload, err := json.Marshal(records)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := &models.OfflineSyncBatch{
+		KioskID:         kioskID,
+		Records:         payload,
+		Reasons:         check.Reasons,
+		SignaturesValid: check.SignaturesValid,
+		FirstSeq:        check.FirstSeq,
+		LastSeq:         check.LastSeq,
+		LastHash:        check.LastHash,
+		ClockOffsetMs:   clockOffset.Milliseconds(),
+		Status:          models.SyncBatchPending,
+	}
+	if err := txRepo.CreateSyncBatch(ctx, batch); err != nil {
+		return nil, err
+	}
+
+	logged := make(map[uuid.UUID]bool)
+	for _, record := range records {
+		clientID, err := uuid.Parse(record.ClientID)
+		if err != nil || logged[clientID] {
+			continue
+		}
+		logged[clientID] = true
+
+		syncRecord := &models.OfflineSyncRecord{
+			Device:         kioskSyncDevice(kioskID),
+			ClientRecordID: clientID,
+			Source:         "kiosk",
+			KioskID:        kioskID,
+			BatchID:        &batch.ID,
+			Type:           record.Type,
+			Status:         models.SyncRecordHeld,
+		}
+		if err := txRepo.CreateSyncRecord(ctx, syncRecord); err != nil {
+			return nil, err
+		}
+	}
+
+	return batch, nil
+}
+
+// applyOfflineRecord applies one kiosk offline record inside the sync transaction.
+// Business rule violations come back as rejected results; only database failures return an error.
+func (h *KioskHandler) applyOfflineRecord(
+	ctx context.Context,
+	txRepo *repository.AttendanceRepository,
+	kiosk *models.Kiosk,
+	record KioskOfflineAttendanceRecord,
+	clockShift time.Duration,
+	seen map[uuid.UUID]OfflineSyncResult,
+) (OfflineSyncResult, error) {
+	clientID, handled, err := checkOfflineDuplicate(ctx, txRepo, kioskSyncDevice(kiosk.KioskID), record.ClientID, seen)
+	if err != nil {
+		return OfflineSyncResult{}, err
+	}
+	if handled != nil {
+		return *handled, nil
+	}
+
+	// Find user
+	user, err := h.userRepo.FindByEmployeeID(ctx, record.EmployeeID)
+	if err != nil {
+		result := rejectOfflineRecord(record.ClientID, "EMPLOYEE_NOT_FOUND", "Employee not found: "+record.EmployeeID)
+		seen[clientID] = result
+		return result, nil
+	}
+
+	result, err := h.applyOfflineAttendance(ctx, txRepo, kiosk, user, record, clockShift)
+	if err != nil {
+		return OfflineSyncResult{}, err
+	}
+	seen[clientID] = result
+	if result.Status != SyncStatusCreated {
+		return result, nil
+	}
+
+	syncRecord := &models.OfflineSyncRecord{
+		Device:         kioskSyncDevice(kiosk.KioskID),
+		ClientRecordID: clientID,
+		Source:         "kiosk",
+		KioskID:        kiosk.KioskID,
+		UserID:         &user.ID,
+		AttendanceID:   result.AttendanceID,
+		Type:           record.Type,
+	}
+	if err := txRepo.CreateSyncRecord(ctx, syncRecord); err != nil {
+		return OfflineSyncResult{}, err
+	}
+
+	return result, nil
+}
+
+// applyOfflineAttendance writes the check-in or check-out described by a kiosk offline record.
+// clockShift corrects the kiosk timestamp for a known clock offset.
+func (h *KioskHandler) applyOfflineAttendance(
+	ctx context.Context,
+	txRepo *repository.AttendanceRepository,
+	kiosk *models.Kiosk,
+	user *models.User,
+	record KioskOfflineAttendanceRecord,
+	clockShift time.Duration,
+) (OfflineSyncResult, error) {
+	// Parse timestamp
+	recordTime, err := time.Parse(time.RFC3339, record.Timestamp)
+	if err != nil {
+		return rejectOfflineRecord(record.ClientID, "INVALID_TIMESTAMP", "Invalid timestamp: "+record.Timestamp), nil
+	}
+
+	clockNote := ""
+	if clockShift != 0 {
+		recordTime = recordTime.Add(clockShift)
+		clockNote = fmt.Sprintf(" | Kiosk clock corrected by %s (kiosk time %s)", clockShift.Round(time.Second), record.Timestamp)
+	}
+
+	// Reject future timestamps
+	if recordTime.After(time.Now().Add(5 * time.Minute)) {
+		return rejectOfflineRecord(record.ClientID, "FUTURE_TIMESTAMP", "Future timestamp rejected: "+record.Timestamp), nil
+	}
+
+	recordDate := recordTime.Format("2006-01-02")
+
+	punch := h.resolveKioskPunch(ctx, kiosk, user)
+	if punch.Rejected {
+		return rejectOfflineRecord(record.ClientID, "CROSS_OFFICE_NOT_ALLOWED", "Employee does not belong to this kiosk's office: "+record.EmployeeID), nil
+	}
+
+	switch record.Type {
+	case "check-in":
+		// Check existing
+		existing, _ := txRepo.FindByUserAndDate(ctx, user.ID, recordDate)
+		if existing != nil && existing.CheckInTime != nil {
+			return rejectOfflineRecord(record.ClientID, "ALREADY_CHECKED_IN", "Already checked in: "+record.EmployeeID+" on "+recordDate), nil
+		}
+
+		isLate := recordTime.Hour() >= 9 && recordTime.Minute() > 0
+
+		attendance := &models.Attendance{
+			UserID:      user.ID,
+			CheckInTime: &recordTime,
+			DeviceInfo:  "Kiosk (offline): " + kiosk.KioskID,
+			IsLate:      isLate,
+			Notes:       fmt.Sprintf("Offline sync | Confidence: %.2f%%", record.Confidence*100) + clockNote,
+		}
+		punch.applyCheckIn(attendance)
+
+		if err := txRepo.Create(ctx, attendance); err != nil {
+			return OfflineSyncResult{}, err
+		}
+		return OfflineSyncResult{ClientID: record.ClientID, Status: SyncStatusCreated, AttendanceID: &attendance.ID}, nil
+
+	case "check-out":
+		existing, err := txRepo.FindByUserAndDate(ctx, user.ID, recordDate)
+		if err != nil || existing == nil {
+			return rejectOfflineRecord(record.ClientID, "NO_CHECK_IN", "No check-in found: "+record.EmployeeID+" on "+recordDate), nil
+		}
+		if existing.CheckOutTime != nil {
+			return rejectOfflineRecord(record.ClientID, "ALREADY_CHECKED_OUT", "Already checked out: "+record.EmployeeID+" on "+recordDate), nil
+		}
+
+		existing.CheckOutTime = &recordTime
+		punch.applyCheckOut(existing)
+		existing.Notes = existing.Notes + " | Check-out synced offline" + clockNote
+
+		if err := txRepo.Update(ctx, existing); err != nil {
+			return OfflineSyncResult{}, err
+		}
+		return OfflineSyncResult{ClientID: record.ClientID, Status: SyncStatusCreated, AttendanceID: &existing.ID}, nil
+	}
+
+	return rejectOfflineRecord(record.ClientID, "INVALID_TYPE", "Unknown record type: "+record.Type), nil
+}
+
+// ========== CLOCK SKEW ==========
+
+// Kiosk clock skew defaults, overridable via the kiosk_clock_skew_tolerance_seconds
+// and kiosk_clock_skew_max_correction_seconds settings
+const (
+	defaultClockSkewTolerance     = 2 * time.Minute
+	defaultClockSkewMaxCorrection = 6 * time.Hour
+	clockOffsetMaxAge             = 24 * time.Hour // Older measurements are not used for correction
+)
+
+// clockCorrection decides how a kiosk's clock offset affects its offline timestamps
+type clockCorrection struct {
+	Offset  time.Duration // Server minus kiosk time, zero when unknown
+	Shift   time.Duration // Added to every record timestamp
+	Exceeds bool          // Offset is beyond the correctable limit
+}
+
+// measureClockOffset estimates server minus kiosk time from a kiosk timestamp taken just before sending.
+// rttMs is the round-trip the kiosk measured on its previous request; half of it is the uplink delay.
+func measureClockOffset(clientTime string, rttMs int64, receivedAt time.Time) (time.Duration, bool) {
+	sentAt, err := time.Parse(time.RFC3339Nano, clientTime)
+	if err != nil {
+		return 0, false
+	}
+	return receivedAt.Sub(sentAt) - time.Duration(rttMs)*time.Millisecond/2, true
+}
+
+// clockSkewLimits returns the configured tolerance and maximum correctable offset
+func (h *KioskHandler) clockSkewLimits(ctx context.Context) (tolerance, maxCorrection time.Duration) {
+	tolerance, maxCorrection = defaultClockSkewTolerance, defaultClockSkewMaxCorrection
+
+	if setting, _ := h.settingsRepo.GetByKey(ctx, "kiosk_clock_skew_tolerance_seconds"); setting != nil {
+		if v, err := strconv.Atoi(setting.Value); err == nil && v >= 0 {
+			tolerance = time.Duration(v) * time.Second
+		}
+	}
+	if setting, _ := h.settingsRepo.GetByKey(ctx, "kiosk_clock_skew_max_correction_seconds"); setting != nil {
+		if v, err := strconv.Atoi(setting.Value); err == nil && v >= 0 {
+			maxCorrection = time.Duration(v) * time.Second
+		}
+	}
+	return tolerance, maxCorrection
+}
+
+// resolveClockCorrection turns a measured offset into a correction.
+// Offsets within tolerance are ignored; offsets beyond maxCorrection are flagged, not applied.
+func (h *KioskHandler) resolveClockCorrection(ctx context.Context, offset time.Duration) clockCorrection {
+	tolerance, maxCorrection := h.clockSkewLimits(ctx)
+	correction := clockCorrection{Offset: offset}
+
+	skew := offset
+	if skew < 0 {
+		skew = -skew
+	}
+	switch {
+	case skew <= tolerance:
+	case skew <= maxCorrection:
+		correction.Shift = offset
+	default:
+		correction.Exceeds = true
+	}
+	return correction
+}
+
+// clockSkewStatus classifies a kiosk's last clock measurement for the admin API
+func (h *KioskHandler) clockSkewStatus(ctx context.Context, kiosk *models.Kiosk) string {
+	if kiosk.ClockCheckedAt == nil || time.Since(*kiosk.ClockCheckedAt) > clockOffsetMaxAge {
+		return "unknown"
+	}
+	correction := h.resolveClockCorrection(ctx, time.Duration(kiosk.ClockOffsetMs)*time.Millisecond)
+	switch {
+	case correction.Exceeds:
+		return "uncorrectable"
+	case correction.Shift != 0:
+		return "drifting"
+	}
+	return "ok"
+}
+
+// KioskHeartbeatRequest carries the kiosk clock reading used for skew measurement
+type KioskHeartbeatRequest struct {
+	KioskID    string `json:"kiosk_id" binding:"required"`
+	AdminCode  string `json:"admin_code" binding:"required"`
+	ClientTime string `json:"client_time" binding:"required"` // RFC3339, taken just before sending
+	RTTMs      int64  `json:"rtt_ms"`                         // Round-trip of the previous heartbeat
+}
+
+// Heartbeat records that a kiosk is alive and measures its clock offset.
+// The kiosk can use server_time and its own round-trip to correct its clock.
+// POST /api/kiosk/heartbeat
+func (h *KioskHandler) Heartbeat(c *gin.Context) {
+	receivedAt := time.Now()
+
+	var req KioskHeartbeatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Verify admin code
+	setting, err := h.settingsRepo.GetByKey(c.Request.Context(), "kiosk_admin_code")
+	expectedCode := "123456"
+	if err == nil && setting != nil {
+		expectedCode = setting.Value
+	}
+
+	if req.AdminCode != expectedCode {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid admin code"})
+		return
+	}
+
+	kiosk, err := h.kioskRepo.FindByKioskID(c.Request.Context(), req.KioskID)
+	if err != nil || kiosk == nil || !kiosk.IsActive {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or inactive kiosk"})
+		return
+	}
+
+	offset, ok := measureClockOffset(req.ClientTime, req.RTTMs, receivedAt)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "client_time must be RFC3339", "code": "INVALID_CLIENT_TIME"})
+		return
+	}
+	if err := h.kioskRepo.UpdateClockOffset(c.Request.Context(), kiosk.ID, offset.Milliseconds(), req.RTTMs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record heartbeat"})
+		return
+	}
+
+	correction := h.resolveClockCorrection(c.Request.Context(), offset)
+	c.JSON(http.StatusOK, gin.H{
+		"success":          true,
+		"server_time":      receivedAt.Format(time.RFC3339Nano),
+		"clock_offset_ms":  offset.Milliseconds(),
+		"within_tolerance": correction.Shift == 0 && !correction.Exceeds,
+	})
+}
+
+// GetKioskClockSkew returns the last measured clock offset of every kiosk
+// GET /api/admin/kiosks/clock-skew
+func (h *KioskHandler) GetKioskClockSkew(c *gin.Context) {
+	kiosks, _, err := h.kioskRepo.GetAll(c.Request.Context(), -1, -1)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch kiosks"})
+		return
+	}
+
+	tolerance, maxCorrection := h.clockSkewLimits(c.Request.Context())
+	data := make([]gin.H, 0, len(kiosks))
+	for i := range kiosks {
+		kiosk := &kiosks[i]
+		data = append(data, gin.H{
+			"id":               kiosk.ID,
+			"kiosk_id":         kiosk.KioskID,
+			"name":             kiosk.Name,
+			"clock_offset_ms":  kiosk.ClockOffsetMs,
+			"clock_rtt_ms":     kiosk.ClockRTTMs,
+			"clock_checked_at": kiosk.ClockCheckedAt,
+			"status":           h.clockSkewStatus(c.Request.Context(), kiosk),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":                   data,
+		"tolerance_seconds":      int64(tolerance.Seconds()),
+		"max_correction_seconds": int64(maxCorrection.Seconds()),
+	})
+}
+
+// ========== OFFLINE BATCH REVIEW ==========
+
+// GetOfflineBatches lists kiosk offline batches held for review
+// GET /api/admin/offline-batches?status=pending
+func (h *KioskHandler) GetOfflineBatches(c *gin.Context) {
+	status := c.DefaultQuery("status", models.SyncBatchPending)
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	batches, total, err := h.attendanceRepo.FindSyncBatches(c.Request.Context(), status, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch offline batches"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  batches,
+		"total": total,
+	})
+}
+
+// ReviewOfflineBatchRequest represents an HR decision on a held batch.
+// ClockShiftSeconds is the correction the reviewer confirms for the batch's timestamps;
+// it is required when the measured offset is beyond the correctable limit.
+type ReviewOfflineBatchRequest struct {
+	Note              string `json:"note"`
+	ClockShiftSeconds *int64 `json:"clock_shift_seconds"`
+}
+
+// ApproveOfflineBatch applies the records of a held batch, corrected for the kiosk clock offset
+// measured at upload. An offset beyond the correctable limit is never applied on its own: the
+// reviewer has to confirm the shift to use. Records that break attendance rules are still
+// rejected individually.
+// POST /api/admin/offline-batches/:id/approve
+func (h *KioskHandler) ApproveOfflineBatch(c *gin.Context) {
+	batch, req, ok := h.loadPendingOfflineBatch(c)
+	if !ok {
+		return
+	}
+	before := *batch
+
+	var records []KioskOfflineAttendanceRecord
+	if err := json.Unmarshal(batch.Records, &records); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Stored batch is corrupt"})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	// The offset measured at upload is applied within the usual limits; beyond them only
+	// the shift the reviewer confirms is
+	correction := h.resolveClockCorrection(ctx, time.Duration(batch.ClockOffsetMs)*time.Millisecond)
+	clockShift := correction.Shift
+	if req.ClockShiftSeconds != nil {
+		clockShift = time.Duration(*req.ClockShiftSeconds) * time.Second
+	} else if correction.Exceeds {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":           "The kiosk clock offset is beyond the correctable limit, confirm the clock shift to apply",
+			"code":            "CLOCK_SHIFT_REQUIRED",
+			"clock_offset_ms": batch.ClockOffsetMs,
+		})
+		return
+	}
+	shiftMs := clockShift.Milliseconds()
+	batch.ClockShiftMs = &shiftMs
+
+	// The kiosk may have been removed since; its office policy then no longer applies
+	kiosk, err := h.kioskRepo.FindByKioskID(ctx, batch.KioskID)
+	if err != nil {
+		kiosk = &models.Kiosk{KioskID: batch.KioskID}
+	}
+
+	var results []OfflineSyncResult
+	err = h.attendanceRepo.Transaction(ctx, func(txRepo *repository.AttendanceRepository) error {
+		results = make([]OfflineSyncResult, 0, len(records))
+
+		heldRecords, err := txRepo.FindSyncRecordsByBatch(ctx, batch.ID)
+		if err != nil {
+			return err
+		}
+		held := make(map[uuid.UUID]*models.OfflineSyncRecord, len(heldRecords))
+		for i := range heldRecords {
+			held[heldRecords[i].ClientRecordID] = &heldRecords[i]
+		}
+
+		for _, record := range records {
+			clientID, _ := uuid.Parse(record.ClientID)
+			syncRecord := held[clientID]
+			if syncRecord == nil || syncRecord.Status != models.SyncRecordHeld {
 				continue
 			}
 
-			existing.CheckOutTime = &recordTime
-			existing.CheckOutLat = &user.OfficeLat
-			existing.CheckOutLong = &user.OfficeLong
-			existing.Notes = existing.Notes + " | Check-out synced offline"
+			var result OfflineSyncResult
+			user, err := h.userRepo.FindByEmployeeID(ctx, record.EmployeeID)
+			if err != nil {
+				result = rejectOfflineRecord(record.ClientID, "EMPLOYEE_NOT_FOUND", "Employee not found: "+record.EmployeeID)
+			} else {
+				result, err = h.applyOfflineAttendance(ctx, txRepo, kiosk, user, record, clockShift)
+				if err != nil {
+					return err
+				}
+				syncRecord.UserID = &user.ID
+			}
 
-			if err := h.attendanceRepo.Update(c.Request.Context(), existing); err != nil {
-				errors = append(errors, "Failed to update check-out: "+err.Error())
-				continue
+			if result.Status == SyncStatusCreated {
+				syncRecord.Status = models.SyncRecordApplied
+				syncRecord.AttendanceID = result.AttendanceID
+			} else {
+				syncRecord.Status = models.SyncRecordDiscarded
 			}
-			synced++
+			if err := txRepo.UpdateSyncRecord(ctx, syncRecord); err != nil {
+				return err
+			}
+			results = append(results, result)
 		}
+
+		return h.finishOfflineBatchReview(c, txRepo, batch, models.SyncBatchApproved, req.Note)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to approve offline batch"})
+		return
 	}
+	auditChange(c, "offline_batch", batch.ID.String(), &before, batch)
 
-	// Broadcast updates if any synced
+	synced, _, rejected, _ := summarizeOfflineSync(results)
 	if synced > 0 && h.wsHub != nil {
 		h.wsHub.Broadcast("kiosk:sync", gin.H{
-			"kiosk_id": req.KioskID,
+			"kiosk_id": batch.KioskID,
 			"synced":   synced,
 		})
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "Sync completed",
-		"synced":  synced,
-		"errors":  errors,
+		"message":  "Offline batch approved",
+		"batch":    batch,
+		"synced":   synced,
+		"rejected": rejected,
+		"results":  results,
 	})
 }
 
+// RejectOfflineBatch discards the records of a held batch
+// POST /api/admin/offline-batches/:id/reject
+func (h *KioskHandler) RejectOfflineBatch(c *gin.Context) {
+	batch, req, ok := h.loadPendingOfflineBatch(c)
+	if !ok {
+		return
+	}
+	before := *batch
+
+	ctx := c.Request.Context()
+	err := h.attendanceRepo.Transaction(ctx, func(txRepo *repository.AttendanceRepository) error {
+		heldRecords, err := txRepo.FindSyncRecordsByBatch(ctx, batch.ID)
+		if err != nil {
+			return err
+		}
+		for i := range heldRecords {
+			heldRecords[i].Status = models.SyncRecordDiscarded
+			if err := txRepo.UpdateSyncRecord(ctx, &heldRecords[i]); err != nil {
+				return err
+			}
+		}
+
+		return h.finishOfflineBatchReview(c, txRepo, batch, models.SyncBatchRejected, req.Note)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reject offline batch"})
+		return
+	}
+	auditChange(c, "offline_batch", batch.ID.String(), &before, batch)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Offline batch rejected",
+		"batch":   batch,
+	})
+}
+
+// loadPendingOfflineBatch resolves the :id batch and review payload, writing the error response itself
+func (h *KioskHandler) loadPendingOfflineBatch(c *gin.Context) (*models.OfflineSyncBatch, ReviewOfflineBatchRequest, bool) {
+	// The review note is optional, so an empty body is allowed but a malformed one is not
+	var req ReviewOfflineBatchRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return nil, req, false
+		}
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid batch ID"})
+		return nil, req, false
+	}
+
+	batch, err := h.attendanceRepo.FindSyncBatchByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Offline batch not found"})
+		return nil, req, false
+	}
+
+	if batch.Status != models.SyncBatchPending {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Offline batch already reviewed"})
+		return nil, req, false
+	}
+
+	// Batches from removed kiosks have no office, so only unscoped users can review them
+	if isOfficeScoped(c) {
+		kiosk, err := h.kioskRepo.FindByKioskID(c.Request.Context(), batch.KioskID)
+		if err != nil || !officeInScope(c, &kiosk.OfficeID) {
+			rejectOutOfScope(c)
+			return nil, req, false
+		}
+	}
+
+	return batch, req, true
+}
+
+// finishOfflineBatchReview records the HR decision and, when every record in the batch
+// carried a valid device signature, lets the kiosk's chain continue after it
+func (h *KioskHandler) finishOfflineBatchReview(
+	c *gin.Context,
+	txRepo *repository.AttendanceRepository,
+	batch *models.OfflineSyncBatch,
+	status string,
+	note string,
+) error {
+	ctx := c.Request.Context()
+	now := time.Now()
+	batch.Status = status
+	batch.ReviewNote = note
+	batch.ReviewedAt = &now
+	if reviewerID, exists := c.Get("user_id"); exists {
+		id := reviewerID.(uuid.UUID)
+		batch.ReviewedBy = &id
+	}
+	if err := txRepo.UpdateSyncBatch(ctx, batch); err != nil {
+		return err
+	}
+
+	if !batch.SignaturesValid {
+		return nil
+	}
+	kiosk, err := h.kioskRepo.FindByKioskID(ctx, batch.KioskID)
+	if err != nil || batch.LastSeq <= kiosk.SyncSeq {
+		return nil
+	}
+	return txRepo.AdvanceKioskSyncChain(ctx, kiosk.ID, kiosk.SyncSeq, batch.LastSeq, batch.LastHash)
+}
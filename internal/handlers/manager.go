@@ -0,0 +1,379 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/attendance-system/internal/models"
+	"github.com/attendance-system/internal/repository"
+	"github.com/attendance-system/internal/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Team presence statuses
+const (
+	PresenceAbsent     = "absent"
+	PresencePresent    = "present"
+	PresenceLate       = "late"
+	PresenceCheckedOut = "checked_out"
+)
+
+// ManagerHandler serves the self-service team view for managers
+type ManagerHandler struct {
+	employeeRepo   *repository.EmployeeRepository
+	attendanceRepo *repository.AttendanceRepository
+	transferRepo   *repository.TransferRequestRepository
+}
+
+// NewManagerHandler creates a new manager handler
+func NewManagerHandler(
+	employeeRepo *repository.EmployeeRepository,
+	attendanceRepo *repository.AttendanceRepository,
+	transferRepo *repository.TransferRequestRepository,
+) *ManagerHandler {
+	return &ManagerHandler{
+		employeeRepo:   employeeRepo,
+		attendanceRepo: attendanceRepo,
+		transferRepo:   transferRepo,
+	}
+}
+
+// TeamMember is a report as shown to their manager, without HR-only employee data
+type TeamMember struct {
+	UserID     uuid.UUID      `json:"user_id"`
+	EmployeeID string         `json:"employee_id"`
+	Name       string         `json:"name"`
+	Position   string         `json:"position"`
+	AvatarURL  string         `json:"avatar_url,omitempty"`
+	ManagerID  *uuid.UUID     `json:"manager_id,omitempty"`
+	Direct     bool           `json:"direct"` // Reports to the manager without anyone in between
+	Office     *models.Office `json:"office,omitempty"`
+
+	FaceVerificationStatus string `json:"face_verification_status,omitempty"`
+}
+
+// TeamPresence is a team member's attendance status on a day
+type TeamPresence struct {
+	TeamMember
+	Status     string             `json:"status"`
+	Attendance *models.Attendance `json:"attendance,omitempty"`
+}
+
+// loadTeam resolves the logged-in user's direct and indirect reports.
+// Users who are neither flagged as manager nor have anyone reporting to them are refused.
+func (h *ManagerHandler) loadTeam(c *gin.Context) ([]TeamMember, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+	managerID := userID.(uuid.UUID)
+
+	reports, err := h.employeeRepo.FindTeam(c.Request.Context(), managerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load team"})
+		return nil, false
+	}
+
+	if len(reports) == 0 {
+		self, err := h.employeeRepo.FindByUserID(c.Request.Context(), managerID)
+		if err != nil || !self.IsManager {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only managers can view a team", "code": "NOT_A_MANAGER"})
+			return nil, false
+		}
+	}
+
+	team := make([]TeamMember, 0, len(reports))
+	for _, report := range reports {
+		member := TeamMember{
+			UserID:     *report.UserID,
+			EmployeeID: report.NIK,
+			Name:       report.Name,
+			Position:   report.Position,
+			ManagerID:  report.ManagerID,
+			Direct:     report.ManagerID != nil && *report.ManagerID == managerID,
+			Office:     report.Office,
+		}
+		if report.User != nil {
+			member.AvatarURL = report.User.AvatarURL
+			member.FaceVerificationStatus = report.User.FaceVerificationStatus
+			if member.Name == "" {
+				member.Name = report.User.Name
+			}
+		}
+		team = append(team, member)
+	}
+	return team, true
+}
+
+// teamUserIDs returns the user IDs of a team
+func teamUserIDs(team []TeamMember) []uuid.UUID {
+	ids := make([]uuid.UUID, len(team))
+	for i, member := range team {
+		ids[i] = member.UserID
+	}
+	return ids
+}
+
+// parseTeamDate reads the date query parameter (YYYY-MM-DD), defaulting to today
+func parseTeamDate(c *gin.Context) (string, bool) {
+	date := c.Query("date")
+	if date == "" {
+		return time.Now().Format("2006-01-02"), true
+	}
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
+		return "", false
+	}
+	return date, true
+}
+
+// presenceStatus classifies a day's attendance record; nil means the member did not check in
+func presenceStatus(attendance *models.Attendance) string {
+	switch {
+	case attendance == nil:
+		return PresenceAbsent
+	case attendance.CheckOutTime != nil:
+		return PresenceCheckedOut
+	case attendance.IsLate || attendance.CheckInStatus == utils.StatusLate:
+		return PresenceLate
+	default:
+		return PresencePresent
+	}
+}
+
+// teamPresence pairs each team member with their attendance on a date
+func (h *ManagerHandler) teamPresence(c *gin.Context, team []TeamMember, date string) ([]TeamPresence, bool) {
+	attendances, err := h.attendanceRepo.FindByUsersAndDate(c.Request.Context(), teamUserIDs(team), date)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get attendance records"})
+		return nil, false
+	}
+
+	byUser := make(map[uuid.UUID]*models.Attendance, len(attendances))
+	for i := range attendances {
+		if _, ok := byUser[attendances[i].UserID]; !ok {
+			byUser[attendances[i].UserID] = &attendances[i]
+		}
+	}
+
+	presence := make([]TeamPresence, 0, len(team))
+	for _, member := range team {
+		attendance := byUser[member.UserID]
+		presence = append(presence, TeamPresence{
+			TeamMember: member,
+			Status:     presenceStatus(attendance),
+			Attendance: attendance,
+		})
+	}
+	return presence, true
+}
+
+// GetTeam lists the manager's direct and indirect reports
+// GET /api/manager/team
+func (h *ManagerHandler) GetTeam(c *gin.Context) {
+	team, ok := h.loadTeam(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": team, "total": len(team)})
+}
+
+// GetTeamPresence returns who in the team is in, late, gone home or absent on a day
+// GET /api/manager/team/presence?date=YYYY-MM-DD
+func (h *ManagerHandler) GetTeamPresence(c *gin.Context) {
+	team, ok := h.loadTeam(c)
+	if !ok {
+		return
+	}
+	date, ok := parseTeamDate(c)
+	if !ok {
+		return
+	}
+
+	presence, ok := h.teamPresence(c, team, date)
+	if !ok {
+		return
+	}
+
+	summary := map[string]int{
+		PresencePresent:    0,
+		PresenceLate:       0,
+		PresenceCheckedOut: 0,
+		PresenceAbsent:     0,
+	}
+	for _, p := range presence {
+		summary[p.Status]++
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"date":    date,
+		"data":    presence,
+		"summary": summary,
+		"total":   len(presence),
+	})
+}
+
+// GetTeamLate lists team members who checked in late on a day
+// GET /api/manager/team/late?date=YYYY-MM-DD
+func (h *ManagerHandler) GetTeamLate(c *gin.Context) {
+	h.listTeamByPresence(c, func(p TeamPresence) bool {
+		return p.Attendance != nil && (p.Attendance.IsLate || p.Attendance.CheckInStatus == utils.StatusLate)
+	})
+}
+
+// GetTeamAbsent lists team members without a check-in on a day
+// GET /api/manager/team/absent?date=YYYY-MM-DD
+func (h *ManagerHandler) GetTeamAbsent(c *gin.Context) {
+	h.listTeamByPresence(c, func(p TeamPresence) bool {
+		return p.Status == PresenceAbsent
+	})
+}
+
+// listTeamByPresence responds with the team members on a day that match keep
+func (h *ManagerHandler) listTeamByPresence(c *gin.Context, keep func(TeamPresence) bool) {
+	team, ok := h.loadTeam(c)
+	if !ok {
+		return
+	}
+	date, ok := parseTeamDate(c)
+	if !ok {
+		return
+	}
+
+	presence, ok := h.teamPresence(c, team, date)
+	if !ok {
+		return
+	}
+
+	result := make([]TeamPresence, 0)
+	for _, p := range presence {
+		if keep(p) {
+			result = append(result, p)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"date":  date,
+		"data":  result,
+		"total": len(result),
+	})
+}
+
+// GetTeamAttendance returns the team's attendance history with filters and pagination
+// GET /api/manager/team/attendance?start_date=&end_date=&user_id=&status=
+func (h *ManagerHandler) GetTeamAttendance(c *gin.Context) {
+	team, ok := h.loadTeam(c)
+	if !ok {
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	userIDs := teamUserIDs(team)
+	if userIDStr := c.Query("user_id"); userIDStr != "" {
+		userID, err := uuid.Parse(userIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		inTeam := false
+		for _, id := range userIDs {
+			if id == userID {
+				inTeam = true
+				break
+			}
+		}
+		if !inTeam {
+			c.JSON(http.StatusForbidden, gin.H{"error": "User is not in your team", "code": "NOT_IN_TEAM"})
+			return
+		}
+		userIDs = []uuid.UUID{userID}
+	}
+
+	filters := repository.AttendanceFilters{
+		StartDate: c.Query("start_date"),
+		EndDate:   c.Query("end_date"),
+		Status:    c.Query("status"),
+		SortOrder: c.Query("sort_order"),
+		UserIDs:   userIDs,
+	}
+
+	attendances, total, err := h.attendanceRepo.FindAll(c.Request.Context(), filters, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get attendance records"})
+		return
+	}
+
+	stats, err := h.attendanceRepo.GetReportStats(c.Request.Context(), filters)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get attendance stats"})
+		return
+	}
+
+	// Attendance preloads the full user and employee records; managers only get the basics
+	for i := range attendances {
+		if user := attendances[i].User; user != nil {
+			attendances[i].User = &models.User{
+				ID:         user.ID,
+				EmployeeID: user.EmployeeID,
+				Name:       user.Name,
+				AvatarURL:  user.AvatarURL,
+				OfficeID:   user.OfficeID,
+				Office:     user.Office,
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  attendances,
+		"total": total,
+		"stats": stats,
+	})
+}
+
+// GetTeamApprovals lists the team's items waiting for a decision: office transfers,
+// offline attendance corrections held for review, and face registrations.
+// Leave and overtime are not tracked by the system yet.
+// GET /api/manager/approvals
+func (h *ManagerHandler) GetTeamApprovals(c *gin.Context) {
+	team, ok := h.loadTeam(c)
+	if !ok {
+		return
+	}
+	userIDs := teamUserIDs(team)
+	ctx := c.Request.Context()
+
+	transfers, err := h.transferRepo.FindPendingByUserIDs(ctx, userIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get transfer requests"})
+		return
+	}
+	for i := range transfers {
+		transfers[i].User = nil
+	}
+
+	corrections, err := h.attendanceRepo.FindHeldSyncRecordsByUsers(ctx, userIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get held attendance records"})
+		return
+	}
+
+	pendingFaces := make([]TeamMember, 0)
+	for _, member := range team {
+		if member.FaceVerificationStatus == "pending" {
+			pendingFaces = append(pendingFaces, member)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"transfers":          transfers,
+		"corrections":        corrections,
+		"face_verifications": pendingFaces,
+		"total":              len(transfers) + len(corrections) + len(pendingFaces),
+	})
+}
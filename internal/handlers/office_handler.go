@@ -5,6 +5,7 @@ import (
 
 	"github.com/attendance-system/internal/models"
 	"github.com/attendance-system/internal/repository"
+	"github.com/attendance-system/internal/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"strconv"
@@ -38,9 +39,41 @@ func (h *OfficeHandler) GetAllOffices(c *gin.Context) {
 	})
 }
 
+// Cross-office policies for employees punching at another office's kiosk
+const (
+	CrossOfficeReject = "reject" // Refuse the punch
+	CrossOfficeVisit  = "visit"  // Record it as a visit
+	CrossOfficeFlag   = "flag"   // Record it as a visit and flag it for HR
+)
+
+// isValidCrossOfficePolicy reports whether p is a known policy; empty means "use the global setting"
+func isValidCrossOfficePolicy(p string) bool {
+	switch p {
+	case "", CrossOfficeReject, CrossOfficeVisit, CrossOfficeFlag:
+		return true
+	}
+	return false
+}
+
+// validateKioskFactors checks kiosk factor combos such as "card+face"
+func validateKioskFactors(combos []string) bool {
+	for _, combo := range combos {
+		if !utils.IsValidFactorCombo(combo) {
+			return false
+		}
+	}
+	return true
+}
+
 // CreateOffice creates a new office (admin)
 // POST /api/admin/offices
 func (h *OfficeHandler) CreateOffice(c *gin.Context) {
+	// Offices created by a scoped user would fall outside their own scope
+	if isOfficeScoped(c) {
+		rejectOutOfScope(c)
+		return
+	}
+
 	var req struct {
 		Name              string  `json:"name" binding:"required"`
 		Address           string  `json:"address"`
@@ -51,6 +84,8 @@ func (h *OfficeHandler) CreateOffice(c *gin.Context) {
 		CheckOutTime      string  `json:"check_out_time"`
 		CheckInTolerance  int     `json:"check_in_tolerance"`
 		CheckOutTolerance int     `json:"check_out_tolerance"`
+		CrossOfficePolicy string   `json:"cross_office_policy"`
+		KioskFactors      []string `json:"kiosk_factors"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -58,6 +93,15 @@ func (h *OfficeHandler) CreateOffice(c *gin.Context) {
 		return
 	}
 
+	if !isValidCrossOfficePolicy(req.CrossOfficePolicy) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cross_office_policy must be reject, visit or flag"})
+		return
+	}
+	if !validateKioskFactors(req.KioskFactors) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kiosk_factors entries must combine qr, card, pin and face, e.g. card+face"})
+		return
+	}
+
 	if req.Radius <= 0 {
 		req.Radius = 50
 	}
@@ -89,6 +133,8 @@ func (h *OfficeHandler) CreateOffice(c *gin.Context) {
 		CheckOutTime:      req.CheckOutTime,
 		CheckInTolerance:  req.CheckInTolerance,
 		CheckOutTolerance: req.CheckOutTolerance,
+		CrossOfficePolicy: req.CrossOfficePolicy,
+		KioskFactors:      req.KioskFactors,
 		IsActive:          true,
 	}
 
@@ -96,6 +142,7 @@ func (h *OfficeHandler) CreateOffice(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create office"})
 		return
 	}
+	auditChange(c, "office", office.ID.String(), nil, office)
 
 	c.JSON(http.StatusCreated, gin.H{"message": "Office created successfully", "office": office})
 }
@@ -109,6 +156,10 @@ func (h *OfficeHandler) UpdateOffice(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid office ID"})
 		return
 	}
+	if !officeInScope(c, &id) {
+		rejectOutOfScope(c)
+		return
+	}
 
 	var req struct {
 		Name              string  `json:"name"`
@@ -120,7 +171,9 @@ func (h *OfficeHandler) UpdateOffice(c *gin.Context) {
 		CheckOutTime      string  `json:"check_out_time"`
 		CheckInTolerance  *int    `json:"check_in_tolerance"`
 		CheckOutTolerance *int    `json:"check_out_tolerance"`
-		IsActive          *bool   `json:"is_active"` // Pointer to handle false value
+		CrossOfficePolicy *string   `json:"cross_office_policy"` // Empty string resets to the global setting
+		KioskFactors      *[]string `json:"kiosk_factors"`       // Empty list resets to the global setting
+		IsActive          *bool     `json:"is_active"`           // Pointer to handle false value
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -128,11 +181,21 @@ func (h *OfficeHandler) UpdateOffice(c *gin.Context) {
 		return
 	}
 
+	if req.CrossOfficePolicy != nil && !isValidCrossOfficePolicy(*req.CrossOfficePolicy) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cross_office_policy must be reject, visit or flag"})
+		return
+	}
+	if req.KioskFactors != nil && !validateKioskFactors(*req.KioskFactors) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kiosk_factors entries must combine qr, card, pin and face, e.g. card+face"})
+		return
+	}
+
 	office, err := h.officeRepo.FindByID(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Office not found"})
 		return
 	}
+	before := *office
 
 	if req.Name != "" {
 		office.Name = req.Name
@@ -161,6 +224,12 @@ func (h *OfficeHandler) UpdateOffice(c *gin.Context) {
 	if req.CheckOutTolerance != nil {
 		office.CheckOutTolerance = *req.CheckOutTolerance
 	}
+	if req.CrossOfficePolicy != nil {
+		office.CrossOfficePolicy = *req.CrossOfficePolicy
+	}
+	if req.KioskFactors != nil {
+		office.KioskFactors = *req.KioskFactors
+	}
 	if req.IsActive != nil {
 		office.IsActive = *req.IsActive
 	}
@@ -169,6 +238,7 @@ func (h *OfficeHandler) UpdateOffice(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update office"})
 		return
 	}
+	auditChange(c, "office", office.ID.String(), &before, office)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Office updated successfully", "office": office})
 }
@@ -182,11 +252,22 @@ func (h *OfficeHandler) DeleteOffice(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid office ID"})
 		return
 	}
+	if !officeInScope(c, &id) {
+		rejectOutOfScope(c)
+		return
+	}
+
+	office, err := h.officeRepo.FindByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Office not found"})
+		return
+	}
 
 	if err := h.officeRepo.Delete(c.Request.Context(), id); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete office"})
 		return
 	}
+	auditChange(c, "office", office.ID.String(), office, nil)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Office deleted successfully"})
 }
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/attendance-system/internal/middleware"
+	"github.com/attendance-system/internal/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// OfficeScopeHandler manages which offices an admin or HR user may administer
+type OfficeScopeHandler struct {
+	scopeRepo  *repository.OfficeScopeRepository
+	userRepo   *repository.UserRepository
+	officeRepo *repository.OfficeRepository
+	authorizer *middleware.Authorizer
+}
+
+// NewOfficeScopeHandler creates a new office scope handler
+func NewOfficeScopeHandler(
+	scopeRepo *repository.OfficeScopeRepository,
+	userRepo *repository.UserRepository,
+	officeRepo *repository.OfficeRepository,
+	authorizer *middleware.Authorizer,
+) *OfficeScopeHandler {
+	return &OfficeScopeHandler{
+		scopeRepo:  scopeRepo,
+		userRepo:   userRepo,
+		officeRepo: officeRepo,
+		authorizer: authorizer,
+	}
+}
+
+// officeInScope reports whether the current user may act on an office.
+// Unscoped users may act on any office; scoped users only on their own, and never
+// on records without an office.
+func officeInScope(c *gin.Context, officeID *uuid.UUID) bool {
+	officeIDs, scoped := repository.OfficeScopeFrom(c.Request.Context())
+	if !scoped {
+		return true
+	}
+	if officeID == nil {
+		return false
+	}
+	for _, id := range officeIDs {
+		if id == *officeID {
+			return true
+		}
+	}
+	return false
+}
+
+// isOfficeScoped reports whether the current user is limited to specific offices
+func isOfficeScoped(c *gin.Context) bool {
+	_, scoped := repository.OfficeScopeFrom(c.Request.Context())
+	return scoped
+}
+
+// rejectOutOfScope responds to a request touching an office outside the user's scope
+func rejectOutOfScope(c *gin.Context) {
+	c.JSON(http.StatusForbidden, gin.H{"error": "Office is outside your administrative scope", "code": "OUT_OF_SCOPE"})
+}
+
+// OfficeScopeRequest is the body for replacing a user's office scope
+type OfficeScopeRequest struct {
+	OfficeIDs []uuid.UUID `json:"office_ids"`
+}
+
+// GetUserOfficeScopes lists the offices a user is limited to; an empty list means all offices
+// GET /api/admin/users/:id/office-scopes
+func (h *OfficeScopeHandler) GetUserOfficeScopes(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	user, err := h.userRepo.FindByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !officeInScope(c, user.OfficeID) {
+		rejectOutOfScope(c)
+		return
+	}
+
+	scopes, err := h.scopeRepo.FindByUserID(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch office scopes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":         scopes,
+		"unrestricted": len(scopes) == 0,
+	})
+}
+
+// UpdateUserOfficeScopes replaces the offices a user is limited to.
+// Sending an empty list removes the restriction, which only unscoped callers may do.
+// PUT /api/admin/users/:id/office-scopes
+func (h *OfficeScopeHandler) UpdateUserOfficeScopes(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req OfficeScopeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.userRepo.FindByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.Role == "employee" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Office scopes only apply to admin and HR users"})
+		return
+	}
+	// Like editing the user, this needs the user's office in scope and at least their access
+	if !officeInScope(c, user.OfficeID) {
+		rejectOutOfScope(c)
+		return
+	}
+	if !canGrantAll(c, h.authorizer.Permissions(c.Request.Context(), user.Role)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot change the office scope of this user", "code": "PERMISSION_DENIED"})
+		return
+	}
+
+	// A scoped caller can only hand out offices they administer themselves
+	if len(req.OfficeIDs) == 0 && isOfficeScoped(c) {
+		rejectOutOfScope(c)
+		return
+	}
+
+	seen := make(map[uuid.UUID]bool)
+	officeIDs := make([]uuid.UUID, 0, len(req.OfficeIDs))
+	for _, officeID := range req.OfficeIDs {
+		if seen[officeID] {
+			continue
+		}
+		seen[officeID] = true
+
+		officeID := officeID
+		if !officeInScope(c, &officeID) {
+			rejectOutOfScope(c)
+			return
+		}
+		if _, err := h.officeRepo.FindByID(c.Request.Context(), officeID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Office not found: " + officeID.String()})
+			return
+		}
+		officeIDs = append(officeIDs, officeID)
+	}
+
+	previous, err := h.scopeRepo.FindByUserID(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load office scopes"})
+		return
+	}
+	previousIDs := make([]uuid.UUID, 0, len(previous))
+	for _, scope := range previous {
+		previousIDs = append(previousIDs, scope.OfficeID)
+	}
+
+	var createdBy *uuid.UUID
+	if callerID, exists := c.Get("user_id"); exists {
+		caller := callerID.(uuid.UUID)
+		createdBy = &caller
+	}
+
+	if err := h.scopeRepo.Replace(c.Request.Context(), user.ID, officeIDs, createdBy); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update office scopes"})
+		return
+	}
+	auditChange(c, "user", user.ID.String(), gin.H{"office_ids": previousIDs}, gin.H{"office_ids": officeIDs})
+
+	scopes, _ := h.scopeRepo.FindByUserID(c.Request.Context(), user.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Office scopes updated",
+		"data":         scopes,
+		"unrestricted": len(scopes) == 0,
+	})
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/attendance-system/internal/mailer"
+	"github.com/attendance-system/internal/middleware"
+	"github.com/attendance-system/internal/models"
+	"github.com/attendance-system/internal/repository"
+	"github.com/attendance-system/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// maxResetsPerHour limits how many reset emails one account can be sent
+const maxResetsPerHour = 3
+
+// PasswordResetHandler handles the forgot-password flow
+type PasswordResetHandler struct {
+	resetRepo   *repository.PasswordResetRepository
+	userRepo    *repository.UserRepository
+	sessionRepo *repository.SessionRepository
+	denylist    *middleware.TokenDenylist
+	loginGuard  *middleware.LoginGuard
+	mailer      mailer.Mailer
+	ttl         time.Duration
+	baseURL     string
+}
+
+// NewPasswordResetHandler creates a new password reset handler; links point at baseURL and last for ttl
+func NewPasswordResetHandler(
+	resetRepo *repository.PasswordResetRepository,
+	userRepo *repository.UserRepository,
+	sessionRepo *repository.SessionRepository,
+	denylist *middleware.TokenDenylist,
+	loginGuard *middleware.LoginGuard,
+	mail mailer.Mailer,
+	ttl time.Duration,
+	baseURL string,
+) *PasswordResetHandler {
+	return &PasswordResetHandler{
+		resetRepo:   resetRepo,
+		userRepo:    userRepo,
+		sessionRepo: sessionRepo,
+		denylist:    denylist,
+		loginGuard:  loginGuard,
+		mailer:      mail,
+		ttl:         ttl,
+		baseURL:     baseURL,
+	}
+}
+
+// ForgotPasswordRequest asks for a reset link
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ForgotPassword emails a reset link. The response is the same whether or not the
+// account exists, and the email is sent in the background so timing does not tell either.
+// POST /api/auth/password/forgot
+func (h *PasswordResetHandler) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := gin.H{"message": "If an account exists for that email, a reset link has been sent"}
+
+	user, err := h.userRepo.FindByEmail(c.Request.Context(), req.Email)
+	if err != nil || !user.IsActive {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	recent, err := h.resetRepo.CountSince(c.Request.Context(), user.ID, time.Now().Add(-time.Hour))
+	if err != nil || recent >= maxResetsPerHour {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	token, err := utils.NewSecureToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create reset link"})
+		return
+	}
+	reset := &models.PasswordReset{
+		UserID:    user.ID,
+		TokenHash: utils.HashSecureToken(token),
+		ExpiresAt: time.Now().Add(h.ttl),
+		IPAddress: c.ClientIP(),
+	}
+	if err := h.resetRepo.Create(c.Request.Context(), reset); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create reset link"})
+		return
+	}
+
+	msg := mailer.Message{
+		To:      []string{user.Email},
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Someone asked to reset the password of your attendance account. "+
+			"Open this link within %d minutes to choose a new password:\n\n%s\n\n"+
+			"If you did not ask for this, ignore this email and your password stays the same.\n",
+			user.Name, int(h.ttl.Minutes()), h.baseURL+"/reset-password/"+token),
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := h.mailer.Send(ctx, msg); err != nil {
+			log.Printf("[PasswordReset] Failed to send reset email to user %s: %v", user.ID, err)
+		}
+	}()
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ResetPasswordRequest sets a new password with a reset link
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+// ResetPassword sets a new password, signs the user out everywhere and lifts any login lockout
+// POST /api/auth/password/reset
+func (h *PasswordResetHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reset, err := h.resetRepo.FindValidByTokenHash(c.Request.Context(), utils.HashSecureToken(req.Token))
+	if err != nil || reset.User == nil || !reset.User.IsActive {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reset link is invalid or has expired", "code": "RESET_TOKEN_INVALID"})
+		return
+	}
+
+	hashedPassword, err := utils.HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	if err := h.resetRepo.Consume(c.Request.Context(), reset, hashedPassword); err != nil {
+		if errors.Is(err, repository.ErrPasswordResetUnavailable) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Reset link is invalid or has expired", "code": "RESET_TOKEN_INVALID"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if _, err := h.sessionRepo.RevokeAllForUser(ctx, reset.UserID, models.SessionPasswordReset, nil); err != nil {
+		log.Printf("[PasswordReset] Failed to revoke sessions of user %s: %v", reset.UserID, err)
+	}
+	if err := h.denylist.RevokeUser(ctx, reset.UserID); err != nil {
+		log.Printf("[PasswordReset] Failed to revoke access tokens of user %s: %v", reset.UserID, err)
+	}
+	if _, err := h.loginGuard.Unlock(ctx, middleware.LoginAccount(reset.User.Email)); err != nil {
+		log.Printf("[PasswordReset] Failed to clear failed logins of user %s: %v", reset.UserID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset, you can now log in"})
+}
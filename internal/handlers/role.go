@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/attendance-system/internal/middleware"
+	"github.com/attendance-system/internal/models"
+	"github.com/attendance-system/internal/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// roleNamePattern keeps role names usable in JWT claims and query strings
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,31}$`)
+
+// RoleHandler handles role and permission management
+type RoleHandler struct {
+	roleRepo   *repository.RoleRepository
+	authorizer *middleware.Authorizer
+}
+
+// NewRoleHandler creates a new role handler
+func NewRoleHandler(roleRepo *repository.RoleRepository, authorizer *middleware.Authorizer) *RoleHandler {
+	return &RoleHandler{
+		roleRepo:   roleRepo,
+		authorizer: authorizer,
+	}
+}
+
+// hasPermission reports whether the current user's permissions, loaded by the
+// permission middleware, include permission
+func hasPermission(c *gin.Context, permission string) bool {
+	permissions, exists := c.Get("permissions")
+	if !exists {
+		return false
+	}
+	list, _ := permissions.([]string)
+	return models.PermissionsGrant(list, permission)
+}
+
+// canGrantAll reports whether the current user holds every permission in the list,
+// so nobody can hand out more access than they have
+func canGrantAll(c *gin.Context, permissions []string) bool {
+	for _, permission := range permissions {
+		if !hasPermission(c, permission) {
+			return false
+		}
+	}
+	return true
+}
+
+// RoleRequest is the body for creating or updating a role
+type RoleRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// validatePermissions checks the permission names and removes duplicates
+func validatePermissions(permissions []string) ([]string, string) {
+	seen := make(map[string]bool)
+	result := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		permission = strings.TrimSpace(permission)
+		if !models.IsKnownPermission(permission) {
+			return nil, "Unknown permission: " + permission
+		}
+		if !seen[permission] {
+			seen[permission] = true
+			result = append(result, permission)
+		}
+	}
+	return result, ""
+}
+
+// GetPermissions returns the catalog of grantable permissions
+// GET /api/admin/permissions
+func (h *RoleHandler) GetPermissions(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"data": models.PermissionCatalog})
+}
+
+// GetMyPermissions returns the permissions of the logged-in user's role
+// GET /api/users/me/permissions
+func (h *RoleHandler) GetMyPermissions(c *gin.Context) {
+	role, _ := c.Get("role")
+	roleName, _ := role.(string)
+
+	permissions := h.authorizer.Permissions(c.Request.Context(), roleName)
+	if permissions == nil {
+		permissions = []string{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"role":        roleName,
+		"permissions": permissions,
+	})
+}
+
+// GetRoles lists all roles
+// GET /api/admin/roles
+func (h *RoleHandler) GetRoles(c *gin.Context) {
+	roles, err := h.roleRepo.FindAll(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": roles})
+}
+
+// CreateRole creates a custom role
+// POST /api/admin/roles
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	var req RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.Name = strings.ToLower(strings.TrimSpace(req.Name))
+	if !roleNamePattern.MatchString(req.Name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role name must be 2-32 lowercase letters, digits, '-' or '_'"})
+		return
+	}
+
+	permissions, msg := validatePermissions(req.Permissions)
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if !canGrantAll(c, permissions) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot grant permissions you do not have", "code": "PERMISSION_DENIED"})
+		return
+	}
+
+	if existing, _ := h.roleRepo.FindByName(c.Request.Context(), req.Name); existing != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Role already exists"})
+		return
+	}
+
+	role := &models.Role{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: permissions,
+	}
+	if err := h.roleRepo.Create(c.Request.Context(), role); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create role"})
+		return
+	}
+
+	auditChange(c, "role", role.ID.String(), nil, role)
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Role created",
+		"role":    role,
+	})
+}
+
+// UpdateRole updates a role's description and permissions; custom roles can also be renamed
+// PUT /api/admin/roles/:id
+func (h *RoleHandler) UpdateRole(c *gin.Context) {
+	role, ok := h.loadRole(c)
+	if !ok {
+		return
+	}
+
+	var req RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if role.Name == "admin" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The admin role always has full access and cannot be edited"})
+		return
+	}
+	// Editing a role also takes away what it had, so it must not have more access than the caller
+	if !canGrantAll(c, h.authorizer.Permissions(c.Request.Context(), role.Name)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot manage roles with more access than your own", "code": "PERMISSION_DENIED"})
+		return
+	}
+
+	permissions, msg := validatePermissions(req.Permissions)
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if !canGrantAll(c, permissions) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot grant permissions you do not have", "code": "PERMISSION_DENIED"})
+		return
+	}
+
+	before := *role
+	if name := strings.ToLower(strings.TrimSpace(req.Name)); name != "" && name != role.Name {
+		if role.IsSystem {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Built-in roles cannot be renamed"})
+			return
+		}
+		if !roleNamePattern.MatchString(name) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Role name must be 2-32 lowercase letters, digits, '-' or '_'"})
+			return
+		}
+		if count, _ := h.roleRepo.CountUsers(c.Request.Context(), role.Name); count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Role is assigned to users and cannot be renamed"})
+			return
+		}
+		role.Name = name
+	}
+
+	role.Description = req.Description
+	role.Permissions = permissions
+	if err := h.roleRepo.Update(c.Request.Context(), role); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+	h.authorizer.Invalidate()
+
+	auditChange(c, "role", role.ID.String(), &before, role)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Role updated",
+		"role":    role,
+	})
+}
+
+// DeleteRole deletes a custom role that no user holds
+// DELETE /api/admin/roles/:id
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	role, ok := h.loadRole(c)
+	if !ok {
+		return
+	}
+
+	if role.IsSystem {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Built-in roles cannot be deleted"})
+		return
+	}
+	if !canGrantAll(c, h.authorizer.Permissions(c.Request.Context(), role.Name)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot manage roles with more access than your own", "code": "PERMISSION_DENIED"})
+		return
+	}
+
+	count, err := h.roleRepo.CountUsers(c.Request.Context(), role.Name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check role usage"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Role is still assigned to users", "users": count})
+		return
+	}
+
+	if err := h.roleRepo.Delete(c.Request.Context(), role.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete role"})
+		return
+	}
+	h.authorizer.Invalidate()
+
+	auditChange(c, "role", role.ID.String(), role, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted"})
+}
+
+// loadRole finds the role named by the :id path parameter
+func (h *RoleHandler) loadRole(c *gin.Context) (*models.Role, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		return nil, false
+	}
+
+	role, err := h.roleRepo.FindByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return nil, false
+	}
+	return role, true
+}
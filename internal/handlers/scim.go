@@ -0,0 +1,600 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/attendance-system/internal/middleware"
+	"github.com/attendance-system/internal/models"
+	"github.com/attendance-system/internal/repository"
+	"github.com/attendance-system/internal/scim"
+	"github.com/attendance-system/internal/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// scimMaxResults caps the page size of SCIM queries
+const scimMaxResults = 500
+
+// scimEnterprisePrefix starts PATCH paths into the enterprise extension, lower-cased
+var scimEnterprisePrefix = strings.ToLower(scim.SchemaEnterpriseUser) + ":"
+
+// SCIMHandler serves the SCIM 2.0 API the HR system provisions accounts through.
+// Users are users with their employee records; groups are offices and roles.
+type SCIMHandler struct {
+	scimRepo          *repository.SCIMRepository
+	userRepo          *repository.UserRepository
+	roleRepo          *repository.RoleRepository
+	sessionRepo       *repository.SessionRepository
+	denylist          *middleware.TokenDenylist
+	wsHub             *WebSocketHub
+	defaultOfficeLat  float64
+	defaultOfficeLong float64
+}
+
+// NewSCIMHandler creates a new SCIM handler
+func NewSCIMHandler(
+	scimRepo *repository.SCIMRepository,
+	userRepo *repository.UserRepository,
+	roleRepo *repository.RoleRepository,
+	sessionRepo *repository.SessionRepository,
+	denylist *middleware.TokenDenylist,
+	wsHub *WebSocketHub,
+	defaultOfficeLat, defaultOfficeLong float64,
+) *SCIMHandler {
+	return &SCIMHandler{
+		scimRepo:          scimRepo,
+		userRepo:          userRepo,
+		roleRepo:          roleRepo,
+		sessionRepo:       sessionRepo,
+		denylist:          denylist,
+		wsHub:             wsHub,
+		defaultOfficeLat:  defaultOfficeLat,
+		defaultOfficeLong: defaultOfficeLong,
+	}
+}
+
+// scimJSON responds with a SCIM document
+func scimJSON(c *gin.Context, status int, v interface{}) {
+	c.Header("Content-Type", scim.ContentType)
+	c.JSON(status, v)
+}
+
+// scimError responds with a SCIM error; scimType may be empty
+func scimError(c *gin.Context, status int, scimType, detail string) {
+	scimJSON(c, status, scim.NewError(status, scimType, detail))
+}
+
+// scimOpError is a request the SCIM spec says to refuse with a scimType
+type scimOpError struct {
+	scimType string
+	detail   string
+}
+
+func (e *scimOpError) Error() string { return e.detail }
+
+// respondSCIMOpError answers a scimOpError with 400, anything else with 500
+func respondSCIMOpError(c *gin.Context, err error, fallback string) {
+	var opErr *scimOpError
+	if errors.As(err, &opErr) {
+		scimError(c, http.StatusBadRequest, opErr.scimType, opErr.detail)
+		return
+	}
+	scimError(c, http.StatusInternalServerError, "", fallback)
+}
+
+// scimLocation returns the absolute URL of a SCIM resource
+func scimLocation(c *gin.Context, path string) string {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host + "/scim/v2/" + path
+}
+
+// scimPage reads the 1-based startIndex and the count of a query
+func scimPage(c *gin.Context) (int, int) {
+	startIndex, err := strconv.Atoi(c.DefaultQuery("startIndex", "1"))
+	if err != nil || startIndex < 1 {
+		startIndex = 1
+	}
+	count, err := strconv.Atoi(c.DefaultQuery("count", "100"))
+	if err != nil || count < 0 {
+		count = 100
+	}
+	if count > scimMaxResults {
+		count = scimMaxResults
+	}
+	return startIndex, count
+}
+
+// bindSCIM decodes a SCIM request body, responding if it is malformed
+func bindSCIM(c *gin.Context, v interface{}) bool {
+	if err := json.NewDecoder(c.Request.Body).Decode(v); err != nil {
+		scimError(c, http.StatusBadRequest, scim.ErrInvalidSyntax, "Request body is not valid JSON: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// GetServiceProviderConfig describes which SCIM features are supported
+// GET /scim/v2/ServiceProviderConfig
+func (h *SCIMHandler) GetServiceProviderConfig(c *gin.Context) {
+	scimJSON(c, http.StatusOK, gin.H{
+		"schemas":        []string{scim.SchemaServiceConfig},
+		"patch":          gin.H{"supported": true},
+		"bulk":           gin.H{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         gin.H{"supported": true, "maxResults": scimMaxResults},
+		"changePassword": gin.H{"supported": true},
+		"sort":           gin.H{"supported": false},
+		"etag":           gin.H{"supported": false},
+		"authenticationSchemes": []gin.H{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer token",
+			"description": "The token configured as SCIM_TOKEN",
+			"primary":     true,
+		}},
+	})
+}
+
+// GetResourceTypes lists the resource types
+// GET /scim/v2/ResourceTypes
+func (h *SCIMHandler) GetResourceTypes(c *gin.Context) {
+	types := []gin.H{
+		{
+			"schemas":          []string{scim.SchemaResourceType},
+			"id":               "User",
+			"name":             "User",
+			"endpoint":         "/Users",
+			"schema":           scim.SchemaUser,
+			"schemaExtensions": []gin.H{{"schema": scim.SchemaEnterpriseUser, "required": false}},
+		},
+		{
+			"schemas":          []string{scim.SchemaResourceType},
+			"id":               "Group",
+			"name":             "Group",
+			"endpoint":         "/Groups",
+			"description":      "Offices and roles",
+			"schema":           scim.SchemaGroup,
+			"schemaExtensions": []gin.H{{"schema": scim.SchemaGroupKind, "required": false}},
+		},
+	}
+	scimJSON(c, http.StatusOK, scim.NewListResponse(types, len(types), int64(len(types)), 1))
+}
+
+// rolesByName loads the roles so users can list their role group
+func (h *SCIMHandler) rolesByName(ctx context.Context) map[string]models.Role {
+	roles, _ := h.roleRepo.FindAll(ctx)
+	byName := make(map[string]models.Role, len(roles))
+	for _, role := range roles {
+		byName[role.Name] = role
+	}
+	return byName
+}
+
+// toSCIMUser renders a user loaded with office and employee record
+func (h *SCIMHandler) toSCIMUser(c *gin.Context, user *models.User, roles map[string]models.Role) scim.User {
+	active := user.IsActive
+	u := scim.User{
+		Schemas:     []string{scim.SchemaUser, scim.SchemaEnterpriseUser},
+		ID:          user.ID.String(),
+		UserName:    user.Email,
+		Name:        &scim.Name{Formatted: user.Name},
+		DisplayName: user.Name,
+		Emails:      []scim.Email{{Value: user.Email, Type: "work", Primary: true}},
+		Active:      &active,
+		Enterprise:  &scim.EnterpriseUser{EmployeeNumber: user.EmployeeID},
+		Meta: &scim.Meta{
+			ResourceType: "User",
+			Created:      user.CreatedAt,
+			LastModified: user.UpdatedAt,
+			Location:     scimLocation(c, "Users/"+user.ID.String()),
+		},
+	}
+	if user.ExternalID != nil {
+		u.ExternalID = *user.ExternalID
+	}
+	if user.Employee != nil {
+		u.Title = user.Employee.Position
+		if user.Employee.ManagerID != nil {
+			u.Enterprise.Manager = &scim.Manager{Value: user.Employee.ManagerID.String()}
+		}
+	}
+	if user.Office != nil {
+		u.Groups = append(u.Groups, scim.GroupRef{Value: user.Office.ID.String(), Display: user.Office.Name, Type: "direct"})
+	}
+	if role, ok := roles[user.Role]; ok {
+		u.Groups = append(u.Groups, scim.GroupRef{Value: role.ID.String(), Display: role.Name, Type: "direct"})
+	}
+	return u
+}
+
+// applySCIMUser copies the attributes of a SCIM user onto user and returns the ones that
+// live on the employee record. The login email is the primary email, or else userName.
+func (h *SCIMHandler) applySCIMUser(ctx context.Context, user *models.User, u *scim.User) (repository.SCIMProfile, error) {
+	var profile repository.SCIMProfile
+
+	email := u.PrimaryEmail()
+	if email == "" {
+		email = u.UserName
+	}
+	if !strings.Contains(email, "@") {
+		return profile, &scimOpError{scim.ErrInvalidValue, "userName or a primary email must be an email address"}
+	}
+
+	employeeNumber := u.ExternalID
+	if u.Enterprise != nil && u.Enterprise.EmployeeNumber != "" {
+		employeeNumber = u.Enterprise.EmployeeNumber
+	}
+	if employeeNumber == "" {
+		return profile, &scimOpError{scim.ErrInvalidValue, "employeeNumber or externalId is required"}
+	}
+
+	user.Email = email
+	user.EmployeeID = employeeNumber
+	user.Name = u.FullName()
+	if user.Name == "" {
+		user.Name = email
+	}
+	user.ExternalID = nil
+	if u.ExternalID != "" {
+		externalID := u.ExternalID
+		user.ExternalID = &externalID
+	}
+	if u.Active != nil {
+		user.IsActive = *u.Active
+	}
+
+	profile.Title = u.Title
+	if u.Enterprise != nil && u.Enterprise.Manager != nil && u.Enterprise.Manager.Value != "" {
+		managerID, err := uuid.Parse(u.Enterprise.Manager.Value)
+		if err != nil || managerID == user.ID {
+			return profile, &scimOpError{scim.ErrInvalidValue, "manager must be the id of another user"}
+		}
+		if _, err := h.userRepo.FindByID(ctx, managerID); err != nil {
+			return profile, &scimOpError{scim.ErrInvalidValue, "manager not found"}
+		}
+		profile.ManagerID = &managerID
+	}
+	return profile, nil
+}
+
+// afterUserChange signs a user out where the change calls for it and tells clients
+func (h *SCIMHandler) afterUserChange(ctx context.Context, user *models.User, signOut bool) {
+	if signOut {
+		signOutEverywhere(ctx, h.sessionRepo, h.denylist, user.ID)
+	}
+	if h.wsHub != nil {
+		h.wsHub.Broadcast(EventUserUpdated, gin.H{"user_id": user.ID})
+	}
+}
+
+// loadSCIMUser fetches the user named in the URL, responding if that fails
+func (h *SCIMHandler) loadSCIMUser(c *gin.Context) (*models.User, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		scimError(c, http.StatusNotFound, "", "User not found")
+		return nil, false
+	}
+	user, err := h.scimRepo.FindUser(c.Request.Context(), id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		scimError(c, http.StatusNotFound, "", "User not found")
+		return nil, false
+	}
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", "Failed to fetch user")
+		return nil, false
+	}
+	return user, true
+}
+
+// saveSCIMUser validates and stores a changed user, then answers with its new state
+func (h *SCIMHandler) saveSCIMUser(c *gin.Context, user *models.User, u *scim.User, create bool) {
+	ctx := c.Request.Context()
+	wasActive := user.IsActive
+
+	profile, err := h.applySCIMUser(ctx, user, u)
+	if err != nil {
+		respondSCIMOpError(c, err, "Failed to save user")
+		return
+	}
+
+	passwordSet := u.Password != ""
+	if passwordSet {
+		user.PasswordHash, err = utils.HashPassword(u.Password)
+	} else if create {
+		// Provisioned users log in through SSO or an invitation
+		user.PasswordHash, err = unusablePasswordHash()
+	}
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", "Failed to hash password")
+		return
+	}
+
+	attribute, err := h.scimRepo.FindConflict(ctx, user)
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", "Failed to save user")
+		return
+	}
+	if attribute != "" {
+		scimError(c, http.StatusConflict, scim.ErrUniqueness, attribute+" is already in use")
+		return
+	}
+
+	if create {
+		err = h.scimRepo.CreateUser(ctx, user, profile)
+	} else {
+		err = h.scimRepo.SaveUser(ctx, user, profile)
+		if err == nil && passwordSet {
+			err = h.userRepo.UpdatePasswordHash(ctx, user.ID, user.PasswordHash)
+		}
+	}
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", "Failed to save user")
+		return
+	}
+	h.afterUserChange(ctx, user, !create && (passwordSet || (wasActive && !user.IsActive)))
+
+	saved, err := h.scimRepo.FindUser(ctx, user.ID)
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", "Failed to fetch user")
+		return
+	}
+	status := http.StatusOK
+	if create {
+		status = http.StatusCreated
+		c.Header("Location", scimLocation(c, "Users/"+saved.ID.String()))
+	}
+	scimJSON(c, status, h.toSCIMUser(c, saved, h.rolesByName(ctx)))
+}
+
+// GetUsers lists users, optionally filtered, e.g. filter=userName eq "ana@example.com"
+// GET /scim/v2/Users
+func (h *SCIMHandler) GetUsers(c *gin.Context) {
+	var filter *scim.Filter
+	if expr := c.Query("filter"); expr != "" {
+		parsed, err := scim.ParseFilter(expr)
+		if err != nil {
+			scimError(c, http.StatusBadRequest, scim.ErrInvalidFilter, err.Error())
+			return
+		}
+		filter = parsed
+	}
+	startIndex, count := scimPage(c)
+
+	users, total, err := h.scimRepo.FindUsers(c.Request.Context(), filter, count, startIndex-1)
+	if errors.Is(err, repository.ErrInvalidSCIMFilter) {
+		scimError(c, http.StatusBadRequest, scim.ErrInvalidFilter, err.Error())
+		return
+	}
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", "Failed to fetch users")
+		return
+	}
+
+	roles := h.rolesByName(c.Request.Context())
+	resources := make([]scim.User, len(users))
+	for i := range users {
+		resources[i] = h.toSCIMUser(c, &users[i], roles)
+	}
+	scimJSON(c, http.StatusOK, scim.NewListResponse(resources, len(resources), total, startIndex))
+}
+
+// GetUser returns one user
+// GET /scim/v2/Users/:id
+func (h *SCIMHandler) GetUser(c *gin.Context) {
+	user, ok := h.loadSCIMUser(c)
+	if !ok {
+		return
+	}
+	scimJSON(c, http.StatusOK, h.toSCIMUser(c, user, h.rolesByName(c.Request.Context())))
+}
+
+// CreateUser provisions an account. New accounts get the employee role and no office;
+// both are assigned through group membership.
+// POST /scim/v2/Users
+func (h *SCIMHandler) CreateUser(c *gin.Context) {
+	var u scim.User
+	if !bindSCIM(c, &u) {
+		return
+	}
+
+	user := &models.User{
+		Role:          "employee",
+		IsActive:      true,
+		OfficeLat:     h.defaultOfficeLat,
+		OfficeLong:    h.defaultOfficeLong,
+		AllowedRadius: 50,
+	}
+	h.saveSCIMUser(c, user, &u, true)
+}
+
+// ReplaceUser replaces a user's provisioned attributes
+// PUT /scim/v2/Users/:id
+func (h *SCIMHandler) ReplaceUser(c *gin.Context) {
+	user, ok := h.loadSCIMUser(c)
+	if !ok {
+		return
+	}
+	var u scim.User
+	if !bindSCIM(c, &u) {
+		return
+	}
+	h.saveSCIMUser(c, user, &u, false)
+}
+
+// PatchUser applies PATCH operations, e.g. {"op": "replace", "path": "active", "value": false}
+// to deprovision. Attributes the attendance system does not keep are ignored.
+// PATCH /scim/v2/Users/:id
+func (h *SCIMHandler) PatchUser(c *gin.Context) {
+	user, ok := h.loadSCIMUser(c)
+	if !ok {
+		return
+	}
+	var req scim.PatchRequest
+	if !bindSCIM(c, &req) {
+		return
+	}
+
+	u := h.toSCIMUser(c, user, nil)
+	for _, op := range req.Operations {
+		if err := patchSCIMUser(&u, op); err != nil {
+			respondSCIMOpError(c, err, "Failed to apply patch")
+			return
+		}
+	}
+	h.saveSCIMUser(c, user, &u, false)
+}
+
+// DeleteUser deprovisions a user by deactivating the account. The user stays, with
+// active false, so attendance history keeps its owner.
+// DELETE /scim/v2/Users/:id
+func (h *SCIMHandler) DeleteUser(c *gin.Context) {
+	user, ok := h.loadSCIMUser(c)
+	if !ok {
+		return
+	}
+
+	if user.IsActive {
+		profile := repository.SCIMProfile{}
+		if user.Employee != nil {
+			profile.Title = user.Employee.Position
+			profile.ManagerID = user.Employee.ManagerID
+		}
+		user.IsActive = false
+		if err := h.scimRepo.SaveUser(c.Request.Context(), user, profile); err != nil {
+			scimError(c, http.StatusInternalServerError, "", "Failed to deactivate user")
+			return
+		}
+		h.afterUserChange(c.Request.Context(), user, true)
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// patchSCIMUser applies one PATCH operation to a user
+func patchSCIMUser(u *scim.User, op scim.PatchOperation) error {
+	kind := strings.ToLower(op.Op)
+	if kind != "add" && kind != "replace" && kind != "remove" {
+		return &scimOpError{scim.ErrInvalidSyntax, "unknown op " + op.Op}
+	}
+	path := strings.TrimPrefix(strings.ToLower(op.Path), scimEnterprisePrefix)
+
+	// Without a path, or for a complex attribute, the value holds sub-attributes to set
+	prefix := ""
+	switch path {
+	case "", strings.ToLower(scim.SchemaEnterpriseUser):
+	case "name":
+		prefix = "name."
+	default:
+		return patchSCIMUserAttribute(u, kind, path, op)
+	}
+	if kind == "remove" {
+		return &scimOpError{scim.ErrNoTarget, "remove needs a path to an attribute"}
+	}
+	var attrs map[string]json.RawMessage
+	if err := json.Unmarshal(op.Value, &attrs); err != nil {
+		return &scimOpError{scim.ErrInvalidValue, "value must be an object of attributes"}
+	}
+	for name, value := range attrs {
+		sub := scim.PatchOperation{Op: op.Op, Path: prefix + name, Value: value}
+		if err := patchSCIMUser(u, sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// patchSCIMUserAttribute sets or clears one attribute; path is lower-cased
+func patchSCIMUserAttribute(u *scim.User, kind, path string, op scim.PatchOperation) error {
+	remove := kind == "remove"
+	value, isString := op.StringValue()
+	if remove {
+		value, isString = "", true
+	}
+	if u.Enterprise == nil {
+		u.Enterprise = &scim.EnterpriseUser{}
+	}
+
+	switch {
+	case path == "active":
+		active, ok := op.BoolValue()
+		if remove || !ok {
+			return &scimOpError{scim.ErrInvalidValue, "active must be true or false"}
+		}
+		u.Active = &active
+		return nil
+	case path == "manager" || path == "manager.value":
+		var manager scim.Manager
+		if !isString && json.Unmarshal(op.Value, &manager) != nil {
+			return &scimOpError{scim.ErrInvalidValue, "manager must be a user id or {\"value\": id}"}
+		}
+		if isString {
+			manager.Value = value
+		}
+		u.Enterprise.Manager = &manager
+		return nil
+	case strings.HasPrefix(path, "emails"):
+		if !isString {
+			var emails []scim.Email
+			if err := json.Unmarshal(op.Value, &emails); err != nil || len(emails) == 0 {
+				return &scimOpError{scim.ErrInvalidValue, "emails must be a list of email objects"}
+			}
+			value = emails[0].Value
+			for _, e := range emails {
+				if e.Primary {
+					value = e.Value
+				}
+			}
+		}
+		if value == "" {
+			return &scimOpError{scim.ErrMutability, "a user needs an email address"}
+		}
+		u.Emails = []scim.Email{{Value: value, Type: "work", Primary: true}}
+		return nil
+	case path == "groups":
+		return &scimOpError{scim.ErrMutability, "groups are changed through the Groups endpoint"}
+	}
+
+	if !isString {
+		return &scimOpError{scim.ErrInvalidValue, path + " must be a string"}
+	}
+	switch path {
+	case "username":
+		if remove {
+			return &scimOpError{scim.ErrMutability, "userName cannot be removed"}
+		}
+		// userName and the primary email are the same address here, so they move together
+		if u.PrimaryEmail() == u.UserName && strings.Contains(value, "@") {
+			u.Emails = []scim.Email{{Value: value, Type: "work", Primary: true}}
+		}
+		u.UserName = value
+	case "displayname", "name.formatted":
+		u.DisplayName = value
+	case "name.givenname", "name.familyname":
+		if u.Name == nil {
+			u.Name = &scim.Name{}
+		}
+		if path == "name.givenname" {
+			u.Name.GivenName = value
+		} else {
+			u.Name.FamilyName = value
+		}
+		u.Name.Formatted = ""
+		u.DisplayName = ""
+	case "title":
+		u.Title = value
+	case "externalid":
+		u.ExternalID = value
+	case "employeenumber":
+		u.Enterprise.EmployeeNumber = value
+	case "password":
+		u.Password = value
+	}
+	return nil
+}
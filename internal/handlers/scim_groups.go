@@ -0,0 +1,389 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/attendance-system/internal/repository"
+	"github.com/attendance-system/internal/scim"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// scimMemberPath matches the value filter path members[value eq "<id>"]
+var scimMemberPath = regexp.MustCompile(`(?i)^members\[\s*value\s+eq\s+"([^"]+)"\s*\]$`)
+
+// scimGroupFilter matches the one group filter supported, displayName eq "<name>"
+var scimGroupFilter = regexp.MustCompile(`(?i)^\s*displayName\s+eq\s+"([^"]*)"\s*$`)
+
+// toSCIMGroup renders an office or role; members is nil when they were not asked for
+func toSCIMGroup(c *gin.Context, group *repository.SCIMGroup, members []scim.Member) scim.Group {
+	g := scim.Group{
+		Schemas: []string{scim.SchemaGroup, scim.SchemaGroupKind},
+		Members: members,
+	}
+	if group.Office != nil {
+		g.ID = group.Office.ID.String()
+		g.DisplayName = group.Office.Name
+		g.Kind = &scim.GroupKind{Type: "office"}
+		// Offices keep no modification time
+		g.Meta = &scim.Meta{ResourceType: "Group", Created: group.Office.CreatedAt, LastModified: group.Office.CreatedAt}
+	} else {
+		g.ID = group.Role.ID.String()
+		g.DisplayName = group.Role.Name
+		g.Kind = &scim.GroupKind{Type: "role"}
+		g.Meta = &scim.Meta{ResourceType: "Group", Created: group.Role.CreatedAt, LastModified: group.Role.UpdatedAt}
+	}
+	g.Meta.Location = scimLocation(c, "Groups/"+g.ID)
+	return g
+}
+
+// groupMembers loads the members of a group as SCIM members
+func (h *SCIMHandler) groupMembers(c *gin.Context, group *repository.SCIMGroup) ([]scim.Member, error) {
+	users, err := h.scimRepo.FindGroupMembers(c.Request.Context(), group)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]scim.Member, len(users))
+	for i, user := range users {
+		members[i] = scim.Member{
+			Value:   user.ID.String(),
+			Display: user.Name,
+			Ref:     scimLocation(c, "Users/"+user.ID.String()),
+		}
+	}
+	return members, nil
+}
+
+// loadSCIMGroup fetches the group named in the URL, responding if that fails
+func (h *SCIMHandler) loadSCIMGroup(c *gin.Context) (*repository.SCIMGroup, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		scimError(c, http.StatusNotFound, "", "Group not found")
+		return nil, false
+	}
+	group, err := h.scimRepo.FindGroup(c.Request.Context(), id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		scimError(c, http.StatusNotFound, "", "Group not found")
+		return nil, false
+	}
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", "Failed to fetch group")
+		return nil, false
+	}
+	return group, true
+}
+
+// GetGroups lists offices and roles as groups. The only filter supported is
+// displayName eq "..."; excludedAttributes=members leaves out the member lists.
+// GET /scim/v2/Groups
+func (h *SCIMHandler) GetGroups(c *gin.Context) {
+	var displayName *string
+	if expr := c.Query("filter"); expr != "" {
+		match := scimGroupFilter.FindStringSubmatch(expr)
+		if match == nil {
+			scimError(c, http.StatusBadRequest, scim.ErrInvalidFilter, `Groups can only be filtered with displayName eq "name"`)
+			return
+		}
+		displayName = &match[1]
+	}
+	withMembers := !strings.Contains(strings.ToLower(c.Query("excludedAttributes")), "members")
+	startIndex, count := scimPage(c)
+
+	groups, err := h.scimRepo.FindGroups(c.Request.Context(), displayName)
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", "Failed to fetch groups")
+		return
+	}
+	total := int64(len(groups))
+	if startIndex-1 >= len(groups) {
+		groups = nil
+	} else {
+		groups = groups[startIndex-1:]
+	}
+	if len(groups) > count {
+		groups = groups[:count]
+	}
+
+	resources := make([]scim.Group, len(groups))
+	for i := range groups {
+		var members []scim.Member
+		if withMembers {
+			if members, err = h.groupMembers(c, &groups[i]); err != nil {
+				scimError(c, http.StatusInternalServerError, "", "Failed to fetch group members")
+				return
+			}
+		}
+		resources[i] = toSCIMGroup(c, &groups[i], members)
+	}
+	scimJSON(c, http.StatusOK, scim.NewListResponse(resources, len(resources), total, startIndex))
+}
+
+// GetGroup returns one office or role with its members
+// GET /scim/v2/Groups/:id
+func (h *SCIMHandler) GetGroup(c *gin.Context) {
+	group, ok := h.loadSCIMGroup(c)
+	if !ok {
+		return
+	}
+	h.respondSCIMGroup(c, group)
+}
+
+// respondSCIMGroup answers with a group's current state
+func (h *SCIMHandler) respondSCIMGroup(c *gin.Context, group *repository.SCIMGroup) {
+	members, err := h.groupMembers(c, group)
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", "Failed to fetch group members")
+		return
+	}
+	scimJSON(c, http.StatusOK, toSCIMGroup(c, group, members))
+}
+
+// CreateGroup is refused: offices and roles are set up in the attendance system
+// POST /scim/v2/Groups
+func (h *SCIMHandler) CreateGroup(c *gin.Context) {
+	scimError(c, http.StatusNotImplemented, "", "Offices and roles are created in the attendance system")
+}
+
+// DeleteGroup is refused: offices and roles are removed in the attendance system
+// DELETE /scim/v2/Groups/:id
+func (h *SCIMHandler) DeleteGroup(c *gin.Context) {
+	scimError(c, http.StatusNotImplemented, "", "Offices and roles are deleted in the attendance system")
+}
+
+// ReplaceGroup sets the members of an office or role
+// PUT /scim/v2/Groups/:id
+func (h *SCIMHandler) ReplaceGroup(c *gin.Context) {
+	group, ok := h.loadSCIMGroup(c)
+	if !ok {
+		return
+	}
+	var g scim.Group
+	if !bindSCIM(c, &g) {
+		return
+	}
+	current := toSCIMGroup(c, group, nil)
+	if g.DisplayName != "" && g.DisplayName != current.DisplayName {
+		scimError(c, http.StatusBadRequest, scim.ErrMutability, "Offices and roles are renamed in the attendance system")
+		return
+	}
+
+	desired := make(map[uuid.UUID]bool, len(g.Members))
+	for _, m := range g.Members {
+		id, err := uuid.Parse(m.Value)
+		if err != nil {
+			scimError(c, http.StatusBadRequest, scim.ErrInvalidValue, "Member "+m.Value+" is not a user id")
+			return
+		}
+		desired[id] = true
+	}
+	h.setGroupMembers(c, group, desired)
+}
+
+// PatchGroup adds, removes or replaces members of an office or role. Joining an office
+// moves the user there; joining a role replaces the user's role, and leaving one
+// returns the user to the employee role.
+// PATCH /scim/v2/Groups/:id
+func (h *SCIMHandler) PatchGroup(c *gin.Context) {
+	group, ok := h.loadSCIMGroup(c)
+	if !ok {
+		return
+	}
+	var req scim.PatchRequest
+	if !bindSCIM(c, &req) {
+		return
+	}
+
+	members, err := h.scimRepo.FindGroupMembers(c.Request.Context(), group)
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", "Failed to fetch group members")
+		return
+	}
+	desired := make(map[uuid.UUID]bool, len(members))
+	for _, user := range members {
+		desired[user.ID] = true
+	}
+
+	displayName := toSCIMGroup(c, group, nil).DisplayName
+	for _, op := range req.Operations {
+		if err := patchSCIMGroup(desired, displayName, op); err != nil {
+			respondSCIMOpError(c, err, "Failed to apply patch")
+			return
+		}
+	}
+	h.setGroupMembers(c, group, desired)
+}
+
+// patchSCIMGroup applies one PATCH operation to the set of member IDs
+func patchSCIMGroup(members map[uuid.UUID]bool, displayName string, op scim.PatchOperation) error {
+	kind := strings.ToLower(op.Op)
+	path := strings.ToLower(op.Path)
+
+	if match := scimMemberPath.FindStringSubmatch(op.Path); match != nil {
+		if kind != "remove" {
+			return &scimOpError{scim.ErrInvalidPath, "members[value eq ...] can only be removed"}
+		}
+		id, err := uuid.Parse(match[1])
+		if err != nil {
+			return &scimOpError{scim.ErrInvalidValue, "member " + match[1] + " is not a user id"}
+		}
+		delete(members, id)
+		return nil
+	}
+
+	switch path {
+	case "":
+		if kind == "remove" {
+			return &scimOpError{scim.ErrNoTarget, "remove needs a path"}
+		}
+		var attrs map[string]json.RawMessage
+		if err := json.Unmarshal(op.Value, &attrs); err != nil {
+			return &scimOpError{scim.ErrInvalidValue, "value must be an object of attributes"}
+		}
+		for name, value := range attrs {
+			sub := scim.PatchOperation{Op: op.Op, Path: name, Value: value}
+			if err := patchSCIMGroup(members, displayName, sub); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "displayname":
+		if name, ok := op.StringValue(); ok && name == displayName && kind != "remove" {
+			return nil
+		}
+		return &scimOpError{scim.ErrMutability, "offices and roles are renamed in the attendance system"}
+	case "members":
+	default:
+		// Attributes the attendance system does not keep are ignored
+		return nil
+	}
+
+	var values []scim.Member
+	if len(op.Value) > 0 && string(op.Value) != "null" {
+		if err := json.Unmarshal(op.Value, &values); err != nil {
+			return &scimOpError{scim.ErrInvalidValue, "members must be a list of {\"value\": id}"}
+		}
+	}
+	ids := make([]uuid.UUID, len(values))
+	for i, m := range values {
+		id, err := uuid.Parse(m.Value)
+		if err != nil {
+			return &scimOpError{scim.ErrInvalidValue, "member " + m.Value + " is not a user id"}
+		}
+		ids[i] = id
+	}
+
+	switch kind {
+	case "add":
+		for _, id := range ids {
+			members[id] = true
+		}
+	case "remove":
+		if len(ids) == 0 {
+			for id := range members {
+				delete(members, id)
+			}
+		}
+		for _, id := range ids {
+			delete(members, id)
+		}
+	case "replace":
+		for id := range members {
+			delete(members, id)
+		}
+		for _, id := range ids {
+			members[id] = true
+		}
+	default:
+		return &scimOpError{scim.ErrInvalidSyntax, "unknown op " + op.Op}
+	}
+	return nil
+}
+
+// setGroupMembers makes desired the members of a group, then answers with the group
+func (h *SCIMHandler) setGroupMembers(c *gin.Context, group *repository.SCIMGroup, desired map[uuid.UUID]bool) {
+	ctx := c.Request.Context()
+
+	members, err := h.scimRepo.FindGroupMembers(ctx, group)
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", "Failed to fetch group members")
+		return
+	}
+	current := make(map[uuid.UUID]bool, len(members))
+	for _, user := range members {
+		current[user.ID] = true
+	}
+
+	var added, removed []uuid.UUID
+	for id := range desired {
+		if !current[id] {
+			if _, err := h.userRepo.FindByID(ctx, id); err != nil {
+				scimError(c, http.StatusBadRequest, scim.ErrInvalidValue, "Member "+id.String()+" not found")
+				return
+			}
+			added = append(added, id)
+		}
+	}
+	for id := range current {
+		if !desired[id] {
+			removed = append(removed, id)
+		}
+	}
+
+	changed, err := h.applyGroupMembers(ctx, group, added, removed)
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", "Failed to update group members")
+		return
+	}
+	for _, id := range changed {
+		if group.Role != nil {
+			// Permissions come from the role, so tokens issued under the old one must go
+			signOutEverywhere(ctx, h.sessionRepo, h.denylist, id)
+		}
+		if h.wsHub != nil {
+			h.wsHub.Broadcast(EventUserUpdated, gin.H{"user_id": id})
+		}
+	}
+
+	h.respondSCIMGroup(c, group)
+}
+
+// applyGroupMembers stores a membership change and returns the users it changed
+func (h *SCIMHandler) applyGroupMembers(ctx context.Context, group *repository.SCIMGroup, added, removed []uuid.UUID) ([]uuid.UUID, error) {
+	if group.Office != nil {
+		if len(added) > 0 {
+			if err := h.scimRepo.AddToOffice(ctx, group.Office, added); err != nil {
+				return nil, err
+			}
+		}
+		if len(removed) > 0 {
+			if err := h.scimRepo.RemoveFromOffice(ctx, group.Office, removed); err != nil {
+				return nil, err
+			}
+		}
+		return append(added, removed...), nil
+	}
+
+	var changed []uuid.UUID
+	if len(added) > 0 {
+		ids, err := h.scimRepo.SetRole(ctx, group.Role.Name, added, "")
+		if err != nil {
+			return nil, err
+		}
+		changed = append(changed, ids...)
+	}
+	// Everyone has a role, so leaving the employee role only happens by joining another
+	if len(removed) > 0 && group.Role.Name != "employee" {
+		ids, err := h.scimRepo.SetRole(ctx, "employee", removed, group.Role.Name)
+		if err != nil {
+			return nil, err
+		}
+		changed = append(changed, ids...)
+	}
+	return changed, nil
+}
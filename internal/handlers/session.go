@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/attendance-system/internal/middleware"
+	"github.com/attendance-system/internal/models"
+	"github.com/attendance-system/internal/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// SessionHandler lets users see and end their login sessions
+type SessionHandler struct {
+	sessionRepo *repository.SessionRepository
+	denylist    *middleware.TokenDenylist
+}
+
+// NewSessionHandler creates a new session handler
+func NewSessionHandler(sessionRepo *repository.SessionRepository, denylist *middleware.TokenDenylist) *SessionHandler {
+	return &SessionHandler{
+		sessionRepo: sessionRepo,
+		denylist:    denylist,
+	}
+}
+
+// SessionResponse is a session as listed to its owner
+type SessionResponse struct {
+	models.Session
+	Current bool `json:"current"` // The session the request was made from
+}
+
+// currentSessionID returns the session of the access token used for the request, if any
+func currentSessionID(c *gin.Context) *uuid.UUID {
+	value, _ := c.Get("session_id")
+	sessionID, _ := value.(string)
+	id, err := uuid.Parse(sessionID)
+	if err != nil {
+		return nil
+	}
+	return &id
+}
+
+// GetMySessions lists the logged-in user's active sessions
+// GET /api/users/sessions
+func (h *SessionHandler) GetMySessions(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	sessions, err := h.sessionRepo.FindActiveByUserID(c.Request.Context(), userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	current := currentSessionID(c)
+	result := make([]SessionResponse, len(sessions))
+	for i, session := range sessions {
+		result[i] = SessionResponse{
+			Session: session,
+			Current: current != nil && session.ID == *current,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// RevokeMySession ends one of the logged-in user's sessions
+// DELETE /api/users/sessions/:id
+func (h *SessionHandler) RevokeMySession(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	session, err := h.sessionRepo.FindByID(c.Request.Context(), id)
+	if err != nil || session.UserID != userID.(uuid.UUID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	if session.RevokedAt != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Session already ended"})
+		return
+	}
+
+	if err := h.sessionRepo.Revoke(c.Request.Context(), session.ID, models.SessionRevoked); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+	if err := h.denylist.RevokeSessions(c.Request.Context(), session.ID); err != nil {
+		log.Printf("[Sessions] Failed to revoke access tokens of session %s: %v", session.ID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// RevokeOtherSessions ends every session of the logged-in user except the current one
+// DELETE /api/users/sessions
+func (h *SessionHandler) RevokeOtherSessions(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	revoked, err := h.sessionRepo.RevokeAllForUser(c.Request.Context(), userID.(uuid.UUID), models.SessionRevoked, currentSessionID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+	if err := h.denylist.RevokeSessions(c.Request.Context(), revoked...); err != nil {
+		log.Printf("[Sessions] Failed to revoke access tokens of other sessions: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Other sessions revoked",
+		"revoked": len(revoked),
+	})
+}
@@ -22,6 +22,18 @@ func NewSettingsHandler(settingsRepo *repository.SettingsRepository) *SettingsHa
 	return &SettingsHandler{settingsRepo: settingsRepo}
 }
 
+// secretSettings are only shown to users who may change settings
+var secretSettings = map[string]bool{
+	"kiosk_admin_code": true,
+}
+
+// maskSecret hides the value of a secret setting from read-only users
+func maskSecret(c *gin.Context, setting *models.Setting) {
+	if secretSettings[setting.Key] && !hasPermission(c, models.PermSettingsWrite) {
+		setting.Value = "******"
+	}
+}
+
 // GetAllSettings returns all settings
 // GET /api/admin/settings
 func (h *SettingsHandler) GetAllSettings(c *gin.Context) {
@@ -33,8 +45,9 @@ func (h *SettingsHandler) GetAllSettings(c *gin.Context) {
 
 	// Convert to map for easier frontend use
 	settingsMap := make(map[string]string)
-	for _, s := range settings {
-		settingsMap[s.Key] = s.Value
+	for i := range settings {
+		maskSecret(c, &settings[i])
+		settingsMap[settings[i].Key] = settings[i].Value
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -60,11 +73,28 @@ func (h *SettingsHandler) UpdateSetting(c *gin.Context) {
 		Key:   key,
 		Value: req.Value,
 	}
+	var before gin.H // Stays nil for a setting that is new
+	previous, _ := h.settingsRepo.GetByKey(c.Request.Context(), key)
+	if previous != nil {
+		before = gin.H{"value": previous.Value}
+	}
+	after := gin.H{"value": setting.Value}
+	if secretSettings[key] {
+		// Secrets stay out of the audit log, which only shows that they changed
+		if previous != nil {
+			before = gin.H{"value": "******"}
+		}
+		after = gin.H{"value": "****** (changed)"}
+		if previous != nil && previous.Value == setting.Value {
+			after = before
+		}
+	}
 
 	if err := h.settingsRepo.Upsert(c.Request.Context(), setting); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update setting"})
 		return
 	}
+	auditChange(c, "setting", key, before, after)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Setting updated",
@@ -83,6 +113,7 @@ func (h *SettingsHandler) GetSetting(c *gin.Context) {
 		return
 	}
 
+	maskSecret(c, setting)
 	c.JSON(http.StatusOK, setting)
 }
 
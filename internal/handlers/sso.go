@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/attendance-system/internal/config"
+	"github.com/attendance-system/internal/models"
+	"github.com/attendance-system/internal/oidc"
+	"github.com/attendance-system/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// SSOHandler logs users in through the company's OpenID Connect identity provider
+type SSOHandler struct {
+	client   *oidc.Client
+	userRepo *repository.UserRepository
+	auth     *AuthHandler
+	cfg      config.OIDCConfig
+}
+
+// NewSSOHandler creates a new SSO handler; client is nil when SSO is not configured
+func NewSSOHandler(client *oidc.Client, userRepo *repository.UserRepository, auth *AuthHandler, cfg config.OIDCConfig) *SSOHandler {
+	return &SSOHandler{
+		client:   client,
+		userRepo: userRepo,
+		auth:     auth,
+		cfg:      cfg,
+	}
+}
+
+// ssoStateCookie binds a login's state to the browser that started it, so a callback
+// carrying someone else's code and state is refused
+const (
+	ssoStateCookie     = "sso_state"
+	ssoStateCookiePath = "/api/auth/sso"
+)
+
+// errSSONoAccount means the identity provider vouched for someone with no account here
+var errSSONoAccount = errors.New("no account matches the sso login")
+
+// enabled responds with SSO_DISABLED if SSO is not configured
+func (h *SSOHandler) enabled(c *gin.Context) bool {
+	if h.client == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Single sign-on is not configured", "code": "SSO_DISABLED"})
+		return false
+	}
+	return true
+}
+
+// GetSSOStatus tells the login page whether to offer single sign-on
+// GET /api/auth/sso
+func (h *SSOHandler) GetSSOStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"enabled": h.client != nil})
+}
+
+// BeginSSO returns the identity provider URL the login page should send the user to,
+// and binds the login to this browser with an HttpOnly cookie checked by FinishSSO
+// GET /api/auth/sso/authorize
+func (h *SSOHandler) BeginSSO(c *gin.Context) {
+	if !h.enabled(c) {
+		return
+	}
+
+	authURL, state, expiresAt, err := h.client.Begin(c.Request.Context())
+	if err != nil {
+		log.Printf("[SSO] Failed to start login: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Single sign-on is unavailable", "code": "SSO_UNAVAILABLE"})
+		return
+	}
+	h.setStateCookie(c, state, int(time.Until(expiresAt).Seconds()))
+
+	c.JSON(http.StatusOK, gin.H{
+		"authorization_url": authURL,
+		"expires_at":        expiresAt,
+	})
+}
+
+// SSOCallbackRequest carries what the identity provider sent back to the web app
+type SSOCallbackRequest struct {
+	Code       string `json:"code" binding:"required"`
+	State      string `json:"state" binding:"required"`
+	DeviceInfo string `json:"device_info"`
+}
+
+// FinishSSO redeems the provider's code and logs the matching user in exactly like Login,
+// including the 2FA step
+// POST /api/auth/sso/callback
+func (h *SSOHandler) FinishSSO(c *gin.Context) {
+	if !h.enabled(c) {
+		return
+	}
+	var req SSOCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	bound, _ := c.Cookie(ssoStateCookie)
+	h.setStateCookie(c, "", -1)
+	if bound == "" || subtle.ConstantTimeCompare([]byte(bound), []byte(req.State)) != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Login has expired, please try again", "code": "SSO_STATE_INVALID"})
+		return
+	}
+
+	claims, err := h.client.Finish(c.Request.Context(), req.Code, req.State)
+	if errors.Is(err, oidc.ErrInvalidState) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Login has expired, please try again", "code": "SSO_STATE_INVALID"})
+		return
+	}
+	if err != nil {
+		log.Printf("[SSO] Failed to finish login from %s: %v", c.ClientIP(), err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Single sign-on failed", "code": "SSO_FAILED"})
+		return
+	}
+
+	user, err := h.resolveUser(c.Request.Context(), claims)
+	if errors.Is(err, errSSONoAccount) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "No account is linked to this login", "code": "SSO_NO_ACCOUNT"})
+		return
+	}
+	if err != nil {
+		log.Printf("[SSO] Failed to provision user %s: %v", claims.Subject, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create account"})
+		return
+	}
+	if !user.IsActive {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account is deactivated"})
+		return
+	}
+
+	h.auth.completeLogin(c, user, req.DeviceInfo)
+}
+
+// setStateCookie sets or, with a negative maxAge, clears the state cookie
+func (h *SSOHandler) setStateCookie(c *gin.Context, state string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(ssoStateCookie, state, maxAge, ssoStateCookiePath, "", c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https", true)
+}
+
+// emailTrusted reports whether the claims' email may identify an account: the provider
+// asserted email_verified, or the deployment trusts every email it issues
+func (h *SSOHandler) emailTrusted(claims *oidc.Claims) bool {
+	return claims.Email != "" && (claims.EmailVerified || h.cfg.TrustEmail)
+}
+
+// resolveUser finds the user the claims describe: by verified email first, then by
+// employee ID. Unknown users are created if just-in-time provisioning is on.
+func (h *SSOHandler) resolveUser(ctx context.Context, claims *oidc.Claims) (*models.User, error) {
+	if h.emailTrusted(claims) {
+		if user, err := h.userRepo.FindByEmail(ctx, claims.Email); err == nil {
+			return user, nil
+		}
+	}
+	employeeID := claims.String(h.cfg.EmployeeIDClaim)
+	if employeeID != "" {
+		if user, err := h.userRepo.FindByEmployeeID(ctx, employeeID); err == nil {
+			return user, nil
+		}
+	}
+
+	// Provisioned accounts need both keys the users table is unique on
+	if !h.cfg.JITProvisioning || employeeID == "" || !h.emailTrusted(claims) {
+		return nil, errSSONoAccount
+	}
+
+	name := claims.Name
+	if name == "" {
+		name = claims.String("preferred_username")
+	}
+	if name == "" {
+		name = claims.Email
+	}
+	passwordHash, err := unusablePasswordHash()
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{
+		EmployeeID:   employeeID,
+		Name:         name,
+		Email:        claims.Email,
+		PasswordHash: passwordHash,
+		Role:         h.cfg.JITRole,
+		OfficeLat:    h.auth.defaultOfficeLat,
+		OfficeLong:   h.auth.defaultOfficeLong,
+		IsActive:     true,
+	}
+	if err := h.userRepo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	log.Printf("[SSO] Provisioned user %s (%s) on first login", user.ID, user.Email)
+	return user, nil
+}
@@ -159,6 +159,11 @@ func (h *TransferRequestHandler) ApproveRequest(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
+	// Approving moves the employee, so both ends must be within the reviewer's offices
+	if !officeInScope(c, user.OfficeID) || !officeInScope(c, request.RequestedOfficeID) {
+		rejectOutOfScope(c)
+		return
+	}
 
 	user.OfficeLat = request.RequestedOfficeLat
 	user.OfficeLong = request.RequestedOfficeLong
@@ -176,11 +181,13 @@ func (h *TransferRequestHandler) ApproveRequest(c *gin.Context) {
 	}
 
 	// Update request status
+	before := *request
 	request.Status = "approved"
 	if err := h.transferRepo.Update(c.Request.Context(), request); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update request"})
 		return
 	}
+	auditChange(c, "transfer_request", request.ID.String(), &before, request)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Transfer request approved",
@@ -213,7 +220,12 @@ func (h *TransferRequestHandler) RejectRequest(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Request already processed"})
 		return
 	}
+	if !officeInScope(c, request.CurrentOfficeID) && !officeInScope(c, request.RequestedOfficeID) {
+		rejectOutOfScope(c)
+		return
+	}
 
+	before := *request
 	request.Status = "rejected"
 	request.AdminNote = req.AdminNote
 
@@ -221,6 +233,7 @@ func (h *TransferRequestHandler) RejectRequest(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update request"})
 		return
 	}
+	auditChange(c, "transfer_request", request.ID.String(), &before, request)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Transfer request rejected",
@@ -0,0 +1,353 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/attendance-system/internal/middleware"
+	"github.com/attendance-system/internal/models"
+	"github.com/attendance-system/internal/repository"
+	"github.com/attendance-system/internal/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	qrcode "github.com/skip2/go-qrcode"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// twoFactorPolicySetting names the setting holding the comma-separated roles that must use 2FA,
+// e.g. "admin,hr". Unset or empty leaves 2FA optional for everyone.
+const twoFactorPolicySetting = "two_factor_required_roles"
+
+// recoveryCodeCount is how many recovery codes a user gets at a time
+const recoveryCodeCount = 10
+
+// TwoFactorHandler handles TOTP enrollment, recovery codes and the 2FA policy
+type TwoFactorHandler struct {
+	twoFactorRepo *repository.TwoFactorRepository
+	userRepo      *repository.UserRepository
+	settingsRepo  *repository.SettingsRepository
+	auditRepo     *repository.AuditLogRepository
+	authorizer    *middleware.Authorizer
+}
+
+// NewTwoFactorHandler creates a new two-factor handler
+func NewTwoFactorHandler(
+	twoFactorRepo *repository.TwoFactorRepository,
+	userRepo *repository.UserRepository,
+	settingsRepo *repository.SettingsRepository,
+	auditRepo *repository.AuditLogRepository,
+	authorizer *middleware.Authorizer,
+) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		twoFactorRepo: twoFactorRepo,
+		userRepo:      userRepo,
+		settingsRepo:  settingsRepo,
+		auditRepo:     auditRepo,
+		authorizer:    authorizer,
+	}
+}
+
+// TwoFactorSetup is what an authenticator app needs to enroll
+type TwoFactorSetup struct {
+	Secret     string `json:"secret"`      // For typing in by hand
+	OTPAuthURI string `json:"otpauth_uri"` // What the QR code encodes
+	QRCode     string `json:"qr_code"`     // PNG data URL of the QR code
+}
+
+// required reports whether the 2FA policy applies to a role
+func (h *TwoFactorHandler) required(ctx context.Context, role string) bool {
+	setting, _ := h.settingsRepo.GetByKey(ctx, twoFactorPolicySetting)
+	if setting == nil {
+		return false
+	}
+	for _, r := range strings.Split(setting.Value, ",") {
+		if strings.TrimSpace(r) == role {
+			return true
+		}
+	}
+	return false
+}
+
+// beginSetup gives a user a new secret to enroll; it does not turn 2FA on
+func (h *TwoFactorHandler) beginSetup(ctx context.Context, user *models.User) (*TwoFactorSetup, error) {
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := h.twoFactorRepo.SaveSecret(ctx, user.ID, secret); err != nil {
+		return nil, err
+	}
+
+	issuer := "Attendance System"
+	if setting, _ := h.settingsRepo.GetByKey(ctx, "company_name"); setting != nil && setting.Value != "" {
+		issuer = setting.Value
+	}
+	uri := utils.TOTPProvisioningURI(issuer, user.Email, secret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TwoFactorSetup{
+		Secret:     secret,
+		OTPAuthURI: uri,
+		QRCode:     "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	}, nil
+}
+
+// newRecoveryCodes returns fresh recovery codes and the hashes to store
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = utils.HashSecureToken(code)
+	}
+	return codes, hashes, nil
+}
+
+// confirmSetup turns 2FA on once the user proves their app produces valid codes.
+// It returns the new recovery codes, or nil if the code was wrong.
+func (h *TwoFactorHandler) confirmSetup(ctx context.Context, user *models.User, code string) ([]string, error) {
+	if user.TwoFactorEnabled || user.TOTPSecret == "" {
+		return nil, nil
+	}
+	counter, ok := utils.MatchTOTP(user.TOTPSecret, code, time.Now())
+	if !ok {
+		return nil, nil
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := h.twoFactorRepo.Enable(ctx, user.ID, counter, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// verify checks a TOTP code, or a recovery code if no TOTP code is given. Each works once.
+func (h *TwoFactorHandler) verify(ctx context.Context, user *models.User, code, recoveryCode string) (bool, error) {
+	if !user.TwoFactorEnabled {
+		return false, nil
+	}
+	if code != "" {
+		counter, ok := utils.MatchTOTP(user.TOTPSecret, code, time.Now())
+		if !ok {
+			return false, nil
+		}
+		return h.twoFactorRepo.UseCounter(ctx, user.ID, counter)
+	}
+	if recoveryCode != "" {
+		return h.twoFactorRepo.UseRecoveryCode(ctx, user.ID, utils.HashSecureToken(utils.NormalizeRecoveryCode(recoveryCode)))
+	}
+	return false, nil
+}
+
+// loadCurrentUser fetches the logged-in user, responding if that fails
+func (h *TwoFactorHandler) loadCurrentUser(c *gin.Context) (*models.User, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+	user, err := h.userRepo.FindByID(c.Request.Context(), userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil, false
+	}
+	return user, true
+}
+
+// GetMyTwoFactor returns the logged-in user's 2FA status
+// GET /api/users/2fa
+func (h *TwoFactorHandler) GetMyTwoFactor(c *gin.Context) {
+	user, ok := h.loadCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	var remaining int64
+	if user.TwoFactorEnabled {
+		remaining, _ = h.twoFactorRepo.CountUnusedRecoveryCodes(c.Request.Context(), user.ID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":                  user.TwoFactorEnabled,
+		"required":                 h.required(c.Request.Context(), user.Role),
+		"recovery_codes_remaining": remaining,
+	})
+}
+
+// SetupMyTwoFactor starts enrollment with a new secret
+// POST /api/users/2fa/setup
+func (h *TwoFactorHandler) SetupMyTwoFactor(c *gin.Context) {
+	user, ok := h.loadCurrentUser(c)
+	if !ok {
+		return
+	}
+	if user.TwoFactorEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	setup, err := h.beginSetup(c.Request.Context(), user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start two-factor setup"})
+		return
+	}
+
+	c.JSON(http.StatusOK, setup)
+}
+
+// TwoFactorCodeRequest carries a code from the authenticator app
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// ConfirmMyTwoFactor finishes enrollment and returns recovery codes, shown this once only
+// POST /api/users/2fa/confirm
+func (h *TwoFactorHandler) ConfirmMyTwoFactor(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := h.loadCurrentUser(c)
+	if !ok {
+		return
+	}
+	if user.TwoFactorEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	codes, err := h.confirmSetup(c.Request.Context(), user, req.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+	if codes == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid verification code", "code": "INVALID_2FA_CODE"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTwoFactorRequest proves both factors before 2FA is turned off
+type DisableTwoFactorRequest struct {
+	Password     string `json:"password" binding:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// DisableMyTwoFactor turns 2FA off, unless the policy requires it for the user's role
+// POST /api/users/2fa/disable
+func (h *TwoFactorHandler) DisableMyTwoFactor(c *gin.Context) {
+	var req DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := h.loadCurrentUser(c)
+	if !ok {
+		return
+	}
+	if !user.TwoFactorEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+	if h.required(c.Request.Context(), user.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is required for your role", "code": "TWO_FACTOR_REQUIRED"})
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Password is incorrect"})
+		return
+	}
+	if ok, err := h.verify(c.Request.Context(), user, req.Code, req.RecoveryCode); err != nil || !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid verification code", "code": "INVALID_2FA_CODE"})
+		return
+	}
+
+	if err := h.twoFactorRepo.Disable(c.Request.Context(), user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateMyRecoveryCodes replaces the recovery codes, e.g. when few are left
+// POST /api/users/2fa/recovery-codes
+func (h *TwoFactorHandler) RegenerateMyRecoveryCodes(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := h.loadCurrentUser(c)
+	if !ok {
+		return
+	}
+	if ok, err := h.verify(c.Request.Context(), user, req.Code, ""); err != nil || !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid verification code", "code": "INVALID_2FA_CODE"})
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create recovery codes"})
+		return
+	}
+	if err := h.twoFactorRepo.ReplaceRecoveryCodes(c.Request.Context(), user.ID, hashes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save recovery codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// ResetUserTwoFactor removes a user's 2FA, e.g. after a lost phone. If the policy
+// requires 2FA for them they enroll again at their next login.
+// POST /api/admin/users/:id/2fa/reset
+func (h *TwoFactorHandler) ResetUserTwoFactor(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	user, err := h.userRepo.FindByID(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !officeInScope(c, user.OfficeID) {
+		rejectOutOfScope(c)
+		return
+	}
+	if !canGrantAll(c, h.authorizer.Permissions(c.Request.Context(), user.Role)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot manage users with more access than your own", "code": "PERMISSION_DENIED"})
+		return
+	}
+
+	if err := h.twoFactorRepo.Disable(c.Request.Context(), user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset two-factor authentication"})
+		return
+	}
+	recordAudit(c, h.auditRepo, models.AuditTwoFactorReset, "user", user.ID.String(), gin.H{"email": user.Email})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset"})
+}
@@ -12,6 +12,7 @@ import (
 	"encoding/json"
 	"strconv"
 
+	"github.com/attendance-system/internal/middleware"
 	"github.com/attendance-system/internal/models"
 	"github.com/attendance-system/internal/repository"
 	"github.com/gin-gonic/gin"
@@ -24,6 +25,13 @@ type UserHandler struct {
 	userRepo          *repository.UserRepository
 	employeeRepo      *repository.EmployeeRepository
 	officeRepo        *repository.OfficeRepository
+	scopeRepo         *repository.OfficeScopeRepository
+	roleRepo          *repository.RoleRepository
+	sessionRepo       *repository.SessionRepository
+	denylist          *middleware.TokenDenylist
+	loginGuard        *middleware.LoginGuard
+	auditRepo         *repository.AuditLogRepository
+	authorizer        *middleware.Authorizer
 	wsHub             *WebSocketHub
 	defaultOfficeLat  float64
 	defaultOfficeLong float64
@@ -34,6 +42,13 @@ func NewUserHandler(
 	userRepo *repository.UserRepository,
 	employeeRepo *repository.EmployeeRepository,
 	officeRepo *repository.OfficeRepository,
+	scopeRepo *repository.OfficeScopeRepository,
+	roleRepo *repository.RoleRepository,
+	sessionRepo *repository.SessionRepository,
+	denylist *middleware.TokenDenylist,
+	loginGuard *middleware.LoginGuard,
+	auditRepo *repository.AuditLogRepository,
+	authorizer *middleware.Authorizer,
 	wsHub *WebSocketHub,
 	defaultOfficeLat, defaultOfficeLong float64,
 ) *UserHandler {
@@ -41,6 +56,13 @@ func NewUserHandler(
 		userRepo:          userRepo,
 		employeeRepo:      employeeRepo,
 		officeRepo:        officeRepo,
+		scopeRepo:         scopeRepo,
+		roleRepo:          roleRepo,
+		sessionRepo:       sessionRepo,
+		denylist:          denylist,
+		loginGuard:        loginGuard,
+		auditRepo:         auditRepo,
+		authorizer:        authorizer,
 		wsHub:             wsHub,
 		defaultOfficeLat:  defaultOfficeLat,
 		defaultOfficeLong: defaultOfficeLong,
@@ -241,6 +263,10 @@ func (h *UserHandler) ChangePassword(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
 		return
 	}
+	if user.MustChangePassword && req.NewPassword == req.CurrentPassword {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "New password must differ from the temporary one"})
+		return
+	}
 
 	// Hash new password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
@@ -255,17 +281,112 @@ func (h *UserHandler) ChangePassword(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+	// Sign out every other device; the one that changed the password stays logged in
+	revoked, err := h.sessionRepo.RevokeAllForUser(c.Request.Context(), user.ID, models.SessionLogoutAll, currentSessionID(c))
+	if err != nil {
+		log.Printf("[ChangePassword] Failed to revoke sessions of user %s: %v", user.ID, err)
+	}
+	if err := h.denylist.RevokeSessions(c.Request.Context(), revoked...); err != nil {
+		log.Printf("[ChangePassword] Failed to revoke access tokens of user %s: %v", user.ID, err)
+	}
+
+	// The access token used here was limited to this endpoint; refreshing lifts the limit
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "Password changed successfully",
+		"refresh_required": user.MustChangePassword,
+	})
 }
 
 // CreateUserRequest represents create user payload
 type CreateUserRequest struct {
-	EmployeeID string `json:"employee_id" binding:"required"`
-	Name       string `json:"name" binding:"required"`
-	Email      string `json:"email" binding:"required,email"`
-	Password   string `json:"password" binding:"required,min=6"`
-	Role       string `json:"role" binding:"required,oneof=admin hr employee"`
-	OfficeID   string `json:"office_id"`
+	EmployeeID string      `json:"employee_id" binding:"required"`
+	Name       string      `json:"name" binding:"required"`
+	Email      string      `json:"email" binding:"required,email"`
+	Password   string      `json:"password" binding:"required,min=6"`
+	Role       string      `json:"role" binding:"required"`
+	OfficeID   string      `json:"office_id"`
+	OfficeIDs  []uuid.UUID `json:"office_ids"` // Office scope for admin and HR roles; see officeScopeFor
+}
+
+// checkRoleAccess makes sure the role exists and the caller holds every permission it grants,
+// so nobody can create, promote or take over an account with more access than their own
+func (h *UserHandler) checkRoleAccess(c *gin.Context, roleName string) bool {
+	role, err := h.roleRepo.FindByName(c.Request.Context(), roleName)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown role: " + roleName})
+		return false
+	}
+
+	if !canGrantAll(c, h.authorizer.Permissions(c.Request.Context(), role.Name)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot manage users with more access than your own", "code": "PERMISSION_DENIED"})
+		return false
+	}
+	return true
+}
+
+// checkScopeAccess makes sure an office-scoped caller only manages admin and HR users
+// whose own scope lies inside theirs; an unscoped account sees every office
+func (h *UserHandler) checkScopeAccess(c *gin.Context, user *models.User) bool {
+	if !isOfficeScoped(c) || user.Role == "employee" {
+		return true
+	}
+	officeIDs, err := h.scopeRepo.FindOfficeIDs(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load office scopes"})
+		return false
+	}
+	if len(officeIDs) == 0 {
+		rejectOutOfScope(c)
+		return false
+	}
+	for _, officeID := range officeIDs {
+		officeID := officeID
+		if !officeInScope(c, &officeID) {
+			rejectOutOfScope(c)
+			return false
+		}
+	}
+	return true
+}
+
+// officeScopeFor works out the office scope of an account given an admin or HR role.
+// It is the requested offices, or for an office-scoped caller who requests none, the
+// caller's own offices, since an account without a scope sees every office. Requested
+// offices must exist and lie inside the caller's scope. Employee roles and unscoped
+// callers requesting nothing get nil, leaving the scope alone.
+func (h *UserHandler) officeScopeFor(c *gin.Context, role string, requested []uuid.UUID) ([]uuid.UUID, bool) {
+	if role == "employee" {
+		if len(requested) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Office scopes only apply to admin and HR users"})
+			return nil, false
+		}
+		return nil, true
+	}
+	if len(requested) == 0 {
+		officeIDs, _ := repository.OfficeScopeFrom(c.Request.Context())
+		return officeIDs, true
+	}
+
+	seen := make(map[uuid.UUID]bool)
+	officeIDs := make([]uuid.UUID, 0, len(requested))
+	for _, officeID := range requested {
+		if seen[officeID] {
+			continue
+		}
+		seen[officeID] = true
+
+		officeID := officeID
+		if !officeInScope(c, &officeID) {
+			rejectOutOfScope(c)
+			return nil, false
+		}
+		if _, err := h.officeRepo.FindByID(c.Request.Context(), officeID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Office not found: " + officeID.String()})
+			return nil, false
+		}
+		officeIDs = append(officeIDs, officeID)
+	}
+	return officeIDs, true
 }
 
 // CreateUser creates a new user (admin only)
@@ -277,6 +398,10 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 		return
 	}
 
+	if !h.checkRoleAccess(c, req.Role) {
+		return
+	}
+
 	// Check if email already exists
 	existing, _ := h.userRepo.FindByEmail(c.Request.Context(), req.Email)
 	if existing != nil {
@@ -334,6 +459,14 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 		officeLong = office.Longitude
 		allowedRadius = office.Radius
 	}
+	if !officeInScope(c, officeID) {
+		rejectOutOfScope(c)
+		return
+	}
+	scopes, ok := h.officeScopeFor(c, req.Role, req.OfficeIDs)
+	if !ok {
+		return
+	}
 
 	// Create user
 	user := &models.User{
@@ -347,12 +480,15 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 		OfficeLong:    officeLong,
 		AllowedRadius: allowedRadius,
 		IsActive:      true,
+
+		MustChangePassword: true, // The admin chose the password
 	}
 
-	if err := h.userRepo.Create(c.Request.Context(), user); err != nil {
+	if err := h.userRepo.CreateWithOfficeScopes(c.Request.Context(), user, scopes, callerID(c)); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
 	}
+	auditChange(c, "user", user.ID.String(), nil, auditedUser{User: user, OfficeIDs: scopes})
 
 	// Broadcast update
 	if h.wsHub != nil {
@@ -382,13 +518,23 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 
 // UpdateUserRequest represents update user payload
 type UpdateUserRequest struct {
-	Name                   string `json:"name"`
-	Email                  string `json:"email" binding:"omitempty,email"`
-	Role                   string `json:"role" binding:"omitempty,oneof=admin hr employee"`
-	Password               string `json:"password" binding:"omitempty,min=6"`
-	IsActive               *bool  `json:"is_active"`
-	OfficeID               string `json:"office_id"`
-	FaceVerificationStatus string `json:"face_verification_status"` // "none", "pending", "verified", "rejected"
+	Name                   string      `json:"name"`
+	Email                  string      `json:"email" binding:"omitempty,email"`
+	Role                   string      `json:"role"`
+	Password               string      `json:"password" binding:"omitempty,min=6"`
+	IsActive               *bool       `json:"is_active"`
+	OfficeID               string      `json:"office_id"`
+	FaceVerificationStatus string      `json:"face_verification_status"` // "none", "pending", "verified", "rejected"
+	MustChangePassword     *bool       `json:"must_change_password"`     // Setting a password turns this on unless sent as false
+	OfficeIDs              []uuid.UUID `json:"office_ids"`               // Office scope when changing to an admin or HR role
+}
+
+// auditedUser is a user as the audit log sees it, noting whether the password was set
+// and the office scope it was given
+type auditedUser struct {
+	*models.User
+	PasswordSet bool        `json:"password_set,omitempty"`
+	OfficeIDs   []uuid.UUID `json:"office_ids,omitempty"`
 }
 
 // UpdateUser updates a user (admin only)
@@ -414,6 +560,37 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
+	if !h.checkRoleAccess(c, user.Role) {
+		return
+	}
+	if req.Role != "" && req.Role != user.Role && !h.checkRoleAccess(c, req.Role) {
+		return
+	}
+	if !officeInScope(c, user.OfficeID) {
+		rejectOutOfScope(c)
+		return
+	}
+	if !h.checkScopeAccess(c, user) {
+		return
+	}
+
+	// A role change sets the office scope the same way creating the account does
+	var scopes []uuid.UUID
+	if req.Role != "" && req.Role != user.Role {
+		var ok bool
+		if scopes, ok = h.officeScopeFor(c, req.Role, req.OfficeIDs); !ok {
+			return
+		}
+	}
+
+	previous := *user
+	before := auditedUser{User: &previous}
+
+	// Losing access or credentials must take effect on every device right away
+	signOut := (req.Role != "" && req.Role != user.Role) ||
+		(req.IsActive != nil && !*req.IsActive && user.IsActive) ||
+		req.Password != ""
+
 	// Update fields if provided
 	if req.Name != "" {
 		user.Name = req.Name
@@ -438,6 +615,10 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 			return
 		}
 		user.PasswordHash = string(hashedPassword)
+		user.MustChangePassword = true
+	}
+	if req.MustChangePassword != nil {
+		user.MustChangePassword = *req.MustChangePassword
 	}
 	// Handle office change
 	if req.OfficeID != "" {
@@ -446,6 +627,10 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid office ID"})
 			return
 		}
+		if !officeInScope(c, &officeUUID) {
+			rejectOutOfScope(c)
+			return
+		}
 		office, err := h.officeRepo.FindByID(c.Request.Context(), officeUUID)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Office not found"})
@@ -461,7 +646,12 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 	}
 
 	// Use Select to explicitly specify which fields to update
-	if err := h.userRepo.UpdateWithSelect(c.Request.Context(), user); err != nil {
+	if scopes != nil {
+		err = h.userRepo.UpdateWithOfficeScopes(c.Request.Context(), user, scopes, callerID(c))
+	} else {
+		err = h.userRepo.UpdateWithSelect(c.Request.Context(), user)
+	}
+	if err != nil {
 		// Simplify error handling for now
 		log.Printf("[UpdateUser] Error updating user: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user: " + err.Error()})
@@ -469,6 +659,11 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 	}
 	
 	log.Printf("[UpdateUser] Successfully updated user %s, office_id: %v", userID, user.OfficeID)
+	auditChange(c, "user", user.ID.String(), before, auditedUser{User: user, PasswordSet: req.Password != "", OfficeIDs: scopes})
+
+	if signOut {
+		h.signOutEverywhere(c.Request.Context(), user.ID)
+	}
 
 	// Broadcast update to client (mobile/web)
 	if h.wsHub != nil {
@@ -504,14 +699,130 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 		return
 	}
 
+	user, err := h.userRepo.FindByID(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !h.checkRoleAccess(c, user.Role) {
+		return
+	}
+	if !officeInScope(c, user.OfficeID) {
+		rejectOutOfScope(c)
+		return
+	}
+	if !h.checkScopeAccess(c, user) {
+		return
+	}
+
 	if err := h.userRepo.Delete(c.Request.Context(), userID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
 		return
 	}
+	auditChange(c, "user", userID.String(), user, nil)
+	h.signOutEverywhere(c.Request.Context(), userID)
 
 	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
 }
 
+// signOutEverywhere ends every session of a user and refuses the access tokens already
+// issued to them. Failures are logged rather than failing the change that caused them.
+func (h *UserHandler) signOutEverywhere(ctx context.Context, userID uuid.UUID) {
+	signOutEverywhere(ctx, h.sessionRepo, h.denylist, userID)
+}
+
+// signOutEverywhere is shared by the handlers that change accounts
+func signOutEverywhere(ctx context.Context, sessionRepo *repository.SessionRepository, denylist *middleware.TokenDenylist, userID uuid.UUID) {
+	if _, err := sessionRepo.RevokeAllForUser(ctx, userID, models.SessionLogoutAll, nil); err != nil {
+		log.Printf("[Sessions] Failed to revoke sessions of user %s: %v", userID, err)
+	}
+	if err := denylist.RevokeUser(ctx, userID); err != nil {
+		log.Printf("[Sessions] Failed to revoke access tokens of user %s: %v", userID, err)
+	}
+}
+
+// LogoutEverywhere ends every session of a user, e.g. after a lost phone,
+// and revokes the access tokens already issued to them.
+// POST /api/admin/users/:id/logout-everywhere
+func (h *UserHandler) LogoutEverywhere(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	user, err := h.userRepo.FindByID(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !h.checkRoleAccess(c, user.Role) {
+		return
+	}
+	if !officeInScope(c, user.OfficeID) {
+		rejectOutOfScope(c)
+		return
+	}
+	if !h.checkScopeAccess(c, user) {
+		return
+	}
+
+	revoked, err := h.sessionRepo.RevokeAllForUser(c.Request.Context(), user.ID, models.SessionLogoutAll, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+	if err := h.denylist.RevokeUser(c.Request.Context(), user.ID); err != nil {
+		log.Printf("[Sessions] Failed to revoke access tokens of user %s: %v", user.ID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User logged out everywhere",
+		"revoked": len(revoked),
+	})
+}
+
+// UnlockUser lifts a login lockout and clears the user's failed logins
+// POST /api/admin/users/:id/unlock
+func (h *UserHandler) UnlockUser(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	user, err := h.userRepo.FindByID(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !h.checkRoleAccess(c, user.Role) {
+		return
+	}
+	if !officeInScope(c, user.OfficeID) {
+		rejectOutOfScope(c)
+		return
+	}
+	if !h.checkScopeAccess(c, user) {
+		return
+	}
+
+	account := middleware.LoginAccount(user.Email)
+	wasLocked, err := h.loginGuard.Unlock(c.Request.Context(), account)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to unlock account"})
+		return
+	}
+	if wasLocked {
+		recordAudit(c, h.auditRepo, models.AuditAccountUnlocked, "user", user.ID.String(), gin.H{"email": account})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Account unlocked",
+		"was_locked": wasLocked,
+	})
+}
+
 // UploadAvatar handles avatar upload
 // POST /api/users/:id/avatar
 func (h *UserHandler) UploadAvatar(c *gin.Context) {
@@ -522,6 +833,16 @@ func (h *UserHandler) UploadAvatar(c *gin.Context) {
 		return
 	}
 
+	user, err := h.userRepo.FindByID(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !officeInScope(c, user.OfficeID) {
+		rejectOutOfScope(c)
+		return
+	}
+
 	// Get file from request
 	file, err := c.FormFile("avatar")
 	if err != nil {
@@ -549,11 +870,6 @@ func (h *UserHandler) UploadAvatar(c *gin.Context) {
 
 	// Update user record
 	avatarURL := "/uploads/avatars/" + filename
-	user, err := h.userRepo.FindByID(c.Request.Context(), userID)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		return
-	}
 
 	user.AvatarURL = avatarURL
 	if err := h.userRepo.Update(c.Request.Context(), user); err != nil {
@@ -0,0 +1,857 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/attendance-system/internal/models"
+	"github.com/attendance-system/internal/repository"
+	"github.com/attendance-system/internal/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// VisitorHandler handles visitor pre-registration, kiosk visitor check-in/out and the visitor log
+type VisitorHandler struct {
+	visitorRepo  *repository.VisitorRepository
+	userRepo     *repository.UserRepository
+	kioskRepo    *repository.KioskRepository
+	settingsRepo *repository.SettingsRepository
+	qrSigner     *utils.QRSigner
+	wsHub        *WebSocketHub
+}
+
+// NewVisitorHandler creates a new visitor handler
+func NewVisitorHandler(
+	visitorRepo *repository.VisitorRepository,
+	userRepo *repository.UserRepository,
+	kioskRepo *repository.KioskRepository,
+	settingsRepo *repository.SettingsRepository,
+	qrSigner *utils.QRSigner,
+	wsHub *WebSocketHub,
+) *VisitorHandler {
+	return &VisitorHandler{
+		visitorRepo:  visitorRepo,
+		userRepo:     userRepo,
+		kioskRepo:    kioskRepo,
+		settingsRepo: settingsRepo,
+		qrSigner:     qrSigner,
+		wsHub:        wsHub,
+	}
+}
+
+// VisitorRequest is the guest information shared by pre-registration and walk-in check-in
+type VisitorRequest struct {
+	Name       string     `json:"name" binding:"required"`
+	Company    string     `json:"company"`
+	Phone      string     `json:"phone"`
+	Email      string     `json:"email"`
+	Purpose    string     `json:"purpose"`
+	ExpectedAt *time.Time `json:"expected_at"`
+}
+
+// VisitorEvent is pushed to the host and the front desk when a visitor arrives or leaves
+type VisitorEvent struct {
+	VisitorID uuid.UUID `json:"visitor_id"`
+	Name      string    `json:"name"`
+	Company   string    `json:"company,omitempty"`
+	Purpose   string    `json:"purpose,omitempty"`
+	PhotoURL  string    `json:"photo_url,omitempty"`
+	HostID    uuid.UUID `json:"host_id"`
+	HostName  string    `json:"host_name"`
+	OfficeID  uuid.UUID `json:"office_id"`
+	KioskID   string    `json:"kiosk_id,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// newVisitor builds an expected visitor with a fresh pass code
+func newVisitor(req VisitorRequest, host *models.User, officeID uuid.UUID) (*models.Visitor, error) {
+	passCode, err := utils.NewVisitorPassCode()
+	if err != nil {
+		return nil, err
+	}
+	return &models.Visitor{
+		OfficeID:   officeID,
+		HostID:     host.ID,
+		Name:       strings.TrimSpace(req.Name),
+		Company:    req.Company,
+		Phone:      req.Phone,
+		Email:      req.Email,
+		Purpose:    req.Purpose,
+		PassCode:   passCode,
+		Status:     models.VisitorExpected,
+		ExpectedAt: req.ExpectedAt,
+	}, nil
+}
+
+// passResponse returns the visitor together with the signed pass payload and its QR image
+func (h *VisitorHandler) passResponse(visitor *models.Visitor) gin.H {
+	payload := h.qrSigner.VisitorPassPayload(visitor.PassCode)
+	response := gin.H{
+		"visitor":      visitor,
+		"pass_payload": payload,
+	}
+	if png, err := qrcode.Encode(payload, qrcode.Medium, 256); err == nil {
+		response["pass_qr"] = "data:image/png;base64," + base64.StdEncoding.EncodeToString(png)
+	}
+	return response
+}
+
+// CreateMyVisitor pre-registers a guest hosted by the logged-in employee
+// POST /api/users/visitors
+func (h *VisitorHandler) CreateMyVisitor(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req VisitorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	host, err := h.userRepo.FindByID(c.Request.Context(), userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if host.OfficeID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You are not assigned to an office"})
+		return
+	}
+
+	visitor, err := newVisitor(req, host, *host.OfficeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate visitor pass"})
+		return
+	}
+	visitor.CreatedBy = &host.ID
+
+	if err := h.visitorRepo.Create(c.Request.Context(), visitor); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register visitor"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, h.passResponse(visitor))
+}
+
+// GetMyVisitors lists the guests hosted by the logged-in employee
+// GET /api/users/visitors
+func (h *VisitorHandler) GetMyVisitors(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	filters := repository.VisitorFilters{
+		HostID: userID.(uuid.UUID).String(),
+		Status: c.Query("status"),
+	}
+	visitors, total, err := h.visitorRepo.FindAll(c.Request.Context(), filters, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch visitors"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": visitors, "total": total})
+}
+
+// CreateVisitor pre-registers a guest for any host (front desk)
+// POST /api/admin/visitors
+func (h *VisitorHandler) CreateVisitor(c *gin.Context) {
+	var req struct {
+		VisitorRequest
+		HostID   uuid.UUID  `json:"host_id" binding:"required"`
+		OfficeID *uuid.UUID `json:"office_id"` // Defaults to the host's office
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	host, err := h.userRepo.FindByID(c.Request.Context(), req.HostID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Host not found"})
+		return
+	}
+
+	officeID := req.OfficeID
+	if officeID == nil {
+		officeID = host.OfficeID
+	}
+	if officeID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "office_id is required when the host has no office"})
+		return
+	}
+	if !officeInScope(c, officeID) {
+		rejectOutOfScope(c)
+		return
+	}
+
+	visitor, err := newVisitor(req.VisitorRequest, host, *officeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate visitor pass"})
+		return
+	}
+	if creatorID, exists := c.Get("user_id"); exists {
+		creator := creatorID.(uuid.UUID)
+		visitor.CreatedBy = &creator
+	}
+
+	if err := h.visitorRepo.Create(c.Request.Context(), visitor); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register visitor"})
+		return
+	}
+	auditChange(c, "visitor", visitor.ID.String(), nil, visitor)
+
+	c.JSON(http.StatusCreated, h.passResponse(visitor))
+}
+
+// parseVisitorRange reads start_date/end_date (YYYY-MM-DD); both default to today
+func parseVisitorRange(c *gin.Context) (time.Time, time.Time, error) {
+	now := time.Now()
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	end := start
+
+	if s := c.Query("start_date"); s != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", s, now.Location())
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		start, end = parsed, parsed
+	}
+	if e := c.Query("end_date"); e != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", e, now.Location())
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		end = parsed
+	}
+	return start, end.Add(24*time.Hour - time.Nanosecond), nil
+}
+
+// GetVisitors lists visitors with filtering and pagination
+// GET /api/admin/visitors
+func (h *VisitorHandler) GetVisitors(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	filters := repository.VisitorFilters{
+		OfficeID: c.Query("office_id"),
+		HostID:   c.Query("host_id"),
+		Status:   c.Query("status"),
+		Name:     c.Query("name"),
+	}
+	if c.Query("start_date") != "" || c.Query("end_date") != "" {
+		start, end, err := parseVisitorRange(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
+			return
+		}
+		filters.From, filters.To = &start, &end
+	}
+
+	visitors, total, err := h.visitorRepo.FindAll(c.Request.Context(), filters, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch visitors"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": visitors, "total": total})
+}
+
+// CancelVisitor cancels an expected visit so its pass no longer works
+// POST /api/admin/visitors/:id/cancel
+func (h *VisitorHandler) CancelVisitor(c *gin.Context) {
+	visitor, ok := h.loadVisitor(c)
+	if !ok {
+		return
+	}
+
+	if visitor.Status != models.VisitorExpected {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only expected visits can be cancelled"})
+		return
+	}
+
+	before := *visitor
+	visitor.Status = models.VisitorCancelled
+	if err := h.visitorRepo.Update(c.Request.Context(), visitor); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel visit"})
+		return
+	}
+	auditChange(c, "visitor", visitor.ID.String(), &before, visitor)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Visit cancelled", "visitor": visitor})
+}
+
+// CheckOutVisitor checks a visitor out from the front desk, e.g. when they left without scanning
+// POST /api/admin/visitors/:id/check-out
+func (h *VisitorHandler) CheckOutVisitor(c *gin.Context) {
+	visitor, ok := h.loadVisitor(c)
+	if !ok {
+		return
+	}
+
+	if visitor.Status != models.VisitorCheckedIn {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Visitor is not checked in"})
+		return
+	}
+
+	before := *visitor
+	checkOutVisitor(visitor, "")
+	if err := h.visitorRepo.Update(c.Request.Context(), visitor); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check out visitor"})
+		return
+	}
+	auditChange(c, "visitor", visitor.ID.String(), &before, visitor)
+	h.notifyVisitor(EventVisitorDeparted, visitor, "")
+
+	c.JSON(http.StatusOK, gin.H{"message": "Visitor checked out", "visitor": visitor})
+}
+
+// loadVisitor finds the visitor named by the :id path parameter
+func (h *VisitorHandler) loadVisitor(c *gin.Context) (*models.Visitor, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid visitor ID"})
+		return nil, false
+	}
+
+	visitor, err := h.visitorRepo.FindByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Visitor not found"})
+		return nil, false
+	}
+	if !officeInScope(c, &visitor.OfficeID) {
+		rejectOutOfScope(c)
+		return nil, false
+	}
+	return visitor, true
+}
+
+var visitorPassTemplate = template.Must(template.New("visitor-pass").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Visitor Pass</title>
+<style>
+	body { font-family: sans-serif; margin: 0; padding: 10mm; }
+	.pass { width: 86mm; border: 1px solid #999; border-radius: 3mm; padding: 5mm; box-sizing: border-box; text-align: center; }
+	.company { font-size: 9pt; font-weight: bold; text-transform: uppercase; }
+	.title { font-size: 16pt; font-weight: bold; letter-spacing: 2pt; margin: 2mm 0; }
+	.qr { width: 40mm; height: 40mm; margin: 2mm auto; }
+	.name { font-size: 13pt; font-weight: bold; }
+	.row { font-size: 9pt; margin-top: 1mm; }
+	.code { font-family: monospace; font-size: 12pt; margin-top: 3mm; }
+	@media print { body { padding: 0; } }
+</style>
+</head>
+<body>
+<div class="pass">
+	<div class="company">{{.Company}}</div>
+	<div class="title">VISITOR</div>
+	<img class="qr" src="{{.QRDataURI}}" alt="QR">
+	<div class="name">{{.Visitor.Name}}</div>
+	{{if .Visitor.Company}}<div class="row">{{.Visitor.Company}}</div>{{end}}
+	<div class="row">Host: {{.HostName}}</div>
+	{{if .OfficeName}}<div class="row">{{.OfficeName}}</div>{{end}}
+	{{if .Date}}<div class="row">{{.Date}}</div>{{end}}
+	<div class="code">{{.Visitor.PassCode}}</div>
+</div>
+</body>
+</html>`))
+
+// PrintVisitorPass renders a visitor pass as a printable HTML page
+// GET /api/admin/visitors/:id/pass
+func (h *VisitorHandler) PrintVisitorPass(c *gin.Context) {
+	visitor, ok := h.loadVisitor(c)
+	if !ok {
+		return
+	}
+
+	if visitor.Status == models.VisitorCancelled || visitor.Status == models.VisitorCheckedOut {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Visit is no longer active"})
+		return
+	}
+
+	png, err := qrcode.Encode(h.qrSigner.VisitorPassPayload(visitor.PassCode), qrcode.Medium, 256)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render QR code"})
+		return
+	}
+
+	data := gin.H{
+		"Visitor":   visitor,
+		"QRDataURI": template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(png)),
+	}
+	if setting, err := h.settingsRepo.GetByKey(c.Request.Context(), "company_name"); err == nil && setting != nil {
+		data["Company"] = setting.Value
+	}
+	if visitor.Host != nil {
+		data["HostName"] = visitor.Host.Name
+	}
+	if visitor.Office != nil {
+		data["OfficeName"] = visitor.Office.Name
+	}
+	if visitor.ExpectedAt != nil {
+		data["Date"] = visitor.ExpectedAt.Format("02 Jan 2006")
+	} else if visitor.CheckInAt != nil {
+		data["Date"] = visitor.CheckInAt.Format("02 Jan 2006")
+	}
+
+	var page bytes.Buffer
+	if err := visitorPassTemplate.Execute(&page, data); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render visitor pass"})
+		return
+	}
+
+	c.Data(http.StatusOK, "text/html; charset=utf-8", page.Bytes())
+}
+
+// VisitorLogOffice is one office's section of the visitor log
+type VisitorLogOffice struct {
+	OfficeID   uuid.UUID        `json:"office_id"`
+	OfficeName string           `json:"office_name"`
+	Total      int              `json:"total"`
+	WalkIns    int              `json:"walk_ins"`
+	OnSite     int              `json:"on_site"`
+	Visitors   []models.Visitor `json:"visitors"`
+}
+
+// GetVisitorLog returns the visitor log grouped by office, as JSON or CSV (format=csv)
+// GET /api/admin/visitors/report
+func (h *VisitorHandler) GetVisitorLog(c *gin.Context) {
+	start, end, err := parseVisitorRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
+		return
+	}
+
+	filters := repository.VisitorFilters{
+		OfficeID: c.Query("office_id"),
+		HostID:   c.Query("host_id"),
+		From:     &start,
+		To:       &end,
+	}
+	visitors, err := h.visitorRepo.FindLog(c.Request.Context(), filters)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch visitor log"})
+		return
+	}
+
+	if c.Query("format") == "csv" {
+		h.writeVisitorLogCSV(c, visitors, start, end)
+		return
+	}
+
+	var offices []*VisitorLogOffice
+	byOffice := make(map[uuid.UUID]*VisitorLogOffice)
+	for _, visitor := range visitors {
+		office, ok := byOffice[visitor.OfficeID]
+		if !ok {
+			office = &VisitorLogOffice{OfficeID: visitor.OfficeID, Visitors: []models.Visitor{}}
+			if visitor.Office != nil {
+				office.OfficeName = visitor.Office.Name
+			}
+			byOffice[visitor.OfficeID] = office
+			offices = append(offices, office)
+		}
+
+		office.Total++
+		if visitor.IsWalkIn {
+			office.WalkIns++
+		}
+		if visitor.Status == models.VisitorCheckedIn {
+			office.OnSite++
+		}
+		office.Visitors = append(office.Visitors, visitor)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"start_date": start.Format("2006-01-02"),
+		"end_date":   end.Format("2006-01-02"),
+		"total":      len(visitors),
+		"offices":    offices,
+	})
+}
+
+// writeVisitorLogCSV sends the visitor log as a CSV download
+func (h *VisitorHandler) writeVisitorLogCSV(c *gin.Context, visitors []models.Visitor, start, end time.Time) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"Office", "Visitor", "Company", "Phone", "Purpose", "Host", "Walk-in", "Check In", "Check Out", "Duration (min)"})
+
+	for _, visitor := range visitors {
+		office, host, checkIn, checkOut, duration := "", "", "", "", ""
+		if visitor.Office != nil {
+			office = visitor.Office.Name
+		}
+		if visitor.Host != nil {
+			host = visitor.Host.Name
+		}
+		if visitor.CheckInAt != nil {
+			checkIn = visitor.CheckInAt.Format("2006-01-02 15:04")
+		}
+		if visitor.CheckOutAt != nil {
+			checkOut = visitor.CheckOutAt.Format("2006-01-02 15:04")
+			if visitor.CheckInAt != nil {
+				duration = strconv.Itoa(int(visitor.CheckOutAt.Sub(*visitor.CheckInAt).Minutes()))
+			}
+		}
+		w.Write([]string{office, visitor.Name, visitor.Company, visitor.Phone, visitor.Purpose, host,
+			strconv.FormatBool(visitor.IsWalkIn), checkIn, checkOut, duration})
+	}
+	w.Flush()
+
+	filename := fmt.Sprintf("visitor-log_%s_%s.csv", start.Format("20060102"), end.Format("20060102"))
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
+
+// ==========================================
+// KIOSK VISITOR MODE
+// ==========================================
+
+// Kiosk visitor mode limits
+const (
+	maxVisitorPhotoBytes   = 2 << 20 // Decoded webcam capture
+	maxVisitorCheckInBytes = 3 << 20 // Whole check-in request, base64 photo included
+	minHostQueryLength     = 3
+	maxHostResults         = 5
+)
+
+// findActiveKiosk checks a kiosk credential, the kiosk ID plus the kiosk admin code, and
+// marks the kiosk as seen. Only active, paired kiosks are accepted.
+func (h *VisitorHandler) findActiveKiosk(c *gin.Context, kioskID, adminCode string) (*models.Kiosk, bool) {
+	setting, err := h.settingsRepo.GetByKey(c.Request.Context(), "kiosk_admin_code")
+	expectedCode := "123456"
+	if err == nil && setting != nil {
+		expectedCode = setting.Value
+	}
+	if adminCode != expectedCode {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid admin code"})
+		return nil, false
+	}
+
+	kiosk, err := h.kioskRepo.FindByKioskID(c.Request.Context(), kioskID)
+	if err != nil || kiosk == nil || !kiosk.IsActive || !kiosk.IsPaired {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Perangkat Kiosk tidak terdaftar atau tidak aktif"})
+		return nil, false
+	}
+	_ = h.kioskRepo.UpdateLastSeen(c.Request.Context(), kiosk.ID)
+	return kiosk, true
+}
+
+// findVisitorByPass accepts a scanned signed pass or a typed pass code
+func (h *VisitorHandler) findVisitorByPass(ctx context.Context, pass string) (*models.Visitor, error) {
+	code := strings.ToUpper(strings.TrimSpace(pass))
+	if utils.IsVisitorPassPayload(pass) {
+		verified, err := h.qrSigner.VerifyVisitorPass(pass)
+		if err != nil {
+			return nil, err
+		}
+		code = verified
+	}
+	return h.visitorRepo.FindByPassCode(ctx, code)
+}
+
+// GetVisitorHosts lets a walk-in visitor pick their host among the kiosk office's employees.
+// It only answers name searches, with a few names and the IDs to send back as host_id.
+// GET /api/kiosk/visitors/hosts?kiosk_id=...&code=...&q=name
+func (h *VisitorHandler) GetVisitorHosts(c *gin.Context) {
+	kiosk, ok := h.findActiveKiosk(c, c.Query("kiosk_id"), c.Query("code"))
+	if !ok {
+		return
+	}
+
+	query := strings.TrimSpace(c.Query("q"))
+	if len(query) < minHostQueryLength {
+		c.JSON(http.StatusOK, gin.H{"data": []gin.H{}})
+		return
+	}
+
+	active := true
+	users, _, err := h.userRepo.FindAll(c.Request.Context(), repository.UserFilters{
+		Name:     query,
+		OfficeID: kiosk.OfficeID.String(),
+		IsActive: &active,
+		SortBy:   "name",
+	}, maxHostResults, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mencari karyawan"})
+		return
+	}
+
+	hosts := make([]gin.H, 0, len(users))
+	for _, user := range users {
+		hosts = append(hosts, gin.H{"id": user.ID, "name": user.Name})
+	}
+	c.JSON(http.StatusOK, gin.H{"data": hosts})
+}
+
+// KioskVisitorCheckInRequest checks in a pre-registered visitor by pass, or registers a walk-in.
+// Walk-ins fill in the VisitorRequest fields and name their host by the ID GetVisitorHosts returned.
+type KioskVisitorCheckInRequest struct {
+	KioskID     string `json:"kiosk_id" binding:"required"`
+	AdminCode   string `json:"admin_code" binding:"required"`
+	Pass        string `json:"pass"` // Scanned pass QR or typed pass code
+	Name        string `json:"name"`
+	Company     string `json:"company"`
+	Phone       string `json:"phone"`
+	Email       string `json:"email"`
+	Purpose     string `json:"purpose"`
+	HostID      string `json:"host_id"`
+	PhotoBase64 string `json:"photo_base64"` // Optional webcam capture, JPEG, PNG or WebP up to 2 MB
+}
+
+// KioskVisitorCheckIn checks a visitor in at the kiosk and notifies the host
+// POST /api/kiosk/visitors/check-in
+func (h *VisitorHandler) KioskVisitorCheckIn(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxVisitorCheckInBytes)
+
+	var req KioskVisitorCheckInRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	kiosk, ok := h.findActiveKiosk(c, req.KioskID, req.AdminCode)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	var visitor *models.Visitor
+	if req.Pass != "" {
+		found, err := h.findVisitorByPass(ctx, req.Pass)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Kartu tamu tidak valid", "code": "VISITOR_PASS_INVALID"})
+			return
+		}
+		if code, message, status := checkVisitorPass(found, kiosk); code != "" {
+			c.JSON(status, gin.H{"error": message, "code": code})
+			return
+		}
+		visitor = found
+	} else {
+		if setting, _ := h.settingsRepo.GetByKey(ctx, "kiosk_visitor_walk_in"); setting != nil && setting.Value == "false" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Tamu tanpa registrasi harus melapor ke resepsionis", "code": "WALK_IN_DISABLED"})
+			return
+		}
+		hostID, err := uuid.Parse(req.HostID)
+		if strings.TrimSpace(req.Name) == "" || err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Nama tamu dan karyawan yang dituju wajib diisi"})
+			return
+		}
+
+		// Hosts are limited to the office the kiosk is in, as in GetVisitorHosts
+		host, err := h.userRepo.FindByID(ctx, hostID)
+		if err != nil || !host.IsActive || host.OfficeID == nil || *host.OfficeID != kiosk.OfficeID {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Karyawan yang dituju tidak ditemukan", "code": "HOST_NOT_FOUND"})
+			return
+		}
+
+		visitor, err = newVisitor(VisitorRequest{
+			Name:    req.Name,
+			Company: req.Company,
+			Phone:   req.Phone,
+			Email:   req.Email,
+			Purpose: req.Purpose,
+		}, host, kiosk.OfficeID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat kartu tamu"})
+			return
+		}
+		visitor.IsWalkIn = true
+		visitor.Host = host
+	}
+
+	if req.PhotoBase64 != "" {
+		photoURL, err := saveVisitorPhoto(req.PhotoBase64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Foto tidak valid"})
+			return
+		}
+		visitor.PhotoURL = photoURL
+	}
+
+	now := time.Now()
+	visitor.Status = models.VisitorCheckedIn
+	visitor.CheckInAt = &now
+	visitor.CheckInKioskID = kiosk.KioskID
+
+	var err error
+	if visitor.ID == uuid.Nil {
+		err = h.visitorRepo.Create(ctx, visitor)
+	} else {
+		err = h.visitorRepo.Update(ctx, visitor)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mencatat kedatangan tamu"})
+		return
+	}
+
+	h.notifyVisitor(EventVisitorArrived, visitor, kiosk.KioskID)
+
+	response := h.passResponse(visitor)
+	response["success"] = true
+	response["message"] = "Selamat datang, " + visitor.Name
+	c.JSON(http.StatusOK, response)
+}
+
+// checkVisitorPass validates a pre-registered pass against the kiosk and today's date
+func checkVisitorPass(visitor *models.Visitor, kiosk *models.Kiosk) (code, message string, status int) {
+	switch visitor.Status {
+	case models.VisitorCancelled:
+		return "VISITOR_CANCELLED", "Kunjungan sudah dibatalkan", http.StatusForbidden
+	case models.VisitorCheckedIn:
+		return "VISITOR_ALREADY_CHECKED_IN", "Tamu sudah check-in", http.StatusConflict
+	case models.VisitorCheckedOut:
+		return "VISITOR_ALREADY_CHECKED_OUT", "Kunjungan sudah selesai", http.StatusConflict
+	}
+
+	if visitor.OfficeID != kiosk.OfficeID {
+		return "VISITOR_WRONG_OFFICE", "Kunjungan terdaftar di kantor lain", http.StatusForbidden
+	}
+
+	if visitor.ExpectedAt != nil {
+		now := time.Now()
+		expected := visitor.ExpectedAt.In(now.Location())
+		if expected.Year() != now.Year() || expected.YearDay() != now.YearDay() {
+			return "VISITOR_PASS_NOT_TODAY", "Kartu tamu tidak berlaku hari ini", http.StatusForbidden
+		}
+	}
+	return "", "", 0
+}
+
+// KioskVisitorCheckOut checks a visitor out at the kiosk by pass
+// POST /api/kiosk/visitors/check-out
+func (h *VisitorHandler) KioskVisitorCheckOut(c *gin.Context) {
+	var req struct {
+		KioskID   string `json:"kiosk_id" binding:"required"`
+		AdminCode string `json:"admin_code" binding:"required"`
+		Pass      string `json:"pass" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	kiosk, ok := h.findActiveKiosk(c, req.KioskID, req.AdminCode)
+	if !ok {
+		return
+	}
+
+	visitor, err := h.findVisitorByPass(c.Request.Context(), req.Pass)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Kartu tamu tidak valid", "code": "VISITOR_PASS_INVALID"})
+		return
+	}
+
+	if visitor.Status != models.VisitorCheckedIn {
+		c.JSON(http.StatusConflict, gin.H{"error": "Tamu belum check-in", "code": "VISITOR_NOT_CHECKED_IN"})
+		return
+	}
+
+	checkOutVisitor(visitor, kiosk.KioskID)
+	if err := h.visitorRepo.Update(c.Request.Context(), visitor); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mencatat kepulangan tamu"})
+		return
+	}
+	h.notifyVisitor(EventVisitorDeparted, visitor, kiosk.KioskID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Terima kasih atas kunjungan Anda, " + visitor.Name,
+		"visitor": visitor,
+	})
+}
+
+// checkOutVisitor marks a visitor as departed
+func checkOutVisitor(visitor *models.Visitor, kioskID string) {
+	now := time.Now()
+	visitor.Status = models.VisitorCheckedOut
+	visitor.CheckOutAt = &now
+	visitor.CheckOutKioskID = kioskID
+}
+
+// notifyVisitor pushes a visitor event to the host's own clients and to the visitor
+// readers following the office
+func (h *VisitorHandler) notifyVisitor(event string, visitor *models.Visitor, kioskID string) {
+	if h.wsHub == nil {
+		return
+	}
+
+	payload := VisitorEvent{
+		VisitorID: visitor.ID,
+		Name:      visitor.Name,
+		Company:   visitor.Company,
+		Purpose:   visitor.Purpose,
+		PhotoURL:  visitor.PhotoURL,
+		HostID:    visitor.HostID,
+		OfficeID:  visitor.OfficeID,
+		KioskID:   kioskID,
+		Time:      time.Now(),
+	}
+	if visitor.Host != nil {
+		payload.HostName = visitor.Host.Name
+	}
+
+	h.wsHub.BroadcastToUser(visitor.HostID.String(), event, payload)
+	h.wsHub.BroadcastToOffice(visitor.OfficeID, models.PermVisitorsRead, event, payload)
+}
+
+// saveVisitorPhoto stores a base64 (optionally data URL) webcam capture and returns its URL.
+// Only JPEG, PNG and WebP images up to maxVisitorPhotoBytes are accepted.
+func saveVisitorPhoto(data string) (string, error) {
+	if i := strings.Index(data, ";base64,"); strings.HasPrefix(data, "data:image/") && i > 0 {
+		data = data[i+len(";base64,"):]
+	}
+	if base64.StdEncoding.DecodedLen(len(data)) > maxVisitorPhotoBytes {
+		return "", fmt.Errorf("image too large")
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return "", err
+	}
+
+	ext := ".jpg"
+	switch http.DetectContentType(decoded) {
+	case "image/jpeg":
+	case "image/png":
+		ext = ".png"
+	case "image/webp":
+		ext = ".webp"
+	default:
+		return "", fmt.Errorf("unsupported image type")
+	}
+
+	uploadDir := filepath.Join("uploads", "visitors")
+	if err := os.MkdirAll(uploadDir, 0755); err != nil {
+		return "", err
+	}
+
+	filename := uuid.New().String() + ext
+	if err := os.WriteFile(filepath.Join(uploadDir, filename), decoded, 0644); err != nil {
+		return "", err
+	}
+	return "/uploads/visitors/" + filename, nil
+}
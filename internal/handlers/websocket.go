@@ -2,15 +2,21 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/attendance-system/internal/models"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+	"github.com/redis/go-redis/v9"
 )
 
 // Event types
@@ -20,6 +26,38 @@ const (
 	EventAttendanceCheckOut = "attendance:checkout"
 	EventSettingsUpdated   = "settings:updated"
 	EventFaceVerified      = "face:verified"
+	EventVisitorArrived    = "visitor:arrived"
+	EventVisitorDeparted   = "visitor:departed"
+	EventAnnouncementPublished = "announcement:published"
+	EventAnnouncementWithdrawn = "announcement:withdrawn"
+)
+
+// Events the hub sends to a client about its own connection. These carry no seq.
+const (
+	EventSubscribed        = "subscribed"
+	EventUnsubscribed      = "unsubscribed"
+	EventSubscriptionError = "subscription:error"
+	EventStream            = "stream" // First message: the stream ID, current seq and followed topics, for resuming later
+	EventResync            = "resync" // Missed events cannot be replayed; reload everything
+)
+
+const (
+	// maxClientMessageSize bounds the subscription requests clients send
+	maxClientMessageSize = 4096
+	// subscribeTimeout bounds the lookups made to authorize a subscription
+	subscribeTimeout = 5 * time.Second
+	// closeTokenExpired is the close code sent when the client's access token expires;
+	// the client should reconnect with a fresh token
+	closeTokenExpired = 4001
+	// clientSendBuffer is how many messages may wait for a slow client
+	clientSendBuffer = 256
+	// eventHistorySize is how many recent events are kept for resuming clients
+	eventHistorySize = 1024
+	// maxReplay is the most events replayed to a resuming client; it stays below
+	// clientSendBuffer so the replay fits before the client starts reading
+	maxReplay = 200
+	// maxConnectTopics bounds the topics a client can list when connecting
+	maxConnectTopics = 256
 )
 
 // AttendanceEvent payload
@@ -30,6 +68,30 @@ type AttendanceEvent struct {
 	EmployeeID string    `json:"employee_id"`
 	Time       time.Time `json:"time"`
 	IsLate     bool      `json:"is_late"`
+
+	OfficeID      *uuid.UUID `json:"office_id,omitempty"`       // Employee's office
+	PunchOfficeID *uuid.UUID `json:"punch_office_id,omitempty"` // Office of the kiosk punched at, if any
+}
+
+// AnnouncementAudience is who an announcement event goes to
+type AnnouncementAudience struct {
+	OfficeIDs []uuid.UUID `json:"office_ids,omitempty"` // Targeted offices; empty means every office
+	Kiosk     bool        `json:"kiosk"`                // Kiosks of the targeted offices
+	AllUsers  bool        `json:"all_users"`            // Every mobile user, when nothing is targeted
+	UserIDs   []string    `json:"user_ids,omitempty"`   // Mobile users matching the targeting
+}
+
+// targetsOffice reports whether the audience covers an office
+func (a *AnnouncementAudience) targetsOffice(officeID uuid.UUID) bool {
+	if len(a.OfficeIDs) == 0 {
+		return true
+	}
+	for _, id := range a.OfficeIDs {
+		if id == officeID {
+			return true
+		}
+	}
+	return false
 }
 
 // WebSocketMessage represents a message to broadcast
@@ -38,45 +100,104 @@ type WebSocketMessage struct {
 	Payload interface{} `json:"payload"`
 }
 
+// SubscriptionRequest is a message from a client changing the topics it follows
+type SubscriptionRequest struct {
+	Action string `json:"action"` // "subscribe" or "unsubscribe"
+	Topic  string `json:"topic"`  // e.g. "office:<id>", "team" or "user:<id>"
+}
+
 // Client represents a connected WebSocket client
 type Client struct {
-	hub      *WebSocketHub
-	conn     *websocket.Conn
-	send     chan []byte
-	userID   string  // Optional: authenticated user ID
-	clientType string // "admin", "kiosk", "mobile"
+	hub        *WebSocketHub
+	conn       *websocket.Conn
+	send       chan []byte
+	userID     string // Authenticated user; empty for kiosks
+	clientType string // ClientTypeAdmin, ClientTypeKiosk or ClientTypeMobile
+
+	officeID    *uuid.UUID  // Kiosk's office
+	permissions []string    // Permissions of the user's role when they connected
+	officeScope []uuid.UUID // Offices the user is limited to; nil means every office
+	expiresAt   time.Time   // When the access token runs out and the connection is closed
+
+	// Resuming: the stream and last seq the client saw before reconnecting
+	resuming     bool
+	resumeStream string
+	resumeSeq    int64
+	listedTopics bool // Connected with ?topics=, so the topics it followed are known
+
+	mu     sync.RWMutex
+	topics map[string]bool
+	team   map[uuid.UUID]bool // Reports of the user, loaded when subscribing to TopicTeam
 }
 
-// WebSocketHub maintains active clients and broadcasts messages
+// messageRoute picks the clients a message goes to. It returns what a client is sent,
+// or nil to skip it.
+type messageRoute func(client *Client) []byte
+
+// WebSocketHub maintains active clients and broadcasts messages.
+// Every event has a seq in the hub's stream and is kept in a ring buffer, so clients
+// that reconnect can have the events they missed replayed. With Redis the stream is
+// shared by all instances; without it, it is this hub's own.
 type WebSocketHub struct {
 	clients    map[*Client]bool
-	broadcast  chan []byte
+	broadcast  chan *hubEvent
 	register   chan *Client
 	unregister chan *Client
 	mu         sync.RWMutex
-}
+	auth       *WebSocketAuthenticator
+	upgrader   websocket.Upgrader
+
+	// Relaying over Redis to the other API instances; rdb nil keeps events local
+	rdb     *redis.Client
+	relay   chan *hubEvent
+	streams chan *streamState
 
-// Global hub instance
-var WSHub *WebSocketHub
+	// Owned by Run: the stream followed, its last seq and the events kept from it.
+	// The stream is empty until the shared stream has been loaded.
+	stream  string
+	seq     int64
+	history []*hubEvent
+}
 
-// NewWebSocketHub creates a new Hub
-func NewWebSocketHub() *WebSocketHub {
-	return &WebSocketHub{
+// NewWebSocketHub creates a new Hub. With rdb, events are also relayed to the hubs of
+// other API instances and theirs to this one.
+func NewWebSocketHub(auth *WebSocketAuthenticator, rdb *redis.Client) *WebSocketHub {
+	hub := &WebSocketHub{
 		clients:    make(map[*Client]bool),
-		broadcast:  make(chan []byte),
+		broadcast:  make(chan *hubEvent),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
+		auth:       auth,
+		rdb:        rdb,
+		relay:      make(chan *hubEvent, relayQueueSize),
+		streams:    make(chan *streamState),
+		history:    make([]*hubEvent, eventHistorySize),
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin:     auth.checkOrigin,
+		},
 	}
+	if rdb == nil {
+		hub.stream = uuid.NewString()
+	}
+	return hub
 }
 
 // Run starts the hub's main loop
 func (h *WebSocketHub) Run() {
+	if h.rdb != nil {
+		go h.publishRelayed()
+		go h.followStream()
+	}
+
 	for {
 		select {
 		case client := <-h.register:
 			h.mu.Lock()
 			h.clients[client] = true
 			h.mu.Unlock()
+			h.resume(client)
 			log.Printf("WebSocket client connected. Total: %d", len(h.clients))
 
 		case client := <-h.unregister:
@@ -88,76 +209,132 @@ func (h *WebSocketHub) Run() {
 			h.mu.Unlock()
 			log.Printf("WebSocket client disconnected. Total: %d", len(h.clients))
 
-		case message := <-h.broadcast:
-			h.mu.RLock()
-			for client := range h.clients {
-				select {
-				case client.send <- message:
-				default:
-					close(client.send)
-					delete(h.clients, client)
-				}
-			}
-			h.mu.RUnlock()
+		case event := <-h.broadcast:
+			h.deliver(event)
+
+		case state := <-h.streams:
+			h.attach(state)
 		}
 	}
 }
 
-// Broadcast sends a message to all connected clients
-func (h *WebSocketHub) Broadcast(event string, payload interface{}) {
-	msg := WebSocketMessage{
+// deliver records an event and sends it to the clients it is for. Only Run calls it.
+func (h *WebSocketHub) deliver(event *hubEvent) {
+	route := h.record(event)
+	if route == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for client := range h.clients {
+		if message := route(client); message != nil {
+			h.send(client, message)
+		}
+	}
+}
+
+// send queues a message for a client; h.mu must be held
+func (h *WebSocketHub) send(client *Client, message []byte) {
+	select {
+	case client.send <- message:
+	default:
+		// Too slow to keep up; dropping it makes it reconnect
+		close(client.send)
+		delete(h.clients, client)
+	}
+}
+
+// encodeMessage marshals an event in the standard message format
+func encodeMessage(event string, payload interface{}) []byte {
+	data, err := json.Marshal(WebSocketMessage{
 		Event:   event,
 		Payload: payload,
-	}
-	data, err := json.Marshal(msg)
+	})
 	if err != nil {
 		log.Printf("WebSocket marshal error: %v", err)
+		return nil
+	}
+	return data
+}
+
+// Broadcast sends a message to all connected clients
+func (h *WebSocketHub) Broadcast(event string, payload interface{}) {
+	data := encodeMessage(event, payload)
+	if data == nil {
 		return
 	}
-	h.broadcast <- data
+	h.dispatch(&hubEvent{Audience: audienceAll, Data: data})
 }
 
-// BroadcastAttendanceUpdate simplifies broadcasting attendance events
-// Matches the method signature called in attendance.go
+// BroadcastAttendanceUpdate sends an attendance event to the employee's own clients, to
+// managers following TopicTeam with the employee in their team, and to attendance readers
+// following the employee's office or the office punched at. Kiosks following those
+// offices get the event without the employee's name and employee ID.
 func (h *WebSocketHub) BroadcastAttendanceUpdate(event AttendanceEvent) {
 	// Wrap in standard message format using legacy/expected type "attendance_update"
 	// Dashboard expects "attendance_update"
-	msg := map[string]interface{}{
+	data, err := json.Marshal(map[string]interface{}{
 		"type":    "attendance_update", // Dashboard listener expects this
 		"payload": event,
+	})
+	if err != nil {
+		log.Printf("WebSocket marshal error: %v", err)
+		return
 	}
-	
-	data, err := json.Marshal(msg)
+
+	anonymous := event
+	anonymous.UserName = ""
+	anonymous.EmployeeID = ""
+	redacted, err := json.Marshal(map[string]interface{}{
+		"type":    "attendance_update",
+		"payload": anonymous,
+	})
 	if err != nil {
 		log.Printf("WebSocket marshal error: %v", err)
 		return
 	}
-	h.broadcast <- data
+
+	h.dispatch(&hubEvent{Audience: audienceAttendance, Attendance: &event, Data: data, Redacted: redacted})
 }
 
 // BroadcastToType sends a message only to clients of a specific type
 func (h *WebSocketHub) BroadcastToType(clientType string, event string, payload interface{}) {
-	msg := WebSocketMessage{
-		Event:   event,
-		Payload: payload,
+	data := encodeMessage(event, payload)
+	if data == nil {
+		return
 	}
-	data, err := json.Marshal(msg)
-	if err != nil {
-		log.Printf("WebSocket marshal error: %v", err)
+	h.dispatch(&hubEvent{Audience: audienceType, Target: clientType, Data: data})
+}
+
+// BroadcastToUser sends a message only to the clients following a user's own topic
+func (h *WebSocketHub) BroadcastToUser(userID string, event string, payload interface{}) {
+	data := encodeMessage(event, payload)
+	if data == nil {
 		return
 	}
-	
-	h.mu.RLock()
-	for client := range h.clients {
-		if client.clientType == clientType {
-			select {
-			case client.send <- data:
-			default:
-			}
-		}
+	h.dispatch(&hubEvent{Audience: audienceUser, Target: userID, Data: data})
+}
+
+// BroadcastToOffice sends a message to the clients following an office whose user
+// holds permission
+func (h *WebSocketHub) BroadcastToOffice(officeID uuid.UUID, permission string, event string, payload interface{}) {
+	data := encodeMessage(event, payload)
+	if data == nil {
+		return
+	}
+	h.dispatch(&hubEvent{Audience: audienceOffice, Target: officeID.String(), Permission: permission, Data: data})
+}
+
+// BroadcastAnnouncement sends an announcement event to its audience, and to the
+// dashboards of users who manage announcements for every office it targets
+func (h *WebSocketHub) BroadcastAnnouncement(audience AnnouncementAudience, event string, payload interface{}) {
+	data := encodeMessage(event, payload)
+	if data == nil {
+		return
 	}
-	h.mu.RUnlock()
+	h.dispatch(&hubEvent{Audience: audienceAnnouncement, Announcement: &audience, Data: data})
 }
+
 // GetConnectedCount returns number of connected clients
 func (h *WebSocketHub) GetConnectedCount() int {
     h.mu.RLock()
@@ -165,78 +342,281 @@ func (h *WebSocketHub) GetConnectedCount() int {
     return len(h.clients)
 }
 
-// WebSocket upgrader
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all origins in development
-	},
-}
-
-// HandleWebSocket handles WebSocket connections
-// Renamed from WebSocketHandler to match main.go usage: wsHub.HandleWebSocket
+// HandleWebSocket authenticates a client and upgrades its connection. The client starts
+// out following its default topics, or the comma-separated ?topics= it may follow, and
+// can then send SubscriptionRequests. A client reconnecting with ?stream=, ?last_seq=
+// and the ?topics= from its previous connection gets the events it missed on those
+// topics replayed, or EventResync when they are gone or its topics are not known.
+// GET /ws/dashboard
 func (h *WebSocketHub) HandleWebSocket(c *gin.Context) {
-		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
-		if err != nil {
-			log.Printf("WebSocket upgrade error: %v", err)
+	if !h.auth.checkOrigin(c.Request) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Origin not allowed", "code": "ORIGIN_NOT_ALLOWED"})
+		return
+	}
+
+	client, code, message, status := h.auth.authenticate(c)
+	if client == nil {
+		c.JSON(status, gin.H{"error": message, "code": code})
+		return
+	}
+	if lastSeq := c.Query("last_seq"); lastSeq != "" {
+		seq, err := strconv.ParseInt(lastSeq, 10, 64)
+		if err != nil || seq < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "last_seq must be a sequence number", "code": "INVALID_LAST_SEQ"})
 			return
 		}
+		client.resuming = true
+		client.resumeStream = c.Query("stream")
+		client.resumeSeq = seq
+	}
+
+	if list, listed := c.GetQuery("topics"); listed {
+		var topics []string
+		for _, topic := range strings.Split(list, ",") {
+			if topic != "" {
+				topics = append(topics, topic)
+			}
+		}
+		if len(topics) > maxConnectTopics {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Too many topics", "code": "TOO_MANY_TOPICS"})
+			return
+		}
+
+		// Topics the client may no longer follow are left out of the list in EventStream
+		ctx, cancel := context.WithTimeout(c.Request.Context(), subscribeTimeout)
+		for _, topic := range topics {
+			if code, _ := h.auth.authorizeTopic(ctx, client, topic); code == "" {
+				client.topics[topic] = true
+			}
+		}
+		cancel()
+		client.listedTopics = true
+	} else {
+		for _, topic := range h.auth.defaultTopics(client) {
+			client.topics[topic] = true
+		}
+	}
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("WebSocket upgrade error: %v", err)
+		return
+	}
+
+	client.hub = h
+	client.conn = conn
+	client.send = make(chan []byte, clientSendBuffer)
+
+	h.register <- client
+
+	// Start goroutines for reading and writing
+	go client.writePump()
+	go client.readPump()
+}
+
+// can reports whether the client's user holds a permission
+func (c *Client) can(permission string) bool {
+	return models.PermissionsGrant(c.permissions, permission)
+}
+
+// canAny reports whether the client's user holds any of the permissions
+func (c *Client) canAny(permissions []string) bool {
+	for _, permission := range permissions {
+		if c.can(permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// inScope reports whether an office is inside the client's office scope
+func (c *Client) inScope(officeID uuid.UUID) bool {
+	if c.officeScope == nil {
+		return true
+	}
+	for _, id := range c.officeScope {
+		if id == officeID {
+			return true
+		}
+	}
+	return false
+}
+
+// follows reports whether the client subscribed to a topic
+func (c *Client) follows(topic string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.topics[topic]
+}
+
+// topicList returns the topics the client follows, sorted
+func (c *Client) topicList() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	topics := make([]string, 0, len(c.topics))
+	for topic := range c.topics {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// followsOffice reports whether the client follows any of the offices
+func (c *Client) followsOffice(officeIDs ...*uuid.UUID) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.topics[TopicAllOffices] {
+		return true
+	}
+	for _, officeID := range officeIDs {
+		if officeID != nil && c.topics[topicOfficePrefix+officeID.String()] {
+			return true
+		}
+	}
+	return false
+}
 
-		clientType := c.Query("type") // "admin", "kiosk", "mobile"
-		if clientType == "" {
-			clientType = "unknown"
+// managesAnnouncementFor reports whether the client's office scope covers every office an
+// announcement targets. Scoped users do not manage company-wide announcements.
+func (c *Client) managesAnnouncementFor(officeIDs []uuid.UUID) bool {
+	if c.officeScope == nil {
+		return true
+	}
+	if len(officeIDs) == 0 {
+		return false
+	}
+	for _, officeID := range officeIDs {
+		if !c.inScope(officeID) {
+			return false
 		}
+	}
+	return true
+}
+
+// setTeam replaces the reports the client's TopicTeam subscription covers
+func (c *Client) setTeam(team map[uuid.UUID]bool) {
+	c.mu.Lock()
+	c.team = team
+	c.mu.Unlock()
+}
+
+// receivesAttendanceOf reports whether the client may see an attendance event in full
+func (c *Client) receivesAttendanceOf(event AttendanceEvent) bool {
+	if c.follows(topicUserPrefix + event.UserID.String()) {
+		return true
+	}
+
+	c.mu.RLock()
+	inTeam := c.topics[TopicTeam] && c.team[event.UserID]
+	c.mu.RUnlock()
+	if inTeam {
+		return true
+	}
+
+	return c.can(models.PermAttendanceRead) && c.followsOffice(event.OfficeID, event.PunchOfficeID)
+}
+
+// reply sends a message to this client alone, unless the hub already dropped it
+func (c *Client) reply(event string, payload interface{}) {
+	data := encodeMessage(event, payload)
+	if data == nil {
+		return
+	}
+
+	c.hub.mu.RLock()
+	defer c.hub.mu.RUnlock()
+	if _, ok := c.hub.clients[c]; !ok {
+		return
+	}
+	select {
+	case c.send <- data:
+	default:
+	}
+}
+
+// handleMessage applies a subscription request from the client
+func (c *Client) handleMessage(data []byte) {
+	var req SubscriptionRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		c.reply(EventSubscriptionError, gin.H{"error": "Messages must be subscription requests", "code": "INVALID_MESSAGE"})
+		return
+	}
 
-		client := &Client{
-			hub:        h,
-			conn:       conn,
-			send:       make(chan []byte, 256),
-			clientType: clientType,
+	switch req.Action {
+	case "subscribe":
+		ctx, cancel := context.WithTimeout(context.Background(), subscribeTimeout)
+		code, message := c.hub.auth.authorizeTopic(ctx, c, req.Topic)
+		cancel()
+		if code != "" {
+			c.reply(EventSubscriptionError, gin.H{"topic": req.Topic, "error": message, "code": code})
+			return
 		}
+		c.mu.Lock()
+		c.topics[req.Topic] = true
+		c.mu.Unlock()
+		c.reply(EventSubscribed, gin.H{"topic": req.Topic})
 
-		h.register <- client
+	case "unsubscribe":
+		c.mu.Lock()
+		delete(c.topics, req.Topic)
+		if req.Topic == TopicTeam {
+			c.team = nil
+		}
+		c.mu.Unlock()
+		c.reply(EventUnsubscribed, gin.H{"topic": req.Topic})
 
-		// Start goroutines for reading and writing
-		go client.writePump()
-		go client.readPump()
+	default:
+		c.reply(EventSubscriptionError, gin.H{"error": "action must be subscribe or unsubscribe", "code": "INVALID_ACTION"})
+	}
 }
 
-// readPump reads messages from the WebSocket connection
+// readPump reads subscription requests from the WebSocket connection
 func (c *Client) readPump() {
 	defer func() {
 		c.hub.unregister <- c
 		c.conn.Close()
 	}()
 
+	c.conn.SetReadLimit(maxClientMessageSize)
 	for {
-		_, _, err := c.conn.ReadMessage()
+		_, data, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WebSocket error: %v", err)
 			}
 			break
 		}
-		// For now, we only broadcast from server, ignore client messages
+		c.handleMessage(data)
 	}
 }
 
-// writePump writes messages to the WebSocket connection
+// writePump writes messages to the WebSocket connection. Connections opened with an
+// access token are closed with closeTokenExpired when the token runs out.
 func (c *Client) writePump() {
 	defer c.conn.Close()
 
-	for message := range c.send {
-		err := c.conn.WriteMessage(websocket.TextMessage, message)
-		if err != nil {
-			log.Printf("WebSocket write error: %v", err)
+	var expired <-chan time.Time
+	if !c.expiresAt.IsZero() {
+		timer := time.NewTimer(time.Until(c.expiresAt))
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	for {
+		select {
+		case message, ok := <-c.send:
+			if !ok {
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				log.Printf("WebSocket write error: %v", err)
+				return
+			}
+
+		case <-expired:
+			closing := websocket.FormatCloseMessage(closeTokenExpired, "Token expired")
+			_ = c.conn.WriteControl(websocket.CloseMessage, closing, time.Now().Add(time.Second))
 			return
 		}
 	}
 }
-
-// InitWebSocket initializes the global WebSocket hub
-func InitWebSocket() *WebSocketHub {
-	WSHub = NewWebSocketHub()
-	go WSHub.Run()
-	return WSHub
-}
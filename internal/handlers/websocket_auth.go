@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/attendance-system/internal/middleware"
+	"github.com/attendance-system/internal/models"
+	"github.com/attendance-system/internal/repository"
+	"github.com/attendance-system/internal/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// WebSocket client types
+const (
+	ClientTypeAdmin  = "admin"  // Dashboard of a user with dashboard access
+	ClientTypeMobile = "mobile" // Any other logged-in user
+	ClientTypeKiosk  = "kiosk"  // A kiosk, connected with its kiosk ID and admin code
+)
+
+// WebSocket topics. Clients subscribe by sending {"action":"subscribe","topic":"..."}.
+const (
+	TopicTeam         = "team"     // Attendance of the user's direct and indirect reports
+	TopicAllOffices   = "office:*" // Every office, for users not limited to some offices
+	topicOfficePrefix = "office:"  // office:<id>: attendance and visitors at an office
+	topicUserPrefix   = "user:"    // user:<id>: events about the user themselves
+)
+
+// officeTopicPermissions are the permissions that make office topics worth following;
+// each event is still only sent to subscribers holding the permission it needs
+var officeTopicPermissions = []string{models.PermAttendanceRead, models.PermVisitorsRead}
+
+// WebSocketAuthenticator identifies WebSocket clients when they connect and decides
+// which topics they may follow
+type WebSocketAuthenticator struct {
+	jwtManager     *utils.JWTManager
+	denylist       *middleware.TokenDenylist
+	authorizer     *middleware.Authorizer
+	scopeRepo      *repository.OfficeScopeRepository
+	employeeRepo   *repository.EmployeeRepository
+	kioskRepo      *repository.KioskRepository
+	settingsRepo   *repository.SettingsRepository
+	allowedOrigins map[string]bool
+}
+
+// NewWebSocketAuthenticator creates a new WebSocket authenticator.
+// allowedOrigins are the browser origins, besides the API's own host, that may connect.
+func NewWebSocketAuthenticator(
+	jwtManager *utils.JWTManager,
+	denylist *middleware.TokenDenylist,
+	authorizer *middleware.Authorizer,
+	scopeRepo *repository.OfficeScopeRepository,
+	employeeRepo *repository.EmployeeRepository,
+	kioskRepo *repository.KioskRepository,
+	settingsRepo *repository.SettingsRepository,
+	allowedOrigins []string,
+) *WebSocketAuthenticator {
+	origins := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		origins[strings.ToLower(strings.TrimRight(origin, "/"))] = true
+	}
+	return &WebSocketAuthenticator{
+		jwtManager:     jwtManager,
+		denylist:       denylist,
+		authorizer:     authorizer,
+		scopeRepo:      scopeRepo,
+		employeeRepo:   employeeRepo,
+		kioskRepo:      kioskRepo,
+		settingsRepo:   settingsRepo,
+		allowedOrigins: origins,
+	}
+}
+
+// checkOrigin refuses browser connections from pages on other sites, which would
+// otherwise ride on a user's token. Clients that send no Origin are not browsers.
+func (a *WebSocketAuthenticator) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return a.allowedOrigins[strings.ToLower(u.Scheme+"://"+u.Host)]
+}
+
+// authenticate identifies a connecting client from a JWT access token, sent as a bearer
+// token or, since browsers cannot set headers on WebSockets, as ?token=; or from a kiosk
+// credential sent as ?kiosk_id= and ?admin_code=. It returns an error code and message
+// when the client is refused.
+func (a *WebSocketAuthenticator) authenticate(c *gin.Context) (client *Client, code, message string, status int) {
+	if c.Query("kiosk_id") != "" {
+		return a.authenticateKiosk(c)
+	}
+
+	token := c.Query("token")
+	if header := c.GetHeader("Authorization"); header != "" {
+		parts := strings.Split(header, " ")
+		if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+			return nil, "AUTH_INVALID", "Invalid authorization format", http.StatusUnauthorized
+		}
+		token = parts[1]
+	}
+	if token == "" {
+		return nil, "AUTH_REQUIRED", "An access token or kiosk credential is required", http.StatusUnauthorized
+	}
+	if strings.HasPrefix(token, models.APIKeyPrefix) {
+		return nil, "API_KEY_NOT_ALLOWED", "API keys cannot open WebSockets", http.StatusForbidden
+	}
+
+	claims, err := a.jwtManager.ValidateAccessToken(token)
+	if err != nil {
+		if err == utils.ErrExpiredToken {
+			return nil, "TOKEN_EXPIRED", "Token expired", http.StatusUnauthorized
+		}
+		return nil, "AUTH_INVALID", "Invalid token", http.StatusUnauthorized
+	}
+
+	// Same as AuthMiddleware: revocation is best effort while Redis is down
+	ctx := c.Request.Context()
+	revoked, err := a.denylist.IsRevoked(ctx, claims)
+	if err != nil {
+		log.Printf("[WebSocket] Token denylist unavailable: %v", err)
+	} else if revoked {
+		return nil, "TOKEN_REVOKED", "Token has been revoked", http.StatusUnauthorized
+	}
+	if claims.MustChangePassword {
+		return nil, "PASSWORD_CHANGE_REQUIRED", "You must change your password before continuing", http.StatusForbidden
+	}
+
+	officeIDs, err := a.scopeRepo.FindOfficeIDs(ctx, claims.UserID)
+	if err != nil {
+		return nil, "SCOPE_UNAVAILABLE", "Failed to load office scope", http.StatusInternalServerError
+	}
+
+	client = &Client{
+		userID:      claims.UserID.String(),
+		clientType:  ClientTypeMobile,
+		permissions: a.authorizer.Permissions(ctx, claims.Role),
+		topics:      make(map[string]bool),
+	}
+	if len(officeIDs) > 0 {
+		client.officeScope = officeIDs
+	}
+	if claims.ExpiresAt != nil {
+		client.expiresAt = claims.ExpiresAt.Time
+	}
+
+	switch c.Query("type") {
+	case "", ClientTypeMobile:
+	case ClientTypeAdmin:
+		if !client.can(models.PermDashboardRead) {
+			return nil, "PERMISSION_DENIED", "Dashboard access is required", http.StatusForbidden
+		}
+		client.clientType = ClientTypeAdmin
+	default:
+		return nil, "INVALID_CLIENT_TYPE", "type must be admin or mobile; kiosks connect with their kiosk ID", http.StatusBadRequest
+	}
+	return client, "", "", 0
+}
+
+// authenticateKiosk checks a kiosk credential the way the kiosk API does
+func (a *WebSocketAuthenticator) authenticateKiosk(c *gin.Context) (*Client, string, string, int) {
+	ctx := c.Request.Context()
+
+	setting, err := a.settingsRepo.GetByKey(ctx, "kiosk_admin_code")
+	expectedCode := "123456"
+	if err == nil && setting != nil {
+		expectedCode = setting.Value
+	}
+	if c.Query("admin_code") != expectedCode {
+		return nil, "AUTH_INVALID", "Invalid admin code", http.StatusUnauthorized
+	}
+
+	kiosk, err := a.kioskRepo.FindByKioskID(ctx, c.Query("kiosk_id"))
+	if err != nil || kiosk == nil || !kiosk.IsActive {
+		return nil, "AUTH_INVALID", "Invalid or inactive kiosk", http.StatusUnauthorized
+	}
+
+	officeID := kiosk.OfficeID
+	return &Client{
+		clientType: ClientTypeKiosk,
+		officeID:   &officeID,
+		topics:     make(map[string]bool),
+	}, "", "", 0
+}
+
+// defaultTopics are followed from the start: users get their own events, kiosks their
+// office, and dashboards every office they may watch
+func (a *WebSocketAuthenticator) defaultTopics(client *Client) []string {
+	if client.clientType == ClientTypeKiosk {
+		return []string{topicOfficePrefix + client.officeID.String()}
+	}
+
+	topics := []string{topicUserPrefix + client.userID}
+	if client.clientType != ClientTypeAdmin || !client.canAny(officeTopicPermissions) {
+		return topics
+	}
+	if client.officeScope == nil {
+		return append(topics, TopicAllOffices)
+	}
+	for _, officeID := range client.officeScope {
+		topics = append(topics, topicOfficePrefix+officeID.String())
+	}
+	return topics
+}
+
+// authorizeTopic checks that a client may follow a topic. For TopicTeam it also loads
+// the team, so reporting line changes apply when the client subscribes again.
+func (a *WebSocketAuthenticator) authorizeTopic(ctx context.Context, client *Client, topic string) (code, message string) {
+	switch {
+	case strings.HasPrefix(topic, topicUserPrefix):
+		if client.userID == "" || topic != topicUserPrefix+client.userID {
+			return "PERMISSION_DENIED", "You can only follow your own user topic"
+		}
+
+	case topic == TopicTeam:
+		if client.userID == "" {
+			return "PERMISSION_DENIED", "Only users have a team"
+		}
+		managerID, _ := uuid.Parse(client.userID)
+		reports, err := a.employeeRepo.FindTeam(ctx, managerID)
+		if err != nil {
+			return "TEAM_UNAVAILABLE", "Failed to load team"
+		}
+		if len(reports) == 0 {
+			return "NOT_A_MANAGER", "Nobody reports to you"
+		}
+		team := make(map[uuid.UUID]bool, len(reports))
+		for _, report := range reports {
+			team[*report.UserID] = true
+		}
+		client.setTeam(team)
+
+	case topic == TopicAllOffices:
+		if client.clientType == ClientTypeKiosk || client.officeScope != nil || !client.canAny(officeTopicPermissions) {
+			return "PERMISSION_DENIED", "You cannot follow every office"
+		}
+
+	case strings.HasPrefix(topic, topicOfficePrefix):
+		officeID, err := uuid.Parse(strings.TrimPrefix(topic, topicOfficePrefix))
+		if err != nil {
+			return "INVALID_TOPIC", "Invalid office ID"
+		}
+		if client.clientType == ClientTypeKiosk {
+			if officeID != *client.officeID {
+				return "PERMISSION_DENIED", "Kiosks can only follow their own office"
+			}
+			return "", ""
+		}
+		if !client.canAny(officeTopicPermissions) {
+			return "PERMISSION_DENIED", "You cannot follow offices"
+		}
+		if !client.inScope(officeID) {
+			return "OUT_OF_SCOPE", "Office is outside your administrative scope"
+		}
+
+	default:
+		return "INVALID_TOPIC", "Unknown topic"
+	}
+	return "", ""
+}
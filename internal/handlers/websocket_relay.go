@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/attendance-system/internal/models"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Hub events are appended to a Redis stream that every API instance reads, so they
+// all deliver the same events under the same seqs and a client can resume on any of
+// them. The stream ID key names the stream, so seqs from before a Redis reset are
+// not mistaken for current ones.
+const (
+	wsStreamKey   = "ws:stream"
+	wsStreamIDKey = "ws:stream:id"
+)
+
+const (
+	// relayQueueSize bounds the events waiting to be published while Redis is slow
+	relayQueueSize = 256
+	// relayPublishTimeout bounds a single publish to Redis
+	relayPublishTimeout = 2 * time.Second
+	// streamReadBlock is how long one read waits for new stream entries
+	streamReadBlock = 5 * time.Second
+	// streamRetryDelay is the wait before reading the stream again after a Redis error
+	streamRetryDelay = 2 * time.Second
+)
+
+// Hub event audiences, deciding which clients a hubEvent goes to
+const (
+	audienceAll          = "all"
+	audienceType         = "type"         // Clients of type Target
+	audienceUser         = "user"         // Clients following user Target
+	audienceOffice       = "office"       // Clients following office Target that hold Permission
+	audienceAttendance   = "attendance"   // See BroadcastAttendanceUpdate
+	audienceAnnouncement = "announcement" // See BroadcastAnnouncement
+)
+
+// hubEvent is a message with the audience it is for. With Redis, it reaches the hubs
+// of all instances through the shared stream.
+type hubEvent struct {
+	Seq          int64                 `json:"-"` // Position in the stream
+	Audience     string                `json:"audience"`
+	Target       string                `json:"target,omitempty"`
+	Permission   string                `json:"permission,omitempty"`
+	Attendance   *AttendanceEvent      `json:"attendance,omitempty"`   // Who and where, for audienceAttendance
+	Announcement *AnnouncementAudience `json:"announcement,omitempty"` // Who, for audienceAnnouncement
+	Data         json.RawMessage       `json:"data"`
+	Redacted     json.RawMessage       `json:"redacted,omitempty"` // Sent to kiosks instead of Data
+}
+
+// route turns the event's audience into the clients it goes to. Audiences this
+// instance does not know are dropped rather than sent to everyone.
+func (e *hubEvent) route() messageRoute {
+	data := []byte(e.Data)
+
+	switch e.Audience {
+	case audienceAll:
+		return func(*Client) []byte { return data }
+
+	case audienceType:
+		return func(client *Client) []byte {
+			if client.clientType != e.Target {
+				return nil
+			}
+			return data
+		}
+
+	case audienceUser:
+		return func(client *Client) []byte {
+			if !client.follows(topicUserPrefix + e.Target) {
+				return nil
+			}
+			return data
+		}
+
+	case audienceOffice:
+		officeID, err := uuid.Parse(e.Target)
+		if err != nil {
+			return nil
+		}
+		return func(client *Client) []byte {
+			if !client.can(e.Permission) || !client.followsOffice(&officeID) {
+				return nil
+			}
+			return data
+		}
+
+	case audienceAttendance:
+		if e.Attendance == nil {
+			return nil
+		}
+		event := *e.Attendance
+		redacted := []byte(e.Redacted)
+		return func(client *Client) []byte {
+			switch {
+			case client.receivesAttendanceOf(event):
+				return data
+			case client.clientType == ClientTypeKiosk && client.followsOffice(event.OfficeID, event.PunchOfficeID):
+				return redacted
+			}
+			return nil
+		}
+
+	case audienceAnnouncement:
+		if e.Announcement == nil {
+			return nil
+		}
+		audience := e.Announcement
+		users := make(map[string]bool, len(audience.UserIDs))
+		for _, id := range audience.UserIDs {
+			users[id] = true
+		}
+		return func(client *Client) []byte {
+			switch client.clientType {
+			case ClientTypeKiosk:
+				if audience.Kiosk && client.officeID != nil && audience.targetsOffice(*client.officeID) {
+					return data
+				}
+			case ClientTypeAdmin:
+				if client.can(models.PermAnnouncementsManage) && client.managesAnnouncementFor(audience.OfficeIDs) {
+					return data
+				}
+			default:
+				if audience.AllUsers || users[client.userID] {
+					return data
+				}
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// dispatch delivers an event. With Redis it goes through the shared stream, which
+// numbers it and hands it to every instance, this one included.
+func (h *WebSocketHub) dispatch(event *hubEvent) {
+	if h.rdb == nil {
+		h.broadcast <- event
+		return
+	}
+	// Handlers must not wait on Redis; when it falls behind, the event is only
+	// delivered here and gets no seq
+	select {
+	case h.relay <- event:
+	default:
+		log.Printf("WebSocket relay queue full, event only sent to this instance")
+		h.broadcast <- event
+	}
+}
+
+// publishRelayed appends queued events to the shared stream in order. An event that
+// cannot be appended is still delivered to this instance's clients, without a seq.
+func (h *WebSocketHub) publishRelayed() {
+	for event := range h.relay {
+		data, err := json.Marshal(event)
+		if err != nil {
+			log.Printf("WebSocket relay marshal error: %v", err)
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), relayPublishTimeout)
+		err = h.rdb.XAdd(ctx, &redis.XAddArgs{
+			Stream: wsStreamKey,
+			MaxLen: eventHistorySize,
+			Approx: true,
+			ID:     "0-*", // Entry IDs 0-1, 0-2, ... are the seqs
+			Values: []interface{}{"event", data},
+		}).Err()
+		cancel()
+		if err != nil {
+			log.Printf("WebSocket relay publish error: %v", err)
+			h.broadcast <- event
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Reasons sent with EventResync
+const (
+	resyncStreamChanged = "stream_changed" // The client's stream is gone, e.g. Redis was reset
+	resyncGapTooLarge   = "gap_too_large"  // The missed events are no longer kept, or too many to replay
+	resyncTopicsUnknown = "topics_unknown" // The client did not say which topics it followed
+)
+
+// streamState is the shared stream as loaded from Redis: its ID and the events it keeps
+type streamState struct {
+	id     string
+	events []*hubEvent
+}
+
+// withSeq adds seq as the first field of a JSON object message
+func withSeq(data []byte, seq int64) []byte {
+	if len(data) < 2 || data[0] != '{' {
+		return data
+	}
+	stamped := make([]byte, 0, len(data)+24)
+	stamped = append(stamped, `{"seq":`...)
+	stamped = strconv.AppendInt(stamped, seq, 10)
+	if data[1] != '}' {
+		stamped = append(stamped, ',')
+	}
+	return append(stamped, data[1:]...)
+}
+
+// record keeps an event for resuming clients and returns who it goes to. Without Redis
+// the event gets the next seq here, and events no client could receive are dropped.
+// With Redis it already has its seq from the shared stream, unless Redis could not take
+// it, and then it goes out without one. Only Run calls it.
+func (h *WebSocketHub) record(event *hubEvent) messageRoute {
+	if h.rdb == nil {
+		if event.route() == nil {
+			return nil
+		}
+		event.Seq = h.seq + 1
+	} else if event.Seq <= h.seq {
+		return event.route()
+	}
+	h.keep(event)
+	return event.route()
+}
+
+// keep stamps an event's messages with its seq and stores it in the history
+func (h *WebSocketHub) keep(event *hubEvent) {
+	event.Data = withSeq(event.Data, event.Seq)
+	if len(event.Redacted) > 0 {
+		event.Redacted = withSeq(event.Redacted, event.Seq)
+	}
+	h.history[event.Seq%int64(len(h.history))] = event
+	h.seq = event.Seq
+}
+
+// attach switches to the stream loaded from Redis. When it is the stream already
+// followed, the events that came in while it was not being read are delivered now;
+// when it is another stream or events were lost, the clients are told to resync.
+// Only Run calls it.
+func (h *WebSocketHub) attach(state *streamState) {
+	if state.id == h.stream {
+		if len(state.events) == 0 || state.events[0].Seq <= h.seq+1 {
+			for _, event := range state.events {
+				if event.Seq > h.seq {
+					h.deliver(event)
+				}
+			}
+			return
+		}
+	}
+
+	previous := h.stream
+	h.stream = state.id
+	h.seq = 0
+	h.history = make([]*hubEvent, eventHistorySize)
+	for _, event := range state.events {
+		h.keep(event)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for client := range h.clients {
+		if state.id != previous {
+			h.send(client, encodeMessage(EventStream, gin.H{"stream": h.stream, "seq": h.seq, "topics": client.topicList()}))
+		}
+		if previous != "" {
+			h.send(client, encodeMessage(EventResync, gin.H{"reason": resyncStreamChanged, "seq": h.seq}))
+		}
+	}
+}
+
+// resume tells a newly registered client where the stream stands and which topics it
+// follows and, when it is reconnecting, replays what it missed or tells it to resync.
+// Only Run calls it, so no event can slip in between. The client's send buffer is new
+// and larger than maxReplay, so none of this blocks.
+func (h *WebSocketHub) resume(client *Client) {
+	client.send <- encodeMessage(EventStream, gin.H{"stream": h.stream, "seq": h.seq, "topics": client.topicList()})
+	if !client.resuming {
+		return
+	}
+
+	missed, reason := h.missedEvents(client)
+	if reason != "" {
+		client.send <- encodeMessage(EventResync, gin.H{"reason": reason, "seq": h.seq})
+		return
+	}
+	for _, data := range missed {
+		client.send <- data
+	}
+}
+
+// missedEvents returns the messages a reconnecting client would have received after
+// its last seq, or why they cannot be replayed
+func (h *WebSocketHub) missedEvents(client *Client) ([][]byte, string) {
+	if h.stream == "" || client.resumeStream != h.stream || client.resumeSeq > h.seq {
+		return nil, resyncStreamChanged
+	}
+	if !client.listedTopics {
+		return nil, resyncTopicsUnknown
+	}
+
+	oldest := h.seq - int64(len(h.history)) + 1
+	if client.resumeSeq+1 < oldest {
+		return nil, resyncGapTooLarge
+	}
+
+	var missed [][]byte
+	for seq := client.resumeSeq + 1; seq <= h.seq; seq++ {
+		event := h.history[seq%int64(len(h.history))]
+		if event == nil || event.Seq != seq {
+			// Never reached this instance, so whether the client needed it is unknown
+			return nil, resyncGapTooLarge
+		}
+		route := event.route()
+		if route == nil {
+			continue
+		}
+		if data := route(client); data != nil {
+			if len(missed) == maxReplay {
+				return nil, resyncGapTooLarge
+			}
+			missed = append(missed, data)
+		}
+	}
+	return missed, ""
+}
+
+// followStream delivers the shared stream to this instance's clients. It starts by
+// loading the events the stream keeps, so clients whose previous connection was to
+// another instance can resume here, and loads them again after Redis errors so the
+// events written meanwhile are not lost.
+func (h *WebSocketHub) followStream() {
+	ctx := context.Background()
+	var streamID, lastID string
+
+	for {
+		if streamID == "" {
+			state, err := h.loadStream(ctx)
+			if err != nil {
+				log.Printf("WebSocket stream load error: %v", err)
+				time.Sleep(streamRetryDelay)
+				continue
+			}
+			streamID, lastID = state.id, "0-0"
+			if len(state.events) > 0 {
+				lastID = streamEntryID(state.events[len(state.events)-1].Seq)
+			}
+			h.streams <- state
+		}
+
+		streams, err := h.rdb.XRead(ctx, &redis.XReadArgs{
+			Streams: []string{wsStreamKey, lastID},
+			Count:   relayQueueSize,
+			Block:   streamReadBlock,
+		}).Result()
+		if errors.Is(err, redis.Nil) {
+			// Nothing new; a missing or replaced stream ID means Redis was reset
+			if id, err := h.rdb.Get(ctx, wsStreamIDKey).Result(); errors.Is(err, redis.Nil) || (err == nil && id != streamID) {
+				streamID = ""
+			}
+			continue
+		}
+		if err != nil {
+			log.Printf("WebSocket stream read error: %v", err)
+			time.Sleep(streamRetryDelay)
+			streamID = ""
+			continue
+		}
+
+		for _, stream := range streams {
+			for _, message := range stream.Messages {
+				lastID = message.ID
+				if event := decodeStreamEntry(message); event != nil {
+					h.broadcast <- event
+				}
+			}
+		}
+	}
+}
+
+// loadStream reads the shared stream's ID, creating it for a new stream, and the
+// events the stream keeps, oldest first
+func (h *WebSocketHub) loadStream(ctx context.Context) (*streamState, error) {
+	if err := h.rdb.SetNX(ctx, wsStreamIDKey, uuid.NewString(), 0).Err(); err != nil {
+		return nil, err
+	}
+	id, err := h.rdb.Get(ctx, wsStreamIDKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	messages, err := h.rdb.XRevRangeN(ctx, wsStreamKey, "+", "-", eventHistorySize).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	state := &streamState{id: id}
+	for i := len(messages) - 1; i >= 0; i-- {
+		if event := decodeStreamEntry(messages[i]); event != nil {
+			state.events = append(state.events, event)
+		}
+	}
+	return state, nil
+}
+
+// streamEntryID is the stream entry ID of a seq
+func streamEntryID(seq int64) string {
+	return "0-" + strconv.FormatInt(seq, 10)
+}
+
+// decodeStreamEntry turns a stream entry back into its event, with the seq taken from
+// the entry ID. Entries that cannot be decoded are logged and skipped.
+func decodeStreamEntry(message redis.XMessage) *hubEvent {
+	rawSeq, found := strings.CutPrefix(message.ID, "0-")
+	seq, err := strconv.ParseInt(rawSeq, 10, 64)
+	if !found || err != nil {
+		log.Printf("WebSocket stream entry %s has no seq", message.ID)
+		return nil
+	}
+	data, _ := message.Values["event"].(string)
+
+	var event hubEvent
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		log.Printf("WebSocket stream entry %s unmarshal error: %v", message.ID, err)
+		return nil
+	}
+	event.Seq = seq
+	return &event
+}
@@ -0,0 +1,88 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/attendance-system/internal/config"
+	"github.com/google/uuid"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      []string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers email
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New returns the mailer selected by cfg.Driver: "smtp", "file" or "log".
+// Use "file" to read the links in development; "log" redacts them.
+func New(cfg config.MailConfig) (Mailer, error) {
+	switch cfg.Driver {
+	case "smtp":
+		return NewSMTPMailer(cfg.Host, cfg.Port, cfg.Username, cfg.Password, cfg.From), nil
+	case "file":
+		if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create mail directory: %w", err)
+		}
+		return &FileMailer{dir: cfg.Dir, from: cfg.From}, nil
+	case "log", "":
+		return &LogMailer{from: cfg.From}, nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.Driver)
+	}
+}
+
+// compose renders msg as an RFC 5322 message
+func compose(from string, msg Message) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(msg.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return buf.Bytes()
+}
+
+// FileMailer writes each message as an .eml file, for development and tests
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+// Send writes msg to a new file in the mail directory
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	name := time.Now().Format("20060102-150405") + "-" + uuid.NewString() + ".eml"
+	return os.WriteFile(filepath.Join(m.dir, name), compose(m.from, msg), 0600)
+}
+
+// LogMailer prints messages to the server log instead of sending them.
+// Tokens in links are redacted, since anyone reading the log could use them.
+type LogMailer struct {
+	from string
+}
+
+// linkToken matches the token at the end of an invitation or reset link
+var linkToken = regexp.MustCompile(`(https?://\S*/)[A-Za-z0-9_-]{16,}`)
+
+// Send logs msg with link tokens redacted
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	body := linkToken.ReplaceAllString(msg.Body, "${1}[redacted]")
+	log.Printf("[Mail] To: %s | Subject: %s\n%s", strings.Join(msg.To, ", "), msg.Subject, body)
+	return nil
+}
@@ -0,0 +1,41 @@
+package mailer
+
+import (
+	"context"
+	"net"
+	"net/mail"
+	"net/smtp"
+)
+
+// SMTPMailer sends mail through an SMTP server. STARTTLS is used when the server offers
+// it; credentials are optional so a local catcher such as Mailpit works out of the box.
+type SMTPMailer struct {
+	addr   string
+	auth   smtp.Auth
+	from   string // From header, may include a display name
+	sender string // Bare envelope sender address
+}
+
+// NewSMTPMailer creates a mailer for the server at host:port
+func NewSMTPMailer(host, port, username, password, from string) *SMTPMailer {
+	m := &SMTPMailer{
+		addr:   net.JoinHostPort(host, port),
+		from:   from,
+		sender: from,
+	}
+	if address, err := mail.ParseAddress(from); err == nil {
+		m.sender = address.Address
+	}
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m
+}
+
+// Send delivers msg; smtp.SendMail does not take a context, so ctx only stops a send that has not started
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return smtp.SendMail(m.addr, m.auth, m.sender, msg.To, compose(m.from, msg))
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/attendance-system/internal/models"
+	"github.com/attendance-system/internal/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// auditTargetParams are the route parameters tried, in order, as an entry's target
+var auditTargetParams = []string{"id", "key", "serial"}
+
+// NewAuditEntry starts an audit entry for the request: who made it, from where, and the
+// target and changes a handler noted with "audit_target_type", "audit_target_id" and
+// "audit_changes". It also marks the request so AuditTrail does not record it again.
+func NewAuditEntry(c *gin.Context, action, targetType, targetID string) *models.AuditLog {
+	entry := &models.AuditLog{
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+	}
+	if userID, exists := c.Get("user_id"); exists {
+		actorID := userID.(uuid.UUID)
+		entry.ActorID = &actorID
+		entry.ActorEmail = c.GetString("email")
+	}
+	if keyID, exists := c.Get("api_key_id"); exists {
+		apiKeyID := keyID.(uuid.UUID)
+		entry.APIKeyID = &apiKeyID
+	}
+	if changes, exists := c.Get("audit_changes"); exists {
+		entry.Changes = models.JSONRaw(changes.(json.RawMessage))
+	}
+	c.Set("audit_recorded", true)
+	return entry
+}
+
+// AuditTrail records every successful POST, PUT, PATCH and DELETE in the audit log.
+// Handlers that record their own, more specific entry are skipped. Without one, the
+// action is the route, e.g. "PUT /api/admin/settings/:key", and the target is the
+// route's ID parameter unless the handler named it.
+func AuditTrail(auditRepo *repository.AuditLogRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			return
+		}
+		status := c.Writer.Status()
+		if status >= http.StatusBadRequest {
+			return
+		}
+		if _, recorded := c.Get("audit_recorded"); recorded {
+			return
+		}
+
+		targetType := c.GetString("audit_target_type")
+		targetID := c.GetString("audit_target_id")
+		if targetID == "" {
+			for _, param := range auditTargetParams {
+				if value := c.Param(param); value != "" {
+					targetID = value
+					break
+				}
+			}
+		}
+
+		entry := NewAuditEntry(c, c.Request.Method+" "+c.FullPath(), targetType, targetID)
+		entry.StatusCode = status
+		if err := auditRepo.Create(c.Request.Context(), entry); err != nil {
+			log.Printf("[Audit] Failed to record %s on %s %s: %v", entry.Action, targetType, targetID, err)
+		}
+	}
+}
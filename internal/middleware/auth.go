@@ -1,15 +1,25 @@
 package middleware
 
 import (
+	"log"
 	"net/http"
 	"strings"
 
+	"github.com/attendance-system/internal/models"
 	"github.com/attendance-system/internal/utils"
 	"github.com/gin-gonic/gin"
 )
 
-// AuthMiddleware validates JWT tokens and sets user info in context
-func AuthMiddleware(jwtManager *utils.JWTManager) gin.HandlerFunc {
+// passwordChangeRoutes stay usable while a user must change their password
+var passwordChangeRoutes = map[string]bool{
+	"GET /api/users/me":       true,
+	"PUT /api/users/password": true,
+}
+
+// AuthMiddleware validates JWT tokens, refuses revoked ones and sets user info in context.
+// Users who must change their password are limited to passwordChangeRoutes.
+// Bearer tokens starting with models.APIKeyPrefix are checked as API keys instead.
+func AuthMiddleware(jwtManager *utils.JWTManager, denylist *TokenDenylist, apiKeys *APIKeyAuthenticator) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -26,6 +36,11 @@ func AuthMiddleware(jwtManager *utils.JWTManager) gin.HandlerFunc {
 			return
 		}
 
+		if strings.HasPrefix(parts[1], models.APIKeyPrefix) {
+			authenticateAPIKey(c, apiKeys, parts[1])
+			return
+		}
+
 		claims, err := jwtManager.ValidateAccessToken(parts[1])
 		if err != nil {
 			if err == utils.ErrExpiredToken {
@@ -37,45 +52,32 @@ func AuthMiddleware(jwtManager *utils.JWTManager) gin.HandlerFunc {
 			return
 		}
 
+		// A Redis outage should not lock everyone out, so revocation is best effort then
+		revoked, err := denylist.IsRevoked(c.Request.Context(), claims)
+		if err != nil {
+			log.Printf("[Auth] Token denylist unavailable: %v", err)
+		} else if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked", "code": "TOKEN_REVOKED"})
+			c.Abort()
+			return
+		}
+
 		// Set user info in context
 		c.Set("user_id", claims.UserID)
 		c.Set("email", claims.Email)
 		c.Set("role", claims.Role)
 		c.Set("employee_id", claims.EmployeeID)
+		c.Set("session_id", claims.SessionID)
 
-		c.Next()
-	}
-}
-
-// AdminMiddleware checks if user has admin role
-func AdminMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		role, exists := c.Get("role")
-		if !exists || role != "admin" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		if claims.MustChangePassword && !passwordChangeRoutes[c.Request.Method+" "+c.FullPath()] {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "You must change your password before continuing",
+				"code":  "PASSWORD_CHANGE_REQUIRED",
+			})
 			c.Abort()
 			return
 		}
-		c.Next()
-	}
-}
 
-// HRMiddleware checks if user has HR or admin role
-func HRMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		role, exists := c.Get("role")
-		if !exists {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
-			c.Abort()
-			return
-		}
-		
-		roleStr := role.(string)
-		if roleStr != "admin" && roleStr != "hr" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "HR or Admin access required"})
-			c.Abort()
-			return
-		}
 		c.Next()
 	}
 }
@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/attendance-system/internal/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// Reasons a login attempt is refused before the password is checked
+const (
+	LoginBlockedIP      = "ip_rate_limited" // Too many attempts from one address
+	LoginBlockedDelay   = "delayed"         // Sent before the delay after the last failure ran out
+	LoginBlockedAccount = "locked"          // The account is locked
+)
+
+// maxLoginDelay caps the wait imposed after a failed login
+const maxLoginDelay = 30 * time.Second
+
+// LoginBlock explains why a login attempt was refused and when to try again
+type LoginBlock struct {
+	Reason     string
+	RetryAfter time.Duration
+}
+
+// LoginGuard throttles password logins. Every address gets a fixed number of attempts
+// per minute; every account gets a doubling delay after each failed login and is
+// locked once failures reach the configured limit. Accounts are tracked by the email
+// sent, whether or not it exists, so lockouts do not reveal which accounts exist.
+type LoginGuard struct {
+	rdb    *redis.Client
+	policy config.LoginConfig
+}
+
+// NewLoginGuard creates a login guard enforcing the given policy
+func NewLoginGuard(rdb *redis.Client, policy config.LoginConfig) *LoginGuard {
+	return &LoginGuard{rdb: rdb, policy: policy}
+}
+
+func loginIPKey(ip string) string            { return "auth:login:ip:" + ip }
+func loginFailuresKey(account string) string { return "auth:login:failures:" + account }
+func loginDelayKey(account string) string    { return "auth:login:delay:" + account }
+func loginLockKey(account string) string     { return "auth:login:lock:" + account }
+
+// LoginAccount normalizes an email into the key its attempts are counted under
+func LoginAccount(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// LockoutDuration is how long an account stays locked
+func (g *LoginGuard) LockoutDuration() time.Duration {
+	return g.policy.LockoutDuration
+}
+
+// Check counts a login attempt from ip for account and reports whether it must be
+// refused. A nil block means the attempt may go ahead.
+func (g *LoginGuard) Check(ctx context.Context, ip, account string) (*LoginBlock, error) {
+	pipe := g.rdb.Pipeline()
+	attempts := pipe.Incr(ctx, loginIPKey(ip))
+	pipe.ExpireNX(ctx, loginIPKey(ip), time.Minute)
+	ipTTL := pipe.PTTL(ctx, loginIPKey(ip))
+	lockTTL := pipe.PTTL(ctx, loginLockKey(account))
+	delayTTL := pipe.PTTL(ctx, loginDelayKey(account))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	if g.policy.IPLimit > 0 && attempts.Val() > int64(g.policy.IPLimit) {
+		return &LoginBlock{Reason: LoginBlockedIP, RetryAfter: ipTTL.Val()}, nil
+	}
+	if ttl := lockTTL.Val(); ttl > 0 {
+		return &LoginBlock{Reason: LoginBlockedAccount, RetryAfter: ttl}, nil
+	}
+	if ttl := delayTTL.Val(); ttl > 0 {
+		return &LoginBlock{Reason: LoginBlockedDelay, RetryAfter: ttl}, nil
+	}
+	return nil, nil
+}
+
+// RecordFailure counts a failed login for account. It returns the number of failures
+// in a row and whether this one locked the account.
+func (g *LoginGuard) RecordFailure(ctx context.Context, account string) (int, bool, error) {
+	pipe := g.rdb.Pipeline()
+	count := pipe.Incr(ctx, loginFailuresKey(account))
+	pipe.Expire(ctx, loginFailuresKey(account), g.policy.FailureWindow)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, false, err
+	}
+	failures := int(count.Val())
+
+	if g.policy.MaxFailures > 0 && failures >= g.policy.MaxFailures {
+		pipe := g.rdb.TxPipeline()
+		pipe.Set(ctx, loginLockKey(account), 1, g.policy.LockoutDuration)
+		pipe.Del(ctx, loginFailuresKey(account), loginDelayKey(account))
+		_, err := pipe.Exec(ctx)
+		return failures, err == nil, err
+	}
+
+	// 1s after the first failure, then 2s, 4s, ... up to maxLoginDelay
+	delay := maxLoginDelay
+	if failures <= 5 {
+		delay = time.Second << (failures - 1)
+	}
+	return failures, false, g.rdb.Set(ctx, loginDelayKey(account), 1, delay).Err()
+}
+
+// RecordSuccess clears the failed logins of account
+func (g *LoginGuard) RecordSuccess(ctx context.Context, account string) error {
+	return g.rdb.Del(ctx, loginFailuresKey(account), loginDelayKey(account)).Err()
+}
+
+// Unlock lifts a lockout and clears the failed logins of account.
+// It reports whether the account was locked.
+func (g *LoginGuard) Unlock(ctx context.Context, account string) (bool, error) {
+	pipe := g.rdb.TxPipeline()
+	lock := pipe.Del(ctx, loginLockKey(account))
+	pipe.Del(ctx, loginFailuresKey(account), loginDelayKey(account))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+	return lock.Val() > 0, nil
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/attendance-system/internal/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// OfficeScope limits the request to the offices assigned to the logged-in user.
+// Scoped repository queries then only see those offices; users without any
+// assignment keep access to every office.
+func OfficeScope(scopeRepo *repository.OfficeScopeRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("user_id")
+		if !exists {
+			c.Next()
+			return
+		}
+
+		officeIDs, err := scopeRepo.FindOfficeIDs(c.Request.Context(), userID.(uuid.UUID))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load office scope"})
+			c.Abort()
+			return
+		}
+
+		if len(officeIDs) > 0 {
+			c.Request = c.Request.WithContext(repository.WithOfficeScope(c.Request.Context(), officeIDs))
+			c.Set("office_scope", officeIDs)
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/attendance-system/internal/models"
+	"github.com/attendance-system/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// permissionCacheTTL bounds how long a role's permissions are reused before reloading
+const permissionCacheTTL = time.Minute
+
+type cachedPermissions struct {
+	permissions []string
+	loadedAt    time.Time
+}
+
+// Authorizer resolves a role's permissions from the database, with a short-lived cache
+type Authorizer struct {
+	roleRepo *repository.RoleRepository
+	mu       sync.RWMutex
+	cache    map[string]cachedPermissions
+}
+
+// NewAuthorizer creates a new authorizer
+func NewAuthorizer(roleRepo *repository.RoleRepository) *Authorizer {
+	return &Authorizer{
+		roleRepo: roleRepo,
+		cache:    make(map[string]cachedPermissions),
+	}
+}
+
+// Permissions returns the permissions granted to a role; unknown roles get none
+func (a *Authorizer) Permissions(ctx context.Context, role string) []string {
+	a.mu.RLock()
+	cached, ok := a.cache[role]
+	a.mu.RUnlock()
+	if ok && time.Since(cached.loadedAt) < permissionCacheTTL {
+		return cached.permissions
+	}
+
+	var permissions []string
+	if r, err := a.roleRepo.FindByName(ctx, role); err == nil {
+		permissions = r.Permissions
+	}
+
+	a.mu.Lock()
+	a.cache[role] = cachedPermissions{permissions: permissions, loadedAt: time.Now()}
+	a.mu.Unlock()
+	return permissions
+}
+
+// Invalidate drops cached permissions so role changes apply immediately
+func (a *Authorizer) Invalidate() {
+	a.mu.Lock()
+	a.cache = make(map[string]cachedPermissions)
+	a.mu.Unlock()
+}
+
+// Require checks that the user's role grants permission.
+// It also stores the role's permissions in the context as "permissions" for handlers.
+// For API keys both are limited to the key's scopes.
+func (a *Authorizer) Require(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, exists := c.Get("role")
+		if !exists {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			c.Abort()
+			return
+		}
+
+		permissions := a.Permissions(c.Request.Context(), role.(string))
+		if scopes, ok := c.Get("api_key_scopes"); ok {
+			permissions = limitToScopes(permissions, scopes.([]string))
+		}
+		c.Set("permissions", permissions)
+
+		if !models.PermissionsGrant(permissions, permission) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":      "Permission denied",
+				"code":       "PERMISSION_DENIED",
+				"permission": permission,
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
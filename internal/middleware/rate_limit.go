@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RateLimiter counts requests per key in one-minute windows, e.g. kiosk
+// identifications per kiosk and per address
+type RateLimiter struct {
+	rdb   *redis.Client
+	name  string
+	limit int
+}
+
+// NewRateLimiter creates a limiter allowing limit requests per key each minute.
+// name keeps the counters of different limiters apart.
+func NewRateLimiter(rdb *redis.Client, name string, limit int) *RateLimiter {
+	return &RateLimiter{rdb: rdb, name: name, limit: limit}
+}
+
+func rateLimitKey(name, key string, window int64) string {
+	return "ratelimit:" + name + ":" + key + ":" + strconv.FormatInt(window, 10)
+}
+
+// Allow counts a request for key. Once the limit is used up it returns how long
+// until the next minute; zero means the request may go ahead.
+func (l *RateLimiter) Allow(ctx context.Context, key string) (time.Duration, error) {
+	now := time.Now()
+	window := now.Unix() / 60
+	redisKey := rateLimitKey(l.name, key, window)
+
+	pipe := l.rdb.Pipeline()
+	count := pipe.Incr(ctx, redisKey)
+	pipe.Expire(ctx, redisKey, 2*time.Minute)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+
+	if count.Val() > int64(l.limit) {
+		return time.Unix((window+1)*60, 0).Sub(now), nil
+	}
+	return 0, nil
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/attendance-system/internal/scim"
+	"github.com/gin-gonic/gin"
+)
+
+// SCIMAuthMiddleware lets the HR system in with its bearer token. Errors are SCIM error
+// responses, since SCIM clients expect nothing else. With no token configured every
+// request is refused.
+func SCIMAuthMiddleware(token string) gin.HandlerFunc {
+	expected := sha256.Sum256([]byte(token))
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		parts := strings.SplitN(authHeader, " ", 2)
+		ok := token != "" && len(parts) == 2 && strings.EqualFold(parts[0], "bearer")
+		if ok {
+			// Comparing digests keeps the comparison constant-time whatever the length
+			presented := sha256.Sum256([]byte(parts[1]))
+			ok = subtle.ConstantTimeCompare(presented[:], expected[:]) == 1
+		}
+		if !ok {
+			c.Header("Content-Type", scim.ContentType)
+			c.Header("WWW-Authenticate", `Bearer realm="scim"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, scim.NewError(http.StatusUnauthorized, "", "Invalid or missing bearer token"))
+			return
+		}
+		c.Next()
+	}
+}
@@ -1,9 +1,16 @@
 package models
 
 import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
 	"database/sql/driver"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"hash"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
@@ -44,6 +51,11 @@ type User struct {
 	OfficeLong             float64        `gorm:"not null" json:"office_long"`
 	AllowedRadius          int            `gorm:"default:50" json:"allowed_radius"`
 	IsActive               bool           `gorm:"default:true" json:"is_active"`
+	MustChangePassword     bool           `gorm:"default:false" json:"must_change_password"` // Set for admin-chosen passwords; only a password change is allowed until cleared
+	TwoFactorEnabled       bool           `gorm:"default:false" json:"two_factor_enabled"`
+	TOTPSecret             string         `json:"-"`                                        // Set at enrollment, in use once TwoFactorEnabled
+	TOTPLastCounter        int64          `gorm:"default:0" json:"-"`                       // Time step of the last accepted code, so a code works only once
+	ExternalID             *string        `gorm:"uniqueIndex" json:"external_id,omitempty"` // ID in the HR system that provisions the user over SCIM
 	CreatedAt              time.Time      `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt              time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
 	Attendances            []Attendance   `gorm:"foreignKey:UserID" json:"attendances,omitempty"`
@@ -52,6 +64,17 @@ type User struct {
 	Employee               *Employee      `gorm:"foreignKey:UserID" json:"employee,omitempty"`
 }
 
+// UserTombstone remembers a user that left an office, by hard delete or by moving to another
+// office, so that office's offline kiosks can drop it on their next delta sync
+type UserTombstone struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	EmployeeID string     `gorm:"not null" json:"employee_id"`
+	OfficeID   *uuid.UUID `gorm:"type:uuid;index" json:"office_id,omitempty"` // Office the user left
+	Reason     string     `gorm:"not null;default:deleted" json:"reason"`      // deleted, transferred
+	DeletedAt  time.Time  `gorm:"not null;index" json:"deleted_at"`
+}
+
 // Attendance represents a check-in/check-out record
 type Attendance struct {
 	ID             uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
@@ -70,32 +93,304 @@ type Attendance struct {
 	Notes          string     `json:"notes,omitempty"`
 	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
 	User           *User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
+
+	// Where the employee actually punched (kiosk office); nil for mobile punches at the home office
+	CheckInOfficeID  *uuid.UUID `gorm:"type:uuid;index" json:"check_in_office_id,omitempty"`
+	CheckOutOfficeID *uuid.UUID `gorm:"type:uuid" json:"check_out_office_id,omitempty"`
+	IsVisit          bool       `gorm:"default:false" json:"is_visit"` // Punched at another office's kiosk
+	IsFlagged        bool       `gorm:"default:false" json:"is_flagged"`
+	FlagReason       string     `json:"flag_reason,omitempty"`
+	CheckInOffice    *Office    `gorm:"foreignKey:CheckInOfficeID" json:"check_in_office,omitempty"`
+	CheckOutOffice   *Office    `gorm:"foreignKey:CheckOutOfficeID" json:"check_out_office,omitempty"`
+}
+
+// OfflineSyncRecord remembers client-generated offline record IDs that were applied or held,
+// so a retried upload is answered from here instead of being re-applied.
+// Client IDs are only unique per device: "user:<id>" for the mobile app, "kiosk:<kiosk_id>" for kiosks.
+type OfflineSyncRecord struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Device         string     `gorm:"not null;default:'';uniqueIndex:idx_sync_record_device_client" json:"device"`
+	ClientRecordID uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_sync_record_device_client" json:"client_record_id"`
+	Source         string     `gorm:"not null" json:"source"` // "kiosk", "mobile"
+	KioskID        string     `json:"kiosk_id,omitempty"`
+	UserID         *uuid.UUID `gorm:"type:uuid" json:"user_id,omitempty"`
+	AttendanceID   *uuid.UUID `gorm:"type:uuid" json:"attendance_id,omitempty"`
+	BatchID        *uuid.UUID `gorm:"type:uuid;index" json:"batch_id,omitempty"` // Set while held for review
+	Type           string     `gorm:"not null" json:"type"`                      // "check-in", "check-out"
+	Status         string     `gorm:"default:applied" json:"status"`             // "applied", "held", "discarded"
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
 }
 
-// RefreshToken stores JWT refresh tokens
+// Offline sync record statuses
+const (
+	SyncRecordApplied   = "applied"
+	SyncRecordHeld      = "held"
+	SyncRecordDiscarded = "discarded"
+)
+
+// Offline sync batch review statuses
+const (
+	SyncBatchPending  = "pending"
+	SyncBatchApproved = "approved"
+	SyncBatchRejected = "rejected"
+)
+
+// OfflineSyncBatch is a kiosk offline upload that failed integrity checks and waits for HR review
+type OfflineSyncBatch struct {
+	ID              uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	KioskID         string          `gorm:"not null;index" json:"kiosk_id"`
+	Records         JSONRaw         `gorm:"type:jsonb" json:"records"`
+	Reasons         JSONStringArray `gorm:"type:jsonb" json:"reasons"`
+	SignaturesValid bool            `gorm:"default:false" json:"signatures_valid"`
+	FirstSeq        int64           `json:"first_seq"`
+	LastSeq         int64           `json:"last_seq"`
+	LastHash        string          `json:"last_hash"`
+	ClockOffsetMs   int64           `json:"clock_offset_ms"`                     // Kiosk clock offset measured at upload
+	ClockShiftMs    *int64          `json:"clock_shift_ms,omitempty"`            // Shift applied to the timestamps on approval
+	Status          string          `gorm:"default:pending;index" json:"status"` // "pending", "approved", "rejected"
+	ReviewedBy      *uuid.UUID      `gorm:"type:uuid" json:"reviewed_by,omitempty"`
+	ReviewNote      string          `json:"review_note,omitempty"`
+	ReviewedAt      *time.Time      `json:"reviewed_at,omitempty"`
+	CreatedAt       time.Time       `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// RefreshToken stores JWT refresh tokens. Tokens are single use: each refresh marks the
+// presented token used and issues the next one in the same session, so a used token
+// showing up again means it was stolen.
 type RefreshToken struct {
-	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-	UserID    uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`
-	Token     string    `gorm:"uniqueIndex;not null" json:"token"`
-	ExpiresAt time.Time `gorm:"not null" json:"expires_at"`
-	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
-	User      *User     `gorm:"foreignKey:UserID" json:"-"`
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null" json:"user_id"`
+	SessionID *uuid.UUID `gorm:"type:uuid;index" json:"session_id,omitempty"` // Token family; nil for tokens issued before sessions existed
+	Token     string     `gorm:"uniqueIndex;not null" json:"token"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"` // Set when the token was rotated
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	User      *User      `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// Session revoke reasons
+const (
+	SessionLoggedOut  = "logout"
+	SessionRevoked    = "revoked"     // Ended by the user from the session list
+	SessionLogoutAll  = "logout_all"  // Ended by an admin or a "log out everywhere"
+	SessionTokenReuse = "token_reuse" // A rotated refresh token was presented again
+
+	SessionPasswordReset = "password_reset" // The password was reset through a forgot-password link
+)
+
+// Session is one login on one device; its refresh tokens form a rotation family
+type Session struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID       uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	DeviceInfo   string     `json:"device_info,omitempty"`
+	IPAddress    string     `json:"ip_address,omitempty"`
+	UserAgent    string     `json:"user_agent,omitempty"`
+	ExpiresAt    time.Time  `gorm:"not null" json:"expires_at"` // Expiry of the newest refresh token
+	LastUsedAt   time.Time  `json:"last_used_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	RevokeReason string     `json:"revoke_reason,omitempty"`
+	CreatedAt    time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// Invitation is a one-time link an employee uses to set their first password.
+// Only the SHA-256 of the token is stored; the token itself is handed out once.
+type Invitation struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	TokenHash string     `gorm:"uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"` // Set when revoked by an admin or replaced by a resend
+	CreatedBy *uuid.UUID `gorm:"type:uuid" json:"created_by,omitempty"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+
+	User *User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+// Invitation statuses, derived from the timestamps
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationRevoked  = "revoked"
+	InvitationExpired  = "expired"
+)
+
+// Status reports where the invitation stands
+func (i *Invitation) Status() string {
+	switch {
+	case i.UsedAt != nil:
+		return InvitationAccepted
+	case i.RevokedAt != nil:
+		return InvitationRevoked
+	case time.Now().After(i.ExpiresAt):
+		return InvitationExpired
+	default:
+		return InvitationPending
+	}
+}
+
+// PasswordReset is a single-use link for setting a new password after "forgot password".
+// Only the SHA-256 of the token is stored.
+type PasswordReset struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	TokenHash string     `gorm:"uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	IPAddress string     `json:"ip_address,omitempty"` // Where the reset was requested from
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+
+	User *User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+// RecoveryCode is a single-use code for logging in without the authenticator app.
+// Only the SHA-256 of the code is stored.
+type RecoveryCode struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// APIKeyPrefix starts every API key, telling them apart from JWTs
+const APIKeyPrefix = "atk_"
+
+// APIKey lets a script call the admin API. A key acts for the admin who issued it,
+// limited to its Scopes (permission names), and stops working with that admin's account.
+// Only the SHA-256 of the key is stored; Prefix keeps its first characters for display.
+type APIKey struct {
+	ID         uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Name       string          `gorm:"not null" json:"name"`
+	Prefix     string          `gorm:"not null" json:"prefix"`
+	KeyHash    string          `gorm:"uniqueIndex;not null" json:"-"`
+	Scopes     JSONStringArray `gorm:"type:jsonb;default:'[]'" json:"scopes"`
+	RateLimit  int             `gorm:"not null" json:"rate_limit"` // Requests per minute
+	ExpiresAt  *time.Time      `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time      `json:"last_used_at,omitempty"`
+	LastUsedIP string          `json:"last_used_ip,omitempty"`
+	RevokedAt  *time.Time      `json:"revoked_at,omitempty"`
+	CreatedBy  uuid.UUID       `gorm:"type:uuid;not null;index" json:"created_by"`
+	CreatedAt  time.Time       `gorm:"autoCreateTime" json:"created_at"`
+
+	Creator *User `gorm:"foreignKey:CreatedBy" json:"creator,omitempty"`
+}
+
+// Active reports whether the key can still be used
+func (k *APIKey) Active() bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || k.ExpiresAt.After(time.Now()))
+}
+
+// Audit log actions
+const (
+	AuditAccountLocked   = "auth.account_locked"   // Too many failed logins
+	AuditAccountUnlocked = "auth.account_unlocked" // Lockout lifted by an admin
+	AuditTwoFactorReset  = "auth.two_factor_reset" // 2FA removed by an admin, e.g. after a lost phone
+	AuditAPIKeyIssued    = "api_key.issued"
+	AuditAPIKeyRevoked   = "api_key.revoked"
+)
+
+// AuditLog records a security-relevant event or an admin change. ActorID is nil for
+// events without a logged-in actor, such as a lockout triggered by failed logins.
+// The log is append-only and hash-chained: every entry's Hash covers its fields and
+// the previous entry's Hash, so editing or removing an entry breaks the chain after it.
+type AuditLog struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Seq        int64      `gorm:"not null;default:0;index" json:"seq"` // Position in the chain, from 1
+	ActorID    *uuid.UUID `gorm:"type:uuid;index" json:"actor_id,omitempty"`
+	ActorEmail string     `json:"actor_email,omitempty"`                  // As it was at the time
+	APIKeyID   *uuid.UUID `gorm:"type:uuid" json:"api_key_id,omitempty"` // Set when the actor used an API key
+	Action     string     `gorm:"not null;index" json:"action"`
+	TargetType string     `gorm:"index" json:"target_type,omitempty"` // e.g. "user"
+	TargetID   string     `gorm:"index" json:"target_id,omitempty"`
+	Details    JSONRaw    `gorm:"type:jsonb" json:"details,omitempty"`
+	Changes    JSONRaw    `gorm:"type:jsonb" json:"changes,omitempty"` // {"field": {"from": ..., "to": ...}}
+	StatusCode int        `json:"status_code,omitempty"`
+	IPAddress  string     `json:"ip_address,omitempty"`
+	UserAgent  string     `json:"user_agent,omitempty"`
+	PrevHash   string     `json:"prev_hash"`
+	Hash       string     `json:"hash"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+// ComputeHash returns the HMAC, under key, of every field but Hash. Without the key,
+// which never enters the database, rewritten entries cannot be given valid hashes.
+func (l *AuditLog) ComputeHash(key []byte) string {
+	return l.digest(hmac.New(sha256.New, key))
+}
+
+// LegacyHash returns the unkeyed digest entries were chained with before the chain was keyed
+func (l *AuditLog) LegacyHash() string {
+	return l.digest(sha256.New())
+}
+
+// digest hashes every field but Hash. JSON is hashed in a canonical form, because
+// jsonb gives it back reformatted.
+func (l *AuditLog) digest(sum hash.Hash) string {
+	fields := []string{
+		strconv.FormatInt(l.Seq, 10),
+		l.PrevHash,
+		l.ID.String(),
+		uuidOrEmpty(l.ActorID),
+		l.ActorEmail,
+		uuidOrEmpty(l.APIKeyID),
+		l.Action,
+		l.TargetType,
+		l.TargetID,
+		canonicalJSON(l.Details),
+		canonicalJSON(l.Changes),
+		strconv.Itoa(l.StatusCode),
+		l.IPAddress,
+		l.UserAgent,
+		l.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+	for _, field := range fields {
+		fmt.Fprintf(sum, "%d:%s;", len(field), field)
+	}
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+func uuidOrEmpty(id *uuid.UUID) string {
+	if id == nil {
+		return ""
+	}
+	return id.String()
+}
+
+// canonicalJSON re-encodes JSON with sorted keys and no spacing; empty reads as null
+func canonicalJSON(raw []byte) string {
+	if len(raw) == 0 {
+		return "null"
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return string(raw)
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return string(raw)
+	}
+	return string(out)
 }
 
 // Office represents a company office location
 type Office struct {
-	ID                uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-	Name              string    `gorm:"not null" json:"name"`
-	Address           string    `json:"address"`
-	Latitude          float64   `gorm:"not null" json:"latitude"`
-	Longitude         float64   `gorm:"not null" json:"longitude"`
-	Radius            int       `gorm:"default:50" json:"radius"`
-	CheckInTime       string    `gorm:"default:'08:00'" json:"check_in_time"`       // HH:mm
-	CheckOutTime      string    `gorm:"default:'17:00'" json:"check_out_time"`      // HH:mm
-	CheckInTolerance  int       `gorm:"default:30" json:"check_in_tolerance"`       // Minutes
-	CheckOutTolerance int       `gorm:"default:15" json:"check_out_tolerance"`      // Minutes
-	IsActive          bool      `gorm:"default:true" json:"is_active"`
-	CreatedAt         time.Time `gorm:"autoCreateTime" json:"created_at"`
+	ID                uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Name              string          `gorm:"not null" json:"name"`
+	Address           string          `json:"address"`
+	Latitude          float64         `gorm:"not null" json:"latitude"`
+	Longitude         float64         `gorm:"not null" json:"longitude"`
+	Radius            int             `gorm:"default:50" json:"radius"`
+	CheckInTime       string          `gorm:"default:'08:00'" json:"check_in_time"`      // HH:mm
+	CheckOutTime      string          `gorm:"default:'17:00'" json:"check_out_time"`     // HH:mm
+	CheckInTolerance  int             `gorm:"default:30" json:"check_in_tolerance"`      // Minutes
+	CheckOutTolerance int             `gorm:"default:15" json:"check_out_tolerance"`     // Minutes
+	CrossOfficePolicy string          `json:"cross_office_policy,omitempty"`             // "reject", "visit", "flag"; empty uses the global setting
+	KioskFactors      JSONStringArray `gorm:"type:jsonb" json:"kiosk_factors,omitempty"` // Accepted combos, e.g. "card+face"; empty uses the global setting
+	IsActive          bool            `gorm:"default:true" json:"is_active"`
+	CreatedAt         time.Time       `gorm:"autoCreateTime" json:"created_at"`
 }
 
 // FacePhoto represents a temporary face photo pending verification
@@ -149,17 +444,266 @@ type Kiosk struct {
 	LastSeen  time.Time `json:"last_seen"`
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
 	Office    *Office   `gorm:"foreignKey:OfficeID" json:"office,omitempty"`
+
+	// Offline record signing: device public key (base64 SPKI) and the last accepted hash chain link
+	PublicKey string `json:"public_key,omitempty"`
+	SyncSeq   int64  `gorm:"default:0" json:"sync_seq"`
+	SyncHash  string `json:"sync_hash,omitempty"`
+
+	// One-time token an admin issues so a device can register or replace the key above; only its hash is kept
+	PairingTokenHash      string     `json:"-"`
+	PairingTokenExpiresAt *time.Time `json:"pairing_token_expires_at,omitempty"`
+
+	// Clock skew: server time minus kiosk time, measured from heartbeats and sync round-trips
+	ClockOffsetMs  int64      `gorm:"default:0" json:"clock_offset_ms"`
+	ClockRTTMs     int64      `gorm:"default:0" json:"clock_rtt_ms"`
+	ClockCheckedAt *time.Time `json:"clock_checked_at,omitempty"`
+}
+
+// Badge is a printed static QR badge for staff without a phone.
+// The serial is embedded in the signed QR payload and can be revoked.
+type Badge struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID        uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	Serial        string     `gorm:"uniqueIndex;not null" json:"serial"`
+	IssuedBy      *uuid.UUID `gorm:"type:uuid" json:"issued_by,omitempty"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	RevokedReason string     `json:"revoked_reason,omitempty"`
+	CreatedAt     time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	User          *User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+// Credential types
+const (
+	CredentialCard = "card"
+	CredentialPIN  = "pin"
+)
+
+// Credential links an RFID/NFC card UID or a hashed PIN to a user for kiosk identification
+type Credential struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID         uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	Type           string     `gorm:"not null" json:"type"`            // "card", "pin"
+	CardUID        string     `gorm:"index" json:"card_uid,omitempty"` // Normalized upper-case hex
+	PINHash        string     `json:"-"`
+	Label          string     `json:"label,omitempty"`
+	FailedAttempts int        `gorm:"default:0" json:"failed_attempts"`
+	LockedUntil    *time.Time `json:"locked_until,omitempty"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+	CreatedBy      *uuid.UUID `gorm:"type:uuid" json:"created_by,omitempty"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	User           *User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+// Visitor statuses
+const (
+	VisitorExpected   = "expected"
+	VisitorCheckedIn  = "checked_in"
+	VisitorCheckedOut = "checked_out"
+	VisitorCancelled  = "cancelled"
+)
+
+// Visitor is a guest logged at the front desk, pre-registered by a host or registered as a walk-in at the kiosk
+type Visitor struct {
+	ID              uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	OfficeID        uuid.UUID  `gorm:"type:uuid;not null;index" json:"office_id"`
+	HostID          uuid.UUID  `gorm:"type:uuid;not null;index" json:"host_id"`
+	Name            string     `gorm:"not null" json:"name"`
+	Company         string     `json:"company,omitempty"`
+	Phone           string     `json:"phone,omitempty"`
+	Email           string     `json:"email,omitempty"`
+	Purpose         string     `json:"purpose,omitempty"`
+	PhotoURL        string     `json:"photo_url,omitempty"`
+	PassCode        string     `gorm:"uniqueIndex;not null" json:"pass_code"`
+	Status          string     `gorm:"default:expected;index" json:"status"` // "expected", "checked_in", "checked_out", "cancelled"
+	IsWalkIn        bool       `gorm:"default:false" json:"is_walk_in"`
+	ExpectedAt      *time.Time `json:"expected_at,omitempty"`
+	CheckInAt       *time.Time `gorm:"index" json:"check_in_at,omitempty"`
+	CheckOutAt      *time.Time `json:"check_out_at,omitempty"`
+	CheckInKioskID  string     `json:"check_in_kiosk_id,omitempty"`
+	CheckOutKioskID string     `json:"check_out_kiosk_id,omitempty"`
+	CreatedBy       *uuid.UUID `gorm:"type:uuid" json:"created_by,omitempty"`
+	CreatedAt       time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt       time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+	Office          *Office    `gorm:"foreignKey:OfficeID" json:"office,omitempty"`
+	Host            *User      `gorm:"foreignKey:HostID" json:"host,omitempty"`
+}
+
+// Announcement priorities
+const (
+	AnnouncementInfo     = "info"
+	AnnouncementWarning  = "warning"
+	AnnouncementCritical = "critical"
+)
+
+// Announcement is an HR notice shown on kiosk idle screens and in the mobile app.
+// Empty OfficeIDs or Positions means no restriction on that dimension.
+type Announcement struct {
+	ID           uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Title        string          `gorm:"not null" json:"title"`
+	Body         string          `gorm:"type:text" json:"body"`
+	Priority     string          `gorm:"default:info" json:"priority"` // "info", "warning", "critical"
+	OfficeIDs    JSONStringArray `gorm:"type:jsonb;default:'[]'" json:"office_ids"`
+	Positions    JSONStringArray `gorm:"type:jsonb;default:'[]'" json:"positions"`
+	ShowOnKiosk  bool            `gorm:"default:true" json:"show_on_kiosk"`
+	ShowOnMobile bool            `gorm:"default:true" json:"show_on_mobile"`
+	StartsAt     time.Time       `gorm:"not null;index" json:"starts_at"`
+	EndsAt       *time.Time      `gorm:"index" json:"ends_at,omitempty"`
+	IsPublished  bool            `gorm:"default:false" json:"is_published"`
+	PublishedAt  *time.Time      `json:"published_at,omitempty"`
+	CreatedBy    *uuid.UUID      `gorm:"type:uuid" json:"created_by,omitempty"`
+	CreatedAt    time.Time       `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time       `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// Permissions are named capabilities granted to roles and checked per route
+
+const (
+	PermAll                    = "*" // Grants every permission
+	PermDashboardRead          = "dashboard.read"
+	PermUsersRead              = "users.read"
+	PermUsersWrite             = "users.write"
+	PermEmployeesRead          = "employees.read"
+	PermEmployeesWrite         = "employees.write"
+	PermEmployeesReadSensitive = "employees.read_sensitive"
+	PermAttendanceRead         = "attendance.read"
+	PermAttendanceCorrect      = "attendance.correct"
+	PermFaceVerify             = "face.verify"
+	PermSettingsRead           = "settings.read"
+	PermSettingsWrite          = "settings.write"
+	PermTransfersManage        = "transfers.manage"
+	PermOfficesRead            = "offices.read"
+	PermOfficesWrite           = "offices.write"
+	PermKiosksRead             = "kiosks.read"
+	PermKiosksWrite            = "kiosks.write"
+	PermCredentialsManage      = "credentials.manage"
+	PermVisitorsRead           = "visitors.read"
+	PermVisitorsWrite          = "visitors.write"
+	PermAnnouncementsManage    = "announcements.manage"
+	PermRolesManage            = "roles.manage"
+	PermScopesManage           = "scopes.manage"
+	PermAPIKeysManage          = "api_keys.manage"
+	PermAuditRead              = "audit.read"
+)
+
+// PermissionInfo describes a permission for the role management API
+type PermissionInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// PermissionCatalog lists every permission that can be granted
+var PermissionCatalog = []PermissionInfo{
+	{PermDashboardRead, "View dashboard statistics"},
+	{PermUsersRead, "List user accounts"},
+	{PermUsersWrite, "Create, update and delete user accounts"},
+	{PermEmployeesRead, "View employee records"},
+	{PermEmployeesWrite, "Create, update, import and delete employee records"},
+	{PermEmployeesReadSensitive, "View and change salary, bank, tax and ID numbers"},
+	{PermAttendanceRead, "View attendance and held offline batches"},
+	{PermAttendanceCorrect, "Approve or reject held offline attendance"},
+	{PermFaceVerify, "Review face registrations"},
+	{PermSettingsRead, "View system settings"},
+	{PermSettingsWrite, "Change system settings, including the kiosk admin code"},
+	{PermTransfersManage, "Review office transfer requests"},
+	{PermOfficesRead, "View offices"},
+	{PermOfficesWrite, "Create, update and delete offices"},
+	{PermKiosksRead, "View kiosks and clock skew"},
+	{PermKiosksWrite, "Register, update, unpair and delete kiosks"},
+	{PermCredentialsManage, "Issue and revoke badges, cards and PINs"},
+	{PermVisitorsRead, "View visitors and the visitor log"},
+	{PermVisitorsWrite, "Register, cancel and check out visitors"},
+	{PermAnnouncementsManage, "Create, publish and delete announcements"},
+	{PermRolesManage, "Manage roles and their permissions"},
+	{PermScopesManage, "Limit admin and HR users to specific offices"},
+	{PermAPIKeysManage, "Issue and revoke API keys for integrations"},
+	{PermAuditRead, "View and export the audit log"},
+}
+
+// IsKnownPermission reports whether a permission is in the catalog (or is the wildcard)
+func IsKnownPermission(permission string) bool {
+	if permission == PermAll {
+		return true
+	}
+	for _, p := range PermissionCatalog {
+		if p.Name == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// PermissionsGrant reports whether a permission set includes permission
+func PermissionsGrant(permissions []string, permission string) bool {
+	for _, p := range permissions {
+		if p == PermAll || p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Role is a named set of permissions; User.Role holds the role name
+type Role struct {
+	ID          uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Name        string          `gorm:"uniqueIndex;not null" json:"name"`
+	Description string          `json:"description,omitempty"`
+	Permissions JSONStringArray `gorm:"type:jsonb;default:'[]'" json:"permissions"`
+	IsSystem    bool            `gorm:"default:false" json:"is_system"` // Built-in roles cannot be renamed or deleted
+	CreatedAt   time.Time       `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time       `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// OfficeScope limits an admin or HR user to one office. A user with no scope rows
+// administers every office; a user with one or more only sees and changes those offices.
+type OfficeScope struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_office_scope_user_office" json:"user_id"`
+	OfficeID  uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_office_scope_user_office" json:"office_id"`
+	CreatedBy *uuid.UUID `gorm:"type:uuid" json:"created_by,omitempty"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+
+	Office *Office `gorm:"foreignKey:OfficeID" json:"office,omitempty"`
+}
+
+// DefaultRoles are seeded on migration. HR keeps day-to-day access but cannot change
+// offices or settings, see sensitive employee data, or manage roles.
+var DefaultRoles = []Role{
+	{Name: "admin", Description: "Full access", Permissions: JSONStringArray{PermAll}, IsSystem: true},
+	{Name: "hr", Description: "Human resources", IsSystem: true, Permissions: JSONStringArray{
+		PermDashboardRead, PermUsersRead, PermUsersWrite, PermEmployeesRead, PermEmployeesWrite,
+		PermAttendanceRead, PermAttendanceCorrect, PermFaceVerify, PermSettingsRead, PermTransfersManage,
+		PermOfficesRead, PermKiosksRead, PermCredentialsManage, PermVisitorsRead, PermVisitorsWrite,
+		PermAnnouncementsManage,
+	}},
+	{Name: "employee", Description: "Regular employee", Permissions: JSONStringArray{}, IsSystem: true},
 }
 
 // TableName overrides for GORM
 func (User) TableName() string                  { return "users" }
+func (UserTombstone) TableName() string         { return "user_tombstones" }
 func (Attendance) TableName() string            { return "attendances" }
+func (OfflineSyncRecord) TableName() string     { return "offline_sync_records" }
+func (OfflineSyncBatch) TableName() string      { return "offline_sync_batches" }
 func (RefreshToken) TableName() string          { return "refresh_tokens" }
+func (Session) TableName() string               { return "sessions" }
+func (AuditLog) TableName() string              { return "audit_logs" }
+func (Invitation) TableName() string            { return "invitations" }
+func (PasswordReset) TableName() string         { return "password_resets" }
+func (RecoveryCode) TableName() string          { return "recovery_codes" }
+func (APIKey) TableName() string                { return "api_keys" }
 func (Office) TableName() string                { return "offices" }
 func (FacePhoto) TableName() string             { return "face_photos" }
 func (Setting) TableName() string               { return "settings" }
 func (OfficeTransferRequest) TableName() string { return "office_transfer_requests" }
 func (Kiosk) TableName() string                 { return "kiosks" }
+func (Badge) TableName() string                 { return "badges" }
+func (Credential) TableName() string            { return "credentials" }
+func (Visitor) TableName() string               { return "visitors" }
+func (Announcement) TableName() string          { return "announcements" }
+func (Role) TableName() string                  { return "roles" }
+func (OfficeScope) TableName() string           { return "office_scopes" }
 func (Employee) TableName() string              { return "employees" }
 func (WorkExperience) TableName() string        { return "work_experiences" }
 func (EmployeeEvaluation) TableName() string    { return "employee_evaluations" }
@@ -186,6 +730,42 @@ func (a *JSONStringArray) Scan(value interface{}) error {
 	return json.Unmarshal(bytes, a)
 }
 
+// JSONRaw stores an arbitrary JSON document as JSONB
+type JSONRaw []byte
+
+func (j JSONRaw) Value() (driver.Value, error) {
+	if len(j) == 0 {
+		return "null", nil
+	}
+	return string(j), nil
+}
+
+func (j *JSONRaw) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*j = nil
+	case []byte:
+		*j = append((*j)[0:0], v...)
+	case string:
+		*j = JSONRaw(v)
+	default:
+		return errors.New("type assertion to []byte failed")
+	}
+	return nil
+}
+
+func (j JSONRaw) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return []byte("null"), nil
+	}
+	return j, nil
+}
+
+func (j *JSONRaw) UnmarshalJSON(data []byte) error {
+	*j = append((*j)[0:0], data...)
+	return nil
+}
+
 // Employee represents detailed HR data linked to a User
 type Employee struct {
 	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
@@ -0,0 +1,330 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/attendance-system/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+)
+
+// loginTTL is how long a user has to finish logging in at the identity provider
+const loginTTL = 10 * time.Minute
+
+// statePrefix namespaces pending logins in Redis
+const statePrefix = "oidc:state:"
+
+// ErrInvalidState is returned when a callback's state is unknown, expired or already used
+var ErrInvalidState = errors.New("sso login expired or was already used")
+
+// Client runs the authorization code flow with PKCE against one identity provider.
+// The provider's metadata and keys are fetched on first use, so the server starts
+// even while the provider is unreachable.
+type Client struct {
+	cfg    config.OIDCConfig
+	logins loginStore
+	http   *http.Client
+
+	mu            sync.Mutex
+	metadata      *metadata
+	keys          map[string]crypto.PublicKey
+	keysFetchedAt time.Time
+}
+
+// metadata is the part of the provider's discovery document the flow needs
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// pendingLogin is what the callback needs to finish a login, kept under its state
+type pendingLogin struct {
+	Verifier string `json:"verifier"`
+	Nonce    string `json:"nonce"`
+}
+
+// loginStore keeps pending logins between Begin and Finish
+type loginStore interface {
+	put(ctx context.Context, state string, data []byte, ttl time.Duration) error
+	// take returns and removes a pending login, or ErrInvalidState if there is none
+	take(ctx context.Context, state string) ([]byte, error)
+}
+
+// redisLoginStore keeps pending logins in Redis so any instance can finish them
+type redisLoginStore struct {
+	rdb *redis.Client
+}
+
+func (s redisLoginStore) put(ctx context.Context, state string, data []byte, ttl time.Duration) error {
+	return s.rdb.Set(ctx, statePrefix+state, data, ttl).Err()
+}
+
+func (s redisLoginStore) take(ctx context.Context, state string) ([]byte, error) {
+	data, err := s.rdb.GetDel(ctx, statePrefix+state).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrInvalidState
+	}
+	return data, err
+}
+
+// NewClient creates a client for the configured provider, or returns nil if SSO is not configured
+func NewClient(cfg config.OIDCConfig, rdb *redis.Client) *Client {
+	if cfg.IssuerURL == "" {
+		return nil
+	}
+	return &Client{
+		cfg:    cfg,
+		logins: redisLoginStore{rdb: rdb},
+		http:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Claims are the verified claims of an ID token
+type Claims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool // True only if the provider asserts email_verified
+	Name          string
+	Raw           jwt.MapClaims
+}
+
+// String returns a string or numeric claim as a string, or "" if it is missing
+func (c *Claims) String(name string) string {
+	switch v := c.Raw[name].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
+
+// Begin starts a login and returns the provider URL to send the user to and the login's
+// state, which the caller binds to the browser. The PKCE verifier and nonce stay on the
+// server until Finish.
+func (c *Client) Begin(ctx context.Context) (string, string, time.Time, error) {
+	md, err := c.provider(ctx)
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+
+	state, err := randomString()
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	pending := pendingLogin{}
+	if pending.Verifier, err = randomString(); err != nil {
+		return "", "", time.Time{}, err
+	}
+	if pending.Nonce, err = randomString(); err != nil {
+		return "", "", time.Time{}, err
+	}
+
+	data, _ := json.Marshal(pending)
+	if err := c.logins.put(ctx, state, data, loginTTL); err != nil {
+		return "", "", time.Time{}, fmt.Errorf("failed to store sso login: %w", err)
+	}
+
+	challenge := sha256.Sum256([]byte(pending.Verifier))
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", c.cfg.ClientID)
+	params.Set("redirect_uri", c.cfg.RedirectURL)
+	params.Set("scope", c.scope())
+	params.Set("state", state)
+	params.Set("nonce", pending.Nonce)
+	params.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	params.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(md.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return md.AuthorizationEndpoint + separator + params.Encode(), state, time.Now().Add(loginTTL), nil
+}
+
+// Finish redeems the authorization code the provider sent back with state and returns
+// the verified claims of the ID token. Each state can be finished once.
+func (c *Client) Finish(ctx context.Context, code, state string) (*Claims, error) {
+	data, err := c.logins.take(ctx, state)
+	if errors.Is(err, ErrInvalidState) {
+		return nil, ErrInvalidState
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sso login: %w", err)
+	}
+	var pending pendingLogin
+	if err := json.Unmarshal(data, &pending); err != nil {
+		return nil, ErrInvalidState
+	}
+
+	md, err := c.provider(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	idToken, err := c.exchange(ctx, md, code, pending.Verifier)
+	if err != nil {
+		return nil, err
+	}
+	return c.verify(ctx, md, idToken, pending.Nonce)
+}
+
+// scope returns the requested scopes, making sure "openid" is among them
+func (c *Client) scope() string {
+	scopes := []string{"openid"}
+	for _, s := range c.cfg.Scopes {
+		if s != "openid" {
+			scopes = append(scopes, s)
+		}
+	}
+	return strings.Join(scopes, " ")
+}
+
+// provider returns the provider's metadata, fetching it once
+func (c *Client) provider(ctx context.Context) (*metadata, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.metadata != nil {
+		return c.metadata, nil
+	}
+
+	var md metadata
+	wellKnown := strings.TrimSuffix(c.cfg.IssuerURL, "/") + "/.well-known/openid-configuration"
+	if err := c.getJSON(ctx, wellKnown, &md); err != nil {
+		return nil, fmt.Errorf("failed to discover sso provider: %w", err)
+	}
+	if strings.TrimSuffix(md.Issuer, "/") != strings.TrimSuffix(c.cfg.IssuerURL, "/") {
+		return nil, fmt.Errorf("sso provider reports issuer %q, expected %q", md.Issuer, c.cfg.IssuerURL)
+	}
+	if md.AuthorizationEndpoint == "" || md.TokenEndpoint == "" || md.JWKSURI == "" {
+		return nil, errors.New("sso provider metadata is incomplete")
+	}
+
+	c.metadata = &md
+	return c.metadata, nil
+}
+
+// exchange redeems an authorization code and returns the ID token
+func (c *Client) exchange(ctx context.Context, md *metadata, code, verifier string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", c.cfg.RedirectURL)
+	form.Set("client_id", c.cfg.ClientID)
+	form.Set("code_verifier", verifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, md.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if c.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(c.cfg.ClientID), url.QueryEscape(c.cfg.ClientSecret))
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to reach sso token endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return "", fmt.Errorf("sso token endpoint returned %s", resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK || body.Error != "" {
+		return "", fmt.Errorf("sso token endpoint refused the code: %s %s", body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return "", errors.New("sso token endpoint returned no id_token")
+	}
+	return body.IDToken, nil
+}
+
+// verify checks an ID token's signature, issuer, audience, expiry and nonce
+func (c *Client) verify(ctx context.Context, md *metadata, idToken, nonce string) (*Claims, error) {
+	raw := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, raw, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return c.key(ctx, md, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(md.Issuer),
+		jwt.WithAudience(c.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+	if got, _ := raw["nonce"].(string); got != nonce {
+		return nil, errors.New("invalid id_token: nonce does not match")
+	}
+
+	claims := &Claims{Raw: raw}
+	claims.Subject, _ = raw["sub"].(string)
+	claims.Email, _ = raw["email"].(string)
+	claims.Name, _ = raw["name"].(string)
+	// Some providers send the flag as a string
+	switch verified := raw["email_verified"].(type) {
+	case bool:
+		claims.EmailVerified = verified
+	case string:
+		claims.EmailVerified = verified == "true"
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("invalid id_token: no subject")
+	}
+	return claims, nil
+}
+
+// getJSON fetches a JSON document from the provider
+func (c *Client) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %s", url, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// randomString returns 32 random bytes, base64url encoded
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
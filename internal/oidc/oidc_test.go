@@ -0,0 +1,331 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/attendance-system/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID     = "attendance"
+	testClientSecret = "client-secret"
+	testRedirectURL  = "https://attendance.example.com/sso/callback"
+	testKeyID        = "test-key"
+)
+
+// memoryLoginStore keeps pending logins in memory so the flow runs without Redis
+type memoryLoginStore struct {
+	mu     sync.Mutex
+	logins map[string][]byte
+}
+
+func (s *memoryLoginStore) put(_ context.Context, state string, data []byte, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logins[state] = data
+	return nil
+}
+
+func (s *memoryLoginStore) take(_ context.Context, state string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.logins[state]
+	if !ok {
+		return nil, ErrInvalidState
+	}
+	delete(s.logins, state)
+	return data, nil
+}
+
+// authorization is what the provider remembers about a code it handed out
+type authorization struct {
+	challenge string
+	nonce     string
+}
+
+// testProvider is a minimal OpenID provider: discovery, JWKS and a token endpoint
+// that enforces PKCE and client authentication and signs ID tokens with an RSA key
+type testProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu     sync.Mutex
+	codes  map[string]authorization
+	claims jwt.MapClaims // Extra claims put in every ID token
+	nonce  *string       // Overrides the nonce put in ID tokens when set
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	p := &testProvider{
+		key:    key,
+		codes:  map[string]authorization{},
+		claims: jwt.MapClaims{"email": "budi@example.com", "email_verified": true, "name": "Budi"},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{
+			"issuer":                 p.server.URL,
+			"authorization_endpoint": p.server.URL + "/authorize",
+			"token_endpoint":         p.server.URL + "/token",
+			"jwks_uri":               p.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": testKeyID,
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", p.token)
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+// authorize plays the user logging in at the provider: it checks the authorization
+// URL the client built and returns the code the provider would redirect back with
+func (p *testProvider) authorize(t *testing.T, authURL string) (code, state string) {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("authorization URL %q: %v", authURL, err)
+	}
+	q := u.Query()
+	if u.Path != "/authorize" {
+		t.Errorf("authorization path = %q, want /authorize", u.Path)
+	}
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"redirect_uri":          testRedirectURL,
+		"scope":                 "openid email profile",
+		"code_challenge_method": "S256",
+	}
+	for name, value := range want {
+		if got := q.Get(name); got != value {
+			t.Errorf("authorization %s = %q, want %q", name, got, value)
+		}
+	}
+	for _, name := range []string{"state", "nonce", "code_challenge"} {
+		if q.Get(name) == "" {
+			t.Fatalf("authorization URL has no %s", name)
+		}
+	}
+
+	code, err = randomString()
+	if err != nil {
+		t.Fatalf("randomString: %v", err)
+	}
+	p.mu.Lock()
+	p.codes[code] = authorization{challenge: q.Get("code_challenge"), nonce: q.Get("nonce")}
+	p.mu.Unlock()
+	return code, q.Get("state")
+}
+
+func (p *testProvider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	if id, secret, ok := r.BasicAuth(); !ok || id != testClientID || secret != testClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" || r.PostForm.Get("redirect_uri") != testRedirectURL {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	p.mu.Lock()
+	auth, ok := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	claims := jwt.MapClaims{}
+	for name, value := range p.claims {
+		claims[name] = value
+	}
+	nonce := auth.nonce
+	if p.nonce != nil {
+		nonce = *p.nonce
+	}
+	p.mu.Unlock()
+
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "unknown code"})
+		return
+	}
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(challenge[:]) != auth.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "code_verifier does not match"})
+		return
+	}
+
+	now := time.Now()
+	claims["iss"] = p.server.URL
+	claims["aud"] = testClientID
+	claims["sub"] = "user-123"
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(5 * time.Minute).Unix()
+	claims["nonce"] = nonce
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = testKeyID
+	signed, err := token.SignedString(p.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"access_token": "access", "token_type": "Bearer", "id_token": signed})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func newTestClient(p *testProvider) *Client {
+	return &Client{
+		cfg: config.OIDCConfig{
+			IssuerURL:    p.server.URL,
+			ClientID:     testClientID,
+			ClientSecret: testClientSecret,
+			RedirectURL:  testRedirectURL,
+			Scopes:       []string{"openid", "email", "profile"},
+		},
+		logins: &memoryLoginStore{logins: map[string][]byte{}},
+		http:   p.server.Client(),
+	}
+}
+
+// login runs Begin and the provider's authorization step and returns the code and state
+func login(t *testing.T, p *testProvider, c *Client) (code, state string) {
+	t.Helper()
+	authURL, state, expiresAt, err := c.Begin(context.Background())
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	if time.Until(expiresAt) <= 0 {
+		t.Errorf("Begin returned an expiry in the past: %v", expiresAt)
+	}
+	code, urlState := p.authorize(t, authURL)
+	if urlState != state {
+		t.Fatalf("authorization URL state = %q, Begin returned %q", urlState, state)
+	}
+	return code, state
+}
+
+func TestBeginFinish(t *testing.T) {
+	p := newTestProvider(t)
+	c := newTestClient(p)
+
+	code, state := login(t, p, c)
+	claims, err := c.Finish(context.Background(), code, state)
+	if err != nil {
+		t.Fatalf("Finish: %v", err)
+	}
+	if claims.Subject != "user-123" || claims.Email != "budi@example.com" || claims.Name != "Budi" || !claims.EmailVerified {
+		t.Errorf("Finish claims = %+v", claims)
+	}
+}
+
+func TestFinishState(t *testing.T) {
+	p := newTestProvider(t)
+	c := newTestClient(p)
+	ctx := context.Background()
+
+	code, state := login(t, p, c)
+	if _, err := c.Finish(ctx, code, "unknown-state"); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("Finish with an unknown state: err = %v, want ErrInvalidState", err)
+	}
+	if _, err := c.Finish(ctx, code, state); err != nil {
+		t.Fatalf("Finish: %v", err)
+	}
+	if _, err := c.Finish(ctx, code, state); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("Finish with a used state: err = %v, want ErrInvalidState", err)
+	}
+}
+
+func TestFinishNonce(t *testing.T) {
+	p := newTestProvider(t)
+	c := newTestClient(p)
+
+	code, state := login(t, p, c)
+	other := "nonce-of-another-login"
+	p.nonce = &other
+	if _, err := c.Finish(context.Background(), code, state); err == nil {
+		t.Error("Finish accepted an ID token with another login's nonce")
+	}
+}
+
+func TestFinishPKCE(t *testing.T) {
+	p := newTestProvider(t)
+	c := newTestClient(p)
+	ctx := context.Background()
+
+	// A code is bound to the challenge of the login that requested it, so it cannot
+	// be redeemed under another login's state and verifier
+	stolenCode, _ := login(t, p, c)
+	_, state := login(t, p, c)
+	if _, err := c.Finish(ctx, stolenCode, state); err == nil {
+		t.Error("Finish redeemed a code with another login's verifier")
+	}
+}
+
+func TestFinishEmailVerified(t *testing.T) {
+	tests := []struct {
+		name     string
+		verified interface{} // nil leaves the claim out
+		want     bool
+	}{
+		{"true", true, true},
+		{"false", false, false},
+		{"string true", "true", true},
+		{"string false", "false", false},
+		{"missing", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProvider(t)
+			c := newTestClient(p)
+			if tt.verified == nil {
+				delete(p.claims, "email_verified")
+			} else {
+				p.claims["email_verified"] = tt.verified
+			}
+
+			code, state := login(t, p, c)
+			claims, err := c.Finish(context.Background(), code, state)
+			if err != nil {
+				t.Fatalf("Finish: %v", err)
+			}
+			if claims.EmailVerified != tt.want {
+				t.Errorf("EmailVerified = %v, want %v", claims.EmailVerified, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/attendance-system/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AnnouncementFilters holds filter options for the admin announcement list
+type AnnouncementFilters struct {
+	OfficeID  string
+	Published *bool
+	ActiveAt  *time.Time // Only announcements whose display window contains this time
+}
+
+// AnnouncementRepository handles database operations for announcements
+type AnnouncementRepository struct {
+	db *gorm.DB
+}
+
+// NewAnnouncementRepository creates a new announcement repository
+func NewAnnouncementRepository(db *gorm.DB) *AnnouncementRepository {
+	return &AnnouncementRepository{db: db}
+}
+
+// Create creates a new announcement
+func (r *AnnouncementRepository) Create(ctx context.Context, announcement *models.Announcement) error {
+	return r.db.WithContext(ctx).Create(announcement).Error
+}
+
+// FindByID finds an announcement by ID
+func (r *AnnouncementRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.Announcement, error) {
+	var announcement models.Announcement
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&announcement).Error
+	if err != nil {
+		return nil, err
+	}
+	return &announcement, nil
+}
+
+// FindAll returns announcements with optional filtering and pagination, newest first
+func (r *AnnouncementRepository) FindAll(ctx context.Context, filters AnnouncementFilters, limit, offset int) ([]models.Announcement, int64, error) {
+	var announcements []models.Announcement
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&models.Announcement{})
+	if filters.OfficeID != "" {
+		query = whereOfficeTargeted(query, filters.OfficeID)
+	}
+	if filters.Published != nil {
+		query = query.Where("is_published = ?", *filters.Published)
+	}
+	if filters.ActiveAt != nil {
+		query = whereActiveAt(query, *filters.ActiveAt)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("starts_at DESC").Limit(limit).Offset(offset).Find(&announcements).Error
+	return announcements, total, err
+}
+
+// FindActive returns published announcements visible at now for an office, most urgent first.
+// A nil officeID only matches announcements that are not restricted to offices.
+// Position targeting is left to the caller, since positions are free text.
+func (r *AnnouncementRepository) FindActive(ctx context.Context, now time.Time, officeID *uuid.UUID) ([]models.Announcement, error) {
+	var announcements []models.Announcement
+
+	query := r.db.WithContext(ctx).Where("is_published = ?", true)
+	query = whereActiveAt(query, now)
+	if officeID != nil {
+		query = whereOfficeTargeted(query, officeID.String())
+	} else {
+		query = query.Where("office_ids = '[]'::jsonb")
+	}
+
+	err := query.
+		Order("CASE priority WHEN 'critical' THEN 0 WHEN 'warning' THEN 1 ELSE 2 END").
+		Order("starts_at DESC").
+		Find(&announcements).Error
+	return announcements, err
+}
+
+func whereActiveAt(query *gorm.DB, at time.Time) *gorm.DB {
+	return query.Where("starts_at <= ? AND (ends_at IS NULL OR ends_at > ?)", at, at)
+}
+
+func whereOfficeTargeted(query *gorm.DB, officeID string) *gorm.DB {
+	target, _ := json.Marshal([]string{officeID})
+	return query.Where("(office_ids = '[]'::jsonb OR office_ids @> ?::jsonb)", string(target))
+}
+
+// FindRecipientIDs returns the active users an announcement is targeted at by office
+// and position. Positions match case-insensitively, as on GetMyAnnouncements.
+func (r *AnnouncementRepository) FindRecipientIDs(ctx context.Context, announcement *models.Announcement) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+
+	query := r.db.WithContext(ctx).Table("users").
+		Joins("LEFT JOIN employees ON employees.user_id = users.id").
+		Where("users.is_active = ?", true)
+	if len(announcement.OfficeIDs) > 0 {
+		query = query.Where("users.office_id IN ?", []string(announcement.OfficeIDs))
+	}
+	if len(announcement.Positions) > 0 {
+		positions := make([]string, len(announcement.Positions))
+		for i, position := range announcement.Positions {
+			positions[i] = strings.ToLower(position)
+		}
+		query = query.Where("LOWER(employees.position) IN ?", positions)
+	}
+
+	err := query.Pluck("users.id", &ids).Error
+	return ids, err
+}
+
+// Update updates an announcement
+func (r *AnnouncementRepository) Update(ctx context.Context, announcement *models.Announcement) error {
+	return r.db.WithContext(ctx).Save(announcement).Error
+}
+
+// Delete deletes an announcement
+func (r *AnnouncementRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&models.Announcement{}, "id = ?", id).Error
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/attendance-system/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrAPIKeyRevoked is returned when revoking a key that was already revoked
+var ErrAPIKeyRevoked = errors.New("api key already revoked")
+
+// apiKeyTouchInterval limits how often last-used tracking writes to the database
+const apiKeyTouchInterval = time.Minute
+
+// APIKeyRepository handles database operations for API keys
+type APIKeyRepository struct {
+	db *gorm.DB
+}
+
+// NewAPIKeyRepository creates a new API key repository
+func NewAPIKeyRepository(db *gorm.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+// Create stores a new API key
+func (r *APIKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	return r.db.WithContext(ctx).Create(key).Error
+}
+
+// FindByID finds an API key by ID
+func (r *APIKeyRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.db.WithContext(ctx).Preload("Creator").Where("id = ?", id).First(&key).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// FindByKeyHash finds an API key with its issuer by the key's digest
+func (r *APIKeyRepository) FindByKeyHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.db.WithContext(ctx).Preload("Creator").Where("key_hash = ?", keyHash).First(&key).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// FindAll lists API keys, newest first, optionally including revoked and expired ones
+func (r *APIKeyRepository) FindAll(ctx context.Context, includeInactive bool, limit, offset int) ([]models.APIKey, int64, error) {
+	var keys []models.APIKey
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&models.APIKey{})
+	if !includeInactive {
+		query = query.Where("revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", time.Now())
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Preload("Creator").
+		Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&keys).Error
+	return keys, total, err
+}
+
+// Revoke stops a key from working
+func (r *APIKeyRepository) Revoke(ctx context.Context, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).Model(&models.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrAPIKeyRevoked
+	}
+	return nil
+}
+
+// Touch records that a key was used from ip. Busy keys are written at most once per
+// apiKeyTouchInterval, so last_used_at is accurate to about a minute.
+func (r *APIKeyRepository) Touch(ctx context.Context, id uuid.UUID, ip string) error {
+	now := time.Now()
+	return r.db.WithContext(ctx).Model(&models.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, now.Add(-apiKeyTouchInterval)).
+		Updates(map[string]interface{}{"last_used_at": now, "last_used_ip": ip}).Error
+}
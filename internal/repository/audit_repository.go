@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/attendance-system/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AuditLogFilters narrows an audit log query; empty fields are ignored
+type AuditLogFilters struct {
+	ActorID    string
+	Action     string // An exact action, or a prefix ending in "*", e.g. "PUT *"
+	TargetType string
+	TargetID   string
+	From       *time.Time
+	To         *time.Time
+}
+
+// AuditChainBreak describes the first entry whose hash does not match
+type AuditChainBreak struct {
+	Seq    int64  `json:"seq"`
+	ID     string `json:"id,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// AuditChainStatus is the result of verifying the chain. Removing entries from the end
+// cannot be detected from the chain alone, so HeadHash is worth keeping elsewhere.
+type AuditChainStatus struct {
+	Valid    bool             `json:"valid"`
+	Entries  int64            `json:"entries"`
+	HeadHash string           `json:"head_hash"`
+	Break    *AuditChainBreak `json:"break,omitempty"`
+}
+
+// auditVerifyBatch is how many entries are read at a time when verifying the chain
+const auditVerifyBatch = 1000
+
+// AuditLogRepository handles database operations for the audit log. Entries are only
+// ever appended; each one is chained to the one before by its hash.
+type AuditLogRepository struct {
+	db       *gorm.DB
+	chainKey []byte
+}
+
+// NewAuditLogRepository creates a new audit log repository; chainKey keys the hash chain
+func NewAuditLogRepository(db *gorm.DB, chainKey []byte) *AuditLogRepository {
+	return &AuditLogRepository{db: db, chainKey: chainKey}
+}
+
+// Create appends an entry to the audit log. The table is locked while the entry is
+// chained, so concurrent entries get consecutive sequence numbers.
+func (r *AuditLogRepository) Create(ctx context.Context, entry *models.AuditLog) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("LOCK TABLE audit_logs IN EXCLUSIVE MODE").Error; err != nil {
+			return err
+		}
+		var last models.AuditLog
+		if err := tx.Select("seq", "hash").Order("seq DESC").Limit(1).Find(&last).Error; err != nil {
+			return err
+		}
+
+		if entry.ID == uuid.Nil {
+			entry.ID = uuid.New()
+		}
+		entry.Seq = last.Seq + 1
+		entry.PrevHash = last.Hash
+		// Postgres keeps microseconds, and the hash must match what is read back
+		entry.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+		entry.Hash = entry.ComputeHash(r.chainKey)
+		return tx.Create(entry).Error
+	})
+}
+
+// FindAll lists entries, newest first
+func (r *AuditLogRepository) FindAll(ctx context.Context, filters AuditLogFilters, limit, offset int) ([]models.AuditLog, int64, error) {
+	var entries []models.AuditLog
+	var total int64
+
+	query := r.filtered(ctx, filters)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("seq DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&entries).Error
+	return entries, total, err
+}
+
+// FindForExport returns up to limit entries in chain order, for CSV export
+func (r *AuditLogRepository) FindForExport(ctx context.Context, filters AuditLogFilters, limit int) ([]models.AuditLog, error) {
+	var entries []models.AuditLog
+	err := r.filtered(ctx, filters).
+		Order("seq ASC").
+		Limit(limit).
+		Find(&entries).Error
+	return entries, err
+}
+
+func (r *AuditLogRepository) filtered(ctx context.Context, filters AuditLogFilters) *gorm.DB {
+	query := r.db.WithContext(ctx).Model(&models.AuditLog{})
+
+	if filters.ActorID != "" {
+		query = query.Where("actor_id = ?", filters.ActorID)
+	}
+	if prefix, ok := strings.CutSuffix(filters.Action, "*"); ok {
+		like := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix)
+		query = query.Where("action LIKE ?", like+"%")
+	} else if filters.Action != "" {
+		query = query.Where("action = ?", filters.Action)
+	}
+	if filters.TargetType != "" {
+		query = query.Where("target_type = ?", filters.TargetType)
+	}
+	if filters.TargetID != "" {
+		query = query.Where("target_id = ?", filters.TargetID)
+	}
+	if filters.From != nil {
+		query = query.Where("created_at >= ?", *filters.From)
+	}
+	if filters.To != nil {
+		query = query.Where("created_at <= ?", *filters.To)
+	}
+	return query
+}
+
+// Verify walks the whole chain and stops at the first entry that is missing, out of
+// place or modified
+func (r *AuditLogRepository) Verify(ctx context.Context) (*AuditChainStatus, error) {
+	status := &AuditChainStatus{}
+	for {
+		var entries []models.AuditLog
+		err := r.db.WithContext(ctx).
+			Where("seq > ?", status.Entries).
+			Order("seq ASC").
+			Limit(auditVerifyBatch).
+			Find(&entries).Error
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range entries {
+			entry := &entries[i]
+			switch {
+			case entry.Seq != status.Entries+1:
+				status.Break = &AuditChainBreak{Seq: status.Entries + 1, Reason: "entry is missing"}
+			case entry.PrevHash != status.HeadHash:
+				status.Break = &AuditChainBreak{Seq: entry.Seq, ID: entry.ID.String(), Reason: "previous hash does not match"}
+			case entry.ComputeHash(r.chainKey) != entry.Hash:
+				status.Break = &AuditChainBreak{Seq: entry.Seq, ID: entry.ID.String(), Reason: "entry was modified"}
+			}
+			if status.Break != nil {
+				return status, nil
+			}
+			status.Entries = entry.Seq
+			status.HeadHash = entry.Hash
+		}
+		if len(entries) < auditVerifyBatch {
+			status.Valid = true
+			return status, nil
+		}
+	}
+}
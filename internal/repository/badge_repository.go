@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/attendance-system/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// BadgeRepository handles database operations for printed QR badges
+type BadgeRepository struct {
+	db *gorm.DB
+}
+
+// NewBadgeRepository creates a new badge repository
+func NewBadgeRepository(db *gorm.DB) *BadgeRepository {
+	return &BadgeRepository{db: db}
+}
+
+// Issue stores a new badge and revokes the user's previous active badges
+func (r *BadgeRepository) Issue(ctx context.Context, badge *models.Badge) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Badge{}).
+			Where("user_id = ? AND revoked_at IS NULL", badge.UserID).
+			Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": "Replaced by " + badge.Serial}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(badge).Error
+	})
+}
+
+// FindBySerial finds a badge by its serial number
+func (r *BadgeRepository) FindBySerial(ctx context.Context, serial string) (*models.Badge, error) {
+	var badge models.Badge
+	err := r.db.WithContext(ctx).Preload("User").Where("serial = ?", serial).First(&badge).Error
+	if err != nil {
+		return nil, err
+	}
+	return &badge, nil
+}
+
+// FindBySerials returns the badges with the given serial numbers
+func (r *BadgeRepository) FindBySerials(ctx context.Context, serials []string) ([]models.Badge, error) {
+	var badges []models.Badge
+	err := r.db.WithContext(ctx).Preload("User").Where("serial IN ?", serials).Find(&badges).Error
+	return badges, err
+}
+
+// FindByUserID returns all badges issued to a user, newest first
+func (r *BadgeRepository) FindByUserID(ctx context.Context, userID uuid.UUID) ([]models.Badge, error) {
+	var badges []models.Badge
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").Find(&badges).Error
+	return badges, err
+}
+
+// Update updates a badge
+func (r *BadgeRepository) Update(ctx context.Context, badge *models.Badge) error {
+	return r.db.WithContext(ctx).Save(badge).Error
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/attendance-system/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CredentialRepository handles database operations for kiosk cards and PINs
+type CredentialRepository struct {
+	db *gorm.DB
+}
+
+// NewCredentialRepository creates a new credential repository
+func NewCredentialRepository(db *gorm.DB) *CredentialRepository {
+	return &CredentialRepository{db: db}
+}
+
+// Create stores a new credential
+func (r *CredentialRepository) Create(ctx context.Context, credential *models.Credential) error {
+	return r.db.WithContext(ctx).Create(credential).Error
+}
+
+// ReplacePIN revokes the user's active PIN and stores the new one
+func (r *CredentialRepository) ReplacePIN(ctx context.Context, credential *models.Credential) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Credential{}).
+			Where("user_id = ? AND type = ? AND revoked_at IS NULL", credential.UserID, models.CredentialPIN).
+			Update("revoked_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(credential).Error
+	})
+}
+
+// FindByID finds a credential by ID
+func (r *CredentialRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.Credential, error) {
+	var credential models.Credential
+	err := r.db.WithContext(ctx).First(&credential, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// FindActiveCard finds the active card credential with the given UID
+func (r *CredentialRepository) FindActiveCard(ctx context.Context, cardUID string) (*models.Credential, error) {
+	var credential models.Credential
+	err := r.db.WithContext(ctx).Preload("User").
+		Where("type = ? AND card_uid = ? AND revoked_at IS NULL", models.CredentialCard, cardUID).
+		First(&credential).Error
+	if err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// FindActivePIN finds the active PIN credential of a user
+func (r *CredentialRepository) FindActivePIN(ctx context.Context, userID uuid.UUID) (*models.Credential, error) {
+	var credential models.Credential
+	err := r.db.WithContext(ctx).
+		Where("type = ? AND user_id = ? AND revoked_at IS NULL", models.CredentialPIN, userID).
+		First(&credential).Error
+	if err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// FindByUserID returns all credentials of a user, newest first
+func (r *CredentialRepository) FindByUserID(ctx context.Context, userID uuid.UUID) ([]models.Credential, error) {
+	var credentials []models.Credential
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").Find(&credentials).Error
+	return credentials, err
+}
+
+// Update updates a credential
+func (r *CredentialRepository) Update(ctx context.Context, credential *models.Credential) error {
+	return r.db.WithContext(ctx).Save(credential).Error
+}
@@ -85,8 +85,9 @@ func (r *EmployeeRepository) FindAll(ctx context.Context, filters EmployeeFilter
 	query = query.Preload("User").Preload("Office").Preload("Manager")
 
 	if filters.OfficeID != "" {
-		query = query.Where("office_id = ?", filters.OfficeID)
+		query = query.Where("employees.office_id = ?", filters.OfficeID)
 	}
+	query = scopeToOffices(ctx, query, "employees.office_id")
 
 	if filters.Name != "" {
 		// Search in Employee NIK or User Name
@@ -192,6 +193,43 @@ func (r *EmployeeRepository) FindByUserID(ctx context.Context, userID uuid.UUID)
 	return &employee, nil
 }
 
+// maxTeamDepth bounds how many management levels FindTeam walks down
+const maxTeamDepth = 20
+
+// FindTeam returns the direct and indirect reports of a manager, nearest levels first.
+// Employee.ManagerID points at the manager's user, so each level is found from the
+// user IDs of the level above. Users already seen are skipped, so a cycle in the
+// reporting lines cannot loop or list the manager as their own report.
+func (r *EmployeeRepository) FindTeam(ctx context.Context, managerUserID uuid.UUID) ([]models.Employee, error) {
+	team := []models.Employee{}
+	seen := map[uuid.UUID]bool{managerUserID: true}
+	level := []uuid.UUID{managerUserID}
+
+	for depth := 0; depth < maxTeamDepth && len(level) > 0; depth++ {
+		var reports []models.Employee
+		err := r.db.WithContext(ctx).
+			Preload("User").
+			Preload("Office").
+			Where("manager_id IN ? AND user_id IS NOT NULL", level).
+			Order("name ASC").
+			Find(&reports).Error
+		if err != nil {
+			return nil, err
+		}
+
+		level = level[:0:0]
+		for _, report := range reports {
+			if seen[*report.UserID] {
+				continue
+			}
+			seen[*report.UserID] = true
+			team = append(team, report)
+			level = append(level, *report.UserID)
+		}
+	}
+	return team, nil
+}
+
 // FindByNIK finds an employee by their NIK (EmployeeID)
 func (r *EmployeeRepository) FindByNIK(ctx context.Context, nik string) (*models.Employee, error) {
 	var employee models.Employee
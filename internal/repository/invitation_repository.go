@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/attendance-system/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrInvitationUnavailable is returned when an invitation was already used, revoked or has expired
+var ErrInvitationUnavailable = errors.New("invitation is no longer valid")
+
+// InvitationRepository handles database operations for account invitations
+type InvitationRepository struct {
+	db *gorm.DB
+}
+
+// NewInvitationRepository creates a new invitation repository
+func NewInvitationRepository(db *gorm.DB) *InvitationRepository {
+	return &InvitationRepository{db: db}
+}
+
+// pendingInvitation restricts a query to invitations that can still be accepted
+func pendingInvitation(query *gorm.DB) *gorm.DB {
+	return query.Where("invitations.used_at IS NULL AND invitations.revoked_at IS NULL AND invitations.expires_at > ?", time.Now())
+}
+
+// Issue stores a new invitation for a user and revokes the user's earlier pending ones,
+// so only the newest link works
+func (r *InvitationRepository) Issue(ctx context.Context, invitation *models.Invitation) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Invitation{}).
+			Where("user_id = ? AND used_at IS NULL AND revoked_at IS NULL", invitation.UserID).
+			Update("revoked_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(invitation).Error
+	})
+}
+
+// FindByID finds an invitation by ID
+func (r *InvitationRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.Invitation, error) {
+	var invitation models.Invitation
+	err := r.db.WithContext(ctx).Preload("User").Where("id = ?", id).First(&invitation).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// FindPendingByTokenHash finds an invitation that can still be accepted by its token digest
+func (r *InvitationRepository) FindPendingByTokenHash(ctx context.Context, tokenHash string) (*models.Invitation, error) {
+	var invitation models.Invitation
+	err := pendingInvitation(r.db.WithContext(ctx)).
+		Preload("User").
+		Where("token_hash = ?", tokenHash).
+		First(&invitation).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// FindAll lists invitations, newest first, optionally only the pending ones.
+// Results are limited to the caller's office scope.
+func (r *InvitationRepository) FindAll(ctx context.Context, pendingOnly bool, limit, offset int) ([]models.Invitation, int64, error) {
+	var invitations []models.Invitation
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&models.Invitation{}).
+		Joins("JOIN users ON users.id = invitations.user_id")
+	query = scopeToOffices(ctx, query, "users.office_id")
+	if pendingOnly {
+		query = pendingInvitation(query)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Preload("User").
+		Order("invitations.created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&invitations).Error
+	return invitations, total, err
+}
+
+// FindUsersAwaitingInvitation returns active users whose invitation was never accepted
+// and who have no password of their own yet, limited to the caller's office scope
+func (r *InvitationRepository) FindUsersAwaitingInvitation(ctx context.Context) ([]models.User, error) {
+	var users []models.User
+	query := r.db.WithContext(ctx).Model(&models.User{}).
+		Where("users.is_active = ?", true).
+		Where("EXISTS (SELECT 1 FROM invitations WHERE invitations.user_id = users.id)").
+		Where("NOT EXISTS (SELECT 1 FROM invitations WHERE invitations.user_id = users.id AND invitations.used_at IS NOT NULL)")
+	query = scopeToOffices(ctx, query, "users.office_id")
+	err := query.Find(&users).Error
+	return users, err
+}
+
+// Revoke invalidates a pending invitation
+func (r *InvitationRepository) Revoke(ctx context.Context, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).Model(&models.Invitation{}).
+		Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvitationUnavailable
+	}
+	return nil
+}
+
+// Accept uses up an invitation and sets the invited user's password. It fails with
+// ErrInvitationUnavailable if the invitation was used, revoked or expired meanwhile.
+func (r *InvitationRepository) Accept(ctx context.Context, invitation *models.Invitation, passwordHash string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := pendingInvitation(tx.Model(&models.Invitation{})).
+			Where("id = ?", invitation.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvitationUnavailable
+		}
+		return tx.Model(&models.User{}).
+			Where("id = ?", invitation.UserID).
+			Updates(map[string]interface{}{"password_hash": passwordHash, "must_change_password": false}).Error
+	})
+}
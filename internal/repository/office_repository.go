@@ -25,6 +25,7 @@ func (r *OfficeRepository) FindAll(ctx context.Context, limit, offset int) ([]mo
 	var total int64
 	
 	query := r.db.WithContext(ctx).Model(&models.Office{})
+	query = scopeToOffices(ctx, query, "offices.id")
 	
 	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"strings"
+
+	"github.com/attendance-system/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type officeScopeKey struct{}
+
+// WithOfficeScope returns a context that limits scoped repository queries to the given offices
+func WithOfficeScope(ctx context.Context, officeIDs []uuid.UUID) context.Context {
+	return context.WithValue(ctx, officeScopeKey{}, officeIDs)
+}
+
+// OfficeScopeFrom returns the offices a context is limited to; ok is false when it is unrestricted
+func OfficeScopeFrom(ctx context.Context) (officeIDs []uuid.UUID, ok bool) {
+	officeIDs, ok = ctx.Value(officeScopeKey{}).([]uuid.UUID)
+	return officeIDs, ok
+}
+
+// scopeToOffices limits a query to rows where any of the given office columns falls
+// inside the context's office scope. Unrestricted contexts leave the query unchanged.
+func scopeToOffices(ctx context.Context, query *gorm.DB, columns ...string) *gorm.DB {
+	officeIDs, ok := OfficeScopeFrom(ctx)
+	if !ok {
+		return query
+	}
+	if len(officeIDs) == 0 {
+		return query.Where("1 = 0")
+	}
+
+	conditions := make([]string, len(columns))
+	args := make([]interface{}, len(columns))
+	for i, column := range columns {
+		conditions[i] = column + " IN ?"
+		args[i] = officeIDs
+	}
+	return query.Where("("+strings.Join(conditions, " OR ")+")", args...)
+}
+
+// OfficeScopeRepository handles database operations for office scope assignments
+type OfficeScopeRepository struct {
+	db *gorm.DB
+}
+
+// NewOfficeScopeRepository creates a new office scope repository
+func NewOfficeScopeRepository(db *gorm.DB) *OfficeScopeRepository {
+	return &OfficeScopeRepository{db: db}
+}
+
+// FindByUserID returns a user's scope assignments with their offices
+func (r *OfficeScopeRepository) FindByUserID(ctx context.Context, userID uuid.UUID) ([]models.OfficeScope, error) {
+	scopes := []models.OfficeScope{}
+	err := r.db.WithContext(ctx).Preload("Office").
+		Where("user_id = ?", userID).
+		Order("created_at ASC").
+		Find(&scopes).Error
+	return scopes, err
+}
+
+// FindOfficeIDs returns the offices a user is limited to; empty means unrestricted
+func (r *OfficeScopeRepository) FindOfficeIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	var officeIDs []uuid.UUID
+	err := r.db.WithContext(ctx).Model(&models.OfficeScope{}).
+		Where("user_id = ?", userID).
+		Pluck("office_id", &officeIDs).Error
+	return officeIDs, err
+}
+
+// Replace swaps a user's scope assignments for the given offices in one transaction
+func (r *OfficeScopeRepository) Replace(ctx context.Context, userID uuid.UUID, officeIDs []uuid.UUID, createdBy *uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return replaceOfficeScopes(tx, userID, officeIDs, createdBy)
+	})
+}
+
+// replaceOfficeScopes swaps a user's scope assignments within the caller's transaction
+func replaceOfficeScopes(tx *gorm.DB, userID uuid.UUID, officeIDs []uuid.UUID, createdBy *uuid.UUID) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.OfficeScope{}).Error; err != nil {
+		return err
+	}
+	for _, officeID := range officeIDs {
+		scope := &models.OfficeScope{UserID: userID, OfficeID: officeID, CreatedBy: createdBy}
+		if err := tx.Create(scope).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/attendance-system/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrPasswordResetUnavailable is returned when a reset link was already used or has expired
+var ErrPasswordResetUnavailable = errors.New("password reset link is no longer valid")
+
+// PasswordResetRepository handles database operations for password reset links
+type PasswordResetRepository struct {
+	db *gorm.DB
+}
+
+// NewPasswordResetRepository creates a new password reset repository
+func NewPasswordResetRepository(db *gorm.DB) *PasswordResetRepository {
+	return &PasswordResetRepository{db: db}
+}
+
+// Create stores a new reset link and invalidates the user's earlier unused ones,
+// so only the newest email works
+func (r *PasswordResetRepository) Create(ctx context.Context, reset *models.PasswordReset) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.PasswordReset{}).
+			Where("user_id = ? AND used_at IS NULL AND expires_at > ?", reset.UserID, time.Now()).
+			Update("expires_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(reset).Error
+	})
+}
+
+// CountSince counts the reset links requested for a user since a point in time
+func (r *PasswordResetRepository) CountSince(ctx context.Context, userID uuid.UUID, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.PasswordReset{}).
+		Where("user_id = ? AND created_at >= ?", userID, since).
+		Count(&count).Error
+	return count, err
+}
+
+// FindValidByTokenHash finds an unused, unexpired reset link by its token digest
+func (r *PasswordResetRepository) FindValidByTokenHash(ctx context.Context, tokenHash string) (*models.PasswordReset, error) {
+	var reset models.PasswordReset
+	err := r.db.WithContext(ctx).
+		Preload("User").
+		Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", tokenHash, time.Now()).
+		First(&reset).Error
+	if err != nil {
+		return nil, err
+	}
+	return &reset, nil
+}
+
+// Consume uses up a reset link and sets the user's new password. It fails with
+// ErrPasswordResetUnavailable if the link was used or expired meanwhile.
+func (r *PasswordResetRepository) Consume(ctx context.Context, reset *models.PasswordReset, passwordHash string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.PasswordReset{}).
+			Where("id = ? AND used_at IS NULL AND expires_at > ?", reset.ID, time.Now()).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrPasswordResetUnavailable
+		}
+		return tx.Model(&models.User{}).
+			Where("id = ?", reset.UserID).
+			Updates(map[string]interface{}{"password_hash": passwordHash, "must_change_password": false}).Error
+	})
+}
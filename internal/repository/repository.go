@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -41,6 +42,20 @@ func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
 	return r.db.WithContext(ctx).Create(user).Error
 }
 
+// CreateWithOfficeScopes creates a user limited to the given offices in one transaction;
+// no offices leaves the user unrestricted
+func (r *UserRepository) CreateWithOfficeScopes(ctx context.Context, user *models.User, officeIDs []uuid.UUID, createdBy *uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		if len(officeIDs) == 0 {
+			return nil
+		}
+		return replaceOfficeScopes(tx, user.ID, officeIDs, createdBy)
+	})
+}
+
 // FindByID finds a user by ID
 func (r *UserRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
 	var user models.User
@@ -84,10 +99,21 @@ func (r *UserRepository) Update(ctx context.Context, user *models.User) error {
 // UpdateWithSelect updates a user with explicit column selection to avoid GORM association issues
 func (r *UserRepository) UpdateWithSelect(ctx context.Context, user *models.User) error {
 	return r.db.WithContext(ctx).Model(user).
-		Select("name", "email", "role", "is_active", "office_id", "office_lat", "office_long", "allowed_radius", "password_hash", "avatar_url", "face_verification_status", "face_embeddings", "updated_at").
+		Select("name", "email", "role", "is_active", "office_id", "office_lat", "office_long", "allowed_radius", "password_hash", "must_change_password", "avatar_url", "face_verification_status", "face_embeddings", "updated_at").
 		Updates(user).Error
 }
 
+// UpdateWithOfficeScopes updates a user like UpdateWithSelect and replaces their office
+// scopes in the same transaction
+func (r *UserRepository) UpdateWithOfficeScopes(ctx context.Context, user *models.User, officeIDs []uuid.UUID, createdBy *uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := (&UserRepository{db: tx}).UpdateWithSelect(ctx, user); err != nil {
+			return err
+		}
+		return replaceOfficeScopes(tx, user.ID, officeIDs, createdBy)
+	})
+}
+
 // UpdateFaceEmbeddings updates user's face embeddings
 func (r *UserRepository) UpdateFaceEmbeddings(ctx context.Context, userID uuid.UUID, embeddings models.FaceEmbeddings) error {
 	return r.db.WithContext(ctx).Model(&models.User{}).
@@ -98,7 +124,8 @@ func (r *UserRepository) UpdateFaceEmbeddings(ctx context.Context, userID uuid.U
 // GetAll returns all active users (simple version)
 func (r *UserRepository) GetAll(ctx context.Context) ([]models.User, error) {
 	var users []models.User
-	err := r.db.WithContext(ctx).Preload("Office").Where("is_active = ?", true).Find(&users).Error
+	query := r.db.WithContext(ctx).Preload("Office").Where("is_active = ?", true)
+	err := scopeToOffices(ctx, query, "users.office_id").Find(&users).Error
 	return users, err
 }
 
@@ -119,8 +146,9 @@ func (r *UserRepository) FindAll(ctx context.Context, filters UserFilters, limit
 		query = query.Where("role = ?", filters.Role)
 	}
 	if filters.OfficeID != "" {
-		query = query.Where("office_id = ?", filters.OfficeID)
+		query = query.Where("users.office_id = ?", filters.OfficeID)
 	}
+	query = scopeToOffices(ctx, query, "users.office_id")
 	if filters.IsActive != nil {
 		query = query.Where("users.is_active = ?", *filters.IsActive)
 	}
@@ -191,16 +219,105 @@ func (r *UserRepository) FindAll(ctx context.Context, filters UserFilters, limit
 	return users, total, err
 }
 
-// UpdatePasswordHash updates user's password hash
+// UpdatePasswordHash sets a password the user chose themselves, which clears must_change_password
 func (r *UserRepository) UpdatePasswordHash(ctx context.Context, userID uuid.UUID, passwordHash string) error {
 	return r.db.WithContext(ctx).Model(&models.User{}).
 		Where("id = ?", userID).
-		Update("password_hash", passwordHash).Error
+		Updates(map[string]interface{}{"password_hash": passwordHash, "must_change_password": false}).Error
 }
 
-// Delete deletes a user
+// Delete deletes a user and leaves a tombstone for kiosk delta sync
 func (r *UserRepository) Delete(ctx context.Context, userID uuid.UUID) error {
-	return r.db.WithContext(ctx).Delete(&models.User{}, userID).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Where("id = ?", userID).First(&user).Error; err != nil {
+			return err
+		}
+
+		tombstone := &models.UserTombstone{
+			UserID:     user.ID,
+			EmployeeID: user.EmployeeID,
+			OfficeID:   user.OfficeID,
+			Reason:     "deleted",
+			DeletedAt:  time.Now(),
+		}
+		if err := tx.Create(tombstone).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&models.User{}, userID).Error
+	})
+}
+
+// KioskSyncQuery describes one page of a kiosk employee sync.
+// A zero Since requests a full snapshot of eligible users in the office;
+// otherwise every user of the office changed after Since is returned so the caller can
+// emit tombstones for those no longer eligible. Users who left the office are tombstone rows.
+type KioskSyncQuery struct {
+	OfficeID       uuid.UUID
+	Since          time.Time
+	Until          time.Time // High-water mark fixed for the whole paged sync
+	AfterUpdatedAt time.Time // Keyset position of the previous page
+	AfterID        uuid.UUID
+	Limit          int
+}
+
+// syncSettleWindow is how far behind the database clock a sync stops. Change times are
+// stamped by the app before the transaction commits, so a change can become visible
+// with a time older than the newest one already committed; the window must exceed the
+// longest such transaction plus any clock skew between app and database.
+const syncSettleWindow = 30 * time.Second
+
+// SyncHighWaterMark returns the latest change time across users and user tombstones,
+// capped at the database clock minus syncSettleWindow so that changes still committing
+// fall into the next sync instead of before its since
+func (r *UserRepository) SyncHighWaterMark(ctx context.Context) (time.Time, error) {
+	var mark struct {
+		Mark *time.Time
+	}
+	err := r.db.WithContext(ctx).Raw(
+		"SELECT LEAST(GREATEST((SELECT MAX(updated_at) FROM users), (SELECT MAX(deleted_at) FROM user_tombstones)), "+
+			"now() - make_interval(secs => ?)) AS mark",
+		syncSettleWindow.Seconds(),
+	).Scan(&mark).Error
+	if err != nil || mark.Mark == nil {
+		return time.Time{}, err
+	}
+	return *mark.Mark, nil
+}
+
+// FindForKioskSync returns one page of users ordered by (updated_at, id)
+func (r *UserRepository) FindForKioskSync(ctx context.Context, q KioskSyncQuery) ([]models.User, error) {
+	var users []models.User
+
+	query := r.db.WithContext(ctx).Model(&models.User{}).
+		Where("updated_at <= ?", q.Until)
+
+	query = query.Where("office_id = ?", q.OfficeID)
+	if q.Since.IsZero() {
+		query = query.Where("is_active = ? AND face_verification_status = ?", true, "verified")
+	} else {
+		query = query.Where("updated_at > ?", q.Since)
+	}
+
+	if !q.AfterUpdatedAt.IsZero() {
+		query = query.Where("(updated_at, id) > (?, ?)", q.AfterUpdatedAt, q.AfterID)
+	}
+
+	err := query.Order("updated_at ASC, id ASC").Limit(q.Limit).Find(&users).Error
+	return users, err
+}
+
+// FindTombstones returns users that left an office in the (since, until] window.
+// Users who have since come back are left out; they sync as regular changes.
+func (r *UserRepository) FindTombstones(ctx context.Context, officeID uuid.UUID, since, until time.Time) ([]models.UserTombstone, error) {
+	var tombstones []models.UserTombstone
+	err := r.db.WithContext(ctx).
+		Where("office_id = ? AND deleted_at > ? AND deleted_at <= ?", officeID, since, until).
+		Where("NOT EXISTS (SELECT 1 FROM users WHERE users.id = user_tombstones.user_id AND users.office_id = ?)", officeID).
+		Order("deleted_at ASC").
+		Find(&tombstones).Error
+	return tombstones, err
 }
 
 // FindByFaceStatus finds users by face verification status with pagination
@@ -210,6 +327,7 @@ func (r *UserRepository) FindByFaceStatus(ctx context.Context, status string, li
 
 	query := r.db.WithContext(ctx).Model(&models.User{}).
 		Where("face_verification_status = ?", status)
+	query = scopeToOffices(ctx, query, "users.office_id")
 
 	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
@@ -271,6 +389,29 @@ type AttendanceFilters struct {
 	Status    string // "late", "on_time"
 	SortBy    string
 	SortOrder string // "ASC", "DESC"
+
+	WorkedOfficeID string // Office where the check-in actually happened
+	Visit          string // "visit", "home", "flagged"
+
+	UserIDs []uuid.UUID // Limit to these users when non-nil, e.g. a manager's team
+}
+
+// applyWorkedOfficeFilters narrows an attendance query joined with users by where people actually worked.
+// Punches without a recorded office (mobile) count as made at the user's own office.
+func applyWorkedOfficeFilters(query *gorm.DB, filters AttendanceFilters) *gorm.DB {
+	if filters.WorkedOfficeID != "" {
+		query = query.Where("COALESCE(attendances.check_in_office_id, users.office_id) = ?", filters.WorkedOfficeID)
+	}
+
+	switch filters.Visit {
+	case "visit":
+		query = query.Where("attendances.is_visit = ?", true)
+	case "home":
+		query = query.Where("attendances.is_visit = ?", false)
+	case "flagged":
+		query = query.Where("attendances.is_flagged = ?", true)
+	}
+	return query
 }
 
 // AttendanceRepository handles database operations for attendance
@@ -325,11 +466,134 @@ func (r *AttendanceRepository) FindByUserAndDate(ctx context.Context, userID uui
 	return &attendance, nil
 }
 
+// FindByUsersAndDate returns the attendance of several users on a date (format: 2006-01-02)
+func (r *AttendanceRepository) FindByUsersAndDate(ctx context.Context, userIDs []uuid.UUID, date string) ([]models.Attendance, error) {
+	attendances := []models.Attendance{}
+	if len(userIDs) == 0 {
+		return attendances, nil
+	}
+	err := r.db.WithContext(ctx).
+		Preload("CheckInOffice").
+		Where("user_id IN ? AND DATE(check_in_time) = ?", userIDs, date).
+		Order("check_in_time ASC").
+		Find(&attendances).Error
+	return attendances, err
+}
+
+// FindHeldSyncRecordsByUsers returns offline records of the given users that wait for HR review
+func (r *AttendanceRepository) FindHeldSyncRecordsByUsers(ctx context.Context, userIDs []uuid.UUID) ([]models.OfflineSyncRecord, error) {
+	records := []models.OfflineSyncRecord{}
+	if len(userIDs) == 0 {
+		return records, nil
+	}
+	err := r.db.WithContext(ctx).
+		Where("user_id IN ? AND status = ?", userIDs, models.SyncRecordHeld).
+		Order("created_at ASC").
+		Find(&records).Error
+	return records, err
+}
+
 // Update updates an attendance record
 func (r *AttendanceRepository) Update(ctx context.Context, attendance *models.Attendance) error {
 	return r.db.WithContext(ctx).Save(attendance).Error
 }
 
+// Transaction runs fn with a repository bound to a single database transaction.
+// Returning an error from fn rolls back everything written through txRepo.
+func (r *AttendanceRepository) Transaction(ctx context.Context, fn func(txRepo *AttendanceRepository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&AttendanceRepository{db: tx})
+	})
+}
+
+// FindSyncRecord finds an applied or held offline record by the device that sent it and
+// its client-generated ID. It returns nil without an error when the ID has not been seen before.
+func (r *AttendanceRepository) FindSyncRecord(ctx context.Context, device string, clientRecordID uuid.UUID) (*models.OfflineSyncRecord, error) {
+	var record models.OfflineSyncRecord
+	err := r.db.WithContext(ctx).Where("device = ? AND client_record_id = ?", device, clientRecordID).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// CreateSyncRecord stores the client-generated ID of an applied or held offline record
+func (r *AttendanceRepository) CreateSyncRecord(ctx context.Context, record *models.OfflineSyncRecord) error {
+	return r.db.WithContext(ctx).Create(record).Error
+}
+
+// UpdateSyncRecord updates an offline sync record
+func (r *AttendanceRepository) UpdateSyncRecord(ctx context.Context, record *models.OfflineSyncRecord) error {
+	return r.db.WithContext(ctx).Save(record).Error
+}
+
+// CreateSyncBatch stores an offline batch that is held for review
+func (r *AttendanceRepository) CreateSyncBatch(ctx context.Context, batch *models.OfflineSyncBatch) error {
+	return r.db.WithContext(ctx).Create(batch).Error
+}
+
+// FindSyncBatchByID finds a held offline batch by ID
+func (r *AttendanceRepository) FindSyncBatchByID(ctx context.Context, id uuid.UUID) (*models.OfflineSyncBatch, error) {
+	var batch models.OfflineSyncBatch
+	err := r.db.WithContext(ctx).First(&batch, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// FindSyncBatches lists held offline batches, optionally filtered by status
+func (r *AttendanceRepository) FindSyncBatches(ctx context.Context, status string, limit, offset int) ([]models.OfflineSyncBatch, int64, error) {
+	var batches []models.OfflineSyncBatch
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&models.OfflineSyncBatch{})
+	if officeIDs, ok := OfficeScopeFrom(ctx); ok {
+		query = query.Where("kiosk_id IN (?)", r.db.Model(&models.Kiosk{}).Select("kiosk_id").Where("office_id IN ?", officeIDs))
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("created_at ASC").Limit(limit).Offset(offset).Find(&batches).Error
+	return batches, total, err
+}
+
+// UpdateSyncBatch updates a held offline batch
+func (r *AttendanceRepository) UpdateSyncBatch(ctx context.Context, batch *models.OfflineSyncBatch) error {
+	return r.db.WithContext(ctx).Save(batch).Error
+}
+
+// FindSyncRecordsByBatch returns the offline records held in a batch
+func (r *AttendanceRepository) FindSyncRecordsByBatch(ctx context.Context, batchID uuid.UUID) ([]models.OfflineSyncRecord, error) {
+	var records []models.OfflineSyncRecord
+	err := r.db.WithContext(ctx).Where("batch_id = ?", batchID).Find(&records).Error
+	return records, err
+}
+
+// ErrSyncChainConflict is returned when a kiosk's signed record chain moved concurrently
+var ErrSyncChainConflict = errors.New("kiosk sync chain was modified concurrently")
+
+// AdvanceKioskSyncChain moves a kiosk's last accepted chain link from fromSeq to seq/hash.
+// It fails with ErrSyncChainConflict when another upload advanced the chain first.
+func (r *AttendanceRepository) AdvanceKioskSyncChain(ctx context.Context, kioskID uuid.UUID, fromSeq, seq int64, hash string) error {
+	result := r.db.WithContext(ctx).Model(&models.Kiosk{}).
+		Where("id = ? AND sync_seq = ?", kioskID, fromSeq).
+		Updates(map[string]interface{}{"sync_seq": seq, "sync_hash": hash})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSyncChainConflict
+	}
+	return nil
+}
+
 // GetHistory returns attendance history for a user
 func (r *AttendanceRepository) GetHistory(ctx context.Context, userID uuid.UUID, limit, offset int) ([]models.Attendance, error) {
 	var attendances []models.Attendance
@@ -351,6 +615,8 @@ func (r *AttendanceRepository) FindAll(ctx context.Context, filters AttendanceFi
 		Preload("User").
 		Preload("User.Office").   // Preload Office for User
 		Preload("User.Employee"). // Preload Employee for User
+		Preload("CheckInOffice").
+		Preload("CheckOutOffice").
 		Joins("JOIN users ON users.id = attendances.user_id").
 		Joins("LEFT JOIN employees ON employees.user_id = users.id") // Join employees for position
 
@@ -376,6 +642,12 @@ func (r *AttendanceRepository) FindAll(ctx context.Context, filters AttendanceFi
 	if filters.OfficeID != "" {
 		query = query.Where("users.office_id = ?", filters.OfficeID)
 	}
+	query = scopeToOffices(ctx, query, "users.office_id", "attendances.check_in_office_id")
+	if filters.UserIDs != nil {
+		query = query.Where("attendances.user_id IN ?", filters.UserIDs)
+	}
+
+	query = applyWorkedOfficeFilters(query, filters)
 
 	// Status Filter (Late vs On Time)
 	if filters.Status != "" {
@@ -396,6 +668,7 @@ func (r *AttendanceRepository) FindAll(ctx context.Context, filters AttendanceFi
 		"name":          "users.name",
 		"position":      "employees.position",
 		"office":        "users.office_id", // Sorting by ID is weird, maybe join office name?
+		"worked_office": "attendances.check_in_office_id",
 		// For now simple field mapping
 	}
 
@@ -434,6 +707,8 @@ type ReportStats struct {
 	TotalOnTime     int64 `json:"total_on_time"`
 	TotalLate       int64 `json:"total_late"`
 	TotalEarlyLeave int64 `json:"total_early_leave"`
+	TotalVisits     int64 `json:"total_visits"`
+	TotalFlagged    int64 `json:"total_flagged"`
 }
 
 // GetReportStats calculates attendance statistics based on filters
@@ -464,6 +739,12 @@ func (r *AttendanceRepository) GetReportStats(ctx context.Context, filters Atten
 	if filters.OfficeID != "" {
 		query = query.Where("users.office_id = ?", filters.OfficeID)
 	}
+	query = scopeToOffices(ctx, query, "users.office_id", "attendances.check_in_office_id")
+	if filters.UserIDs != nil {
+		query = query.Where("attendances.user_id IN ?", filters.UserIDs)
+	}
+
+	query = applyWorkedOfficeFilters(query, filters)
 
 	// Count Total Present (all check-ins)
 	if err := query.Session(&gorm.Session{}).Count(&stats.TotalPresent).Error; err != nil {
@@ -485,6 +766,14 @@ func (r *AttendanceRepository) GetReportStats(ctx context.Context, filters Atten
 		return nil, err
 	}
 
+	// Count cross-office visits and flagged punches
+	if err := query.Session(&gorm.Session{}).Where("attendances.is_visit = ?", true).Count(&stats.TotalVisits).Error; err != nil {
+		return nil, err
+	}
+	if err := query.Session(&gorm.Session{}).Where("attendances.is_flagged = ?", true).Count(&stats.TotalFlagged).Error; err != nil {
+		return nil, err
+	}
+
 	return stats, nil
 }
 
@@ -508,8 +797,11 @@ type HourlyStat struct {
 func (r *AttendanceRepository) GetDailyStats(ctx context.Context, startTime, endTime time.Time) ([]DailyStat, error) {
 	var stats []DailyStat
 
-	err := r.db.WithContext(ctx).Model(&models.Attendance{}).
-		Joins("JOIN users ON users.id = attendances.user_id").
+	query := r.db.WithContext(ctx).Model(&models.Attendance{}).
+		Joins("JOIN users ON users.id = attendances.user_id")
+	query = scopeToOffices(ctx, query, "users.office_id", "attendances.check_in_office_id")
+
+	err := query.
 		Select("TO_CHAR(check_in_time, 'YYYY-MM-DD') as date, COUNT(*) as total, "+
 			"SUM(CASE WHEN is_late = false THEN 1 ELSE 0 END) as present, "+
 			"SUM(CASE WHEN is_late = true THEN 1 ELSE 0 END) as late").
@@ -527,7 +819,11 @@ func (r *AttendanceRepository) GetHourlyStats(ctx context.Context, startTime, en
 
 	// Group by H (Hour 0-23)
 	// TO_CHAR(check_in_time, 'HH24:00') returns like "09:00", "14:00"
-	err := r.db.WithContext(ctx).Model(&models.Attendance{}).
+	query := r.db.WithContext(ctx).Model(&models.Attendance{}).
+		Joins("JOIN users ON users.id = attendances.user_id")
+	query = scopeToOffices(ctx, query, "users.office_id", "attendances.check_in_office_id")
+
+	err := query.
 		Select("TO_CHAR(check_in_time, 'HH24:00') as hour, COUNT(*) as total, "+
 			"SUM(CASE WHEN is_late = false THEN 1 ELSE 0 END) as present, "+
 			"SUM(CASE WHEN is_late = true THEN 1 ELSE 0 END) as late").
@@ -554,7 +850,7 @@ func (r *RefreshTokenRepository) Create(ctx context.Context, token *models.Refre
 	return r.db.WithContext(ctx).Create(token).Error
 }
 
-// FindByToken finds a refresh token by token string
+// FindByToken finds an unexpired refresh token by token string, including used ones
 func (r *RefreshTokenRepository) FindByToken(ctx context.Context, token string) (*models.RefreshToken, error) {
 	var refreshToken models.RefreshToken
 	err := r.db.WithContext(ctx).
@@ -567,6 +863,20 @@ func (r *RefreshTokenRepository) FindByToken(ctx context.Context, token string)
 	return &refreshToken, nil
 }
 
+// MarkUsed marks a refresh token as rotated. It reports false when the token was
+// already used, so two requests racing with the same token cannot both succeed.
+func (r *RefreshTokenRepository) MarkUsed(ctx context.Context, id uuid.UUID) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+// DeleteBySessionID deletes every refresh token of a session
+func (r *RefreshTokenRepository) DeleteBySessionID(ctx context.Context, sessionID uuid.UUID) error {
+	return r.db.WithContext(ctx).Where("session_id = ?", sessionID).Delete(&models.RefreshToken{}).Error
+}
+
 // DeleteByToken deletes a refresh token
 func (r *RefreshTokenRepository) DeleteByToken(ctx context.Context, token string) error {
 	return r.db.WithContext(ctx).Where("token = ?", token).Delete(&models.RefreshToken{}).Error
@@ -669,6 +979,7 @@ func (r *TransferRequestRepository) FindByStatus(ctx context.Context, status str
 	query := r.db.WithContext(ctx).Model(&models.OfficeTransferRequest{}).
 		Preload("User").
 		Where("status = ?", status)
+	query = scopeToOffices(ctx, query, "office_transfer_requests.current_office_id", "office_transfer_requests.requested_office_id")
 
 	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
@@ -681,6 +992,20 @@ func (r *TransferRequestRepository) FindByStatus(ctx context.Context, status str
 	return requests, total, err
 }
 
+// FindPendingByUserIDs finds the pending transfer requests of several users
+func (r *TransferRequestRepository) FindPendingByUserIDs(ctx context.Context, userIDs []uuid.UUID) ([]models.OfficeTransferRequest, error) {
+	requests := []models.OfficeTransferRequest{}
+	if len(userIDs) == 0 {
+		return requests, nil
+	}
+	err := r.db.WithContext(ctx).
+		Preload("User").
+		Where("user_id IN ? AND status = ?", userIDs, "pending").
+		Order("created_at ASC").
+		Find(&requests).Error
+	return requests, err
+}
+
 // Update updates a transfer request
 func (r *TransferRequestRepository) Update(ctx context.Context, request *models.OfficeTransferRequest) error {
 	return r.db.WithContext(ctx).Save(request).Error
@@ -704,7 +1029,7 @@ func (r *KioskRepository) Create(ctx context.Context, kiosk *models.Kiosk) error
 // FindByKioskID finds a kiosk by its unique ID
 func (r *KioskRepository) FindByKioskID(ctx context.Context, kioskID string) (*models.Kiosk, error) {
 	var kiosk models.Kiosk
-	err := r.db.WithContext(ctx).Where("kiosk_id = ?", kioskID).First(&kiosk).Error
+	err := r.db.WithContext(ctx).Preload("Office").Where("kiosk_id = ?", kioskID).First(&kiosk).Error
 	if err != nil {
 		return nil, err
 	}
@@ -716,12 +1041,24 @@ func (r *KioskRepository) UpdateLastSeen(ctx context.Context, id uuid.UUID) erro
 	return r.db.WithContext(ctx).Model(&models.Kiosk{}).Where("id = ?", id).Update("last_seen", time.Now()).Error
 }
 
+// UpdateClockOffset stores a kiosk's latest clock offset measurement and marks it as seen
+func (r *KioskRepository) UpdateClockOffset(ctx context.Context, id uuid.UUID, offsetMs, rttMs int64) error {
+	now := time.Now()
+	return r.db.WithContext(ctx).Model(&models.Kiosk{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"clock_offset_ms":  offsetMs,
+		"clock_rtt_ms":     rttMs,
+		"clock_checked_at": now,
+		"last_seen":        now,
+	}).Error
+}
+
 // GetAll returns kiosks with pagination
 func (r *KioskRepository) GetAll(ctx context.Context, limit, offset int) ([]models.Kiosk, int64, error) {
 	var kiosks []models.Kiosk
 	var total int64
 
 	query := r.db.WithContext(ctx).Model(&models.Kiosk{}).Preload("Office")
+	query = scopeToOffices(ctx, query, "kiosks.office_id")
 
 	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
@@ -739,6 +1076,15 @@ func (r *KioskRepository) Update(ctx context.Context, kiosk *models.Kiosk) error
 	return r.db.WithContext(ctx).Save(kiosk).Error
 }
 
+// ConsumePairingToken uses up a kiosk's pairing token if it matches and has not expired.
+// It reports false when the token is wrong, expired or was already used.
+func (r *KioskRepository) ConsumePairingToken(ctx context.Context, id uuid.UUID, tokenHash string) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.Kiosk{}).
+		Where("id = ? AND pairing_token_hash = ? AND pairing_token_expires_at > ?", id, tokenHash, time.Now()).
+		Updates(map[string]interface{}{"pairing_token_hash": "", "pairing_token_expires_at": nil})
+	return result.RowsAffected == 1, result.Error
+}
+
 // Delete deletes a kiosk
 func (r *KioskRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	return r.db.WithContext(ctx).Delete(&models.Kiosk{}, id).Error
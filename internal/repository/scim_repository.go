@@ -0,0 +1,295 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/attendance-system/internal/models"
+	"github.com/attendance-system/internal/scim"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidSCIMFilter is returned for filters on attributes that cannot be filtered on
+var ErrInvalidSCIMFilter = errors.New("invalid filter")
+
+// scimUserAttributes are the SCIM user attributes that can be filtered on
+var scimUserAttributes = map[string]scim.Attribute{
+	"id":                {Column: "users.id::text", Type: scim.AttrExact},
+	"externalid":        {Column: "users.external_id", Type: scim.AttrExact},
+	"username":          {Column: "users.email", Type: scim.AttrString},
+	"emails":            {Column: "users.email", Type: scim.AttrString},
+	"emails.value":      {Column: "users.email", Type: scim.AttrString},
+	"displayname":       {Column: "users.name", Type: scim.AttrString},
+	"name.formatted":    {Column: "users.name", Type: scim.AttrString},
+	"active":            {Column: "users.is_active", Type: scim.AttrBool},
+	"title":             {Column: "employees.position", Type: scim.AttrString},
+	"employeenumber":    {Column: "users.employee_id", Type: scim.AttrExact},
+	"meta.created":      {Column: "users.created_at", Type: scim.AttrTime},
+	"meta.lastmodified": {Column: "users.updated_at", Type: scim.AttrTime},
+	"urn:ietf:params:scim:schemas:extension:enterprise:2.0:user:employeenumber": {Column: "users.employee_id", Type: scim.AttrExact},
+}
+
+// SCIMProfile holds the SCIM user attributes that live on the employee record
+type SCIMProfile struct {
+	Title     string
+	ManagerID *uuid.UUID
+}
+
+// SCIMRepository handles database operations for SCIM provisioning. A SCIM user is a
+// users row plus its employee record, which has no office until the user joins one.
+type SCIMRepository struct {
+	db *gorm.DB
+}
+
+// NewSCIMRepository creates a new SCIM repository
+func NewSCIMRepository(db *gorm.DB) *SCIMRepository {
+	return &SCIMRepository{db: db}
+}
+
+// FindUsers returns a page of users matching filter, oldest first; filter may be nil
+func (r *SCIMRepository) FindUsers(ctx context.Context, filter *scim.Filter, limit, offset int) ([]models.User, int64, error) {
+	var users []models.User
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&models.User{}).
+		Joins("LEFT JOIN employees ON employees.user_id = users.id")
+	if filter != nil {
+		condition, args, err := filter.SQL(scimUserAttributes)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w: %v", ErrInvalidSCIMFilter, err)
+		}
+		query = query.Where(condition, args...)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if limit == 0 {
+		return users, total, nil
+	}
+
+	err := query.Preload("Office").Preload("Employee").
+		Order("users.created_at ASC, users.id ASC").
+		Limit(limit).
+		Offset(offset).
+		Find(&users).Error
+	return users, total, err
+}
+
+// FindUser finds a user with their office and employee record
+func (r *SCIMRepository) FindUser(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	var user models.User
+	err := r.db.WithContext(ctx).Preload("Office").Preload("Employee").Where("id = ?", id).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// CreateUser creates a provisioned user and links or creates their employee record
+func (r *SCIMRepository) CreateUser(ctx context.Context, user *models.User, profile SCIMProfile) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return syncSCIMEmployee(tx, user, profile)
+	})
+}
+
+// FindConflict returns the SCIM attribute whose value another user already has:
+// "userName", "employeeNumber" or "externalId", or "" if user can be stored
+func (r *SCIMRepository) FindConflict(ctx context.Context, user *models.User) (string, error) {
+	condition := "email = ? OR employee_id = ?"
+	args := []interface{}{user.Email, user.EmployeeID}
+	if user.ExternalID != nil {
+		condition += " OR external_id = ?"
+		args = append(args, *user.ExternalID)
+	}
+
+	var other models.User
+	err := r.db.WithContext(ctx).Where("id <> ?", user.ID).Where(condition, args...).First(&other).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case other.Email == user.Email:
+		return "userName", nil
+	case other.EmployeeID == user.EmployeeID:
+		return "employeeNumber", nil
+	default:
+		return "externalId", nil
+	}
+}
+
+// SaveUser stores the provisioned attributes of a user and their employee record
+func (r *SCIMRepository) SaveUser(ctx context.Context, user *models.User, profile SCIMProfile) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(user).
+			Select("employee_id", "external_id", "name", "email", "is_active", "updated_at").
+			Updates(user).Error
+		if err != nil {
+			return err
+		}
+		return syncSCIMEmployee(tx, user, profile)
+	})
+}
+
+// syncSCIMEmployee brings the user's employee record in line with the user. An unlinked
+// record with the same NIK is adopted, otherwise one is created.
+func syncSCIMEmployee(tx *gorm.DB, user *models.User, profile SCIMProfile) error {
+	var employee models.Employee
+	err := tx.Where("user_id = ?", user.ID).First(&employee).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = tx.Where("nik = ? AND user_id IS NULL", user.EmployeeID).First(&employee).Error
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		employee = models.Employee{
+			ID:        uuid.New(),
+			UserID:    &user.ID,
+			NIK:       user.EmployeeID,
+			Name:      user.Name,
+			Position:  profile.Title,
+			ManagerID: profile.ManagerID,
+		}
+		omit := []string{"User", "Office", "Manager"}
+		if user.OfficeID != nil {
+			employee.OfficeID = *user.OfficeID
+		} else {
+			omit = append(omit, "OfficeID") // Stays NULL until the user joins an office group
+		}
+		return tx.Omit(omit...).Create(&employee).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{
+		"user_id":    user.ID,
+		"nik":        user.EmployeeID,
+		"name":       user.Name,
+		"position":   profile.Title,
+		"manager_id": profile.ManagerID,
+	}
+	if user.OfficeID != nil {
+		updates["office_id"] = *user.OfficeID
+	}
+	return tx.Model(&models.Employee{}).Where("id = ?", employee.ID).Updates(updates).Error
+}
+
+// SCIMGroup is an office or a role seen as a SCIM group
+type SCIMGroup struct {
+	Office *models.Office
+	Role   *models.Role
+}
+
+// FindGroups lists offices, then roles, optionally only those whose name matches
+// displayName case-insensitively
+func (r *SCIMRepository) FindGroups(ctx context.Context, displayName *string) ([]SCIMGroup, error) {
+	var offices []models.Office
+	var roles []models.Role
+
+	officeQuery := r.db.WithContext(ctx).Order("name ASC")
+	roleQuery := r.db.WithContext(ctx).Order("name ASC")
+	if displayName != nil {
+		officeQuery = officeQuery.Where("LOWER(name) = ?", strings.ToLower(*displayName))
+		roleQuery = roleQuery.Where("LOWER(name) = ?", strings.ToLower(*displayName))
+	}
+	if err := officeQuery.Find(&offices).Error; err != nil {
+		return nil, err
+	}
+	if err := roleQuery.Find(&roles).Error; err != nil {
+		return nil, err
+	}
+
+	groups := make([]SCIMGroup, 0, len(offices)+len(roles))
+	for i := range offices {
+		groups = append(groups, SCIMGroup{Office: &offices[i]})
+	}
+	for i := range roles {
+		groups = append(groups, SCIMGroup{Role: &roles[i]})
+	}
+	return groups, nil
+}
+
+// FindGroup finds the office or role with the given ID
+func (r *SCIMRepository) FindGroup(ctx context.Context, id uuid.UUID) (*SCIMGroup, error) {
+	var office models.Office
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&office).Error
+	if err == nil {
+		return &SCIMGroup{Office: &office}, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var role models.Role
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&role).Error; err != nil {
+		return nil, err
+	}
+	return &SCIMGroup{Role: &role}, nil
+}
+
+// FindGroupMembers returns the users in an office or with a role
+func (r *SCIMRepository) FindGroupMembers(ctx context.Context, group *SCIMGroup) ([]models.User, error) {
+	var users []models.User
+	query := r.db.WithContext(ctx).Select("id", "name", "email").Order("name ASC")
+	if group.Office != nil {
+		query = query.Where("office_id = ?", group.Office.ID)
+	} else {
+		query = query.Where("role = ?", group.Role.Name)
+	}
+	err := query.Find(&users).Error
+	return users, err
+}
+
+// AddToOffice moves users into an office, together with their employee records
+func (r *SCIMRepository) AddToOffice(ctx context.Context, office *models.Office, userIDs []uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.User{}).Where("id IN ?", userIDs).Updates(map[string]interface{}{
+			"office_id":      office.ID,
+			"office_lat":     office.Latitude,
+			"office_long":    office.Longitude,
+			"allowed_radius": office.Radius,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.Employee{}).Where("user_id IN ?", userIDs).Update("office_id", office.ID).Error
+	})
+}
+
+// RemoveFromOffice takes users out of an office; users in other offices are left alone
+func (r *SCIMRepository) RemoveFromOffice(ctx context.Context, office *models.Office, userIDs []uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.User{}).
+			Where("id IN ? AND office_id = ?", userIDs, office.ID).
+			Update("office_id", nil).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.Employee{}).
+			Where("user_id IN ? AND office_id = ?", userIDs, office.ID).
+			Update("office_id", nil).Error
+	})
+}
+
+// SetRole gives users a role and returns the IDs of those whose role changed.
+// With from set, only users who currently have that role are changed.
+func (r *SCIMRepository) SetRole(ctx context.Context, role string, userIDs []uuid.UUID, from string) ([]uuid.UUID, error) {
+	var changed []uuid.UUID
+	query := r.db.WithContext(ctx).Model(&models.User{}).Where("id IN ? AND role <> ?", userIDs, role)
+	if from != "" {
+		query = query.Where("role = ?", from)
+	}
+	if err := query.Pluck("id", &changed).Error; err != nil || len(changed) == 0 {
+		return nil, err
+	}
+	err := r.db.WithContext(ctx).Model(&models.User{}).Where("id IN ?", changed).Update("role", role).Error
+	return changed, err
+}
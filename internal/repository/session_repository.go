@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/attendance-system/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SessionRepository handles database operations for login sessions
+type SessionRepository struct {
+	db *gorm.DB
+}
+
+// NewSessionRepository creates a new session repository
+func NewSessionRepository(db *gorm.DB) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+// Create creates a new session
+func (r *SessionRepository) Create(ctx context.Context, session *models.Session) error {
+	return r.db.WithContext(ctx).Create(session).Error
+}
+
+// FindByID finds a session by ID
+func (r *SessionRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.Session, error) {
+	var session models.Session
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// FindActiveByUserID returns a user's sessions that are neither revoked nor expired, most recent first
+func (r *SessionRepository) FindActiveByUserID(ctx context.Context, userID uuid.UUID) ([]models.Session, error) {
+	sessions := []models.Session{}
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// Touch records a refresh on a session: where it came from and when its new token expires
+func (r *SessionRepository) Touch(ctx context.Context, id uuid.UUID, ipAddress, userAgent string, expiresAt time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.Session{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"ip_address":   ipAddress,
+			"user_agent":   userAgent,
+			"expires_at":   expiresAt,
+			"last_used_at": time.Now(),
+		}).Error
+}
+
+// Revoke ends a session and deletes its refresh tokens
+func (r *SessionRepository) Revoke(ctx context.Context, id uuid.UUID, reason string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Session{}).
+			Where("id = ? AND revoked_at IS NULL", id).
+			Updates(map[string]interface{}{"revoked_at": time.Now(), "revoke_reason": reason}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("session_id = ?", id).Delete(&models.RefreshToken{}).Error
+	})
+}
+
+// RevokeAllForUser ends every session of a user, optionally keeping one, and deletes
+// their refresh tokens. It returns the IDs of the sessions it ended.
+func (r *SessionRepository) RevokeAllForUser(ctx context.Context, userID uuid.UUID, reason string, except *uuid.UUID) ([]uuid.UUID, error) {
+	var revoked []uuid.UUID
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		sessions := tx.Model(&models.Session{}).Where("user_id = ? AND revoked_at IS NULL", userID)
+		tokens := tx.Where("user_id = ?", userID)
+		if except != nil {
+			sessions = sessions.Where("id <> ?", *except)
+			tokens = tokens.Where("(session_id IS NULL OR session_id <> ?)", *except)
+		}
+
+		if err := sessions.Session(&gorm.Session{}).Pluck("id", &revoked).Error; err != nil {
+			return err
+		}
+		if len(revoked) > 0 {
+			err := tx.Model(&models.Session{}).
+				Where("id IN ?", revoked).
+				Updates(map[string]interface{}{"revoked_at": time.Now(), "revoke_reason": reason}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return tokens.Delete(&models.RefreshToken{}).Error
+	})
+	return revoked, err
+}
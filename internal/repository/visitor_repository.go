@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/attendance-system/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// VisitorFilters holds filter options for listing visitors
+type VisitorFilters struct {
+	OfficeID string
+	HostID   string
+	Status   string
+	Name     string
+	From     *time.Time // Matches visits checked in, or expected, at or after From
+	To       *time.Time
+}
+
+// VisitorRepository handles database operations for visitors
+type VisitorRepository struct {
+	db *gorm.DB
+}
+
+// NewVisitorRepository creates a new visitor repository
+func NewVisitorRepository(db *gorm.DB) *VisitorRepository {
+	return &VisitorRepository{db: db}
+}
+
+// Create creates a new visitor
+func (r *VisitorRepository) Create(ctx context.Context, visitor *models.Visitor) error {
+	return r.db.WithContext(ctx).Create(visitor).Error
+}
+
+// FindByID finds a visitor by ID
+func (r *VisitorRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.Visitor, error) {
+	var visitor models.Visitor
+	err := r.db.WithContext(ctx).Preload("Host").Preload("Office").Where("id = ?", id).First(&visitor).Error
+	if err != nil {
+		return nil, err
+	}
+	return &visitor, nil
+}
+
+// FindByPassCode finds a visitor by the code on their pass
+func (r *VisitorRepository) FindByPassCode(ctx context.Context, code string) (*models.Visitor, error) {
+	var visitor models.Visitor
+	err := r.db.WithContext(ctx).Preload("Host").Preload("Office").Where("pass_code = ?", code).First(&visitor).Error
+	if err != nil {
+		return nil, err
+	}
+	return &visitor, nil
+}
+
+// FindAll returns visitors with optional filtering and pagination, newest first
+func (r *VisitorRepository) FindAll(ctx context.Context, filters VisitorFilters, limit, offset int) ([]models.Visitor, int64, error) {
+	var visitors []models.Visitor
+	var total int64
+
+	query := r.filtered(ctx, filters)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Preload("Host").Preload("Office").
+		Order("COALESCE(check_in_at, expected_at, created_at) DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&visitors).Error
+	return visitors, total, err
+}
+
+// FindLog returns every visit in the filter range in arrival order, for the visitor log report
+func (r *VisitorRepository) FindLog(ctx context.Context, filters VisitorFilters) ([]models.Visitor, error) {
+	var visitors []models.Visitor
+	err := r.filtered(ctx, filters).
+		Where("check_in_at IS NOT NULL").
+		Preload("Host").Preload("Office").
+		Order("check_in_at ASC").
+		Find(&visitors).Error
+	return visitors, err
+}
+
+func (r *VisitorRepository) filtered(ctx context.Context, filters VisitorFilters) *gorm.DB {
+	query := r.db.WithContext(ctx).Model(&models.Visitor{})
+
+	if filters.OfficeID != "" {
+		query = query.Where("office_id = ?", filters.OfficeID)
+	}
+	query = scopeToOffices(ctx, query, "visitors.office_id")
+	if filters.HostID != "" {
+		query = query.Where("host_id = ?", filters.HostID)
+	}
+	if filters.Status != "" {
+		query = query.Where("status = ?", filters.Status)
+	}
+	if filters.Name != "" {
+		query = query.Where("name ILIKE ?", "%"+filters.Name+"%")
+	}
+	if filters.From != nil {
+		query = query.Where("COALESCE(check_in_at, expected_at, created_at) >= ?", *filters.From)
+	}
+	if filters.To != nil {
+		query = query.Where("COALESCE(check_in_at, expected_at, created_at) <= ?", *filters.To)
+	}
+	return query
+}
+
+// Update updates a visitor
+func (r *VisitorRepository) Update(ctx context.Context, visitor *models.Visitor) error {
+	return r.db.WithContext(ctx).Save(visitor).Error
+}
@@ -0,0 +1,303 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Filter is a parsed SCIM filter expression (RFC 7644 section 3.4.2.2)
+type Filter struct {
+	Op    string // "and", "or", "not", "pr" or a comparison operator: eq ne co sw ew gt ge lt le
+	Left  *Filter
+	Right *Filter
+	Attr  string      // Attribute path, lower-cased, for "pr" and comparisons
+	Value interface{} // string, float64, bool or nil
+}
+
+// AttrType is how an attribute's values compare
+type AttrType int
+
+const (
+	AttrString AttrType = iota // Case-insensitive text
+	AttrExact                  // Case-sensitive text, e.g. IDs
+	AttrBool
+	AttrTime
+)
+
+// Attribute maps a filterable SCIM attribute to a database column
+type Attribute struct {
+	Column string
+	Type   AttrType
+}
+
+// ParseFilter parses a filter such as `userName eq "ana@example.com" and active eq true`
+func ParseFilter(s string) (*Filter, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	f, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	return f, nil
+}
+
+// SQL renders the filter as a WHERE condition over the given attributes, keyed by
+// lower-cased attribute path. Attributes that are not listed cannot be filtered on.
+func (f *Filter) SQL(attrs map[string]Attribute) (string, []interface{}, error) {
+	switch f.Op {
+	case "and", "or":
+		left, leftArgs, err := f.Left.SQL(attrs)
+		if err != nil {
+			return "", nil, err
+		}
+		right, rightArgs, err := f.Right.SQL(attrs)
+		if err != nil {
+			return "", nil, err
+		}
+		return "(" + left + " " + strings.ToUpper(f.Op) + " " + right + ")", append(leftArgs, rightArgs...), nil
+	case "not":
+		inner, args, err := f.Left.SQL(attrs)
+		if err != nil {
+			return "", nil, err
+		}
+		return "NOT " + inner, args, nil
+	}
+
+	attr, ok := attrs[f.Attr]
+	if !ok {
+		return "", nil, fmt.Errorf("cannot filter on %q", f.Attr)
+	}
+	column := attr.Column
+	if f.Op == "pr" {
+		if attr.Type == AttrString || attr.Type == AttrExact {
+			return "(" + column + " IS NOT NULL AND " + column + " <> '')", nil, nil
+		}
+		return column + " IS NOT NULL", nil, nil
+	}
+
+	if f.Value == nil {
+		switch f.Op {
+		case "eq":
+			return column + " IS NULL", nil, nil
+		case "ne":
+			return column + " IS NOT NULL", nil, nil
+		}
+		return "", nil, fmt.Errorf("%s cannot compare with null", f.Op)
+	}
+
+	switch attr.Type {
+	case AttrBool:
+		b, ok := f.Value.(bool)
+		if !ok || (f.Op != "eq" && f.Op != "ne") {
+			return "", nil, fmt.Errorf("%q only supports eq and ne with true or false", f.Attr)
+		}
+		if f.Op == "ne" {
+			return column + " <> ?", []interface{}{b}, nil
+		}
+		return column + " = ?", []interface{}{b}, nil
+	case AttrTime:
+		s, ok := f.Value.(string)
+		if !ok {
+			return "", nil, fmt.Errorf("%q must be compared with a date string", f.Attr)
+		}
+		op, ok := orderOps[f.Op]
+		if !ok {
+			return "", nil, fmt.Errorf("%q does not support %s", f.Attr, f.Op)
+		}
+		return column + " " + op + " ?", []interface{}{s}, nil
+	}
+
+	var s string
+	switch v := f.Value.(type) {
+	case string:
+		s = v
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		s = fmt.Sprint(v)
+	}
+	if attr.Type == AttrString {
+		column = "LOWER(" + column + ")"
+		s = strings.ToLower(s)
+	}
+	like := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+	switch f.Op {
+	case "co":
+		return column + " LIKE ?", []interface{}{"%" + like + "%"}, nil
+	case "sw":
+		return column + " LIKE ?", []interface{}{like + "%"}, nil
+	case "ew":
+		return column + " LIKE ?", []interface{}{"%" + like}, nil
+	}
+	op, ok := orderOps[f.Op]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown operator %q", f.Op)
+	}
+	return column + " " + op + " ?", []interface{}{s}, nil
+}
+
+// orderOps are the comparison operators that map directly onto SQL
+var orderOps = map[string]string{"eq": "=", "ne": "<>", "gt": ">", "ge": ">=", "lt": "<", "le": "<="}
+
+type token struct {
+	text   string
+	quoted bool
+}
+
+// tokenize splits a filter into words, parentheses and quoted strings
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		ch := s[i]
+		switch {
+		case ch == ' ' || ch == '\t':
+			i++
+		case ch == '(' || ch == ')':
+			tokens = append(tokens, token{text: string(ch)})
+			i++
+		case ch == '"':
+			j := i + 1
+			for j < len(s) && s[j] != '"' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			var value string
+			if err := json.Unmarshal([]byte(s[i:j+1]), &value); err != nil {
+				return nil, fmt.Errorf("invalid string %s", s[i:j+1])
+			}
+			tokens = append(tokens, token{text: value, quoted: true})
+			i = j + 1
+		case ch == '[':
+			return nil, fmt.Errorf("value filters are not supported")
+		default:
+			j := i
+			for j < len(s) && !unicode.IsSpace(rune(s[j])) && s[j] != '(' && s[j] != ')' && s[j] != '"' && s[j] != '[' {
+				j++
+			}
+			tokens = append(tokens, token{text: s[i:j]})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peekWord(word string) bool {
+	return p.pos < len(p.tokens) && !p.tokens[p.pos].quoted && strings.EqualFold(p.tokens[p.pos].text, word)
+}
+
+func (p *parser) or() (*Filter, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekWord("or") {
+		p.pos++
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = &Filter{Op: "or", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (*Filter, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekWord("and") {
+		p.pos++
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = &Filter{Op: "and", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) unary() (*Filter, error) {
+	if p.peekWord("not") {
+		p.pos++
+		if !p.peekWord("(") {
+			return nil, fmt.Errorf("expected ( after not")
+		}
+		inner, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &Filter{Op: "not", Left: inner}, nil
+	}
+	if p.peekWord("(") {
+		p.pos++
+		inner, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.peekWord(")") {
+			return nil, fmt.Errorf("expected )")
+		}
+		p.pos++
+		return inner, nil
+	}
+	return p.comparison()
+}
+
+func (p *parser) comparison() (*Filter, error) {
+	if p.pos+1 >= len(p.tokens) || p.tokens[p.pos].quoted {
+		return nil, fmt.Errorf("expected attribute and operator")
+	}
+	attr := strings.ToLower(p.tokens[p.pos].text)
+	op := strings.ToLower(p.tokens[p.pos+1].text)
+	p.pos += 2
+
+	if op == "pr" {
+		return &Filter{Op: "pr", Attr: attr}, nil
+	}
+	if _, ok := orderOps[op]; !ok && op != "co" && op != "sw" && op != "ew" {
+		return nil, fmt.Errorf("unknown operator %q", op)
+	}
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("expected value after %s", op)
+	}
+
+	tok := p.tokens[p.pos]
+	p.pos++
+	var value interface{}
+	switch {
+	case tok.quoted:
+		value = tok.text
+	case tok.text == "true" || tok.text == "false":
+		value = tok.text == "true"
+	case tok.text == "null":
+		value = nil
+	default:
+		n, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+			return nil, fmt.Errorf("invalid value %q", tok.text)
+		}
+		value = n
+	}
+	return &Filter{Op: op, Attr: attr, Value: value}, nil
+}
@@ -0,0 +1,177 @@
+package scim
+
+import (
+	"reflect"
+	"testing"
+)
+
+var testAttrs = map[string]Attribute{
+	"id":             {Column: "users.id::text", Type: AttrExact},
+	"username":       {Column: "users.email", Type: AttrString},
+	"name.givenname": {Column: "users.name", Type: AttrString},
+	"active":         {Column: "users.is_active", Type: AttrBool},
+	"meta.created":   {Column: "users.created_at", Type: AttrTime},
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter string
+	}{
+		{"empty", ""},
+		{"attribute only", "userName"},
+		{"missing value", "userName eq"},
+		{"unknown operator", `userName like "ana"`},
+		{"unterminated string", `userName eq "ana`},
+		{"invalid escape", `userName eq "\x"`},
+		{"unquoted word value", "userName eq ana"},
+		{"number with trailing text", "userName eq 12abc"},
+		{"not a finite number", "userName eq NaN"},
+		{"missing close paren", `(userName eq "ana"`},
+		{"extra close paren", `userName eq "ana")`},
+		{"not without parens", `not userName eq "ana"`},
+		{"dangling and", `userName eq "ana" and`},
+		{"dangling or", `or userName eq "ana"`},
+		{"value filter", `emails[type eq "work"]`},
+		{"quoted attribute", `"userName" eq "ana"`},
+		{"trailing tokens", `userName eq "ana" active`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if f, err := ParseFilter(tt.filter); err == nil {
+				t.Errorf("ParseFilter(%q) = %+v, want error", tt.filter, f)
+			}
+		})
+	}
+}
+
+func TestFilterSQL(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   string
+		wantSQL  string
+		wantArgs []interface{}
+	}{
+		// Comparisons
+		{"string eq is case-insensitive", `userName eq "Ana@Example.com"`,
+			"LOWER(users.email) = ?", []interface{}{"ana@example.com"}},
+		{"exact eq keeps case", `id eq "ABC-123"`,
+			"users.id::text = ?", []interface{}{"ABC-123"}},
+		{"operator and attribute are case-insensitive", `USERNAME EQ "ana"`,
+			"LOWER(users.email) = ?", []interface{}{"ana"}},
+		{"sub-attribute", `name.givenName ne "Ana"`,
+			"LOWER(users.name) <> ?", []interface{}{"ana"}},
+		{"ordering", `userName gt "m"`,
+			"LOWER(users.email) > ?", []interface{}{"m"}},
+		{"number value", "userName eq 42",
+			"LOWER(users.email) = ?", []interface{}{"42"}},
+		{"bool eq", "active eq true",
+			"users.is_active = ?", []interface{}{true}},
+		{"bool ne", "active ne false",
+			"users.is_active <> ?", []interface{}{false}},
+		{"time ge", `meta.created ge "2024-01-01T00:00:00Z"`,
+			"users.created_at >= ?", []interface{}{"2024-01-01T00:00:00Z"}},
+		{"eq null", "userName eq null",
+			"users.email IS NULL", nil},
+		{"ne null", "userName ne null",
+			"users.email IS NOT NULL", nil},
+		{"present text", "userName pr",
+			"(users.email IS NOT NULL AND users.email <> '')", nil},
+		{"present bool", "active pr",
+			"users.is_active IS NOT NULL", nil},
+
+		// Substring matches, with LIKE metacharacters escaped
+		{"co", `userName co "ana"`,
+			"LOWER(users.email) LIKE ?", []interface{}{"%ana%"}},
+		{"sw", `userName sw "ana"`,
+			"LOWER(users.email) LIKE ?", []interface{}{"ana%"}},
+		{"ew", `userName ew "@example.com"`,
+			"LOWER(users.email) LIKE ?", []interface{}{"%@example.com"}},
+		{"co escapes percent", `userName co "100%"`,
+			"LOWER(users.email) LIKE ?", []interface{}{`%100\%%`}},
+		{"sw escapes underscore", `userName sw "a_b"`,
+			"LOWER(users.email) LIKE ?", []interface{}{`a\_b%`}},
+		{"ew escapes backslash", `userName ew "a\\b"`,
+			"LOWER(users.email) LIKE ?", []interface{}{`%a\\b`}},
+		{"eq does not escape", `userName eq "a_b%"`,
+			"LOWER(users.email) = ?", []interface{}{"a_b%"}},
+
+		// Quoting
+		{"escaped quote", `userName eq "say \"hi\""`,
+			"LOWER(users.email) = ?", []interface{}{`say "hi"`}},
+		{"unicode escape", `userName eq "\u0041na"`,
+			"LOWER(users.email) = ?", []interface{}{"ana"}},
+		{"keywords inside quotes", `userName eq "ana and (bob or not carl)"`,
+			"LOWER(users.email) = ?", []interface{}{"ana and (bob or not carl)"}},
+
+		// Logical operators and precedence
+		{"and", `userName eq "ana" and active eq true`,
+			"(LOWER(users.email) = ? AND users.is_active = ?)", []interface{}{"ana", true}},
+		{"and binds tighter than or", `userName eq "a" or userName eq "b" and active eq true`,
+			"(LOWER(users.email) = ? OR (LOWER(users.email) = ? AND users.is_active = ?))", []interface{}{"a", "b", true}},
+		{"and binds tighter than or on the left", `userName eq "a" and active eq true or userName eq "b"`,
+			"((LOWER(users.email) = ? AND users.is_active = ?) OR LOWER(users.email) = ?)", []interface{}{"a", true, "b"}},
+		{"parentheses override precedence", `(userName eq "a" or userName eq "b") and active eq true`,
+			"((LOWER(users.email) = ? OR LOWER(users.email) = ?) AND users.is_active = ?)", []interface{}{"a", "b", true}},
+		{"or is left-associative", `userName eq "a" or userName eq "b" or userName eq "c"`,
+			"((LOWER(users.email) = ? OR LOWER(users.email) = ?) OR LOWER(users.email) = ?)", []interface{}{"a", "b", "c"}},
+		{"not", `not (active eq true)`,
+			"NOT users.is_active = ?", []interface{}{true}},
+		{"not without space", `not(userName eq "a" or userName eq "b")`,
+			"NOT (LOWER(users.email) = ? OR LOWER(users.email) = ?)", []interface{}{"a", "b"}},
+		{"not binds tighter than and", `not (active eq true) and userName pr`,
+			"(NOT users.is_active = ? AND (users.email IS NOT NULL AND users.email <> ''))", []interface{}{true}},
+		{"uppercase keywords", `userName eq "a" AND NOT (active eq false)`,
+			"(LOWER(users.email) = ? AND NOT users.is_active = ?)", []interface{}{"a", false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseFilter(tt.filter)
+			if err != nil {
+				t.Fatalf("ParseFilter(%q): %v", tt.filter, err)
+			}
+			sql, args, err := f.SQL(testAttrs)
+			if err != nil {
+				t.Fatalf("SQL(%q): %v", tt.filter, err)
+			}
+			if sql != tt.wantSQL {
+				t.Errorf("SQL(%q)\n got  %s\n want %s", tt.filter, sql, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args of %q = %#v, want %#v", tt.filter, args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestFilterSQLErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter string
+	}{
+		{"unknown attribute", `password eq "secret"`},
+		{"unknown attribute in and", `userName eq "ana" and password pr`},
+		{"unknown attribute in not", `not (password eq "secret")`},
+		{"bool with string", `active eq "true"`},
+		{"bool ordering", "active gt false"},
+		{"bool substring", `active co "t"`},
+		{"time with number", "meta.created gt 5"},
+		{"time substring", `meta.created co "2024"`},
+		{"ordering with null", "userName gt null"},
+		{"substring with null", "userName co null"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseFilter(tt.filter)
+			if err != nil {
+				t.Fatalf("ParseFilter(%q): %v", tt.filter, err)
+			}
+			if sql, _, err := f.SQL(testAttrs); err == nil {
+				t.Errorf("SQL(%q) = %q, want error", tt.filter, sql)
+			}
+		})
+	}
+}
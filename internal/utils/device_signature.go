@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"math/big"
+)
+
+// ParseDevicePublicKey parses a base64 SPKI (DER) device public key.
+// Supported keys are ECDSA P-256 (WebCrypto) and Ed25519.
+func ParseDevicePublicKey(encoded string) (interface{}, error) {
+	der, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.New("public key is not valid base64")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, errors.New("public key is not a valid SPKI key")
+	}
+
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return nil, errors.New("only P-256 ECDSA keys are supported")
+		}
+		return k, nil
+	case ed25519.PublicKey:
+		return k, nil
+	}
+	return nil, errors.New("unsupported public key type")
+}
+
+// VerifyDeviceSignature checks a base64 signature over message.
+// ECDSA signatures may be raw r||s (WebCrypto) or ASN.1 encoded and are made over SHA-256(message).
+func VerifyDeviceSignature(key interface{}, message []byte, signature string) bool {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(message)
+		if len(sig) == 64 {
+			r := new(big.Int).SetBytes(sig[:32])
+			s := new(big.Int).SetBytes(sig[32:])
+			return ecdsa.Verify(k, digest[:], r, s)
+		}
+		return ecdsa.VerifyASN1(k, digest[:], sig)
+	case ed25519.PublicKey:
+		return ed25519.Verify(k, message, sig)
+	}
+	return false
+}
+
+// ChainHash returns the hex SHA-256 hash that links a signed record to the next one
+func ChainHash(message []byte) string {
+	sum := sha256.Sum256(message)
+	return hex.EncodeToString(sum[:])
+}
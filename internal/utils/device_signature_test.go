@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"testing"
+)
+
+func encodeSPKI(t *testing.T, key interface{}) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(der)
+}
+
+func TestParseDevicePublicKey(t *testing.T) {
+	p256, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	edPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+
+	tests := []struct {
+		name    string
+		encoded string
+		wantErr bool
+	}{
+		{"ecdsa p-256", encodeSPKI(t, &p256.PublicKey), false},
+		{"ed25519", encodeSPKI(t, edPub), false},
+		{"ecdsa p-384 rejected", encodeSPKI(t, &p384.PublicKey), true},
+		{"rsa rejected", encodeSPKI(t, &rsaKey.PublicKey), true},
+		{"not base64", "not base64!", true},
+		{"not spki", base64.StdEncoding.EncodeToString([]byte("garbage")), true},
+		{"empty", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ParseDevicePublicKey(tt.encoded)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && key == nil {
+				t.Fatal("key is nil")
+			}
+		})
+	}
+}
+
+func TestVerifyDeviceSignature(t *testing.T) {
+	message := []byte("client\nKIOSK-1\nEMP001\ncheck-in\n2024-01-02T03:04:05.000Z\n0.9500\n1\n")
+	tampered := []byte("client\nKIOSK-1\nEMP002\ncheck-in\n2024-01-02T03:04:05.000Z\n0.9500\n1\n")
+
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	otherEC, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	digest := sha256.Sum256(message)
+
+	// WebCrypto produces raw r||s, each padded to 32 bytes
+	r, s, err := ecdsa.Sign(rand.Reader, ecKey, digest[:])
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	raw := make([]byte, 64)
+	r.FillBytes(raw[:32])
+	s.FillBytes(raw[32:])
+	rawSig := base64.StdEncoding.EncodeToString(raw)
+
+	asn1, err := ecdsa.SignASN1(rand.Reader, ecKey, digest[:])
+	if err != nil {
+		t.Fatalf("sign asn1: %v", err)
+	}
+	asn1Sig := base64.StdEncoding.EncodeToString(asn1)
+
+	edPub, edPriv, _ := ed25519.GenerateKey(rand.Reader)
+	edSig := base64.StdEncoding.EncodeToString(ed25519.Sign(edPriv, message))
+
+	tests := []struct {
+		name      string
+		key       interface{}
+		message   []byte
+		signature string
+		want      bool
+	}{
+		{"ecdsa raw r||s", &ecKey.PublicKey, message, rawSig, true},
+		{"ecdsa asn1", &ecKey.PublicKey, message, asn1Sig, true},
+		{"ed25519", edPub, message, edSig, true},
+		{"ecdsa tampered message", &ecKey.PublicKey, tampered, rawSig, false},
+		{"ecdsa wrong key", &otherEC.PublicKey, message, rawSig, false},
+		{"ed25519 tampered message", edPub, tampered, edSig, false},
+		{"ed25519 signature on ecdsa key", &ecKey.PublicKey, message, edSig, false},
+		{"not base64", &ecKey.PublicKey, message, "***", false},
+		{"empty signature", edPub, message, "", false},
+		{"unsupported key type", "key", message, rawSig, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyDeviceSignature(tt.key, tt.message, tt.signature); got != tt.want {
+				t.Errorf("VerifyDeviceSignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChainHash(t *testing.T) {
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{"", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{"abc", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+	}
+
+	for _, tt := range tests {
+		if got := ChainHash([]byte(tt.message)); got != tt.want {
+			t.Errorf("ChainHash(%q) = %s, want %s", tt.message, got, tt.want)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
 	"errors"
 	"time"
 
@@ -19,6 +21,9 @@ type JWTClaims struct {
 	Email      string    `json:"email"`
 	Role       string    `json:"role"`
 	EmployeeID string    `json:"employee_id"`
+	SessionID  string    `json:"sid,omitempty"` // Login session the token was issued for
+
+	MustChangePassword bool `json:"mcp,omitempty"` // Only the password change endpoint may be used
 	jwt.RegisteredClaims
 }
 
@@ -38,14 +43,17 @@ func NewJWTManager(secret string, accessExpiry, refreshExpiry time.Duration) *JW
 	}
 }
 
-// GenerateAccessToken creates a new access token for a user
-func (m *JWTManager) GenerateAccessToken(userID uuid.UUID, email, role, employeeID string) (string, error) {
+// GenerateAccessToken creates a new access token for a user's login session
+func (m *JWTManager) GenerateAccessToken(userID uuid.UUID, email, role, employeeID string, sessionID uuid.UUID, mustChangePassword bool) (string, error) {
 	claims := JWTClaims{
-		UserID:     userID,
-		Email:      email,
-		Role:       role,
-		EmployeeID: employeeID,
+		UserID:             userID,
+		Email:              email,
+		Role:               role,
+		EmployeeID:         employeeID,
+		SessionID:          sessionID.String(),
+		MustChangePassword: mustChangePassword,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(), // Lets a single token be revoked
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(m.accessExpiry)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
@@ -63,6 +71,7 @@ func (m *JWTManager) GenerateRefreshToken(userID uuid.UUID) (string, time.Time,
 	expiresAt := time.Now().Add(m.refreshExpiry)
 	
 	claims := jwt.RegisteredClaims{
+		ID:        uuid.NewString(), // Keeps tokens issued in the same second distinct
 		ExpiresAt: jwt.NewNumericDate(expiresAt),
 		IssuedAt:  jwt.NewNumericDate(time.Now()),
 		Subject:   userID.String(),
@@ -126,3 +135,67 @@ func (m *JWTManager) ValidateRefreshToken(tokenString string) (uuid.UUID, error)
 
 	return userID, nil
 }
+
+// Challenge token purposes
+const (
+	ChallengeTwoFactor      = "2fa"       // Password checked, TOTP or recovery code still needed
+	ChallengeTwoFactorSetup = "2fa_setup" // Password checked, 2FA must be enrolled before logging in
+)
+
+// challengeTTL is how long a half-finished login can be completed
+const challengeTTL = 5 * time.Minute
+
+// ChallengeClaims identify a user part way through logging in
+type ChallengeClaims struct {
+	Purpose string `json:"purpose"`
+	jwt.RegisteredClaims
+}
+
+// challengeKey derives the signing key for challenge tokens, so they can never pass
+// as access or refresh tokens and vice versa
+func (m *JWTManager) challengeKey() []byte {
+	mac := hmac.New(sha256.New, m.secretKey)
+	mac.Write([]byte("login-challenge"))
+	return mac.Sum(nil)
+}
+
+// GenerateChallengeToken creates a short-lived token that lets a user finish logging in
+func (m *JWTManager) GenerateChallengeToken(userID uuid.UUID, purpose string) (string, time.Time, error) {
+	expiresAt := time.Now().Add(challengeTTL)
+	claims := ChallengeClaims{
+		Purpose: purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    "attendance-system",
+			Subject:   userID.String(),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString(m.challengeKey())
+	return signed, expiresAt, err
+}
+
+// ValidateChallengeToken checks a challenge token for the given purpose and returns its user
+func (m *JWTManager) ValidateChallengeToken(tokenString, purpose string) (uuid.UUID, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &ChallengeClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, ErrInvalidToken
+		}
+		return m.challengeKey(), nil
+	})
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return uuid.Nil, ErrExpiredToken
+		}
+		return uuid.Nil, ErrInvalidToken
+	}
+
+	claims, ok := token.Claims.(*ChallengeClaims)
+	if !ok || !token.Valid || claims.Purpose != purpose {
+		return uuid.Nil, ErrInvalidToken
+	}
+	return uuid.Parse(claims.Subject)
+}
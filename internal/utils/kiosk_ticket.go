@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kiosk identification factors
+const (
+	FactorQR   = "qr"
+	FactorCard = "card"
+	FactorPIN  = "pin"
+	FactorFace = "face"
+)
+
+var ErrTicketInvalid = errors.New("invalid or expired kiosk ticket")
+
+// KioskTicket records which identification factors an employee passed at a kiosk.
+// It is handed from identification to face verification to check-in.
+type KioskTicket struct {
+	EmployeeID string    `json:"e"`
+	Factors    []string  `json:"f"`
+	ExpiresAt  time.Time `json:"x"`
+}
+
+// HasFactors reports whether the ticket includes every factor in required
+func (t *KioskTicket) HasFactors(required []string) bool {
+	for _, factor := range required {
+		found := false
+		for _, f := range t.Factors {
+			if f == factor {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// TicketSigner issues and verifies short-lived HMAC-signed kiosk tickets
+type TicketSigner struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// NewTicketSigner creates a ticket signer; ttl bounds the time between identification and check-in
+func NewTicketSigner(secret string, ttl time.Duration) *TicketSigner {
+	return &TicketSigner{secret: []byte(secret), ttl: ttl}
+}
+
+// Issue returns a ticket for employeeID covering factors
+func (s *TicketSigner) Issue(employeeID string, factors []string, now time.Time) string {
+	ticket := KioskTicket{
+		EmployeeID: employeeID,
+		Factors:    normalizeFactors(factors),
+		ExpiresAt:  now.Add(s.ttl),
+	}
+	body, _ := json.Marshal(ticket)
+	encoded := base64.RawURLEncoding.EncodeToString(body)
+	return encoded + "." + s.sign(encoded)
+}
+
+// Verify checks the signature and expiry of a ticket
+func (s *TicketSigner) Verify(token string, now time.Time) (*KioskTicket, error) {
+	encoded, sig, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(s.sign(encoded))) {
+		return nil, ErrTicketInvalid
+	}
+
+	body, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrTicketInvalid
+	}
+
+	var ticket KioskTicket
+	if err := json.Unmarshal(body, &ticket); err != nil || now.After(ticket.ExpiresAt) {
+		return nil, ErrTicketInvalid
+	}
+	return &ticket, nil
+}
+
+func (s *TicketSigner) sign(encoded string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte("ticket:" + encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ParseFactorCombo splits a policy entry such as "card+face" into its factors
+func ParseFactorCombo(combo string) []string {
+	var factors []string
+	for _, f := range strings.Split(combo, "+") {
+		if f = strings.ToLower(strings.TrimSpace(f)); f != "" {
+			factors = append(factors, f)
+		}
+	}
+	return normalizeFactors(factors)
+}
+
+// IsValidFactorCombo reports whether every factor in combo is known
+func IsValidFactorCombo(combo string) bool {
+	factors := ParseFactorCombo(combo)
+	if len(factors) == 0 {
+		return false
+	}
+	for _, f := range factors {
+		switch f {
+		case FactorQR, FactorCard, FactorPIN, FactorFace:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeFactors sorts and de-duplicates factor names
+func normalizeFactors(factors []string) []string {
+	seen := make(map[string]bool, len(factors))
+	out := make([]string, 0, len(factors))
+	for _, f := range factors {
+		if !seen[f] {
+			seen[f] = true
+			out = append(out, f)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QR payload kinds
+const (
+	QRKindRotating = "rotating" // Shown in the mobile app, changes every rotation period
+	QRKindBadge    = "badge"    // Printed static badge, revocable by serial
+)
+
+var (
+	ErrQRInvalid = errors.New("invalid QR payload")
+	ErrQRExpired = errors.New("QR payload expired")
+)
+
+// QRClaims is the verified content of a signed QR payload
+type QRClaims struct {
+	Kind       string
+	EmployeeID string
+	Serial     string // Badge serial, empty for rotating codes
+}
+
+// QRSigner creates and verifies HMAC-signed employee QR payloads.
+// Rotating codes look like "r1.<employee_id>.<window>.<sig>", badges like
+// "b1.<employee_id>.<serial>.<sig>". The employee ID stays readable so offline
+// kiosks can still look the employee up; only the server can check the signature.
+type QRSigner struct {
+	secret []byte
+	period time.Duration
+}
+
+// NewQRSigner creates a signer; period is the lifetime of one rotating code
+func NewQRSigner(secret string, period time.Duration) *QRSigner {
+	if period <= 0 {
+		period = 30 * time.Second
+	}
+	return &QRSigner{secret: []byte(secret), period: period}
+}
+
+// Period returns the rotation period of rotating codes
+func (s *QRSigner) Period() time.Duration {
+	return s.period
+}
+
+// RotatingPayload returns the code for employeeID valid in the current window and when it expires
+func (s *QRSigner) RotatingPayload(employeeID string, now time.Time) (string, time.Time) {
+	window := s.window(now)
+	body := "r1." + employeeID + "." + strconv.FormatInt(window, 10)
+	expiresAt := time.Unix(0, (window+1)*int64(s.period))
+	return body + "." + s.sign(body), expiresAt
+}
+
+// BadgePayload returns the static code printed on a badge
+func (s *QRSigner) BadgePayload(employeeID, serial string) string {
+	body := "b1." + employeeID + "." + serial
+	return body + "." + s.sign(body)
+}
+
+// Verify checks the signature and, for rotating codes, freshness.
+// A rotating code is accepted in its own window and one window either side,
+// to absorb display delay and clock differences between phone and server.
+func (s *QRSigner) Verify(payload string, now time.Time) (*QRClaims, error) {
+	parts := strings.Split(payload, ".")
+	if len(parts) < 4 {
+		return nil, ErrQRInvalid
+	}
+
+	sig := parts[len(parts)-1]
+	body := strings.Join(parts[:len(parts)-1], ".")
+	if !hmac.Equal([]byte(sig), []byte(s.sign(body))) {
+		return nil, ErrQRInvalid
+	}
+
+	employeeID := strings.Join(parts[1:len(parts)-2], ".")
+	last := parts[len(parts)-2]
+
+	switch parts[0] {
+	case "r1":
+		window, err := strconv.ParseInt(last, 10, 64)
+		if err != nil {
+			return nil, ErrQRInvalid
+		}
+		current := s.window(now)
+		if window > current+1 || window < current-1 {
+			return nil, ErrQRExpired
+		}
+		return &QRClaims{Kind: QRKindRotating, EmployeeID: employeeID}, nil
+	case "b1":
+		return &QRClaims{Kind: QRKindBadge, EmployeeID: employeeID, Serial: last}, nil
+	}
+	return nil, ErrQRInvalid
+}
+
+func (s *QRSigner) window(now time.Time) int64 {
+	return now.UnixNano() / int64(s.period)
+}
+
+func (s *QRSigner) sign(body string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte("qr:" + body))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// IsSignedQRPayload reports whether a scanned value looks like a signed payload rather than a raw employee ID
+func IsSignedQRPayload(value string) bool {
+	return strings.HasPrefix(value, "r1.") || strings.HasPrefix(value, "b1.")
+}
+
+// VisitorPassPayload returns the signed code printed on a visitor pass: "v1.<pass_code>.<sig>"
+func (s *QRSigner) VisitorPassPayload(passCode string) string {
+	body := "v1." + passCode
+	return body + "." + s.sign(body)
+}
+
+// VerifyVisitorPass checks a visitor pass payload and returns its pass code.
+// Visitor passes are a separate format so they can never identify an employee.
+func (s *QRSigner) VerifyVisitorPass(payload string) (string, error) {
+	parts := strings.Split(payload, ".")
+	if len(parts) != 3 || parts[0] != "v1" {
+		return "", ErrQRInvalid
+	}
+	body := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(s.sign(body))) {
+		return "", ErrQRInvalid
+	}
+	return parts[1], nil
+}
+
+// IsVisitorPassPayload reports whether a scanned value is a signed visitor pass rather than a typed pass code
+func IsVisitorPassPayload(value string) bool {
+	return strings.HasPrefix(value, "v1.")
+}
+
+// NewVisitorPassCode returns a short random code that can also be typed in at the kiosk
+func NewVisitorPassCode() (string, error) {
+	b := make([]byte, 5)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b), nil
+}
+
+// NewBadgeSerial returns a random, human-readable badge serial number
+func NewBadgeSerial() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b), nil
+}
@@ -0,0 +1,201 @@
+package utils
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA1 key from RFC 6238 appendix B ("12345678901234567890") in base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B vectors, truncated to the last 6 digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		counter := TOTPCounter(time.Unix(tt.unix, 0))
+		got, err := TOTPCode(rfc6238Secret, counter)
+		if err != nil {
+			t.Fatalf("TOTPCode(%d): %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("TOTPCode at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestTOTPCodeLowercaseSecret(t *testing.T) {
+	got, err := TOTPCode(strings.ToLower(rfc6238Secret), 1)
+	if err != nil {
+		t.Fatalf("TOTPCode: %v", err)
+	}
+	if got != "287082" {
+		t.Errorf("TOTPCode = %s, want 287082", got)
+	}
+}
+
+func TestTOTPCodeInvalidSecret(t *testing.T) {
+	if _, err := TOTPCode("not base32!", 1); err == nil {
+		t.Error("TOTPCode accepted an invalid secret")
+	}
+}
+
+func TestMatchTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := TOTPCounter(now)
+	code := func(counter int64) string {
+		c, err := TOTPCode(rfc6238Secret, counter)
+		if err != nil {
+			t.Fatalf("TOTPCode: %v", err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name        string
+		code        string
+		wantCounter int64
+		wantOK      bool
+	}{
+		{"current step", code(current), current, true},
+		{"previous step", code(current - 1), current - 1, true},
+		{"next step", code(current + 1), current + 1, true},
+		{"two steps old", code(current - 2), 0, false},
+		{"two steps ahead", code(current + 2), 0, false},
+		{"spaces and padding", " " + code(current)[:3] + " " + code(current)[3:] + " ", current, true},
+		{"too short", code(current)[:5], 0, false},
+		{"too long", code(current) + "0", 0, false},
+		{"empty", "", 0, false},
+		{"wrong code", "000000", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter, ok := MatchTOTP(rfc6238Secret, tt.code, now)
+			if ok != tt.wantOK || counter != tt.wantCounter {
+				t.Errorf("MatchTOTP(%q) = (%d, %v), want (%d, %v)", tt.code, counter, ok, tt.wantCounter, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestMatchTOTPInvalidSecret(t *testing.T) {
+	if _, ok := MatchTOTP("not base32!", "123456", time.Now()); ok {
+		t.Error("MatchTOTP matched with an invalid secret")
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret: %v", err)
+	}
+	if len(secret) != 32 {
+		t.Errorf("secret length = %d, want 32", len(secret))
+	}
+
+	// A fresh secret must work with its own codes
+	now := time.Now()
+	code, err := TOTPCode(secret, TOTPCounter(now))
+	if err != nil {
+		t.Fatalf("TOTPCode: %v", err)
+	}
+	if _, ok := MatchTOTP(secret, code, now); !ok {
+		t.Error("MatchTOTP rejected a code for a generated secret")
+	}
+
+	other, _ := GenerateTOTPSecret()
+	if other == secret {
+		t.Error("GenerateTOTPSecret returned the same secret twice")
+	}
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	uri := TOTPProvisioningURI("Acme Corp", "jane@example.com", rfc6238Secret)
+
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("parse %q: %v", uri, err)
+	}
+	if parsed.Scheme != "otpauth" || parsed.Host != "totp" {
+		t.Errorf("uri = %q, want otpauth://totp/...", uri)
+	}
+	if parsed.Path != "/Acme Corp:jane@example.com" {
+		t.Errorf("label = %q, want %q", parsed.Path, "/Acme Corp:jane@example.com")
+	}
+
+	query := parsed.Query()
+	want := map[string]string{
+		"secret":    rfc6238Secret,
+		"issuer":    "Acme Corp",
+		"algorithm": "SHA1",
+		"digits":    "6",
+		"period":    "30",
+	}
+	for key, value := range want {
+		if got := query.Get(key); got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes: %v", err)
+	}
+	if len(codes) != 10 {
+		t.Fatalf("got %d codes, want 10", len(codes))
+	}
+
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("code %q is not formatted like xxxxx-xxxxx", code)
+		}
+		if strings.ContainsAny(code, "ilo0") {
+			t.Errorf("code %q contains a lookalike character", code)
+		}
+		if seen[code] {
+			t.Errorf("code %q generated twice", code)
+		}
+		seen[code] = true
+
+		if NormalizeRecoveryCode(code) != code {
+			t.Errorf("NormalizeRecoveryCode(%q) changed a generated code", code)
+		}
+	}
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"k3m9q-x7p2w", "k3m9q-x7p2w"},
+		{"K3M9Q-X7P2W", "k3m9q-x7p2w"},
+		{"k3m9qx7p2w", "k3m9q-x7p2w"},
+		{"  k3m9q x7p2w ", "k3m9q-x7p2w"},
+		{"k3m9q - x7p2w", "k3m9q-x7p2w"},
+		{"short", "short"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeRecoveryCode(tt.in); got != tt.want {
+			t.Errorf("NormalizeRecoveryCode(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -5,6 +5,11 @@ import { useAuthStore } from './store/authStore';
 import { lazy, Suspense } from 'react';
 
 const LoginPage = lazy(() => import('./pages/LoginPage'));
+const ChangePasswordPage = lazy(() => import('./pages/ChangePasswordPage'));
+const InvitePage = lazy(() => import('./pages/InvitePage'));
+const ForgotPasswordPage = lazy(() => import('./pages/ForgotPasswordPage'));
+const ResetPasswordPage = lazy(() => import('./pages/ResetPasswordPage'));
+const SSOCallbackPage = lazy(() => import('./pages/SSOCallbackPage'));
 const KioskPage = lazy(() => import('./pages/KioskPage'));
 const AdminDashboard = lazy(() => import('./pages/AdminDashboard'));
 
@@ -29,6 +34,11 @@ function App() {
         <Suspense fallback={<div className="flex h-screen items-center justify-center bg-slate-950 text-slate-500">Loading...</div>}>
           <Routes>
             <Route path="/login" element={<LoginPage />} />
+            <Route path="/change-password" element={<ChangePasswordPage />} />
+            <Route path="/invite/:token" element={<InvitePage />} />
+            <Route path="/forgot-password" element={<ForgotPasswordPage />} />
+            <Route path="/reset-password/:token" element={<ResetPasswordPage />} />
+            <Route path="/sso/callback" element={<SSOCallbackPage />} />
             <Route path="/kiosk" element={<KioskPage />} />
             <Route
               path="/*"
@@ -30,11 +30,19 @@ apiClient.interceptors.request.use((config) => {
     return config;
 });
 
-// Handle 401 errors
+// Handle 401 errors, and send users who must change their password to the change form
 apiClient.interceptors.response.use(
     (response) => response,
     async (error) => {
-        if (error.response?.status === 401) {
+        if (error.response?.status === 403 && error.response?.data?.code === 'PASSWORD_CHANGE_REQUIRED') {
+            if (window.location.pathname !== '/change-password') {
+                window.location.href = '/change-password';
+            }
+            return Promise.reject(error);
+        }
+        // Login steps and a wrong current password answer 401 too, without a session to end
+        const url: string = error.config?.url || '';
+        if (error.response?.status === 401 && !url.startsWith('/auth/') && url !== '/users/password') {
             localStorage.removeItem('accessToken');
             localStorage.removeItem('refreshToken');
             window.location.href = '/login';
@@ -48,6 +56,27 @@ export const authAPI = {
     login: (email: string, password: string) =>
         apiClient.post('/auth/login', { email, password }),
     logout: () => apiClient.post('/auth/logout'),
+    verifyTwoFactor: (challengeToken: string, code: { code?: string; recovery_code?: string }) =>
+        apiClient.post('/auth/2fa/verify', { challenge_token: challengeToken, ...code }),
+    setupTwoFactor: (challengeToken: string) =>
+        apiClient.post('/auth/2fa/setup', { challenge_token: challengeToken }),
+    confirmTwoFactorSetup: (challengeToken: string, code: string) =>
+        apiClient.post('/auth/2fa/setup/confirm', { challenge_token: challengeToken, code }),
+    refresh: (refreshToken: string) =>
+        apiClient.post('/auth/refresh', { refresh_token: refreshToken }),
+    changePassword: (currentPassword: string, newPassword: string) =>
+        apiClient.put('/users/password', { current_password: currentPassword, new_password: newPassword }),
+    getInvitation: (token: string) => apiClient.get(`/auth/invitations/${encodeURIComponent(token)}`),
+    acceptInvitation: (token: string, password: string) =>
+        apiClient.post('/auth/invitations/accept', { token, password }),
+    // The SSO calls carry the state cookie that binds a login to this browser
+    getSSOStatus: () => apiClient.get('/auth/sso'),
+    beginSSO: () => apiClient.get('/auth/sso/authorize', { withCredentials: true }),
+    finishSSO: (code: string, state: string) =>
+        apiClient.post('/auth/sso/callback', { code, state }, { withCredentials: true }),
+    forgotPassword: (email: string) => apiClient.post('/auth/password/forgot', { email }),
+    resetPassword: (token: string, password: string) =>
+        apiClient.post('/auth/password/reset', { token, password }),
 };
 
 // Public API
@@ -115,6 +144,7 @@ export const adminAPI = {
     updateKiosk: (id: string, data: Partial<UpdateKioskRequest>) => apiClient.put(`/admin/kiosks/${id}`, data),
     deleteKiosk: (id: string) => apiClient.delete(`/admin/kiosks/${id}`),
     unpairKiosk: (id: string) => apiClient.post(`/admin/kiosks/${id}/unpair`),
+    issueKioskPairingToken: (id: string) => apiClient.post(`/admin/kiosks/${id}/pairing-token`),
 };
 
 // Types
@@ -279,15 +309,24 @@ export const kioskAPI = {
         }),
 
     // Offline mode endpoints
-    syncData: (kioskId: string, adminCode: string) =>
+    syncData: (kioskId: string, adminCode: string, cursor?: string, since?: string) =>
         apiClient.get('/kiosk/sync-data', {
-            params: { kiosk_id: kioskId, code: adminCode }
+            params: { kiosk_id: kioskId, code: adminCode, cursor, since: cursor ? undefined : since }
         }),
-    offlineSync: (kioskId: string, adminCode: string, records: any[]) =>
+    offlineSync: (kioskId: string, adminCode: string, records: any[], rttMs?: number) =>
         apiClient.post('/kiosk/offline-sync', {
             kiosk_id: kioskId,
             admin_code: adminCode,
             records,
+            client_time: new Date().toISOString(),
+            rtt_ms: rttMs,
+        }),
+    heartbeat: (kioskId: string, adminCode: string, rttMs?: number) =>
+        apiClient.post('/kiosk/heartbeat', {
+            kiosk_id: kioskId,
+            admin_code: adminCode,
+            client_time: new Date().toISOString(),
+            rtt_ms: rttMs,
         }),
 };
 
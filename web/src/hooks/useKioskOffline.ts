@@ -5,13 +5,17 @@ import { useState, useEffect, useCallback, useRef } from 'react';
 import {
     initOfflineDB,
     cacheEmployees,
+    applyEmployeeDelta,
     getEmployee,
     getAllEmployees,
-    queueAttendance,
+    queueSignedAttendance,
     getPendingAttendance,
+    markAttendanceSynced,
     clearSyncedAttendance,
     getLastSyncTime,
     setLastSyncTime,
+    getSyncScope,
+    setSyncScope,
     isOfflineDataAvailable,
     compareFaceEmbeddings,
 } from '../services/kioskOfflineService';
@@ -37,6 +41,7 @@ export function useKioskOffline({ kioskId, adminCode, autoSync = true }: UseKios
     const [syncError, setSyncError] = useState<string | null>(null);
 
     const syncIntervalRef = useRef<ReturnType<typeof setInterval> | null>(null);
+    const lastRttRef = useRef<number | undefined>(undefined); // Round-trip of the last heartbeat, for clock skew measurement
 
     // Initialize offline database
     useEffect(() => {
@@ -93,8 +98,28 @@ export function useKioskOffline({ kioskId, adminCode, autoSync = true }: UseKios
         setSyncError(null);
 
         try {
-            const response = await kioskAPI.syncData(kioskId, adminCode);
-            const { employees, last_sync_time } = response.data;
+            // Changes since the last sync when the cache belongs to this kiosk, otherwise a full snapshot
+            const [storedSince, storedScope] = await Promise.all([getLastSyncTime(), getSyncScope()]);
+            let since = storedScope?.startsWith(`${kioskId}|`) ? storedSince ?? undefined : undefined;
+
+            const employees: any[] = [];
+            const removed: string[] = [];
+            let cursor: string | undefined;
+            let last_sync_time = '';
+            let officeId = '';
+            do {
+                const response = await kioskAPI.syncData(kioskId, adminCode, cursor, since);
+                officeId = response.data.office_info?.id || '';
+                // The kiosk moved to another office: start over with a full snapshot
+                if (since && !cursor && storedScope !== `${kioskId}|${officeId}`) {
+                    since = undefined;
+                    continue;
+                }
+                employees.push(...(response.data.employees || []));
+                removed.push(...(response.data.tombstones || []).map((t: any) => t.employee_id));
+                last_sync_time = response.data.last_sync_time;
+                cursor = response.data.has_more ? response.data.next_cursor : undefined;
+            } while (cursor || (!last_sync_time && !since));
 
             // Transform and cache employees
             const employeeData: EmployeeData[] = employees.map((emp: any) => ({
@@ -105,14 +130,20 @@ export function useKioskOffline({ kioskId, adminCode, autoSync = true }: UseKios
                 is_active: emp.is_active,
             }));
 
-            await cacheEmployees(employeeData);
+            if (since) {
+                await applyEmployeeDelta(employeeData, removed);
+            } else {
+                await cacheEmployees(employeeData);
+            }
             await setLastSyncTime(last_sync_time);
+            await setSyncScope(`${kioskId}|${officeId}`);
 
-            setEmployeeCount(employeeData.length);
+            const cached = await getAllEmployees();
+            setEmployeeCount(cached.length);
             setLastSyncTimeState(last_sync_time);
             setIsOfflineReady(true);
 
-            console.log(`[KioskOffline] Synced ${employeeData.length} employees`);
+            console.log(`[KioskOffline] Synced ${employeeData.length} employees, removed ${removed.length}`);
             return true;
         } catch (error: any) {
             console.error('[KioskOffline] Sync error:', error);
@@ -135,27 +166,56 @@ export function useKioskOffline({ kioskId, adminCode, autoSync = true }: UseKios
         }
 
         try {
-            const response = await kioskAPI.offlineSync(kioskId, adminCode, pending);
-            const { synced, errors } = response.data;
-
-            if (synced > 0) {
+            const response = await kioskAPI.offlineSync(kioskId, adminCode, pending, lastRttRef.current);
+            const { synced, results } = response.data as {
+                synced: number;
+                results: { client_id: string; status: string; message?: string }[];
+            };
+
+            // Created and duplicate records are safely stored on the server
+            const stored = new Set(
+                results.filter((r) => r.status !== 'rejected').map((r) => r.client_id)
+            );
+            const storedIds = pending
+                .filter((r) => r.id !== undefined && stored.has(r.client_id))
+                .map((r) => r.id as number);
+
+            if (storedIds.length > 0) {
+                await markAttendanceSynced(storedIds);
                 await clearSyncedAttendance();
                 const remaining = await getPendingAttendance();
                 setPendingCount(remaining.length);
             }
 
+            const errors = results
+                .filter((r) => r.status === 'rejected')
+                .map((r) => r.message || r.client_id);
+
             return { synced, errors };
         } catch (error: any) {
             return { synced: 0, errors: [error.message || 'Sync failed'] };
         }
     }, [isOnline, kioskId, adminCode]);
 
+    // Heartbeat lets the server measure this kiosk's clock offset
+    const sendHeartbeat = useCallback(async () => {
+        if (!isOnline || !kioskId || !adminCode) return;
+        try {
+            const startedAt = performance.now();
+            await kioskAPI.heartbeat(kioskId, adminCode, lastRttRef.current);
+            lastRttRef.current = Math.round(performance.now() - startedAt);
+        } catch (error) {
+            console.warn('[KioskOffline] Heartbeat failed:', error);
+        }
+    }, [isOnline, kioskId, adminCode]);
+
     // Full sync (employee data + pending attendance)
     const fullSync = useCallback(async () => {
+        await sendHeartbeat();
         const employeeSuccess = await syncEmployeeData();
         const { synced } = await syncPendingAttendance();
         return { employeeSuccess, attendanceSynced: synced };
-    }, [syncEmployeeData, syncPendingAttendance]);
+    }, [sendHeartbeat, syncEmployeeData, syncPendingAttendance]);
 
     // Auto-sync on interval
     useEffect(() => {
@@ -206,18 +266,19 @@ export function useKioskOffline({ kioskId, adminCode, autoSync = true }: UseKios
         confidence: number
     ): Promise<number> => {
         const record: Omit<AttendanceRecord, 'id'> = {
+            client_id: crypto.randomUUID(),
             employee_id: employeeId,
             type,
             timestamp: new Date().toISOString(),
             confidence,
         };
 
-        const id = await queueAttendance(record);
+        const id = await queueSignedAttendance(kioskId, record);
         const pending = await getPendingAttendance();
         setPendingCount(pending.length);
 
         return id;
-    }, []);
+    }, [kioskId]);
 
     return {
         // Status
@@ -0,0 +1,162 @@
+// Change Password Page
+// Shown when an admin-chosen password must be replaced before the dashboard can be used
+import { useState } from 'react';
+import { Navigate, useNavigate } from 'react-router-dom';
+import { useAuthStore } from '../store/authStore';
+import { authAPI } from '../api/client';
+import { KeyRound, AlertCircle, Eye, EyeOff } from 'lucide-react';
+
+export default function ChangePasswordPage() {
+    const [currentPassword, setCurrentPassword] = useState('');
+    const [newPassword, setNewPassword] = useState('');
+    const [confirmPassword, setConfirmPassword] = useState('');
+    const [showPassword, setShowPassword] = useState(false);
+    const [error, setError] = useState('');
+    const [loading, setLoading] = useState(false);
+    const navigate = useNavigate();
+    const login = useAuthStore((state) => state.login);
+    const logout = useAuthStore((state) => state.logout);
+
+    // The login that asked for the change left its tokens here
+    if (!localStorage.getItem('accessToken')) {
+        return <Navigate to="/login" replace />;
+    }
+
+    const handleSubmit = async (e: React.FormEvent) => {
+        e.preventDefault();
+        if (loading) return;
+
+        setError('');
+        if (newPassword.length < 6) {
+            setError('Password baru minimal 6 karakter.');
+            return;
+        }
+        if (newPassword !== confirmPassword) {
+            setError('Konfirmasi password tidak cocok.');
+            return;
+        }
+        if (newPassword === currentPassword) {
+            setError('Password baru harus berbeda dari password sementara.');
+            return;
+        }
+
+        setLoading(true);
+        try {
+            await authAPI.changePassword(currentPassword, newPassword);
+
+            // The current access token only allows the change; refreshing lifts the limit
+            const response = await authAPI.refresh(localStorage.getItem('refreshToken') || '');
+            const { user, access_token, refresh_token } = response.data;
+            login(user, access_token, refresh_token);
+            navigate('/', { replace: true });
+        } catch (err: any) {
+            setError(err.response?.data?.error || 'Gagal mengubah password');
+        } finally {
+            setLoading(false);
+        }
+    };
+
+    const handleCancel = () => {
+        authAPI.logout().catch(() => undefined);
+        logout();
+        navigate('/login', { replace: true });
+    };
+
+    const inputClass = "w-full px-4 py-3 bg-slate-950/50 border border-slate-700 rounded-xl focus:ring-2 focus:ring-cyan-500/50 focus:border-cyan-500 outline-none transition text-white placeholder-slate-600 focus:bg-slate-950";
+
+    return (
+        <div className="min-h-screen bg-slate-950 flex items-center justify-center p-4 relative overflow-hidden font-sans">
+            <div className="absolute top-20 left-20 w-72 h-72 bg-cyan-500/10 rounded-full blur-3xl animate-pulse delay-1000" />
+            <div className="absolute bottom-20 right-20 w-96 h-96 bg-blue-600/10 rounded-full blur-3xl animate-pulse" />
+
+            <div className="w-full max-w-md relative z-10">
+                <div className="bg-slate-900/60 backdrop-blur-xl rounded-2xl shadow-2xl p-8 border border-white/5 ring-1 ring-white/10">
+                    <div className="flex flex-col items-center text-center mb-6 space-y-3">
+                        <div className="inline-flex items-center justify-center w-14 h-14 bg-slate-900 border border-slate-700/50 rounded-2xl">
+                            <KeyRound size={28} className="text-cyan-500" />
+                        </div>
+                        <h2 className="text-xl font-semibold text-white">Ganti Password</h2>
+                        <p className="text-sm text-slate-400">
+                            Password Anda diatur oleh admin. Buat password baru sebelum melanjutkan.
+                        </p>
+                    </div>
+
+                    {error && (
+                        <div className="mb-6 p-3 bg-red-500/10 border border-red-500/20 rounded-lg flex items-center gap-2 text-red-400 text-sm animate-in fade-in slide-in-from-top-2">
+                            <AlertCircle size={16} className="flex-shrink-0" />
+                            {error}
+                        </div>
+                    )}
+
+                    <form onSubmit={handleSubmit} className="space-y-5">
+                        <div className="space-y-1.5">
+                            <label className="block text-sm font-medium text-slate-300">Password Sementara</label>
+                            <input
+                                type={showPassword ? "text" : "password"}
+                                value={currentPassword}
+                                onChange={(e) => setCurrentPassword(e.target.value)}
+                                className={inputClass}
+                                autoComplete="current-password"
+                                required
+                            />
+                        </div>
+
+                        <div className="space-y-1.5">
+                            <label className="block text-sm font-medium text-slate-300">Password Baru</label>
+                            <div className="relative">
+                                <input
+                                    type={showPassword ? "text" : "password"}
+                                    value={newPassword}
+                                    onChange={(e) => setNewPassword(e.target.value)}
+                                    className={`${inputClass} pr-12`}
+                                    autoComplete="new-password"
+                                    minLength={6}
+                                    required
+                                />
+                                <button
+                                    type="button"
+                                    onClick={() => setShowPassword(!showPassword)}
+                                    className="absolute right-3 top-1/2 -translate-y-1/2 text-slate-500 hover:text-cyan-400 transition p-1 hover:bg-slate-800 rounded-lg"
+                                >
+                                    {showPassword ? <EyeOff size={18} /> : <Eye size={18} />}
+                                </button>
+                            </div>
+                        </div>
+
+                        <div className="space-y-1.5">
+                            <label className="block text-sm font-medium text-slate-300">Konfirmasi Password Baru</label>
+                            <input
+                                type={showPassword ? "text" : "password"}
+                                value={confirmPassword}
+                                onChange={(e) => setConfirmPassword(e.target.value)}
+                                className={inputClass}
+                                autoComplete="new-password"
+                                required
+                            />
+                        </div>
+
+                        <button
+                            type="submit"
+                            disabled={loading}
+                            className="w-full py-3.5 bg-gradient-to-r from-cyan-600 to-blue-600 hover:from-cyan-500 hover:to-blue-500 text-white font-semibold rounded-xl flex items-center justify-center gap-2 transition-all disabled:opacity-50 mt-2 shadow-lg shadow-cyan-900/20 active:scale-[0.98] ring-1 ring-white/10"
+                        >
+                            {loading ? (
+                                <div className="w-5 h-5 border-2 border-white/30 border-t-white rounded-full animate-spin" />
+                            ) : (
+                                'Simpan Password'
+                            )}
+                        </button>
+
+                        <button
+                            type="button"
+                            onClick={handleCancel}
+                            className="w-full py-3 text-sm text-slate-400 hover:text-white transition"
+                        >
+                            Keluar
+                        </button>
+                    </form>
+                </div>
+            </div>
+        </div>
+    );
+}
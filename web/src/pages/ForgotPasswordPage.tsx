@@ -0,0 +1,111 @@
+// Forgot Password Page
+// Asks for the account email and has the server send a reset link to it
+import { useState } from 'react';
+import { useNavigate } from 'react-router-dom';
+import { authAPI } from '../api/client';
+import { KeyRound, AlertCircle, CheckCircle2 } from 'lucide-react';
+
+export default function ForgotPasswordPage() {
+    const [email, setEmail] = useState('');
+    const [error, setError] = useState('');
+    const [loading, setLoading] = useState(false);
+    const [sent, setSent] = useState(false);
+    const navigate = useNavigate();
+
+    const handleSubmit = async (e: React.FormEvent) => {
+        e.preventDefault();
+        if (loading) return;
+
+        setError('');
+        setLoading(true);
+        try {
+            await authAPI.forgotPassword(email);
+            setSent(true);
+        } catch (err: any) {
+            setError(err.response?.data?.error || 'Gagal mengirim tautan reset password');
+        } finally {
+            setLoading(false);
+        }
+    };
+
+    return (
+        <div className="min-h-screen bg-slate-950 flex items-center justify-center p-4 relative overflow-hidden font-sans">
+            <div className="absolute top-20 left-20 w-72 h-72 bg-cyan-500/10 rounded-full blur-3xl animate-pulse delay-1000" />
+            <div className="absolute bottom-20 right-20 w-96 h-96 bg-blue-600/10 rounded-full blur-3xl animate-pulse" />
+
+            <div className="w-full max-w-md relative z-10">
+                <div className="bg-slate-900/60 backdrop-blur-xl rounded-2xl shadow-2xl p-8 border border-white/5 ring-1 ring-white/10">
+                    <div className="flex flex-col items-center text-center mb-6 space-y-3">
+                        <div className="inline-flex items-center justify-center w-14 h-14 bg-slate-900 border border-slate-700/50 rounded-2xl">
+                            <KeyRound size={28} className="text-cyan-500" />
+                        </div>
+                        <h2 className="text-xl font-semibold text-white">Lupa Password</h2>
+                        {!sent && (
+                            <p className="text-sm text-slate-400">
+                                Masukkan email akun Anda, kami akan mengirim tautan untuk membuat password baru.
+                            </p>
+                        )}
+                    </div>
+
+                    {error && (
+                        <div className="mb-6 p-3 bg-red-500/10 border border-red-500/20 rounded-lg flex items-center gap-2 text-red-400 text-sm animate-in fade-in slide-in-from-top-2">
+                            <AlertCircle size={16} className="flex-shrink-0" />
+                            {error}
+                        </div>
+                    )}
+
+                    {sent ? (
+                        <div className="space-y-6 text-center">
+                            <div className="p-4 bg-emerald-500/10 border border-emerald-500/20 rounded-lg flex items-center gap-2 text-emerald-400 text-sm text-left">
+                                <CheckCircle2 size={16} className="flex-shrink-0" />
+                                Jika email tersebut terdaftar, tautan reset password sudah dikirim. Periksa kotak masuk Anda.
+                            </div>
+                            <button
+                                type="button"
+                                onClick={() => navigate('/login', { replace: true })}
+                                className="w-full py-3.5 bg-gradient-to-r from-cyan-600 to-blue-600 hover:from-cyan-500 hover:to-blue-500 text-white font-semibold rounded-xl transition-all ring-1 ring-white/10"
+                            >
+                                Ke Halaman Masuk
+                            </button>
+                        </div>
+                    ) : (
+                        <form onSubmit={handleSubmit} className="space-y-5">
+                            <div className="space-y-1.5">
+                                <label className="block text-sm font-medium text-slate-300">Email</label>
+                                <input
+                                    type="email"
+                                    value={email}
+                                    onChange={(e) => setEmail(e.target.value)}
+                                    className="w-full px-4 py-3 bg-slate-950/50 border border-slate-700 rounded-xl focus:ring-2 focus:ring-cyan-500/50 focus:border-cyan-500 outline-none transition text-white placeholder-slate-600 focus:bg-slate-950"
+                                    placeholder="nama@perusahaan.com"
+                                    autoComplete="email"
+                                    required
+                                />
+                            </div>
+
+                            <button
+                                type="submit"
+                                disabled={loading}
+                                className="w-full py-3.5 bg-gradient-to-r from-cyan-600 to-blue-600 hover:from-cyan-500 hover:to-blue-500 text-white font-semibold rounded-xl flex items-center justify-center gap-2 transition-all disabled:opacity-50 mt-2 shadow-lg shadow-cyan-900/20 active:scale-[0.98] ring-1 ring-white/10"
+                            >
+                                {loading ? (
+                                    <div className="w-5 h-5 border-2 border-white/30 border-t-white rounded-full animate-spin" />
+                                ) : (
+                                    'Kirim Tautan Reset'
+                                )}
+                            </button>
+
+                            <button
+                                type="button"
+                                onClick={() => navigate('/login')}
+                                className="w-full py-3 bg-slate-800/50 hover:bg-slate-800 text-slate-300 hover:text-white font-medium rounded-xl transition-all border border-slate-700"
+                            >
+                                Kembali
+                            </button>
+                        </form>
+                    )}
+                </div>
+            </div>
+        </div>
+    );
+}
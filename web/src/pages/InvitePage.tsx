@@ -0,0 +1,172 @@
+// Invite Page
+// Opened from an invitation email: the invited user sets their own password
+import { useState, useEffect } from 'react';
+import { useNavigate, useParams } from 'react-router-dom';
+import { authAPI } from '../api/client';
+import { UserCheck, AlertCircle, CheckCircle2, Eye, EyeOff } from 'lucide-react';
+
+interface InvitationInfo {
+    name: string;
+    email: string;
+    expires_at: string;
+}
+
+export default function InvitePage() {
+    const { token = '' } = useParams();
+    const [invitation, setInvitation] = useState<InvitationInfo | null>(null);
+    const [checking, setChecking] = useState(true);
+    const [password, setPassword] = useState('');
+    const [confirmPassword, setConfirmPassword] = useState('');
+    const [showPassword, setShowPassword] = useState(false);
+    const [error, setError] = useState('');
+    const [loading, setLoading] = useState(false);
+    const [accepted, setAccepted] = useState(false);
+    const navigate = useNavigate();
+
+    useEffect(() => {
+        const fetchInvitation = async () => {
+            try {
+                const res = await authAPI.getInvitation(token);
+                setInvitation(res.data);
+            } catch (err: any) {
+                setError(err.response?.data?.error || 'Undangan tidak valid atau sudah kedaluwarsa');
+            } finally {
+                setChecking(false);
+            }
+        };
+        fetchInvitation();
+    }, [token]);
+
+    const handleSubmit = async (e: React.FormEvent) => {
+        e.preventDefault();
+        if (loading) return;
+
+        setError('');
+        if (password.length < 6) {
+            setError('Password minimal 6 karakter.');
+            return;
+        }
+        if (password !== confirmPassword) {
+            setError('Konfirmasi password tidak cocok.');
+            return;
+        }
+
+        setLoading(true);
+        try {
+            await authAPI.acceptInvitation(token, password);
+            setAccepted(true);
+        } catch (err: any) {
+            setError(err.response?.data?.error || 'Gagal mengaktifkan akun');
+        } finally {
+            setLoading(false);
+        }
+    };
+
+    const inputClass = "w-full px-4 py-3 bg-slate-950/50 border border-slate-700 rounded-xl focus:ring-2 focus:ring-cyan-500/50 focus:border-cyan-500 outline-none transition text-white placeholder-slate-600 focus:bg-slate-950";
+
+    return (
+        <div className="min-h-screen bg-slate-950 flex items-center justify-center p-4 relative overflow-hidden font-sans">
+            <div className="absolute top-20 left-20 w-72 h-72 bg-cyan-500/10 rounded-full blur-3xl animate-pulse delay-1000" />
+            <div className="absolute bottom-20 right-20 w-96 h-96 bg-blue-600/10 rounded-full blur-3xl animate-pulse" />
+
+            <div className="w-full max-w-md relative z-10">
+                <div className="bg-slate-900/60 backdrop-blur-xl rounded-2xl shadow-2xl p-8 border border-white/5 ring-1 ring-white/10">
+                    <div className="flex flex-col items-center text-center mb-6 space-y-3">
+                        <div className="inline-flex items-center justify-center w-14 h-14 bg-slate-900 border border-slate-700/50 rounded-2xl">
+                            <UserCheck size={28} className="text-cyan-500" />
+                        </div>
+                        <h2 className="text-xl font-semibold text-white">Aktivasi Akun</h2>
+                        {invitation && !accepted && (
+                            <p className="text-sm text-slate-400">
+                                Halo {invitation.name}, buat password untuk <span className="text-slate-200">{invitation.email}</span>.
+                            </p>
+                        )}
+                    </div>
+
+                    {error && (
+                        <div className="mb-6 p-3 bg-red-500/10 border border-red-500/20 rounded-lg flex items-center gap-2 text-red-400 text-sm animate-in fade-in slide-in-from-top-2">
+                            <AlertCircle size={16} className="flex-shrink-0" />
+                            {error}
+                        </div>
+                    )}
+
+                    {checking ? (
+                        <div className="flex justify-center py-6">
+                            <div className="w-6 h-6 border-2 border-slate-600 border-t-cyan-500 rounded-full animate-spin" />
+                        </div>
+                    ) : accepted ? (
+                        <div className="space-y-6 text-center">
+                            <div className="p-4 bg-emerald-500/10 border border-emerald-500/20 rounded-lg flex items-center gap-2 text-emerald-400 text-sm">
+                                <CheckCircle2 size={16} className="flex-shrink-0" />
+                                Password tersimpan. Silakan masuk dengan akun Anda.
+                            </div>
+                            <button
+                                type="button"
+                                onClick={() => navigate('/login', { replace: true })}
+                                className="w-full py-3.5 bg-gradient-to-r from-cyan-600 to-blue-600 hover:from-cyan-500 hover:to-blue-500 text-white font-semibold rounded-xl transition-all ring-1 ring-white/10"
+                            >
+                                Ke Halaman Masuk
+                            </button>
+                        </div>
+                    ) : invitation ? (
+                        <form onSubmit={handleSubmit} className="space-y-5">
+                            <div className="space-y-1.5">
+                                <label className="block text-sm font-medium text-slate-300">Password</label>
+                                <div className="relative">
+                                    <input
+                                        type={showPassword ? "text" : "password"}
+                                        value={password}
+                                        onChange={(e) => setPassword(e.target.value)}
+                                        className={`${inputClass} pr-12`}
+                                        autoComplete="new-password"
+                                        minLength={6}
+                                        required
+                                    />
+                                    <button
+                                        type="button"
+                                        onClick={() => setShowPassword(!showPassword)}
+                                        className="absolute right-3 top-1/2 -translate-y-1/2 text-slate-500 hover:text-cyan-400 transition p-1 hover:bg-slate-800 rounded-lg"
+                                    >
+                                        {showPassword ? <EyeOff size={18} /> : <Eye size={18} />}
+                                    </button>
+                                </div>
+                            </div>
+
+                            <div className="space-y-1.5">
+                                <label className="block text-sm font-medium text-slate-300">Konfirmasi Password</label>
+                                <input
+                                    type={showPassword ? "text" : "password"}
+                                    value={confirmPassword}
+                                    onChange={(e) => setConfirmPassword(e.target.value)}
+                                    className={inputClass}
+                                    autoComplete="new-password"
+                                    required
+                                />
+                            </div>
+
+                            <button
+                                type="submit"
+                                disabled={loading}
+                                className="w-full py-3.5 bg-gradient-to-r from-cyan-600 to-blue-600 hover:from-cyan-500 hover:to-blue-500 text-white font-semibold rounded-xl flex items-center justify-center gap-2 transition-all disabled:opacity-50 mt-2 shadow-lg shadow-cyan-900/20 active:scale-[0.98] ring-1 ring-white/10"
+                            >
+                                {loading ? (
+                                    <div className="w-5 h-5 border-2 border-white/30 border-t-white rounded-full animate-spin" />
+                                ) : (
+                                    'Aktifkan Akun'
+                                )}
+                            </button>
+                        </form>
+                    ) : (
+                        <button
+                            type="button"
+                            onClick={() => navigate('/login', { replace: true })}
+                            className="w-full py-3 bg-slate-800/50 hover:bg-slate-800 text-slate-300 hover:text-white font-medium rounded-xl transition-all border border-slate-700"
+                        >
+                            Ke Halaman Masuk
+                        </button>
+                    )}
+                </div>
+            </div>
+        </div>
+    );
+}
@@ -5,15 +5,25 @@ import { useNavigate } from 'react-router-dom';
 import {
     Clock, MapPin, Scan, CheckCircle2, XCircle,
     Settings, ShieldCheck, ChevronRight, RefreshCw, LogIn, LogOut, UserPlus, Camera,
-    Wifi, WifiOff, AlertCircle
+    Wifi, WifiOff, AlertCircle, Megaphone
 } from 'lucide-react';
 import { useKioskOffline } from '../hooks/useKioskOffline';
 import * as faceapi from 'face-api.js';
-import { compareFaceEmbeddings } from '../services/kioskOfflineService';
+import { compareFaceEmbeddings, createDeviceKey, saveDeviceKey } from '../services/kioskOfflineService';
 
 // API base URL
 const API_URL = import.meta.env.VITE_API_URL || '';
 
+// Signed QR payloads ("r1.<employee_id>.<window>.<sig>", "b1.<employee_id>.<serial>.<sig>")
+// carry the employee ID in clear so offline lookups still work; only the server checks the signature
+function employeeIdFromQR(scanned: string): string {
+    if (scanned.startsWith('r1.') || scanned.startsWith('b1.')) {
+        const parts = scanned.split('.');
+        if (parts.length >= 4) return parts.slice(1, -2).join('.');
+    }
+    return scanned;
+}
+
 interface Employee {
     id: string;
     employee_id: string;
@@ -28,6 +38,16 @@ interface RegistrationEmployee {
     name: string;
 }
 
+interface KioskAnnouncement {
+    id: string;
+    title: string;
+    body: string;
+    priority: 'info' | 'warning' | 'critical';
+}
+
+// Scheduled announcements start and end without a push, so the idle screen refetches
+const ANNOUNCEMENT_REFRESH_MS = 5 * 60 * 1000;
+
 interface Kiosk {
     id: string;
     kiosk_id: string;
@@ -46,6 +66,8 @@ const KioskPage: React.FC = () => {
     const [currentTime, setCurrentTime] = useState(new Date());
     const [autoResetTimer, setAutoResetTimer] = useState(5);
     const [manualId, setManualId] = useState('');
+    const [manualPin, setManualPin] = useState('');
+    const [ticket, setTicket] = useState(''); // From scan/identify, upgraded by face verification, required to punch
     const [cameraError, setCameraError] = useState(false);
 
     // Setup & Admin State
@@ -54,6 +76,7 @@ const KioskPage: React.FC = () => {
     const [adminPIN, setAdminPIN] = useState('');
     const [setupStep, setSetupStep] = useState(1);
     const [availableKiosks, setAvailableKiosks] = useState<Kiosk[]>([]);
+    const [pairingToken, setPairingToken] = useState(''); // Issued by an admin, authorizes this device's signing key
     const [kioskId, setKioskId] = useState(localStorage.getItem('kiosk_id') || '');
 
     // Face Registration State
@@ -67,6 +90,7 @@ const KioskPage: React.FC = () => {
     // Screensaver State
     const [isScreensaverActive, setIsScreensaverActive] = useState(false);
     const [, setIdleTime] = useState(0);
+    const [announcements, setAnnouncements] = useState<KioskAnnouncement[]>([]);
 
     const webcamRef = useRef<Webcam>(null);
     const scannerRef = useRef<Html5Qrcode | null>(null);
@@ -130,7 +154,24 @@ const KioskPage: React.FC = () => {
             }
         };
 
+        // Announcements shown on the idle screen, targeted at this kiosk's office
+        const fetchAnnouncements = async () => {
+            const storedKioskId = localStorage.getItem('kiosk_id');
+            if (!storedKioskId) return;
+            try {
+                const response = await fetch(`${API_URL}/api/kiosk/announcements?kiosk_id=${encodeURIComponent(storedKioskId)}`);
+                if (response.ok) {
+                    const data = await response.json();
+                    setAnnouncements(data.data || []);
+                }
+            } catch (error) {
+                console.error('Failed to fetch announcements:', error);
+            }
+        };
+
         fetchSettings();
+        fetchAnnouncements();
+        const announcementTimer = setInterval(fetchAnnouncements, ANNOUNCEMENT_REFRESH_MS);
 
         // WebSocket for realtime updates
         let ws: WebSocket | null = null;
@@ -139,7 +180,14 @@ const KioskPage: React.FC = () => {
         const connectWs = () => {
             // Replace http/https with ws/wss
             const wsBaseUrl = API_URL.replace(/^http/, 'ws');
-            const wsUrl = `${wsBaseUrl}/ws/dashboard?type=kiosk`; // Reusing dashboard endpoint for simplicity
+            // Kiosks authenticate with the same kiosk ID and admin code as the kiosk API
+            const storedKioskId = localStorage.getItem('kiosk_id');
+            if (!storedKioskId) {
+                reconnectTimer = setTimeout(connectWs, 5000);
+                return;
+            }
+            const credential = `kiosk_id=${encodeURIComponent(storedKioskId)}&admin_code=${encodeURIComponent(adminPIN || '123456')}`;
+            const wsUrl = `${wsBaseUrl}/ws/dashboard?${credential}`; // Reusing dashboard endpoint for simplicity
 
             ws = new WebSocket(wsUrl);
 
@@ -155,6 +203,10 @@ const KioskPage: React.FC = () => {
                         console.log('Settings updated, refetching...');
                         fetchSettings();
                     }
+                    // Announcements are pushed when published, changed or withdrawn
+                    if (data.event === 'announcement:published' || data.event === 'announcement:withdrawn') {
+                        fetchAnnouncements();
+                    }
                     // Handle attendance events (optional: show toast?)
                 } catch (e) {
                     console.error('WS Parse error', e);
@@ -172,6 +224,7 @@ const KioskPage: React.FC = () => {
         return () => {
             if (ws) ws.close();
             if (reconnectTimer) clearTimeout(reconnectTimer);
+            clearInterval(announcementTimer);
         };
     }, []);
 
@@ -181,6 +234,40 @@ const KioskPage: React.FC = () => {
         return () => clearInterval(timer);
     }, []);
 
+    // Moves an identified employee on to face verification, keeping the server's ticket for the punch
+    const startVerification = useCallback(async (data: any) => {
+        // Map response to Employee interface
+        const employeeData: Employee = {
+            id: data.id || '',
+            employee_id: data.employee_id,
+            name: data.name,
+            has_face_data: data.has_face_data,
+            today_status: data.today_status,
+        };
+
+        // VALIDATION: Prevent action if already checked out
+        if (employeeData.today_status === 'checked_out') {
+            setErrorMsg('Anda sudah Check-Out hari ini.');
+            setStep('error');
+            return;
+        }
+
+        setEmployee(employeeData);
+        setTicket(data.ticket || '');
+
+        if (!data.has_face_data) {
+            setErrorMsg('Anda belum mendaftarkan wajah. Silakan hubungi Admin.');
+            setStep('error');
+            return;
+        }
+
+        if (scannerRef.current) {
+            await scannerRef.current.stop();
+            scannerRef.current = null;
+        }
+        setStep('verify');
+    }, []);
+
     const handleQRScan = useCallback(async (employeeId: string) => {
         if (isProcessing) return;
         setIsProcessing(true);
@@ -189,7 +276,7 @@ const KioskPage: React.FC = () => {
             // OFFLINE MODE: Use local IndexedDB lookup
             if (!isOnline && isOfflineReady) {
                 console.log('[Kiosk] Offline mode - looking up employee locally');
-                const offlineEmployee = await lookupEmployee(employeeId);
+                const offlineEmployee = await lookupEmployee(employeeIdFromQR(employeeId));
 
                 if (!offlineEmployee) {
                     setErrorMsg('Karyawan tidak ditemukan (offline mode)');
@@ -227,7 +314,7 @@ const KioskPage: React.FC = () => {
             const response = await fetch(apiUrl, {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
-                body: JSON.stringify({ employee_id: employeeId }),
+                body: JSON.stringify({ employee_id: employeeId, kiosk_id: kioskId }),
             });
 
             const data = await response.json();
@@ -238,35 +325,7 @@ const KioskPage: React.FC = () => {
                 return;
             }
 
-            // Map response to Employee interface
-            const employeeData: Employee = {
-                id: data.id || '',
-                employee_id: data.employee_id,
-                name: data.name,
-                has_face_data: data.has_face_data,
-                today_status: data.today_status,
-            };
-
-            // VALIDATION: Prevent action if already checked out
-            if (employeeData.today_status === 'checked_out') {
-                setErrorMsg('Anda sudah Check-Out hari ini.');
-                setStep('error');
-                return;
-            }
-
-            setEmployee(employeeData);
-
-            if (!data.has_face_data) {
-                setErrorMsg('Anda belum mendaftarkan wajah. Silakan hubungi Admin.');
-                setStep('error');
-                return;
-            }
-
-            if (scannerRef.current) {
-                await scannerRef.current.stop();
-                scannerRef.current = null;
-            }
-            setStep('verify');
+            await startVerification(data);
         } catch (error: any) {
             const errMsg = error?.message || String(error);
             console.error('[Kiosk] Scan error:', error);
@@ -274,7 +333,7 @@ const KioskPage: React.FC = () => {
             // If network error and offline ready, try offline mode
             if (isOfflineReady) {
                 console.log('[Kiosk] Network error - falling back to offline mode');
-                const offlineEmployee = await lookupEmployee(employeeId);
+                const offlineEmployee = await lookupEmployee(employeeIdFromQR(employeeId));
 
                 if (offlineEmployee) {
                     const hasFaceData = offlineEmployee.face_embeddings && offlineEmployee.face_embeddings.length > 0;
@@ -301,7 +360,66 @@ const KioskPage: React.FC = () => {
         } finally {
             setIsProcessing(false);
         }
-    }, [isProcessing, isOnline, isOfflineReady, lookupEmployee]);
+    }, [isProcessing, isOnline, isOfflineReady, kioskId, lookupEmployee, startVerification]);
+
+    // Card tap or employee ID + PIN, as an alternative to QR. Needs the server, which checks the card or PIN.
+    const handleIdentify = useCallback(async (identity: { card_uid?: string; employee_id?: string; pin?: string }) => {
+        if (isProcessing) return;
+        if (!isOnline) {
+            setErrorMsg('Identifikasi kartu/PIN membutuhkan koneksi. Gunakan QR.');
+            setStep('error');
+            return;
+        }
+        setIsProcessing(true);
+
+        try {
+            const response = await fetch(`${API_URL}/api/kiosk/identify`, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ kiosk_id: kioskId, ...identity }),
+            });
+            const data = await response.json();
+
+            if (!response.ok) {
+                setErrorMsg(data.error || 'Identifikasi gagal');
+                setStep('error');
+                return;
+            }
+
+            await startVerification(data);
+        } catch (error) {
+            setErrorMsg('Gagal terhubung ke server');
+            setStep('error');
+        } finally {
+            setIsProcessing(false);
+        }
+    }, [isProcessing, isOnline, kioskId, startVerification]);
+
+    // RFID/NFC readers type the card UID followed by Enter; collect fast keystrokes outside inputs as a tap
+    useEffect(() => {
+        if (step !== 'scan' || showSetup) return;
+
+        let buffer = '';
+        let lastKeyAt = 0;
+        const handleKeyDown = (e: KeyboardEvent) => {
+            const target = e.target as HTMLElement;
+            if (target.tagName === 'INPUT' || target.tagName === 'TEXTAREA' || target.tagName === 'SELECT') return;
+
+            const now = Date.now();
+            if (now - lastKeyAt > 100) buffer = '';
+            lastKeyAt = now;
+
+            if (e.key === 'Enter') {
+                if (buffer.length >= 4) handleIdentify({ card_uid: buffer });
+                buffer = '';
+            } else if (e.key.length === 1) {
+                buffer += e.key;
+            }
+        };
+
+        window.addEventListener('keydown', handleKeyDown);
+        return () => window.removeEventListener('keydown', handleKeyDown);
+    }, [step, showSetup, handleIdentify]);
 
     // Initialize QR Scanner
     useEffect(() => {
@@ -418,9 +536,12 @@ const KioskPage: React.FC = () => {
 
 
 
-    const handleManualSubmit = (e: React.FormEvent) => {
+    const handleManualSubmit = (e: React.SyntheticEvent) => {
         e.preventDefault();
-        if (manualId.trim()) {
+        if (!manualId.trim()) return;
+        if (manualPin) {
+            handleIdentify({ employee_id: manualId.trim().toUpperCase(), pin: manualPin });
+        } else {
             handleQRScan(manualId.trim().toUpperCase());
         }
     };
@@ -473,10 +594,17 @@ const KioskPage: React.FC = () => {
 
         setIsProcessing(true);
         try {
+            // WebCrypto is only available on secure origins; without a key offline records are held for review
+            const device = window.crypto?.subtle && pairingToken ? await createDeviceKey() : null;
             const response = await fetch(`${API_URL}/api/kiosk/pair`, {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
-                body: JSON.stringify({ kiosk_id: kioskId, admin_code: adminPIN }),
+                body: JSON.stringify({
+                    kiosk_id: kioskId,
+                    admin_code: adminPIN,
+                    public_key: device?.publicKey,
+                    pairing_token: device ? pairingToken : undefined,
+                }),
             });
 
             if (!response.ok) {
@@ -485,9 +613,13 @@ const KioskPage: React.FC = () => {
                 return;
             }
 
+            if (device) {
+                await saveDeviceKey(device.keyPair);
+            }
             localStorage.setItem('kiosk_id', kioskId);
             setShowSetup(false);
             setAdminPIN('');
+            setPairingToken('');
             setSetupStep(1);
             alert('Perangkat berhasil dipasangkan!');
             // Reload to fetch settings
@@ -499,6 +631,38 @@ const KioskPage: React.FC = () => {
         }
     };
 
+    // Registers a new signing key for an already paired kiosk, e.g. after its browser data was cleared
+    const handleRegisterDeviceKey = async () => {
+        if (!kioskId) return;
+        if (!window.crypto?.subtle) {
+            alert('Browser ini tidak mendukung kunci perangkat (butuh HTTPS)');
+            return;
+        }
+        const token = window.prompt('Masukkan token pairing dari admin');
+        if (!token) return;
+
+        setIsProcessing(true);
+        try {
+            const device = await createDeviceKey();
+            const response = await fetch(`${API_URL}/api/kiosk/register-key`, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ kiosk_id: kioskId, pairing_token: token.trim(), public_key: device.publicKey }),
+            });
+            const data = await response.json();
+            if (!response.ok) {
+                alert(data.error || 'Gagal mendaftarkan kunci perangkat');
+                return;
+            }
+            await saveDeviceKey(device.keyPair, data.sync_seq, data.sync_hash);
+            alert('Kunci perangkat berhasil didaftarkan');
+        } catch (error) {
+            alert('Gagal terhubung ke server');
+        } finally {
+            setIsProcessing(false);
+        }
+    };
+
     const handleCapturePhoto = () => {
         if (capturedPhotos.length >= 5) return;
         const imageSrc = webcamRef.current?.getScreenshot();
@@ -643,6 +807,8 @@ const KioskPage: React.FC = () => {
                 body: JSON.stringify({
                     employee_id: employee.employee_id,
                     image_base64: imageSrc,
+                    ticket,
+                    kiosk_id: kioskId,
                 }),
             });
 
@@ -663,6 +829,7 @@ const KioskPage: React.FC = () => {
                 body: JSON.stringify({
                     employee_id: employee.employee_id,
                     kiosk_id: kioskId,
+                    ticket: verifyData.ticket || ticket,
                 }),
             });
 
@@ -699,7 +866,7 @@ const KioskPage: React.FC = () => {
         } finally {
             setIsProcessing(false);
         }
-    }, [employee, isProcessing, kioskId, isOnline, isOfflineReady, lookupEmployee, recordOfflineAttendance]);
+    }, [employee, ticket, isProcessing, kioskId, isOnline, isOfflineReady, lookupEmployee, recordOfflineAttendance]);
 
     const formatTime = (date: Date) => {
         return date.toLocaleTimeString('id-ID', { hour12: false });
@@ -713,6 +880,8 @@ const KioskPage: React.FC = () => {
         setAutoResetTimer(5);
         setIsProcessing(false);
         setManualId('');
+        setManualPin('');
+        setTicket('');
     };
 
     return (
@@ -835,8 +1004,18 @@ const KioskPage: React.FC = () => {
                                             <ChevronRight size={24} strokeWidth={3} />
                                         </button>
                                     </form>
+                                    <input
+                                        type="password"
+                                        inputMode="numeric"
+                                        value={manualPin}
+                                        onChange={(e) => setManualPin(e.target.value.replace(/\D/g, ''))}
+                                        onKeyDown={(e) => { if (e.key === 'Enter') handleManualSubmit(e); }}
+                                        placeholder="PIN"
+                                        maxLength={8}
+                                        className="w-full bg-slate-900/50 border-2 border-slate-700 focus:border-cyan-500/50 rounded-2xl px-4 py-3 text-white placeholder-slate-600 focus:outline-none transition-all duration-300 font-mono text-lg tracking-[0.5em] text-center"
+                                    />
                                     <p className="text-[10px] text-center text-slate-600">
-                                        Pastikan ID sesuai dengan data terdaftar
+                                        Masukkan NIK dan PIN, atau tempelkan kartu pada pembaca
                                     </p>
                                 </div>
                             </div>
@@ -1142,6 +1321,22 @@ const KioskPage: React.FC = () => {
                                     </div>
                                 </button>
 
+                                {kioskId && (
+                                    <button
+                                        onClick={handleRegisterDeviceKey}
+                                        disabled={isProcessing}
+                                        className="w-full p-4 bg-slate-800 hover:bg-slate-700 border border-slate-700 rounded-xl flex items-center gap-4 transition group disabled:opacity-50"
+                                    >
+                                        <div className="p-3 bg-amber-500/10 rounded-lg group-hover:bg-amber-500/20 transition">
+                                            <Settings className="text-amber-400" size={24} />
+                                        </div>
+                                        <div className="text-left">
+                                            <h3 className="font-bold text-white">Kunci Perangkat</h3>
+                                            <p className="text-sm text-slate-400">Daftarkan ulang kunci tanda tangan absensi offline</p>
+                                        </div>
+                                    </button>
+                                )}
+
                                 <button
                                     onClick={() => setSetupMode('register')}
                                     className="w-full p-4 bg-slate-800 hover:bg-slate-700 border border-slate-700 rounded-xl flex items-center gap-4 transition group"
@@ -1233,6 +1428,20 @@ const KioskPage: React.FC = () => {
                                     </div>
                                 )}
 
+                                {setupStep === 2 && (
+                                    <div>
+                                        <label className="block text-xs font-bold text-slate-500 uppercase tracking-wider mb-2">Token Pairing</label>
+                                        <input
+                                            type="text"
+                                            value={pairingToken}
+                                            onChange={(e) => setPairingToken(e.target.value.trim())}
+                                            placeholder="Dari menu Manajemen Kiosk admin"
+                                            className="w-full bg-slate-800 border-2 border-slate-700 focus:border-cyan-500 rounded-xl px-4 py-3 text-white outline-none transition font-mono placeholder-slate-600"
+                                        />
+                                        <p className="text-xs text-slate-500 mt-2">Tanpa token, absensi offline perangkat ini harus ditinjau HR.</p>
+                                    </div>
+                                )}
+
 
                                 <div className="pt-4 flex flex-col gap-3 mt-auto">
                                     <div className="flex gap-3">
@@ -1413,6 +1622,28 @@ const KioskPage: React.FC = () => {
                             </div>
                         </div>
 
+                        {announcements.length > 0 && (
+                            <div className="w-full max-w-2xl mx-auto space-y-3 text-left">
+                                {announcements.slice(0, 3).map(announcement => (
+                                    <div
+                                        key={announcement.id}
+                                        className={`px-6 py-4 rounded-2xl border backdrop-blur-xl ${announcement.priority === 'critical'
+                                            ? 'bg-red-500/10 border-red-500/30'
+                                            : announcement.priority === 'warning'
+                                                ? 'bg-amber-500/10 border-amber-500/30'
+                                                : 'bg-white/5 border-white/10'
+                                            }`}
+                                    >
+                                        <div className="flex items-center gap-2 mb-1">
+                                            <Megaphone size={16} className={announcement.priority === 'critical' ? 'text-red-400' : announcement.priority === 'warning' ? 'text-amber-400' : 'text-cyan-400'} />
+                                            <h3 className="text-white font-bold">{announcement.title}</h3>
+                                        </div>
+                                        <p className="text-sm text-slate-400 line-clamp-3 whitespace-pre-line">{announcement.body}</p>
+                                    </div>
+                                ))}
+                            </div>
+                        )}
+
                         <div className="animate-bounce">
                             <div className="inline-flex items-center gap-3 px-6 py-3 rounded-full bg-white/5 border border-white/10 text-slate-400 text-sm tracking-[0.2em] hover:bg-white/10 transition">
                                 <span className="relative flex h-2 w-2">
@@ -1,9 +1,9 @@
 // Login Page
 import { useState, useEffect } from 'react';
-import { useNavigate } from 'react-router-dom';
+import { useNavigate, useLocation } from 'react-router-dom';
 import { useAuthStore } from '../store/authStore';
 import { authAPI, publicAPI, getUploadUrl } from '../api/client';
-import { LogIn, AlertCircle, Eye, EyeOff, Building2, MapPin } from 'lucide-react';
+import { LogIn, AlertCircle, Eye, EyeOff, Building2, MapPin, ShieldCheck, KeySquare } from 'lucide-react';
 
 interface CompanySettings {
     company_name: string;
@@ -11,6 +11,21 @@ interface CompanySettings {
     company_logo: string;
 }
 
+// Login steps: password, then a 2FA code, or enrolling when the role requires 2FA
+type LoginStep = 'credentials' | 'verify' | 'enroll' | 'recovery_codes';
+
+interface TwoFactorSetup {
+    secret: string;
+    otpauth_uri: string;
+    qr_code: string;
+}
+
+interface AuthResult {
+    user: any;
+    access_token: string;
+    refresh_token: string;
+}
+
 export default function LoginPage() {
     const [email, setEmail] = useState('');
     const [password, setPassword] = useState('');
@@ -22,7 +37,16 @@ export default function LoginPage() {
         company_address: '',
         company_logo: '',
     });
+    const [step, setStep] = useState<LoginStep>('credentials');
+    const [challengeToken, setChallengeToken] = useState('');
+    const [code, setCode] = useState('');
+    const [useRecoveryCode, setUseRecoveryCode] = useState(false);
+    const [setup, setSetup] = useState<TwoFactorSetup | null>(null);
+    const [recoveryCodes, setRecoveryCodes] = useState<string[]>([]);
+    const [enrolledAuth, setEnrolledAuth] = useState<AuthResult | null>(null);
+    const [ssoEnabled, setSSOEnabled] = useState(false);
     const navigate = useNavigate();
+    const location = useLocation();
     const login = useAuthStore((state) => state.login);
 
     useEffect(() => {
@@ -35,8 +59,116 @@ export default function LoginPage() {
             }
         };
         fetchSettings();
+
+        const fetchSSOStatus = async () => {
+            try {
+                const res = await authAPI.getSSOStatus();
+                setSSOEnabled(Boolean(res.data.enabled));
+            } catch (err) {
+                console.error("Failed to fetch SSO status", err);
+            }
+        };
+        fetchSSOStatus();
     }, []);
 
+    // An SSO login comes back through /sso/callback, which hands its result over here
+    // so the same 2FA steps follow
+    useEffect(() => {
+        const ssoResult = (location.state as { ssoResult?: any } | null)?.ssoResult;
+        if (!ssoResult) return;
+        navigate(location.pathname, { replace: true, state: null });
+
+        const continueSSO = async () => {
+            setLoading(true);
+            try {
+                await handleLoginResponse(ssoResult);
+            } catch (err: any) {
+                setError(err.response?.data?.error || 'Login gagal');
+                resetLogin();
+            } finally {
+                setLoading(false);
+            }
+        };
+        continueSSO();
+        // eslint-disable-next-line react-hooks/exhaustive-deps
+    }, [location.state]);
+
+    // finishLogin opens the dashboard with the tokens of a completed login
+    const finishLogin = ({ user, access_token, refresh_token }: AuthResult) => {
+        // Regular employees use the mobile app; any other role may open the dashboard
+        if (user.role === 'employee') {
+            setError('Akses ditolak. Hanya admin dan HR yang dapat mengakses dashboard.');
+            resetLogin();
+            return;
+        }
+
+        // An admin-chosen password must be replaced before the dashboard opens
+        if (user.must_change_password) {
+            localStorage.setItem('accessToken', access_token);
+            localStorage.setItem('refreshToken', refresh_token);
+            navigate('/change-password');
+            return;
+        }
+
+        login(user, access_token, refresh_token);
+        navigate('/');
+    };
+
+    // resetLogin goes back to the password step, e.g. when the challenge expired
+    const resetLogin = () => {
+        setStep('credentials');
+        setChallengeToken('');
+        setCode('');
+        setUseRecoveryCode(false);
+        setSetup(null);
+        setRecoveryCodes([]);
+        setEnrolledAuth(null);
+    };
+
+    // handleStepError shows a failed 2FA request; an expired challenge starts over
+    const handleStepError = (err: any, fallback: string) => {
+        setError(err.response?.data?.error || fallback);
+        if (err.response?.data?.code === 'CHALLENGE_INVALID') {
+            resetLogin();
+        }
+    };
+
+    // handleLoginResponse continues a password or SSO login: with a 2FA step if required,
+    // otherwise straight to the dashboard
+    const handleLoginResponse = async (data: any) => {
+        if (data.two_factor_required) {
+            setChallengeToken(data.challenge_token);
+            setCode('');
+            setStep('verify');
+            return;
+        }
+        if (data.two_factor_setup_required) {
+            setChallengeToken(data.challenge_token);
+            setCode('');
+            setStep('enroll');
+            const setupResponse = await authAPI.setupTwoFactor(data.challenge_token);
+            setSetup(setupResponse.data);
+            return;
+        }
+
+        finishLogin(data);
+    };
+
+    // handleSSO sends the browser to the identity provider, which returns to /sso/callback
+    const handleSSO = async () => {
+        if (loading) return;
+
+        setError('');
+        setLoading(true);
+        try {
+            const response = await authAPI.beginSSO();
+            window.location.href = response.data.authorization_url;
+        } catch (err: any) {
+            setError(err.response?.data?.error || 'Single sign-on tidak tersedia');
+            setLoading(false);
+        }
+    };
+
     const handleSubmit = async (e: React.FormEvent) => {
         e.preventDefault();
         if (loading) return;
@@ -46,23 +178,65 @@ export default function LoginPage() {
 
         try {
             const response = await authAPI.login(email, password);
-            const { user, access_token, refresh_token } = response.data;
+            await handleLoginResponse(response.data);
+        } catch (err: any) {
+            setError(err.response?.data?.error || 'Login gagal');
+            resetLogin();
+        } finally {
+            setLoading(false);
+        }
+    };
 
-            // Check if user has admin/hr role
-            if (user.role !== 'admin' && user.role !== 'hr') {
-                setError('Akses ditolak. Hanya admin dan HR yang dapat mengakses dashboard.');
-                return;
-            }
+    const handleVerify = async (e: React.FormEvent) => {
+        e.preventDefault();
+        if (loading) return;
 
-            login(user, access_token, refresh_token);
-            navigate('/');
+        setError('');
+        setLoading(true);
+        try {
+            const response = await authAPI.verifyTwoFactor(
+                challengeToken,
+                useRecoveryCode ? { recovery_code: code } : { code }
+            );
+            finishLogin(response.data);
         } catch (err: any) {
-            setError(err.response?.data?.error || 'Login gagal');
+            handleStepError(err, 'Kode verifikasi salah');
         } finally {
             setLoading(false);
         }
     };
 
+    const handleConfirmSetup = async (e: React.FormEvent) => {
+        e.preventDefault();
+        if (loading) return;
+
+        setError('');
+        setLoading(true);
+        try {
+            const response = await authAPI.confirmTwoFactorSetup(challengeToken, code);
+            const { recovery_codes, ...auth } = response.data;
+            // Recovery codes are shown this once, before the dashboard opens
+            setEnrolledAuth(auth);
+            setRecoveryCodes(recovery_codes || []);
+            setStep('recovery_codes');
+        } catch (err: any) {
+            handleStepError(err, 'Kode verifikasi salah');
+        } finally {
+            setLoading(false);
+        }
+    };
+
+    const stepTitles: Record<LoginStep, string> = {
+        credentials: 'Masuk ke Dashboard',
+        verify: 'Verifikasi Dua Langkah',
+        enroll: 'Aktifkan Verifikasi Dua Langkah',
+        recovery_codes: 'Simpan Kode Pemulihan',
+    };
+
+    const codeInputClass = "w-full px-4 py-3 bg-slate-950/50 border border-slate-700 rounded-xl focus:ring-2 focus:ring-cyan-500/50 focus:border-cyan-500 outline-none transition text-white placeholder-slate-600 focus:bg-slate-950 text-center text-lg tracking-[0.3em] font-mono";
+    const primaryButtonClass = "w-full py-3.5 bg-gradient-to-r from-cyan-600 to-blue-600 hover:from-cyan-500 hover:to-blue-500 text-white font-semibold rounded-xl flex items-center justify-center gap-2 transition-all disabled:opacity-50 mt-2 shadow-lg shadow-cyan-900/20 active:scale-[0.98] ring-1 ring-white/10";
+    const spinner = <div className="w-5 h-5 border-2 border-white/30 border-t-white rounded-full animate-spin" />;
+
     return (
         <div className="min-h-screen bg-slate-950 flex items-center justify-center p-4 relative overflow-hidden font-sans">
             {/* Background Effects */}
@@ -109,7 +283,7 @@ export default function LoginPage() {
                 {/* Form Card */}
                 <div className="bg-slate-900/60 backdrop-blur-xl rounded-2xl shadow-2xl p-8 border border-white/5 ring-1 ring-white/10">
                     <h2 className="text-xl font-semibold text-white mb-6 text-center">
-                        Masuk ke Dashboard
+                        {stepTitles[step]}
                     </h2>
 
                     {error && (
@@ -119,6 +293,7 @@ export default function LoginPage() {
                         </div>
                     )}
 
+                    {step === 'credentials' && (
                     <form onSubmit={handleSubmit} className="space-y-5">
                         <div className="space-y-1.5">
                             <label className="block text-sm font-medium text-slate-300">
@@ -155,6 +330,15 @@ export default function LoginPage() {
                                     {showPassword ? <EyeOff size={18} /> : <Eye size={18} />}
                                 </button>
                             </div>
+                            <div className="flex justify-end">
+                                <button
+                                    type="button"
+                                    onClick={() => navigate('/forgot-password')}
+                                    className="text-xs text-slate-400 hover:text-cyan-400 transition"
+                                >
+                                    Lupa password?
+                                </button>
+                            </div>
                         </div>
 
                         <button
@@ -178,6 +362,18 @@ export default function LoginPage() {
                             <div className="flex-grow h-px bg-slate-800"></div>
                         </div>
 
+                        {ssoEnabled && (
+                            <button
+                                type="button"
+                                onClick={handleSSO}
+                                disabled={loading}
+                                className="w-full py-3 bg-slate-800/50 hover:bg-slate-800 text-slate-300 hover:text-white font-medium rounded-xl flex items-center justify-center gap-2 transition-all border border-slate-700 hover:border-slate-600 active:scale-[0.98] disabled:opacity-50"
+                            >
+                                <KeySquare size={18} />
+                                Masuk dengan SSO
+                            </button>
+                        )}
+
                         <button
                             type="button"
                             onClick={() => navigate('/kiosk')}
@@ -187,6 +383,97 @@ export default function LoginPage() {
                             Buka Mode Kiosk
                         </button>
                     </form>
+                    )}
+
+                    {step === 'verify' && (
+                        <form onSubmit={handleVerify} className="space-y-5">
+                            <p className="text-sm text-slate-400 text-center">
+                                {useRecoveryCode
+                                    ? 'Masukkan salah satu kode pemulihan Anda.'
+                                    : 'Masukkan kode 6 digit dari aplikasi autentikator Anda.'}
+                            </p>
+                            <input
+                                type="text"
+                                value={code}
+                                onChange={(e) => setCode(e.target.value)}
+                                className={codeInputClass}
+                                placeholder={useRecoveryCode ? 'xxxxx-xxxxx' : '000000'}
+                                inputMode={useRecoveryCode ? 'text' : 'numeric'}
+                                autoComplete="one-time-code"
+                                autoFocus
+                                required
+                            />
+                            <button type="submit" disabled={loading} className={primaryButtonClass}>
+                                {loading ? spinner : (<><ShieldCheck size={18} />Verifikasi</>)}
+                            </button>
+                            <div className="flex justify-between text-sm">
+                                <button
+                                    type="button"
+                                    onClick={() => { setUseRecoveryCode(!useRecoveryCode); setCode(''); setError(''); }}
+                                    className="text-cyan-400 hover:text-cyan-300 transition"
+                                >
+                                    {useRecoveryCode ? 'Gunakan kode autentikator' : 'Gunakan kode pemulihan'}
+                                </button>
+                                <button type="button" onClick={resetLogin} className="text-slate-400 hover:text-white transition">
+                                    Kembali
+                                </button>
+                            </div>
+                        </form>
+                    )}
+
+                    {step === 'enroll' && (
+                        <form onSubmit={handleConfirmSetup} className="space-y-5">
+                            <p className="text-sm text-slate-400 text-center">
+                                Peran Anda mewajibkan verifikasi dua langkah. Pindai kode QR dengan aplikasi autentikator, lalu masukkan kode yang muncul.
+                            </p>
+                            {setup ? (
+                                <div className="flex flex-col items-center gap-3">
+                                    <img src={setup.qr_code} alt="QR 2FA" className="w-44 h-44 rounded-xl bg-white p-2" />
+                                    <p className="text-xs text-slate-500">Atau ketik kunci ini secara manual:</p>
+                                    <code className="px-3 py-2 bg-slate-950/70 border border-slate-700 rounded-lg text-cyan-300 text-xs break-all select-all">
+                                        {setup.secret}
+                                    </code>
+                                </div>
+                            ) : (
+                                <div className="flex justify-center py-6">
+                                    <div className="w-6 h-6 border-2 border-slate-600 border-t-cyan-500 rounded-full animate-spin" />
+                                </div>
+                            )}
+                            <input
+                                type="text"
+                                value={code}
+                                onChange={(e) => setCode(e.target.value)}
+                                className={codeInputClass}
+                                placeholder="000000"
+                                inputMode="numeric"
+                                autoComplete="one-time-code"
+                                required
+                            />
+                            <button type="submit" disabled={loading || !setup} className={primaryButtonClass}>
+                                {loading ? spinner : (<><ShieldCheck size={18} />Aktifkan</>)}
+                            </button>
+                            <button type="button" onClick={resetLogin} className="w-full text-sm text-slate-400 hover:text-white transition">
+                                Kembali
+                            </button>
+                        </form>
+                    )}
+
+                    {step === 'recovery_codes' && enrolledAuth && (
+                        <div className="space-y-5">
+                            <p className="text-sm text-slate-400 text-center">
+                                Simpan kode berikut di tempat aman. Setiap kode dapat dipakai sekali jika Anda kehilangan akses ke aplikasi autentikator. Kode ini tidak akan ditampilkan lagi.
+                            </p>
+                            <div className="grid grid-cols-2 gap-2 p-4 bg-slate-950/70 border border-slate-700 rounded-xl font-mono text-sm text-cyan-300 select-all">
+                                {recoveryCodes.map((recoveryCode) => (
+                                    <span key={recoveryCode} className="text-center">{recoveryCode}</span>
+                                ))}
+                            </div>
+                            <button type="button" onClick={() => finishLogin(enrolledAuth)} className={primaryButtonClass}>
+                                <LogIn size={18} />
+                                Saya sudah menyimpannya
+                            </button>
+                        </div>
+                    )}
 
                     <p className="text-center text-xs text-slate-500 mt-8 font-light">
                         Protected by robust authentication
@@ -0,0 +1,84 @@
+// SSO Callback Page
+// The identity provider sends the user back here with a code and state, which the
+// server redeems; the login page then takes over for any 2FA step
+import { useState, useEffect, useRef } from 'react';
+import { useNavigate, useSearchParams } from 'react-router-dom';
+import { authAPI } from '../api/client';
+import { KeySquare, AlertCircle } from 'lucide-react';
+
+const ssoErrors: Record<string, string> = {
+    SSO_STATE_INVALID: 'Sesi login sudah kedaluwarsa. Silakan coba lagi.',
+    SSO_NO_ACCOUNT: 'Tidak ada akun yang terhubung dengan login ini.',
+    SSO_FAILED: 'Single sign-on gagal. Silakan coba lagi.',
+    SSO_DISABLED: 'Single sign-on belum dikonfigurasi.',
+};
+
+export default function SSOCallbackPage() {
+    const [searchParams] = useSearchParams();
+    const [error, setError] = useState('');
+    const started = useRef(false);
+    const navigate = useNavigate();
+
+    useEffect(() => {
+        // The code and state can be redeemed once, so never post them twice
+        if (started.current) return;
+        started.current = true;
+
+        const code = searchParams.get('code');
+        const state = searchParams.get('state');
+        if (searchParams.get('error') || !code || !state) {
+            setError(searchParams.get('error_description') || 'Login dibatalkan oleh penyedia identitas.');
+            return;
+        }
+
+        const finish = async () => {
+            try {
+                const res = await authAPI.finishSSO(code, state);
+                navigate('/login', { replace: true, state: { ssoResult: res.data } });
+            } catch (err: any) {
+                const data = err.response?.data;
+                setError(ssoErrors[data?.code] || data?.error || 'Single sign-on gagal');
+            }
+        };
+        finish();
+    }, [searchParams, navigate]);
+
+    return (
+        <div className="min-h-screen bg-slate-950 flex items-center justify-center p-4 relative overflow-hidden font-sans">
+            <div className="absolute top-20 left-20 w-72 h-72 bg-cyan-500/10 rounded-full blur-3xl animate-pulse delay-1000" />
+            <div className="absolute bottom-20 right-20 w-96 h-96 bg-blue-600/10 rounded-full blur-3xl animate-pulse" />
+
+            <div className="w-full max-w-md relative z-10">
+                <div className="bg-slate-900/60 backdrop-blur-xl rounded-2xl shadow-2xl p-8 border border-white/5 ring-1 ring-white/10">
+                    <div className="flex flex-col items-center text-center mb-6 space-y-3">
+                        <div className="inline-flex items-center justify-center w-14 h-14 bg-slate-900 border border-slate-700/50 rounded-2xl">
+                            <KeySquare size={28} className="text-cyan-500" />
+                        </div>
+                        <h2 className="text-xl font-semibold text-white">Masuk dengan SSO</h2>
+                    </div>
+
+                    {error ? (
+                        <div className="space-y-6">
+                            <div className="p-3 bg-red-500/10 border border-red-500/20 rounded-lg flex items-center gap-2 text-red-400 text-sm animate-in fade-in slide-in-from-top-2">
+                                <AlertCircle size={16} className="flex-shrink-0" />
+                                {error}
+                            </div>
+                            <button
+                                type="button"
+                                onClick={() => navigate('/login', { replace: true })}
+                                className="w-full py-3 bg-slate-800/50 hover:bg-slate-800 text-slate-300 hover:text-white font-medium rounded-xl transition-all border border-slate-700"
+                            >
+                                Ke Halaman Masuk
+                            </button>
+                        </div>
+                    ) : (
+                        <div className="flex flex-col items-center gap-3 py-6 text-sm text-slate-400">
+                            <div className="w-6 h-6 border-2 border-slate-600 border-t-cyan-500 rounded-full animate-spin" />
+                            Menyelesaikan login...
+                        </div>
+                    )}
+                </div>
+            </div>
+        </div>
+    );
+}
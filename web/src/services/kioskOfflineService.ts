@@ -90,6 +90,28 @@ export async function cacheEmployees(employees: EmployeeData[]): Promise<void> {
     });
 }
 
+// Applies a delta sync: upserts changed employees and drops those no longer at this kiosk
+export async function applyEmployeeDelta(employees: EmployeeData[], removedEmployeeIds: string[]): Promise<void> {
+    const database = await initOfflineDB();
+    const transaction = database.transaction(STORES.EMPLOYEES, 'readwrite');
+    const store = transaction.objectStore(STORES.EMPLOYEES);
+
+    for (const employeeId of removedEmployeeIds) {
+        store.delete(employeeId);
+    }
+    for (const emp of employees) {
+        store.put(emp);
+    }
+
+    return new Promise((resolve, reject) => {
+        transaction.oncomplete = () => {
+            console.log(`[OfflineDB] Applied delta: ${employees.length} updated, ${removedEmployeeIds.length} removed`);
+            resolve();
+        };
+        transaction.onerror = () => reject(transaction.error);
+    });
+}
+
 export async function getEmployee(employeeId: string): Promise<EmployeeData | null> {
     const database = await initOfflineDB();
     const transaction = database.transaction(STORES.EMPLOYEES, 'readonly');
@@ -130,10 +152,14 @@ export async function getEmployeeCount(): Promise<number> {
 
 export interface AttendanceRecord {
     id?: number;
+    client_id: string; // UUID sent with every upload so retries are recognised as duplicates
     employee_id: string;
     type: 'check-in' | 'check-out';
     timestamp: string;
     confidence: number;
+    seq?: number; // Position in this device's signed chain
+    prev_hash?: string; // Chain hash of the previous signed record
+    signature?: string; // base64 raw ECDSA P-256 signature over canonicalRecord
     synced?: boolean;
 }
 
@@ -210,6 +236,119 @@ export async function clearSyncedAttendance(): Promise<void> {
     });
 }
 
+// ========== Device Signing ==========
+// Offline records are signed with a non-extractable P-256 key and chained by hash, so the
+// server can tell they came from this device, in order, with none removed or altered.
+
+const DEVICE_KEY = 'device_key';
+const CHAIN_SEQ = 'chain_seq';
+const CHAIN_HASH = 'chain_hash';
+
+function toBase64(buffer: ArrayBuffer): string {
+    return btoa(String.fromCharCode(...new Uint8Array(buffer)));
+}
+
+function toHex(buffer: ArrayBuffer): string {
+    return Array.from(new Uint8Array(buffer), (b) => b.toString(16).padStart(2, '0')).join('');
+}
+
+// Must match canonicalOfflineRecord on the server byte for byte
+function canonicalRecord(kioskId: string, record: Omit<AttendanceRecord, 'id'>): string {
+    return [
+        record.client_id,
+        kioskId,
+        record.employee_id,
+        record.type,
+        record.timestamp,
+        record.confidence.toFixed(4),
+        String(record.seq),
+        record.prev_hash,
+    ].join('\n');
+}
+
+// Creates a device key; the public half (base64 SPKI) is registered with the pairing token
+export async function createDeviceKey(): Promise<{ keyPair: CryptoKeyPair; publicKey: string }> {
+    const keyPair = await crypto.subtle.generateKey(
+        { name: 'ECDSA', namedCurve: 'P-256' },
+        false,
+        ['sign', 'verify']
+    );
+    const spki = await crypto.subtle.exportKey('spki', keyPair.publicKey);
+    return { keyPair, publicKey: toBase64(spki) };
+}
+
+// Stores a newly registered key and starts its chain where the server does
+export async function saveDeviceKey(keyPair: CryptoKeyPair, seq = 0, hash = ''): Promise<void> {
+    const database = await initOfflineDB();
+    const transaction = database.transaction(STORES.SETTINGS, 'readwrite');
+    const store = transaction.objectStore(STORES.SETTINGS);
+
+    store.put({ key: DEVICE_KEY, value: keyPair });
+    store.put({ key: CHAIN_SEQ, value: String(seq) });
+    store.put({ key: CHAIN_HASH, value: hash });
+
+    return new Promise((resolve, reject) => {
+        transaction.oncomplete = () => resolve();
+        transaction.onerror = () => reject(transaction.error);
+    });
+}
+
+async function getDeviceKey(): Promise<CryptoKeyPair | null> {
+    const database = await initOfflineDB();
+    const transaction = database.transaction(STORES.SETTINGS, 'readonly');
+    const store = transaction.objectStore(STORES.SETTINGS);
+
+    return new Promise((resolve, reject) => {
+        const request = store.get(DEVICE_KEY);
+        request.onsuccess = () => resolve(request.result?.value || null);
+        request.onerror = () => reject(request.error);
+    });
+}
+
+// Signs a record as the next link of the chain and queues it, storing the record and the
+// new chain head together. Without a device key the record is queued unsigned and the
+// server holds it for review.
+async function signAndQueue(kioskId: string, record: Omit<AttendanceRecord, 'id'>): Promise<number> {
+    const keyPair = await getDeviceKey();
+    if (!keyPair) {
+        return queueAttendance(record);
+    }
+
+    const seq = Number((await getSetting(CHAIN_SEQ)) || '0') + 1;
+    const signed: Omit<AttendanceRecord, 'id'> = {
+        ...record,
+        confidence: Number(record.confidence.toFixed(4)),
+        seq,
+        prev_hash: (await getSetting(CHAIN_HASH)) || '',
+    };
+    const message = new TextEncoder().encode(canonicalRecord(kioskId, signed));
+    const signature = await crypto.subtle.sign({ name: 'ECDSA', hash: 'SHA-256' }, keyPair.privateKey, message);
+    const hash = toHex(await crypto.subtle.digest('SHA-256', message));
+
+    const database = await initOfflineDB();
+    const transaction = database.transaction([STORES.ATTENDANCE_QUEUE, STORES.SETTINGS], 'readwrite');
+    const request = transaction.objectStore(STORES.ATTENDANCE_QUEUE)
+        .add({ ...signed, signature: toBase64(signature), synced: false });
+    const settings = transaction.objectStore(STORES.SETTINGS);
+    settings.put({ key: CHAIN_SEQ, value: String(seq) });
+    settings.put({ key: CHAIN_HASH, value: hash });
+
+    return new Promise((resolve, reject) => {
+        transaction.oncomplete = () => resolve(request.result as number);
+        transaction.onerror = () => reject(transaction.error);
+    });
+}
+
+let signing: Promise<unknown> = Promise.resolve();
+
+// Queues a record signed by this device. Records are signed one at a time so each
+// extends the chain exactly once.
+export function queueSignedAttendance(kioskId: string, record: Omit<AttendanceRecord, 'id'>): Promise<number> {
+    const queued = signing.then(() => signAndQueue(kioskId, record));
+    signing = queued.catch(() => undefined);
+    return queued;
+}
+
 // ========== Settings ==========
 
 export async function setSetting(key: string, value: string): Promise<void> {
@@ -246,6 +385,15 @@ export async function setLastSyncTime(time: string): Promise<void> {
     return setSetting('last_sync_time', time);
 }
 
+// The kiosk and office the cached employees belong to; a delta only applies on top of the same scope
+export async function getSyncScope(): Promise<string | null> {
+    return getSetting('sync_scope');
+}
+
+export async function setSyncScope(scope: string): Promise<void> {
+    return setSetting('sync_scope', scope);
+}
+
 export async function isOfflineDataAvailable(): Promise<boolean> {
     const count = await getEmployeeCount();
     return count > 0;
@@ -8,6 +8,7 @@ interface User {
     email: string;
     role: string;
     avatar_url?: string;
+    must_change_password?: boolean;
 }
 
 interface AuthState {
@@ -78,29 +78,71 @@ export default function DashboardView() {
     };
     const graphData = statsData?.graph_data || [];
 
-    // WebSocket connection
+    // WebSocket connection; reconnects resume the stream so no check-in is missed
     useEffect(() => {
         // Use BACKEND_URL for WebSocket connection (replace http with ws)
         const wsBaseUrl = BACKEND_URL.replace(/^http/, 'ws');
-        const wsUrl = `${wsBaseUrl}/ws/dashboard`;
-        const ws = new WebSocket(wsUrl);
+        let ws: WebSocket | null = null;
+        let reconnectTimer: ReturnType<typeof setTimeout> | null = null;
+        let stream = '';
+        let lastSeq = -1;
+        let topics: string[] = [];
+        let closed = false;
 
-        ws.onopen = () => {
-            setWsConnected(true);
-            console.log('WebSocket connected');
-        };
-
-        ws.onmessage = (event) => {
-            const data = JSON.parse(event.data);
-            if (data.type === 'attendance_update') {
-                refetchTable();
-                refetch(); // Update stats too
+        const connect = () => {
+            // Browsers cannot set headers on WebSockets, so the access token goes in the query
+            const token = encodeURIComponent(localStorage.getItem('accessToken') || '');
+            let wsUrl = `${wsBaseUrl}/ws/dashboard?type=admin&token=${token}`;
+            if (stream && lastSeq >= 0) {
+                // Replay is filtered by topic, so the server needs the ones followed before
+                wsUrl += `&stream=${encodeURIComponent(stream)}&last_seq=${lastSeq}`;
+                wsUrl += `&topics=${encodeURIComponent(topics.join(','))}`;
             }
+            ws = new WebSocket(wsUrl);
+
+            ws.onopen = () => {
+                setWsConnected(true);
+                console.log('WebSocket connected');
+            };
+
+            ws.onmessage = (event) => {
+                const data = JSON.parse(event.data);
+                if (typeof data.seq === 'number') {
+                    lastSeq = data.seq;
+                }
+                if (data.event === 'stream') {
+                    const resumed = data.payload.stream === stream;
+                    stream = data.payload.stream;
+                    topics = data.payload.topics || [];
+                    if (!resumed) {
+                        lastSeq = data.payload.seq;
+                    }
+                } else if (data.event === 'resync') {
+                    // Missed events are gone; reload everything and continue from here
+                    lastSeq = data.payload.seq;
+                    refetchTable();
+                    refetch();
+                } else if (data.type === 'attendance_update') {
+                    refetchTable();
+                    refetch(); // Update stats too
+                }
+            };
+
+            ws.onclose = () => {
+                setWsConnected(false);
+                if (!closed) {
+                    reconnectTimer = setTimeout(connect, 3000);
+                }
+            };
         };
 
-        ws.onclose = () => setWsConnected(false);
+        connect();
 
-        return () => ws.close();
+        return () => {
+            closed = true;
+            if (reconnectTimer) clearTimeout(reconnectTimer);
+            ws?.close();
+        };
     }, [refetchTable, refetch]);
 
     const renderChart = () => {
@@ -1,6 +1,6 @@
 import { useState, useEffect } from 'react';
 import { adminAPI, type Kiosk } from '../../api/client';
-import { Plus, Edit, Trash2, Monitor, MapPin, Activity, XCircle, Laptop, Smartphone, Unlink, ChevronLeft, ChevronRight } from 'lucide-react';
+import { Plus, Edit, Trash2, Monitor, MapPin, Activity, XCircle, Laptop, Smartphone, Unlink, ChevronLeft, ChevronRight, KeyRound } from 'lucide-react';
 import { format } from 'date-fns';
 import { id as idLocale } from 'date-fns/locale';
 
@@ -127,6 +127,20 @@ export default function KioskManagementView() {
         }
     };
 
+    // The token lets the kiosk register its offline signing key; it is shown only once
+    const handleIssuePairingToken = async (id: string, name: string) => {
+        try {
+            const response = await adminAPI.issueKioskPairingToken(id);
+            const { pairing_token, expires_at } = response.data;
+            window.prompt(
+                `Token pairing untuk ${name}, berlaku sampai ${format(new Date(expires_at), 'HH:mm', { locale: idLocale })}. Masukkan di perangkat kiosk:`,
+                pairing_token
+            );
+        } catch (error) {
+            alert('Gagal membuat token pairing');
+        }
+    };
+
     if (loading) return (
         <div className="flex items-center justify-center min-h-[60vh]">
             <div className="animate-spin rounded-full h-12 w-12 border-t-2 border-b-2 border-cyan-500"></div>
@@ -210,15 +224,24 @@ export default function KioskManagementView() {
                                     <Smartphone size={16} className={kiosk.is_paired ? "text-blue-500" : "text-slate-500"} />
                                     <span>{kiosk.is_paired ? 'Paired' : 'Available'}</span>
                                 </div>
-                                {kiosk.is_paired && (
+                                <div className="flex items-center gap-2">
                                     <button
-                                        onClick={() => handleUnpair(kiosk.id, kiosk.name)}
-                                        className="px-2 py-1 text-xs bg-red-500/10 hover:bg-red-500/20 text-red-400 rounded-lg flex items-center gap-1 transition"
+                                        onClick={() => handleIssuePairingToken(kiosk.id, kiosk.name)}
+                                        className="px-2 py-1 text-xs bg-cyan-500/10 hover:bg-cyan-500/20 text-cyan-400 rounded-lg flex items-center gap-1 transition"
                                     >
-                                        <Unlink size={12} />
-                                        Unpair
+                                        <KeyRound size={12} />
+                                        Token
                                     </button>
-                                )}
+                                    {kiosk.is_paired && (
+                                        <button
+                                            onClick={() => handleUnpair(kiosk.id, kiosk.name)}
+                                            className="px-2 py-1 text-xs bg-red-500/10 hover:bg-red-500/20 text-red-400 rounded-lg flex items-center gap-1 transition"
+                                        >
+                                            <Unlink size={12} />
+                                            Unpair
+                                        </button>
+                                    )}
+                                </div>
                             </div>
                         </div>
 